@@ -29,6 +29,7 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/controller/postgrescluster"
 	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 var versionString string
@@ -80,6 +81,12 @@ func main() {
 	err = addControllersToManager(ctx, mgr)
 	assertNoError(err)
 
+	// serve the admission webhooks when they are enabled; they require a serving
+	// certificate in the manager's certificate directory
+	if strings.EqualFold(os.Getenv("PGO_WEBHOOKS_ENABLED"), "true") {
+		assertNoError(addWebhooksToManager(mgr))
+	}
+
 	log.Info("starting controller runtime manager and will wait for signal to exit")
 	assertNoError(mgr.Start(ctx))
 	log.Info("signal received, exiting")
@@ -98,6 +105,12 @@ func addControllersToManager(ctx context.Context, mgr manager.Manager) error {
 	return r.SetupWithManager(mgr)
 }
 
+// addWebhooksToManager registers the defaulting and validating admission webhooks for all
+// PostgreSQL Operator custom resources with the provided controller runtime manager.
+func addWebhooksToManager(mgr manager.Manager) error {
+	return cruntime.NewWebhookManagedBy(mgr).For(&v1beta1.PostgresCluster{}).Complete()
+}
+
 func isOpenshift(ctx context.Context, cfg *rest.Config) bool {
 	log := logging.FromContext(ctx)
 
@@ -38,6 +38,17 @@ func defaultFromEnv(value, key string) string {
 // - https://redhat-connect.gitbook.io/certified-operator-guide/troubleshooting-and-resources/offline-enabled-operators
 // - https://osbs.readthedocs.io/en/latest/users.html#pullspec-locations
 
+// PGAdminContainerImage returns the container image to use for pgAdmin.
+func PGAdminContainerImage(cluster *v1beta1.PostgresCluster) string {
+	var image string
+	if cluster.Spec.UserInterface != nil &&
+		cluster.Spec.UserInterface.PGAdmin != nil {
+		image = cluster.Spec.UserInterface.PGAdmin.Image
+	}
+
+	return defaultFromEnv(image, "RELATED_IMAGE_PGADMIN")
+}
+
 // PGBackRestContainerImage returns the container image to use for pgBackRest.
 func PGBackRestContainerImage(cluster *v1beta1.PostgresCluster) string {
 	image := cluster.Spec.Backups.PGBackRest.Image
@@ -69,6 +80,16 @@ func PGExporterContainerImage(cluster *v1beta1.PostgresCluster) string {
 	return defaultFromEnv(image, "RELATED_IMAGE_PGEXPORTER")
 }
 
+// PGUpgradeContainerImage returns the container image to use for pg_upgrade.
+func PGUpgradeContainerImage(cluster *v1beta1.PostgresCluster) string {
+	var image string
+	if cluster.Spec.Upgrade != nil {
+		image = cluster.Spec.Upgrade.Image
+	}
+
+	return defaultFromEnv(image, "RELATED_IMAGE_PGUPGRADE")
+}
+
 // PostgresContainerImage returns the container image to use for PostgreSQL.
 func PostgresContainerImage(cluster *v1beta1.PostgresCluster) string {
 	image := cluster.Spec.Image
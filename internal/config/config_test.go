@@ -49,6 +49,24 @@ func unsetEnv(t testing.TB, key string) {
 	assert.NilError(t, os.Unsetenv(key))
 }
 
+func TestPGAdminContainerImage(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+
+	unsetEnv(t, "RELATED_IMAGE_PGADMIN")
+	assert.Equal(t, PGAdminContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGADMIN", "")
+	assert.Equal(t, PGAdminContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGADMIN", "env-var-pgadmin")
+	assert.Equal(t, PGAdminContainerImage(cluster), "env-var-pgadmin")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{
+		userInterface: { pgAdmin: { image: spec-image } },
+	}`), &cluster.Spec))
+	assert.Equal(t, PGAdminContainerImage(cluster), "spec-image")
+}
+
 func TestPGBackRestContainerImage(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 
@@ -103,6 +121,24 @@ func TestPGExporterContainerImage(t *testing.T) {
 	assert.Equal(t, PGExporterContainerImage(cluster), "spec-image")
 }
 
+func TestPGUpgradeContainerImage(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+
+	unsetEnv(t, "RELATED_IMAGE_PGUPGRADE")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGUPGRADE", "")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGUPGRADE", "env-var-pgupgrade")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "env-var-pgupgrade")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{
+		upgrade: { image: spec-image },
+	}`), &cluster.Spec))
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "spec-image")
+}
+
 func TestPostgresContainerImage(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.Spec.PostgresVersion = 12
@@ -0,0 +1,336 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// EventVolumesAdopted is the event reason utilized when a cluster takes ownership of the
+// existing volumes in its data source
+const EventVolumesAdopted = "VolumesAdopted"
+
+// adoptedVolume is an existing PersistentVolumeClaim in the data source of a cluster.
+type adoptedVolume struct {
+	// claim is the name of the existing PersistentVolumeClaim.
+	claim string
+
+	// name is what the operator would otherwise call the claim.
+	name string
+
+	// labels are given to the claim when it is adopted.
+	labels map[string]string
+
+	// mount is where the claim is mounted to check its contents.
+	mount corev1.VolumeMount
+
+	// repo is the name of the pgBackRest repository, if any, that adopts the claim.
+	repo string
+}
+
+// dataSourceVolumes returns the existing PersistentVolumeClaims in the data source of
+// cluster. The data and WAL volumes become those of the startup instance in instanceSet,
+// and the pgBackRest volume becomes that of the first repository that defines a volume.
+func dataSourceVolumes(
+	cluster *v1beta1.PostgresCluster, instanceSet *v1beta1.PostgresInstanceSetSpec,
+) []adoptedVolume {
+	source := cluster.Spec.DataSource.Volumes
+	instance := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Status.StartupInstance,
+	}}
+	instanceLabels := func(role string) map[string]string {
+		return naming.Merge(
+			cluster.Spec.Metadata.GetLabelsOrNil(),
+			instanceSet.Metadata.GetLabelsOrNil(),
+			map[string]string{
+				naming.LabelCluster:     cluster.Name,
+				naming.LabelInstanceSet: instanceSet.Name,
+				naming.LabelInstance:    instance.Name,
+				naming.LabelRole:        role,
+			})
+	}
+
+	volumes := []adoptedVolume{{
+		claim:  source.PGDataVolume.PVCName,
+		name:   naming.InstancePostgresDataVolume(instance).Name,
+		labels: instanceLabels(naming.RolePostgresData),
+		mount:  postgres.DataVolumeMount(),
+	}}
+
+	if source.PGWALVolume != nil {
+		volumes = append(volumes, adoptedVolume{
+			claim:  source.PGWALVolume.PVCName,
+			name:   naming.InstancePostgresWALVolume(instance).Name,
+			labels: instanceLabels(naming.RolePostgresWAL),
+			mount:  postgres.WALVolumeMount(),
+		})
+	}
+
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		if source.PGBackRestVolume != nil && repo.Volume != nil {
+			volumes = append(volumes, adoptedVolume{
+				claim: source.PGBackRestVolume.PVCName,
+				name:  naming.PGBackRestRepoVolume(cluster, repo.Name).Name,
+				labels: naming.Merge(
+					cluster.Spec.Metadata.GetLabelsOrNil(),
+					cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+					naming.PGBackRestRepoVolumeLabels(cluster.Name, repo.Name)),
+				mount: corev1.VolumeMount{
+					Name:      repo.Name,
+					MountPath: pgbackrest.RepoVolumeMountPath(repo.Name),
+				},
+				repo: repo.Name,
+			})
+			break
+		}
+	}
+
+	return volumes
+}
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;patch
+
+// reconcileVolumesDataSource adopts the existing PersistentVolumeClaims in the data source
+// of cluster. A Job first checks the PostgreSQL data on them. Once it succeeds, the claims
+// are labeled and owned as though they were created for the startup instance, and the
+// PostgresDataInitialized condition is set so the cluster starts on them without a restore.
+func (r *Reconciler) reconcileVolumesDataSource(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               ConditionPostgresDataInitialized,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// There is nothing to adopt once the cluster has data of its own.
+	if patroni.ClusterBootstrapped(cluster) {
+		setCondition(metav1.ConditionTrue, "ClusterAlreadyBootstrapped",
+			"The cluster is already bootstrapped")
+		return nil
+	}
+
+	// Patroni only starts a cluster on an instance that can become primary, so the
+	// data volume goes to the first instance set that can.
+	for i := range cluster.Spec.InstanceSets {
+		if set := &cluster.Spec.InstanceSets[i]; cluster.Status.StartupInstance == "" &&
+			set.Patroni.FailoverCandidate() {
+			cluster.Status.StartupInstance = naming.GenerateStartupInstance(cluster, set).Name
+			cluster.Status.StartupInstanceSet = set.Name
+		}
+	}
+	var instanceSet *v1beta1.PostgresInstanceSetSpec
+	for i := range cluster.Spec.InstanceSets {
+		if cluster.Spec.InstanceSets[i].Name == cluster.Status.StartupInstanceSet {
+			instanceSet = &cluster.Spec.InstanceSets[i]
+		}
+	}
+	if instanceSet == nil {
+		return errors.New("unable to determine startup instance for adopted volumes")
+	}
+
+	// Every claim must exist and must not belong to something else.
+	volumes := dataSourceVolumes(cluster, instanceSet)
+	claims := make([]*corev1.PersistentVolumeClaim, len(volumes))
+	for i := range volumes {
+		claims[i] = &corev1.PersistentVolumeClaim{}
+		err := errors.WithStack(r.Client.Get(ctx, client.ObjectKey{
+			Namespace: cluster.Namespace, Name: volumes[i].claim,
+		}, claims[i]))
+
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidDataSource",
+				"PersistentVolumeClaim %q not found", volumes[i].claim)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if owner := metav1.GetControllerOf(claims[i]); owner != nil && owner.UID != cluster.UID {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidDataSource",
+				"PersistentVolumeClaim %q belongs to %s %q", volumes[i].claim, owner.Kind, owner.Name)
+			return nil
+		}
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.DataSourceVolumesJob(cluster)}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(job), job))
+	if apierrors.IsNotFound(err) {
+		setCondition(metav1.ConditionFalse, "CheckingVolumes",
+			"Checking the PostgreSQL data in existing volumes")
+
+		err = r.generateDataSourceVolumesJobIntent(cluster, instanceSet, volumes, job)
+		if err == nil {
+			err = errors.WithStack(r.apply(ctx, job))
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case jobFailed(job):
+		// The Job is left in place until it is deleted, which checks the volumes again.
+		setCondition(metav1.ConditionFalse, "VolumesNotAdopted",
+			"The existing volumes cannot be used; delete Job "+job.Name+" to try again")
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "InvalidDataSource",
+			"existing volumes cannot be used: %s", job.Name)
+		return nil
+
+	case !jobCompleted(job):
+		return nil
+	}
+
+	for i := range volumes {
+		if err == nil {
+			err = r.adoptVolume(ctx, cluster, claims[i], volumes[i].labels)
+		}
+	}
+	if err == nil {
+		for _, volume := range volumes {
+			if volume.claim != volume.name {
+				if cluster.Status.AdoptedVolumes == nil {
+					cluster.Status.AdoptedVolumes = make(map[string]string)
+				}
+				cluster.Status.AdoptedVolumes[volume.name] = volume.claim
+			}
+		}
+		setCondition(metav1.ConditionTrue, "VolumesAdopted",
+			"The cluster starts on existing volumes")
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, EventVolumesAdopted,
+			fmt.Sprintf("%s starts on volume %q", cluster.Status.StartupInstance, volumes[0].claim))
+	}
+	return err
+}
+
+// adoptVolume adds labels to pvc and makes cluster its controller. It is no longer
+// marked as retained from a deleted cluster.
+func (r *Reconciler) adoptVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	pvc *corev1.PersistentVolumeClaim, labels map[string]string,
+) error {
+	before := pvc.DeepCopy()
+	pvc.Labels = naming.Merge(pvc.Labels, labels)
+	delete(pvc.Labels, naming.LabelRetained)
+
+	err := errors.WithStack(r.setControllerReference(cluster, pvc))
+	if err == nil {
+		err = errors.WithStack(r.patch(ctx, pvc,
+			client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})))
+	}
+	return err
+}
+
+// generateDataSourceVolumesJobIntent populates job with the Job that checks the PostgreSQL
+// data in volumes before cluster adopts them.
+func (r *Reconciler) generateDataSourceVolumesJobIntent(cluster *v1beta1.PostgresCluster,
+	instanceSet *v1beta1.PostgresInstanceSetSpec, volumes []adoptedVolume, job *batchv1.Job,
+) error {
+	var backupInfo string
+	var walVolume bool
+	var podVolumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for _, volume := range volumes {
+		podVolumes = append(podVolumes, corev1.Volume{
+			Name: volume.mount.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: volume.claim,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, volume.mount)
+
+		walVolume = walVolume || volume.mount.Name == postgres.WALVolumeMount().Name
+		if volume.repo != "" {
+			backupInfo = volume.mount.MountPath +
+				"/backup/" + pgbackrest.DefaultStanzaName + "/backup.info"
+		}
+	}
+
+	job.ObjectMeta = naming.DataSourceVolumesJob(cluster)
+	job.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	job.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:         cluster.Name,
+			naming.LabelStartupInstance: cluster.Status.StartupInstance,
+		})
+
+	job.Spec = batchv1.JobSpec{
+		// The check is not retried automatically. A failure is reported in the status
+		// of the PostgresCluster instead.
+		BackoffLimit: initialize.Int32(0),
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: job.Annotations,
+				Labels:      job.Labels,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Command: postgres.AdoptCommand(cluster, instanceSet,
+						walVolume, backupInfo),
+					Image:           config.PostgresContainerImage(cluster),
+					Name:            naming.ContainerDatabase,
+					VolumeMounts:    volumeMounts,
+					SecurityContext: initialize.RestrictedSecurityContext(),
+				}},
+				RestartPolicy: corev1.RestartPolicyNever,
+				Volumes:       podVolumes,
+			},
+		},
+	}
+
+	// Set the image pull secrets, if any exist.
+	// This is set here rather than using the service account due to the lack
+	// of propagation to existing pods when the CRD is updated:
+	// https://github.com/kubernetes/kubernetes/issues/88456
+	job.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+
+	podSecurityContext := initialize.RestrictedPodSecurityContext()
+	// set fsGroups if not OpenShift
+	if cluster.Spec.OpenShift == nil || !*cluster.Spec.OpenShift {
+		podSecurityContext.FSGroup = initialize.Int64(26)
+	}
+	job.Spec.Template.Spec.SecurityContext = podSecurityContext
+
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	return errors.WithStack(r.setControllerReference(cluster, job))
+}
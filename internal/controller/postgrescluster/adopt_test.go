@@ -0,0 +1,186 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestDataSourceVolumes(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Status.StartupInstance = "hippo-00-abcd"
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", S3: &v1beta1.RepoS3{}},
+		{Name: "repo2", Volume: &v1beta1.RepoPVC{}},
+	}
+	cluster.Spec.DataSource = &v1beta1.DataSource{Volumes: &v1beta1.DataSourceVolumes{
+		PGDataVolume: &v1beta1.DataSourceVolume{PVCName: "old-pgdata"},
+	}}
+
+	volumes := dataSourceVolumes(cluster, &cluster.Spec.InstanceSets[0])
+	assert.Equal(t, len(volumes), 1)
+	assert.Equal(t, volumes[0].claim, "old-pgdata")
+	assert.Equal(t, volumes[0].name, "hippo-00-abcd-pgdata")
+	assert.Equal(t, volumes[0].mount.MountPath, "/pgdata")
+	assert.DeepEqual(t, volumes[0].labels, map[string]string{
+		naming.LabelCluster:     "hippo",
+		naming.LabelInstanceSet: "00",
+		naming.LabelInstance:    "hippo-00-abcd",
+		naming.LabelRole:        naming.RolePostgresData,
+	})
+
+	cluster.Spec.DataSource.Volumes.PGWALVolume = &v1beta1.DataSourceVolume{PVCName: "old-pgwal"}
+	cluster.Spec.DataSource.Volumes.PGBackRestVolume = &v1beta1.DataSourceVolume{PVCName: "old-repo"}
+
+	volumes = dataSourceVolumes(cluster, &cluster.Spec.InstanceSets[0])
+	assert.Equal(t, len(volumes), 3)
+	assert.Equal(t, volumes[1].name, "hippo-00-abcd-pgwal")
+	assert.Equal(t, volumes[1].labels[naming.LabelRole], naming.RolePostgresWAL)
+	assert.Equal(t, volumes[1].mount.MountPath, "/pgwal")
+
+	// The repository volume goes to the first repository with a volume.
+	assert.Equal(t, volumes[2].claim, "old-repo")
+	assert.Equal(t, volumes[2].name, "hippo-repo2")
+	assert.Equal(t, volumes[2].repo, "repo2")
+	assert.Equal(t, volumes[2].mount.MountPath, "/pgbackrest/repo2")
+	assert.Equal(t, volumes[2].labels[naming.LabelPGBackRestRepo], "repo2")
+}
+
+func TestReconcileVolumesDataSource(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = "ns1", "hippo", types.UID("abc")
+	cluster.Spec.PostgresVersion = 13
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+		{Name: "00", Patroni: &v1beta1.PatroniInstanceSetSpec{FailoverRole: "Replica"}},
+		{Name: "01"},
+	}
+	cluster.Spec.DataSource = &v1beta1.DataSource{Volumes: &v1beta1.DataSourceVolumes{
+		PGDataVolume: &v1beta1.DataSourceVolume{PVCName: "old-pgdata"},
+	}}
+
+	volume := func(name string, owner *metav1.OwnerReference) *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{}
+		pvc.Namespace, pvc.Name = "ns1", name
+		pvc.Labels = map[string]string{
+			naming.LabelCluster: "rhino", naming.LabelRetained: "true",
+		}
+		if owner != nil {
+			pvc.OwnerReferences = []metav1.OwnerReference{*owner}
+		}
+		return pvc
+	}
+	job := func(condition batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: naming.DataSourceVolumesJob(cluster)}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: condition, Status: corev1.ConditionTrue,
+		}}
+		return job
+	}
+	setup := func(objects ...client.Object) (*Reconciler, *record.FakeRecorder) {
+		recorder := record.NewFakeRecorder(10)
+		return &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			Recorder: recorder,
+		}, recorder
+	}
+	condition := func(cluster *v1beta1.PostgresCluster) *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, ConditionPostgresDataInitialized)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		r, recorder := setup()
+		cluster := cluster.DeepCopy()
+
+		assert.NilError(t, r.reconcileVolumesDataSource(ctx, cluster))
+		assert.Assert(t, strings.HasPrefix(cluster.Status.StartupInstance, "hippo-01-"),
+			"expected an instance that can become primary")
+		assert.Equal(t, cluster.Status.StartupInstanceSet, "01")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, `"old-pgdata" not found`))
+		assert.Assert(t, condition(cluster) == nil)
+	})
+
+	t.Run("Controlled", func(t *testing.T) {
+		controller := true
+		r, recorder := setup(volume("old-pgdata", &metav1.OwnerReference{
+			APIVersion: v1beta1.GroupVersion.String(), Kind: "PostgresCluster",
+			Name: "rhino", UID: "xyz", Controller: &controller,
+		}))
+		cluster := cluster.DeepCopy()
+
+		assert.NilError(t, r.reconcileVolumesDataSource(ctx, cluster))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, `belongs to PostgresCluster "rhino"`))
+		assert.Assert(t, condition(cluster) == nil)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		r, recorder := setup(volume("old-pgdata", nil), job(batchv1.JobFailed))
+		cluster := cluster.DeepCopy()
+
+		assert.NilError(t, r.reconcileVolumesDataSource(ctx, cluster))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, condition(cluster) != nil)
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionFalse)
+		assert.Equal(t, condition(cluster).Reason, "VolumesNotAdopted")
+		assert.Assert(t, cluster.Status.AdoptedVolumes == nil)
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		r, recorder := setup(volume("old-pgdata", nil), job(batchv1.JobComplete))
+		cluster := cluster.DeepCopy()
+
+		assert.NilError(t, r.reconcileVolumesDataSource(ctx, cluster))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, condition(cluster) != nil)
+		assert.Equal(t, condition(cluster).Status, metav1.ConditionTrue)
+		assert.Equal(t, condition(cluster).Reason, "VolumesAdopted")
+
+		name := cluster.Status.StartupInstance + "-pgdata"
+		assert.DeepEqual(t, cluster.Status.AdoptedVolumes, map[string]string{name: "old-pgdata"})
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		assert.NilError(t, r.Client.Get(ctx,
+			client.ObjectKey{Namespace: "ns1", Name: "old-pgdata"}, pvc))
+		assert.Assert(t, metav1.IsControlledBy(pvc, cluster))
+		assert.Equal(t, pvc.Labels[naming.LabelCluster], "hippo")
+		assert.Equal(t, pvc.Labels[naming.LabelInstance], cluster.Status.StartupInstance)
+		assert.Equal(t, pvc.Labels[naming.LabelRole], naming.RolePostgresData)
+		assert.Equal(t, pvc.Labels[naming.LabelRetained], "", "expected the label to be removed")
+	})
+}
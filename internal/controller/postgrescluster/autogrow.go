@@ -0,0 +1,264 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventVolumeAutoGrow is the event reason utilized when the operator increases the
+	// storage request of a volume that is filling
+	EventVolumeAutoGrow = "VolumeAutoGrow"
+
+	// EventVolumeAutoGrowLimit is the event reason utilized when a volume that is filling
+	// cannot grow because its storage request is at the maximum
+	EventVolumeAutoGrowLimit = "VolumeAutoGrowLimit"
+)
+
+// autoGrowInterval is how often the filesystems of volumes that grow automatically
+// are checked.
+const autoGrowInterval = time.Minute
+
+// autoGrowVolume is a PersistentVolumeClaim that grows automatically and where its
+// filesystem is mounted.
+type autoGrowVolume struct {
+	spec      *v1beta1.VolumeAutoGrowSpec
+	claim     string
+	pod       *corev1.Pod
+	container string
+	path      string
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=patch
+
+// reconcileVolumeAutoGrow reads the filesystem usage of every volume of cluster that
+// grows automatically and increases the storage request of those that are filling.
+// Filesystems are checked in running Pods only, and the outcome is recorded in events.
+func (r *Reconciler) reconcileVolumeAutoGrow(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, volumes []corev1.PersistentVolumeClaim,
+) (reconcile.Result, error) {
+	var result reconcile.Result
+	targets, enabled, err := r.autoGrowVolumes(ctx, cluster, instances)
+
+	// Filesystems fill without notifying the reconciler. Check again later.
+	if enabled {
+		result.RequeueAfter = autoGrowInterval
+	}
+
+	claims := make(map[string]*corev1.PersistentVolumeClaim, len(volumes))
+	for i := range volumes {
+		claims[volumes[i].Name] = &volumes[i]
+	}
+
+	for _, target := range targets {
+		if err != nil {
+			break
+		}
+		if pvc := claims[target.claim]; pvc != nil {
+			err = r.autoGrowVolume(ctx, cluster, target, pvc.DeepCopy())
+		}
+	}
+
+	return result, err
+}
+
+// autoGrowVolumes returns the volumes of cluster that grow automatically and that
+// are mounted in a running Pod. It also returns whether or not any volume of cluster
+// grows automatically.
+func (r *Reconciler) autoGrowVolumes(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) ([]autoGrowVolume, bool, error) {
+	var enabled bool
+	var targets []autoGrowVolume
+
+	if instances != nil {
+		for _, instance := range instances.forCluster {
+			if instance.Spec == nil || instance.Runner == nil {
+				continue
+			}
+			enabled = enabled ||
+				instance.Spec.DataVolumeAutoGrow != nil || instance.Spec.WALVolumeAutoGrow != nil
+
+			if len(instance.Pods) != 1 || !instanceRunning(instance) {
+				continue
+			}
+			if instance.Spec.DataVolumeAutoGrow != nil {
+				targets = append(targets, autoGrowVolume{
+					spec:      instance.Spec.DataVolumeAutoGrow,
+					claim:     naming.AdoptedVolume(cluster, naming.InstancePostgresDataVolume(instance.Runner)).Name,
+					pod:       instance.Pods[0],
+					container: naming.ContainerDatabase,
+					path:      postgres.DataDirectory(cluster),
+				})
+			}
+			if instance.Spec.WALVolumeAutoGrow != nil && instance.Spec.WALVolumeClaimSpec != nil {
+				targets = append(targets, autoGrowVolume{
+					spec:      instance.Spec.WALVolumeAutoGrow,
+					claim:     naming.AdoptedVolume(cluster, naming.InstancePostgresWALVolume(instance.Runner)).Name,
+					pod:       instance.Pods[0],
+					container: naming.ContainerDatabase,
+					path:      postgres.WALDirectory(cluster, instance.Spec),
+				})
+			}
+		}
+	}
+
+	var repos []v1beta1.PGBackRestRepo
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		if repo.Volume != nil && repo.Volume.AutoGrow != nil {
+			repos = append(repos, repo)
+		}
+	}
+	if len(repos) == 0 {
+		return targets, enabled, nil
+	}
+
+	// Repository volumes are mounted where pgBackRest commands run.
+	selector, container, err := getPGBackRestExecSelector(cluster)
+	pods := &corev1.PodList{}
+	if err == nil {
+		err = errors.WithStack(r.Client.List(ctx, pods,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+		))
+	}
+
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning &&
+			pods.Items[i].DeletionTimestamp == nil {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+	for i := range repos {
+		if pod != nil {
+			targets = append(targets, autoGrowVolume{
+				spec:      repos[i].Volume.AutoGrow,
+				claim:     naming.AdoptedVolume(cluster, naming.PGBackRestRepoVolume(cluster, repos[i].Name)).Name,
+				pod:       pod,
+				container: container,
+				path:      pgbackrest.RepoVolumeMountPath(repos[i].Name),
+			})
+		}
+	}
+
+	return targets, true, err
+}
+
+// autoGrowVolume increases the storage request of pvc when the filesystem of target
+// is used beyond its threshold. Nothing changes while pvc is already changing size.
+func (r *Reconciler) autoGrowVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	target autoGrowVolume, pvc *corev1.PersistentVolumeClaim,
+) error {
+	log := logging.FromContext(ctx).WithValues("volume", pvc.Name)
+
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+	if pvc.DeletionTimestamp != nil || capacity.Cmp(request) < 0 {
+		return nil
+	}
+	for _, condition := range pvc.Status.Conditions {
+		switch condition.Type {
+		case
+			corev1.PersistentVolumeClaimResizing,
+			corev1.PersistentVolumeClaimFileSystemResizePending:
+			return nil
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := r.PodExec(target.pod.Namespace, target.pod.Name, target.container,
+		nil, &stdout, &stderr,
+		"df", "--block-size=1", "--output=used,avail", target.path)
+	if err != nil {
+		// The filesystem may not exist yet. Try again later.
+		log.V(1).Info("unable to read filesystem usage",
+			"error", err.Error(), "stderr", stderr.String())
+		return nil
+	}
+
+	used, available, err := parseFilesystemUsage(stdout.String())
+	if err != nil || used+available == 0 {
+		log.V(1).Info("unable to parse filesystem usage", "stdout", stdout.String())
+		return nil
+	}
+
+	percent := (used*100 + used + available - 1) / (used + available)
+	if percent < int64(target.spec.GetThreshold()) {
+		return nil
+	}
+
+	next, err := target.spec.NextRequest(request)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if next.Cmp(request) <= 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventVolumeAutoGrowLimit, fmt.Sprintf(
+			"volume %q is %d%% full and cannot grow beyond %s",
+			pvc.Name, percent, target.spec.Maximum.String()))
+		return nil
+	}
+
+	before := pvc.DeepCopy()
+	pvc.Spec.Resources.Requests = pvc.Spec.Resources.Requests.DeepCopy()
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = next
+
+	err = errors.WithStack(r.patch(ctx, pvc, client.MergeFrom(before)))
+	if err == nil {
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, EventVolumeAutoGrow, fmt.Sprintf(
+			"volume %q is %d%% full; growing from %s to %s",
+			pvc.Name, percent, request.String(), next.String()))
+	}
+	return r.handlePersistentVolumeClaimError(cluster, err)
+}
+
+// parseFilesystemUsage returns the bytes used and available as reported by
+// `df --block-size=1 --output=used,avail`.
+func parseFilesystemUsage(stdout string) (used, available int64, err error) {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) != 2 {
+		return 0, 0, errors.Errorf("unexpected output: %q", stdout)
+	}
+
+	used, err = strconv.ParseInt(fields[0], 10, 64)
+	if err == nil {
+		available, err = strconv.ParseInt(fields[1], 10, 64)
+	}
+	return used, available, errors.WithStack(err)
+}
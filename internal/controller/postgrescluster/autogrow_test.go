@@ -0,0 +1,185 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestParseFilesystemUsage(t *testing.T) {
+	used, available, err := parseFilesystemUsage(
+		"      Used     Avail\n 123456789 987654321\n")
+	assert.NilError(t, err)
+	assert.Equal(t, used, int64(123456789))
+	assert.Equal(t, available, int64(987654321))
+
+	for _, stdout := range []string{"", "Used Avail\n", "Used Avail\n1 2 3\n", "Used Avail\nx 2\n"} {
+		_, _, err = parseFilesystemUsage(stdout)
+		assert.Assert(t, err != nil, "expected error for %q", stdout)
+	}
+}
+
+func TestReconcileVolumeAutoGrow(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(usage string) (
+		*Reconciler, *v1beta1.PostgresCluster, *observedInstances, *corev1.PersistentVolumeClaim,
+	) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Spec.PostgresVersion = 13
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{
+			Name: "00",
+			DataVolumeAutoGrow: &v1beta1.VolumeAutoGrowSpec{
+				Maximum: resource.MustParse("1500Mi"),
+			},
+		}}
+
+		runner := &appsv1.StatefulSet{}
+		runner.Namespace, runner.Name = "ns1", "hippo-00-abcd"
+
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "ns1", "hippo-00-abcd-0"
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: naming.InstancePostgresDataVolume(runner),
+		}
+		pvc.Spec.Resources.Requests = corev1.ResourceList{
+			corev1.ResourceStorage: resource.MustParse("1Gi"),
+		}
+		pvc.Status.Capacity = corev1.ResourceList{
+			corev1.ResourceStorage: resource.MustParse("1Gi"),
+		}
+
+		instances := &observedInstances{forCluster: []*Instance{{
+			Name: runner.Name, Runner: runner, Pods: []*corev1.Pod{pod},
+			Spec: &cluster.Spec.InstanceSets[0],
+		}}}
+
+		reconciler := &Reconciler{
+			Client:   fake.NewClientBuilder().WithObjects(pvc).Build(),
+			Recorder: record.NewFakeRecorder(10),
+			PodExec: func(
+				namespace, pod, container string,
+				_ io.Reader, stdout, _ io.Writer, command ...string,
+			) error {
+				assert.Equal(t, namespace, "ns1")
+				assert.Equal(t, pod, "hippo-00-abcd-0")
+				assert.Equal(t, container, naming.ContainerDatabase)
+				assert.Equal(t, strings.Join(command, " "),
+					"df --block-size=1 --output=used,avail /pgdata/pg13")
+
+				_, err := stdout.Write([]byte(usage))
+				return err
+			},
+		}
+		return reconciler, cluster, instances, pvc
+	}
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("Used Avail\n70 30\n")
+
+		result, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, autoGrowInterval)
+
+		stored := &corev1.PersistentVolumeClaim{}
+		assert.NilError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(pvc), stored))
+		assert.Equal(t, stored.Spec.Resources.Requests.Storage().String(), "1Gi")
+		assert.Equal(t, len(reconciler.Recorder.(*record.FakeRecorder).Events), 0)
+	})
+
+	t.Run("Grow", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("Used Avail\n85 15\n")
+
+		_, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+
+		stored := &corev1.PersistentVolumeClaim{}
+		assert.NilError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(pvc), stored))
+		assert.Equal(t, stored.Spec.Resources.Requests.Storage().String(), "1280Mi")
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.Contains(event, EventVolumeAutoGrow), "got %q", event)
+		assert.Assert(t, strings.Contains(event, "85% full"), "got %q", event)
+	})
+
+	t.Run("Resizing", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("Used Avail\n85 15\n")
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("1280Mi")
+
+		_, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+		assert.Equal(t, len(reconciler.Recorder.(*record.FakeRecorder).Events), 0)
+	})
+
+	t.Run("Maximum", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("Used Avail\n95 5\n")
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("1500Mi")
+		pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("1500Mi")
+
+		_, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.Contains(event, EventVolumeAutoGrowLimit), "got %q", event)
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("")
+		now := metav1.Now()
+		instances.forCluster[0].Pods[0].DeletionTimestamp = &now
+
+		result, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, autoGrowInterval)
+		assert.Equal(t, len(reconciler.Recorder.(*record.FakeRecorder).Events), 0)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		reconciler, cluster, instances, pvc := setup("")
+		cluster.Spec.InstanceSets[0].DataVolumeAutoGrow = nil
+
+		result, err := reconciler.reconcileVolumeAutoGrow(ctx, cluster, instances,
+			[]corev1.PersistentVolumeClaim{*pvc})
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter.Seconds(), float64(0))
+	})
+}
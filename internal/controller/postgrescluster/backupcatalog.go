@@ -0,0 +1,234 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionRecentBackup is the type used in a condition to indicate whether or not any
+	// pgBackRest repository has a backup newer than the maximum backup age of the cluster
+	ConditionRecentBackup = "PGBackRestRecentBackup"
+)
+
+const (
+	// backupCatalogInterval is how often the backups in each repository are read when no
+	// backup has completed in the meantime.
+	backupCatalogInterval = 5 * time.Minute
+
+	// backupCatalogLimit is how many of the most recent backups in each repository are
+	// recorded in status. The most recent backup of each type is always recorded.
+	backupCatalogLimit = 20
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// reconcileBackupCatalog reads the backups in each repository of cluster using the
+// pgBackRest "info" command and records them in the status of cluster. A repository is
+// read once its stanza exists and again every backupCatalogInterval or after a backup
+// completes. It returns how long until the next read, or zero when nothing will be read.
+func (r *Reconciler) reconcileBackupCatalog(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (time.Duration, error) {
+	now := time.Now()
+	status := cluster.Status.PGBackRest
+
+	var next time.Duration
+	var stale []*v1beta1.RepoStatus
+	for i := range status.Repos {
+		repo := &status.Repos[i]
+		if !repo.StanzaCreated {
+			continue
+		}
+		if next == 0 {
+			next = backupCatalogInterval
+		}
+		if wait := backupCatalogWait(repo, status.LastBackupTime, now); wait > 0 {
+			if wait < next {
+				next = wait
+			}
+		} else {
+			stale = append(stale, repo)
+		}
+	}
+
+	var errs []error
+	if len(stale) > 0 {
+		exec, err := r.backupCatalogExecutor(ctx, cluster)
+		if err != nil {
+			errs = append(errs, err)
+			stale = nil
+		}
+		for _, repo := range stale {
+			info, err := exec.Info(ctx, regexRepoIndex.FindString(repo.Name))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			setBackupCatalog(repo, info, now, backupCatalogLimit)
+		}
+	}
+
+	setRecentBackupCondition(cluster, now)
+
+	return next, utilerrors.NewAggregate(errs)
+}
+
+// backupCatalogExecutor returns a pgbackrest.Executor that runs commands in the Pod where
+// cluster runs its pgBackRest commands.
+func (r *Reconciler) backupCatalogExecutor(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (pgbackrest.Executor, error) {
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(cluster.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(pods.Items) != 1 {
+		return nil, errors.WithStack(
+			errors.New("invalid number of Pods found when attempting to read backup info"))
+	}
+
+	podName := pods.Items[0].GetName()
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(cluster.GetNamespace(), podName, containerName,
+			stdin, stdout, stderr, command...)
+	}, nil
+}
+
+// backupCatalogWait returns how long until the backups in repo should be read again. It
+// is zero or less when they have never been read or a backup completed since the last read.
+func backupCatalogWait(repo *v1beta1.RepoStatus, lastBackup *metav1.Time, now time.Time) time.Duration {
+	if repo.InfoTime == nil || (lastBackup != nil && repo.InfoTime.Before(lastBackup)) {
+		return 0
+	}
+	return repo.InfoTime.Add(backupCatalogInterval).Sub(now)
+}
+
+// setBackupCatalog replaces the backups and WAL archive range in repo with those in info,
+// a stanza that was read at now. Only the most recent limit backups are kept.
+func setBackupCatalog(
+	repo *v1beta1.RepoStatus, info pgbackrest.StanzaInfo, now time.Time, limit int,
+) {
+	repo.Backups = nil
+	repo.LastBackups = nil
+	repo.WALArchiveMin, repo.WALArchiveMax = "", ""
+
+	// The backups are ordered oldest first, so the last of each type is the most recent.
+	for _, backup := range info.Backup {
+		repo.Backups = append(repo.Backups, backupInfoStatus(backup))
+
+		if repo.LastBackups == nil {
+			repo.LastBackups = new(v1beta1.PGBackRestLastBackups)
+		}
+		switch backup.Type {
+		case "full":
+			repo.LastBackups.Full = backup.Label
+		case "diff":
+			repo.LastBackups.Differential = backup.Label
+		case "incr":
+			repo.LastBackups.Incremental = backup.Label
+		}
+	}
+
+	// Keep the status of cluster small no matter how many backups are retained.
+	if n := len(repo.Backups); n > limit {
+		repo.Backups = repo.Backups[n-limit:]
+	}
+
+	// The archive of each PostgreSQL system is ordered oldest first as well.
+	if n := len(info.Archive); n > 0 {
+		repo.WALArchiveMin = info.Archive[0].Min
+		repo.WALArchiveMax = info.Archive[n-1].Max
+	}
+
+	read := metav1.NewTime(now)
+	repo.InfoTime = &read
+}
+
+// setRecentBackupCondition compares the most recent backup in any repository of cluster to
+// its maximum backup age and reports the result in the PGBackRestRecentBackup condition.
+// The condition is removed when cluster has no maximum backup age.
+func setRecentBackupCondition(cluster *v1beta1.PostgresCluster, now time.Time) {
+	maxAge := cluster.Spec.Backups.PGBackRest.MaxBackupAgeSeconds
+	if maxAge == nil {
+		// TODO: remove guard with move to controller-runtime 0.9.0 https://issue.k8s.io/99714
+		if len(cluster.Status.Conditions) > 0 {
+			meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionRecentBackup)
+		}
+		return
+	}
+
+	var newest *v1beta1.PGBackRestBackupInfo
+	var newestRepo string
+	var read bool
+	for _, repo := range cluster.Status.PGBackRest.Repos {
+		read = read || repo.InfoTime != nil
+		for i := range repo.Backups {
+			backup := &repo.Backups[i]
+			if backup.StopTime != nil &&
+				(newest == nil || newest.StopTime.Before(backup.StopTime)) {
+				newest, newestRepo = backup, repo.Name
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionRecentBackup,
+	}
+	switch {
+	case newest == nil && !read:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "BackupsUnknown"
+		condition.Message = "The backups in the pgBackRest repositories have not been read"
+	case newest == nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NoBackups"
+		condition.Message = "There are no backups in the pgBackRest repositories"
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RecentBackup"
+		if now.Sub(newest.StopTime.Time) > time.Duration(*maxAge)*time.Second {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "BackupTooOld"
+		}
+		condition.Message = fmt.Sprintf("The most recent backup, %q in %s, finished at %s",
+			newest.Label, newestRepo, newest.StopTime.UTC().Format(time.RFC3339))
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
@@ -0,0 +1,240 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestBackupCatalogWait(t *testing.T) {
+	now := time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC)
+	read := metav1.NewTime(now.Add(-time.Minute))
+
+	repo := &v1beta1.RepoStatus{}
+	assert.Equal(t, backupCatalogWait(repo, nil, now), time.Duration(0),
+		"expected a repo that was never read to be read now")
+
+	repo.InfoTime = &read
+	assert.Equal(t, backupCatalogWait(repo, nil, now), backupCatalogInterval-time.Minute)
+
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	assert.Equal(t, backupCatalogWait(repo, &earlier, now), backupCatalogInterval-time.Minute)
+
+	later := metav1.NewTime(now.Add(-time.Second))
+	assert.Equal(t, backupCatalogWait(repo, &later, now), time.Duration(0),
+		"expected a repo to be read after a backup completes")
+}
+
+func TestSetBackupCatalog(t *testing.T) {
+	now := time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC)
+	backup := func(label, kind string, start int64) pgbackrest.BackupInfo {
+		var info pgbackrest.BackupInfo
+		info.Label, info.Type = label, kind
+		info.Timestamp.Start, info.Timestamp.Stop = start, start+10
+		return info
+	}
+
+	repo := &v1beta1.RepoStatus{
+		Name:          "repo1",
+		WALArchiveMin: "stale",
+		Backups:       []v1beta1.PGBackRestBackupInfo{{Label: "stale"}},
+	}
+	setBackupCatalog(repo, pgbackrest.StanzaInfo{
+		Archive: []pgbackrest.ArchiveInfo{
+			{ID: "12-1", Min: "000000010000000000000001", Max: "000000010000000000000009"},
+			{ID: "13-2", Min: "00000002000000000000000A", Max: "00000002000000000000000F"},
+		},
+		Backup: []pgbackrest.BackupInfo{
+			backup("20210607-170000F", "full", 1623085200),
+			backup("20210607-170000F_20210607-171000I", "incr", 1623085800),
+			backup("20210607-173000F", "full", 1623087000),
+			backup("20210607-173000F_20210607-174000D", "diff", 1623087600),
+		},
+	}, now, backupCatalogLimit)
+
+	assert.Equal(t, len(repo.Backups), 4)
+	assert.Equal(t, repo.Backups[0].Label, "20210607-170000F")
+	assert.Equal(t, repo.Backups[3].Type, "diff")
+	assert.Equal(t, repo.Backups[3].StopTime.Unix(), int64(1623087610))
+	assert.DeepEqual(t, repo.LastBackups, &v1beta1.PGBackRestLastBackups{
+		Full:         "20210607-173000F",
+		Differential: "20210607-173000F_20210607-174000D",
+		Incremental:  "20210607-170000F_20210607-171000I",
+	})
+	assert.Equal(t, repo.WALArchiveMin, "000000010000000000000001")
+	assert.Equal(t, repo.WALArchiveMax, "00000002000000000000000F")
+	assert.Assert(t, repo.InfoTime.Equal(&metav1.Time{Time: now}))
+
+	// Only the most recent backups are kept.
+	var many pgbackrest.StanzaInfo
+	many.Backup = append(many.Backup, backup("20210601-000000F", "full", 1622505600))
+	for i := 1; i <= backupCatalogLimit; i++ {
+		many.Backup = append(many.Backup, backup(
+			fmt.Sprintf("20210601-000000F_20210601-%02d0000I", i), "incr", 1622505600+int64(i)*3600))
+	}
+	setBackupCatalog(repo, many, now, backupCatalogLimit)
+	assert.Equal(t, len(repo.Backups), backupCatalogLimit)
+	assert.Equal(t, repo.Backups[0].Label, "20210601-000000F_20210601-010000I")
+	assert.Equal(t, repo.LastBackups.Full, "20210601-000000F")
+	assert.Equal(t, repo.LastBackups.Incremental, "20210601-000000F_20210601-200000I")
+
+	// An empty stanza clears the previous catalog.
+	setBackupCatalog(repo, pgbackrest.StanzaInfo{}, now, backupCatalogLimit)
+	assert.Assert(t, repo.Backups == nil)
+	assert.Assert(t, repo.LastBackups == nil)
+	assert.Equal(t, repo.WALArchiveMin, "")
+	assert.Equal(t, repo.WALArchiveMax, "")
+	assert.Assert(t, repo.InfoTime != nil)
+}
+
+func TestSetRecentBackupCondition(t *testing.T) {
+	now := time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC)
+	stopped := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}, {Name: "repo2"}},
+	}
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, ConditionRecentBackup)
+	}
+
+	setRecentBackupCondition(cluster, now)
+	assert.Assert(t, condition() == nil, "expected no condition without a maximum age")
+
+	cluster.Spec.Backups.PGBackRest.MaxBackupAgeSeconds = initialize.Int32(3600)
+	setRecentBackupCondition(cluster, now)
+	assert.Equal(t, condition().Status, metav1.ConditionUnknown)
+	assert.Equal(t, condition().Reason, "BackupsUnknown")
+
+	cluster.Status.PGBackRest.Repos[1].InfoTime = stopped(0)
+	setRecentBackupCondition(cluster, now)
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "NoBackups")
+
+	cluster.Status.PGBackRest.Repos[0].Backups = []v1beta1.PGBackRestBackupInfo{
+		{Label: "one", StopTime: stopped(3 * time.Hour)},
+	}
+	cluster.Status.PGBackRest.Repos[1].Backups = []v1beta1.PGBackRestBackupInfo{
+		{Label: "two", StopTime: stopped(2 * time.Hour)},
+	}
+	setRecentBackupCondition(cluster, now)
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "BackupTooOld")
+	assert.Assert(t, strings.Contains(condition().Message, `"two" in repo2`), condition().Message)
+	assert.Assert(t, strings.Contains(condition().Message, "2021-06-07T16:00:00Z"), condition().Message)
+
+	cluster.Status.PGBackRest.Repos[0].Backups[0].StopTime = stopped(time.Minute)
+	setRecentBackupCondition(cluster, now)
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "RecentBackup")
+	assert.Assert(t, strings.Contains(condition().Message, `"one" in repo1`), condition().Message)
+
+	cluster.Spec.Backups.PGBackRest.MaxBackupAgeSeconds = nil
+	setRecentBackupCondition(cluster, now)
+	assert.Assert(t, condition() == nil, "expected the condition to be removed")
+}
+
+func TestReconcileBackupCatalog(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{Name: "repo1", StanzaCreated: true},
+			{Name: "repo2", StanzaCreated: false},
+		},
+	}
+
+	primary := &corev1.Pod{}
+	primary.Namespace, primary.Name = "ns1", "hippo-00-abcd-0"
+	primary.Labels = map[string]string{
+		naming.LabelCluster:  "hippo",
+		naming.LabelInstance: "hippo-00-abcd",
+		naming.LabelRole:     naming.RolePatroniLeader,
+	}
+
+	var calls []string
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(primary).Build(),
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			assert.Equal(t, namespace, "ns1")
+			assert.Equal(t, pod, "hippo-00-abcd-0")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			calls = append(calls, strings.Join(command, " "))
+
+			_, _ = io.WriteString(stdout, `[{
+	"archive": [{"id": "13-1", "max": "000000010000000000000004", "min": "000000010000000000000001"}],
+	"backup": [{
+		"archive": {"start": "000000010000000000000002", "stop": "000000010000000000000002"},
+		"label": "20210607-175000F",
+		"timestamp": {"start": 1623088200, "stop": 1623088210},
+		"type": "full"
+	}],
+	"name": "db"
+}]`)
+			return nil
+		},
+	}
+
+	next, err := reconciler.reconcileBackupCatalog(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, next, backupCatalogInterval)
+	assert.DeepEqual(t, calls, []string{
+		"pgbackrest info --output=json --stanza=db --repo=1",
+	})
+
+	repo := cluster.Status.PGBackRest.Repos[0]
+	assert.Equal(t, len(repo.Backups), 1)
+	assert.Equal(t, repo.LastBackups.Full, "20210607-175000F")
+	assert.Equal(t, repo.WALArchiveMax, "000000010000000000000004")
+	assert.Assert(t, repo.InfoTime != nil)
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[1].InfoTime == nil,
+		"expected a repo without a stanza to be skipped")
+
+	// The catalog is not read again until the interval passes.
+	calls = nil
+	next, err = reconciler.reconcileBackupCatalog(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, next > 0 && next <= backupCatalogInterval)
+	assert.Assert(t, calls == nil)
+}
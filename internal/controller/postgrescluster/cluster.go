@@ -18,12 +18,15 @@ package postgrescluster
 import (
 	"context"
 	"io"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
@@ -189,6 +192,170 @@ func (r *Reconciler) reconcileClusterPrimaryService(
 	return err
 }
 
+// replicaLagInterval is how often the lag of replicas is checked when the replica Service
+// excludes replicas that are too far behind the primary.
+const replicaLagInterval = 30 * time.Second
+
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=services,verbs=create;patch
+
+// reconcileClusterReplicaService writes the Service and Endpoints that resolve
+// to the ready PostgreSQL replica instances. When the spec sets a maximum lag,
+// replicas further behind the primary are left out of the Endpoints.
+func (r *Reconciler) reconcileClusterReplicaService(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	observed *observedInstances,
+) (reconcile.Result, error) {
+	result := reconcile.Result{}
+
+	clusterReplicaService := &v1.Service{ObjectMeta: naming.ClusterReplicaService(cluster)}
+	clusterReplicaService.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Service"))
+
+	err := errors.WithStack(r.setControllerReference(cluster, clusterReplicaService))
+
+	clusterReplicaService.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	clusterReplicaService.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RoleReplica,
+		})
+
+	// Allocate an IP address and manage the Endpoints ourselves. The Pods of
+	// replicas can be selected by their Patroni role, but a selector cannot
+	// exclude replicas that are lagging.
+	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
+	clusterReplicaService.Spec.Type = v1.ServiceTypeClusterIP
+	clusterReplicaService.Spec.Selector = nil
+
+	clusterReplicaService.Spec.Ports = []v1.ServicePort{{
+		Name:       naming.PortPostgreSQL,
+		Port:       *cluster.Spec.Port,
+		Protocol:   v1.ProtocolTCP,
+		TargetPort: intstr.FromString(naming.PortPostgreSQL),
+	}}
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, clusterReplicaService))
+	}
+
+	// Endpoints for a Service have the same name as the Service.
+	endpoints := &v1.Endpoints{ObjectMeta: naming.ClusterReplicaService(cluster)}
+	endpoints.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Endpoints"))
+
+	if err == nil {
+		err = errors.WithStack(r.setControllerReference(cluster, endpoints))
+	}
+
+	endpoints.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	endpoints.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RoleReplica,
+		})
+
+	// An EndpointSubset must have at least one address. Leave the Endpoints
+	// empty when there are no replicas to send connections to.
+	if addresses := replicaEndpointAddresses(cluster, observed); len(addresses) > 0 {
+		endpoints.Subsets = []v1.EndpointSubset{{Addresses: addresses}}
+
+		// Copy the EndpointPorts from the ServicePorts.
+		for _, sp := range clusterReplicaService.Spec.Ports {
+			endpoints.Subsets[0].Ports = append(endpoints.Subsets[0].Ports,
+				v1.EndpointPort{
+					Name:     sp.Name,
+					Port:     sp.Port,
+					Protocol: sp.Protocol,
+				})
+		}
+	}
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, endpoints))
+	}
+
+	// Patroni reports the progress of replicas continuously, but the reconciler
+	// is not notified of it. Check again later when lag matters.
+	if cluster.Spec.ReplicaService != nil && cluster.Spec.ReplicaService.MaximumLag != nil {
+		result.RequeueAfter = replicaLagInterval
+	}
+
+	return result, err
+}
+
+// replicaEndpointAddresses returns the addresses of Pods that should receive
+// connections through the replica Service of cluster: those of ready, running
+// Patroni replicas that are not too far behind the primary and whose instance set
+// allows load balancing.
+func replicaEndpointAddresses(
+	cluster *v1beta1.PostgresCluster, observed *observedInstances,
+) []v1.EndpointAddress {
+	if observed == nil {
+		return nil
+	}
+
+	var maximumLag *int64
+	if cluster.Spec.ReplicaService != nil {
+		maximumLag = cluster.Spec.ReplicaService.MaximumLag
+	}
+
+	// The position of the primary is needed to calculate lag.
+	var primaryPosition int64
+	var primaryKnown bool
+	for _, instance := range observed.forCluster {
+		if primary, known := instance.IsPrimary(); primary && known {
+			primaryPosition, primaryKnown = patroni.PodWALPosition(instance.Pods[0])
+		}
+	}
+
+	var addresses []v1.EndpointAddress
+	for _, instance := range observed.forCluster {
+		if len(instance.Pods) != 1 {
+			continue
+		}
+		pod := instance.Pods[0]
+
+		if pod.Labels[naming.LabelRole] != naming.RolePatroniReplica || pod.Status.PodIP == "" {
+			continue
+		}
+		if ready, known := instance.IsReady(); !ready || !known {
+			continue
+		}
+		if terminating, known := instance.IsTerminating(); terminating || !known {
+			continue
+		}
+		if instance.Spec != nil && instance.Spec.Patroni != nil &&
+			instance.Spec.Patroni.NoLoadBalance {
+			continue
+		}
+
+		// Without the position of the primary there is nothing to compare to,
+		// so replicas are excluded only when their own position is unknown.
+		if maximumLag != nil {
+			position, known := patroni.PodWALPosition(pod)
+			if !known || (primaryKnown && primaryPosition-position > *maximumLag) {
+				continue
+			}
+		}
+
+		addresses = append(addresses, v1.EndpointAddress{
+			IP: pod.Status.PodIP,
+			TargetRef: &v1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				UID:       pod.UID,
+			},
+		})
+	}
+
+	// Sort the addresses so that the Endpoints do not change needlessly.
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].TargetRef.Name < addresses[j].TargetRef.Name
+	})
+
+	return addresses
+}
+
 // reconcileDataSource is responsible for reconciling the data source for a PostgreSQL cluster.
 // This involves ensuring the PostgreSQL data directory for the cluster is properly populated
 // prior to bootstrapping the cluster, specifically according to any data source configured in the
@@ -213,9 +380,23 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 		return false, errors.WithStack(err)
 	}
 
-	// determine if the user wants to initialize the PG data directory
+	// observe any PostgresRestore of the cluster, and update its status accordingly
+	restore, restoreDataSource, err := r.observePostgresRestores(ctx, cluster)
+	if err != nil {
+		return false, err
+	}
+
+	// check the cluster's conditions to determine if the PG data for the cluster has been
+	// initialized
+	dataSourceCondition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPostgresDataInitialized)
+	postgresDataInitialized := dataSourceCondition != nil &&
+		(dataSourceCondition.Status == metav1.ConditionTrue)
+
+	// Determine if the user wants to initialize the PG data directory.  Once initialized, the
+	// data source is no longer needed, e.g. after the cluster is later restored in-place.
 	postgresDataInitRequested := cluster.Spec.DataSource != nil &&
-		cluster.Spec.DataSource.PostgresCluster != nil
+		cluster.Spec.DataSource.PostgresCluster != nil && !postgresDataInitialized
 
 	// determine if the user has requested an in-place restore
 	restoreID := cluster.GetAnnotations()[naming.PGBackRestRestore]
@@ -223,12 +404,24 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 		cluster.Spec.Backups.PGBackRest.Restore != nil &&
 		*cluster.Spec.Backups.PGBackRest.Restore.Enabled
 
+	// Existing volumes in the data source are adopted rather than restored. Like a restore,
+	// the cluster waits until its data is initialized.
+	if restore == nil && !restoreInPlaceRequested && !postgresDataInitialized &&
+		cluster.Spec.DataSource != nil && cluster.Spec.DataSource.Volumes != nil {
+		return true, r.reconcileVolumesDataSource(ctx, cluster)
+	}
+
 	// Set the proper data source for the restore based on whether we're initializing the PG
 	// data directory (e.g. for a new PostgreSQL cluster), or restoring an existing cluster
 	// in place (and therefore recreating the data directory).  If the user hasn't requested
 	// PG data initialization or an in-place restore, then simply return.
 	var dataSource *v1beta1.PostgresClusterDataSource
 	switch {
+	case restore != nil:
+		// a PostgresRestore either restores the cluster in-place or, when the cluster has no
+		// data yet, initializes its data from the backups of another cluster
+		restoreID = postgresRestoreID(restore)
+		dataSource = restoreDataSource
 	case restoreInPlaceRequested:
 		dataSource = cluster.Spec.Backups.PGBackRest.Restore.PostgresClusterDataSource
 	case postgresDataInitRequested:
@@ -240,13 +433,6 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 		return false, nil
 	}
 
-	// check the cluster's conditions to determine if the PG data for the cluster has been
-	// initialized
-	dataSourceCondition := meta.FindStatusCondition(cluster.Status.Conditions,
-		ConditionPostgresDataInitialized)
-	postgresDataInitialized := dataSourceCondition != nil &&
-		(dataSourceCondition.Status == metav1.ConditionTrue)
-
 	// check the cluster's conditions to determine if an in-place restore is in progress,
 	// and if the reason for that condition indicates that the cluster has been prepared for
 	// restore
@@ -280,6 +466,16 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 			(configHash != restoreJob.GetAnnotations()[naming.PGBackRestConfigHash])
 	}
 
+	// Before tearing down a cluster that has data, check that the restore can reach its
+	// target. Leave the cluster running when it cannot. A cluster created by initdb has
+	// data once it is bootstrapped.
+	if restoreIDChanged && !restoringInPlace &&
+		(postgresDataInitialized || patroni.ClusterBootstrapped(cluster)) {
+		if valid, err := r.validateRestoreTarget(ctx, cluster, dataSource); err != nil || !valid {
+			return false, err
+		}
+	}
+
 	// Proceed with preparing the cluster for restore (e.g. tearing down runners, the DCS,
 	// etc.) if:
 	// - A restore is already in progress, but the cluster has not yet been prepared
@@ -701,3 +701,79 @@ func TestContainerSecurityContext(t *testing.T) {
 		}
 	}
 }
+
+func TestReplicaEndpointAddresses(t *testing.T) {
+	instance := func(name, role, ip, status string, ready bool) *Instance {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "ns1", name+"-0"
+		pod.Annotations = map[string]string{"status": status}
+		pod.Labels = map[string]string{naming.LabelRole: role}
+		pod.Status.PodIP = ip
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionFalse,
+		}}
+		if ready {
+			pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}}
+	}
+
+	observed := &observedInstances{forCluster: []*Instance{
+		instance("hippo-00-aaaa", "master", "10.0.0.1", `{"xlog_location":1000}`, true),
+		instance("hippo-00-cccc", "replica", "10.0.0.3", `{"xlog_location":200}`, true),
+		instance("hippo-00-bbbb", "replica", "10.0.0.2", `{"xlog_location":900}`, true),
+		instance("hippo-00-dddd", "replica", "10.0.0.4", `{"xlog_location":1000}`, false),
+		instance("hippo-00-eeee", "replica", "", `{"xlog_location":1000}`, true),
+		instance("hippo-00-ffff", "replica", "10.0.0.6", `{}`, true),
+	}}
+
+	names := func(addresses []corev1.EndpointAddress) []string {
+		var result []string
+		for _, address := range addresses {
+			result = append(result, address.TargetRef.Name)
+		}
+		return result
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	assert.Assert(t, replicaEndpointAddresses(cluster, nil) == nil)
+
+	t.Run("Default", func(t *testing.T) {
+		// Ready replicas with an IP address, sorted by name.
+		addresses := replicaEndpointAddresses(cluster, observed)
+		assert.DeepEqual(t, names(addresses),
+			[]string{"hippo-00-bbbb-0", "hippo-00-cccc-0", "hippo-00-ffff-0"})
+		assert.Equal(t, addresses[0].IP, "10.0.0.2")
+		assert.Equal(t, addresses[0].TargetRef.Kind, "Pod")
+		assert.Equal(t, addresses[0].TargetRef.Namespace, "ns1")
+	})
+
+	t.Run("MaximumLag", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.ReplicaService = &v1beta1.PostgresReplicaServiceSpec{
+			MaximumLag: new(int64),
+		}
+		*cluster.Spec.ReplicaService.MaximumLag = 100
+
+		// Replicas too far behind or without a position are excluded.
+		assert.DeepEqual(t, names(replicaEndpointAddresses(cluster, observed)),
+			[]string{"hippo-00-bbbb-0"})
+
+		// Lag cannot be calculated without the position of the primary.
+		observed := &observedInstances{forCluster: observed.forCluster[1:]}
+		assert.DeepEqual(t, names(replicaEndpointAddresses(cluster, observed)),
+			[]string{"hippo-00-bbbb-0", "hippo-00-cccc-0"})
+	})
+
+	t.Run("NoLoadBalance", func(t *testing.T) {
+		observed := &observedInstances{forCluster: append([]*Instance{}, observed.forCluster...)}
+		reporting := *observed.forCluster[2]
+		reporting.Spec = &v1beta1.PostgresInstanceSetSpec{
+			Patroni: &v1beta1.PatroniInstanceSetSpec{NoLoadBalance: true},
+		}
+		observed.forCluster[2] = &reporting
+
+		assert.DeepEqual(t, names(replicaEndpointAddresses(cluster, observed)),
+			[]string{"hippo-00-cccc-0", "hippo-00-ffff-0"})
+	})
+}
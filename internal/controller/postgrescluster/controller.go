@@ -26,6 +26,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -147,6 +148,7 @@ func (r *Reconciler) Reconcile(
 		clusterVolumes           []corev1.PersistentVolumeClaim
 		instanceServiceAccount   *corev1.ServiceAccount
 		instances                *observedInstances
+		readyInstances           *observedInstances
 		patroniLeaderService     *corev1.Service
 		primaryCertificate       *corev1.SecretProjection
 		rootCA                   *pki.RootCertificateAuthority
@@ -158,6 +160,8 @@ func (r *Reconciler) Reconcile(
 	// occurs while attempting to patch the status, while otherwise simply returning the
 	// Result and error variables that are populated while reconciling the PostgresCluster.
 	patchClusterStatus := func() (reconcile.Result, error) {
+		updateReadyStatus(cluster, readyInstances)
+
 		if !equality.Semantic.DeepEqual(before.Status, cluster.Status) {
 			// NOTE(cbandy): Kubernetes prior to v1.16.10 and v1.17.6 does not track
 			// managed fields on the status subresource: https://issue.k8s.io/88901
@@ -177,6 +181,7 @@ func (r *Reconciler) Reconcile(
 
 	pgParameters := postgres.NewParameters()
 	pgbackrest.PostgreSQL(cluster, &pgParameters)
+	postgres.AutoTuneParameters(cluster, &pgParameters)
 
 	pgmonitor.PostgreSQLParameters(cluster, &pgParameters)
 
@@ -185,6 +190,11 @@ func (r *Reconciler) Reconcile(
 	}
 	if err == nil {
 		instances, err = r.observeInstances(ctx, cluster)
+
+		// The Ready condition is summarized only from instances that were fully observed.
+		if err == nil {
+			readyInstances = instances
+		}
 	}
 	if err == nil {
 		err = updateResult(r.reconcilePatroniStatus(ctx, cluster, instances))
@@ -212,6 +222,16 @@ func (r *Reconciler) Reconcile(
 			return patchClusterStatus()
 		}
 	}
+	// Similarly, a major PostgreSQL upgrade stops the cluster while pg_upgrade runs. Further
+	// reconciliation would start instances using the new version of PostgreSQL before the
+	// data directory is upgraded.
+	if err == nil {
+		var returnEarly bool
+		returnEarly, err = r.reconcileMajorUpgrade(ctx, cluster, instances)
+		if err != nil || returnEarly {
+			return patchClusterStatus()
+		}
+	}
 	if err == nil {
 		clusterConfigMap, err = r.reconcileClusterConfigMap(ctx, cluster, pgHBAs, pgParameters)
 	}
@@ -227,6 +247,9 @@ func (r *Reconciler) Reconcile(
 	if err == nil {
 		err = r.reconcileClusterPrimaryService(ctx, cluster, patroniLeaderService)
 	}
+	if err == nil {
+		err = updateResult(r.reconcileClusterReplicaService(ctx, cluster, instances))
+	}
 	if err == nil {
 		primaryCertificate, err = r.reconcileClusterCertificate(ctx, rootCA, cluster)
 	}
@@ -239,6 +262,9 @@ func (r *Reconciler) Reconcile(
 	if err == nil {
 		err = r.reconcilePatroniDynamicConfiguration(ctx, cluster, instances, pgHBAs, pgParameters)
 	}
+	if err == nil {
+		err = r.reconcilePatroniSwitchover(ctx, cluster, instances)
+	}
 	if err == nil {
 		monitoringSecret, err = r.reconcileMonitoringSecret(ctx, cluster)
 	}
@@ -246,7 +272,7 @@ func (r *Reconciler) Reconcile(
 		err = r.reconcileInstanceSets(
 			ctx, cluster, clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount, instances,
-			patroniLeaderService, primaryCertificate, clusterVolumes)
+			patroniLeaderService, primaryCertificate, clusterVolumes, pgParameters)
 	}
 
 	if err == nil {
@@ -259,14 +285,27 @@ func (r *Reconciler) Reconcile(
 	if err == nil {
 		err = updateResult(r.reconcilePGBackRest(ctx, cluster, instances))
 	}
+	if err == nil {
+		err = updateResult(r.reconcileStanzaUpgrade(ctx, cluster, instances))
+	}
+	if err == nil {
+		err = updateResult(r.reconcileVolumeAutoGrow(ctx, cluster, instances, clusterVolumes))
+	}
+	if err == nil {
+		err = updateResult(r.reconcileVolumeSnapshots(ctx, cluster, instances))
+	}
+	if err == nil {
+		err = updateResult(r.reconcileStandbyStatus(ctx, cluster, instances))
+	}
 	if err == nil {
 		err = r.reconcilePGBouncer(ctx, cluster, instances, primaryCertificate, rootCA)
 	}
 	if err == nil {
 		err = r.reconcilePGMonitor(ctx, cluster, instances, monitoringSecret)
 	}
-
-	// TODO reconcile pgadmin4
+	if err == nil {
+		err = r.reconcilePGAdmin(ctx, cluster)
+	}
 
 	// at this point everything reconciled successfully, and we can update the
 	// observedGeneration
@@ -365,10 +404,14 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 		Owns(&appsv1.Deployment{}).
 		Owns(&appsv1.StatefulSet{}).
 		Owns(&batchv1.Job{}).
+		Owns(&policyv1beta1.PodDisruptionBudget{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
 		Owns(&batchv1beta1.CronJob{}).
 		Watches(&source.Kind{Type: &corev1.Pod{}}, r.watchPods()).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, r.watchPostgresBackupJobs()).
+		Watches(&source.Kind{Type: &v1beta1.PostgresBackup{}}, r.watchPostgresBackups()).
+		Watches(&source.Kind{Type: &v1beta1.PostgresRestore{}}, r.watchPostgresRestores()).
 		Watches(&source.Kind{Type: &appsv1.StatefulSet{}},
 			r.controllerRefHandlerFuncs()). // watch all StatefulSets
 		Complete(r)
@@ -17,16 +17,36 @@ package postgrescluster
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+const (
+	// EventDeletionProtected is the event reason utilized when a cluster that is protected
+	// from deletion is deleted anyway
+	EventDeletionProtected = "DeletionProtected"
+
+	// EventFinalBackupFailed is the event reason utilized when the backup taken before a
+	// cluster is deleted cannot be taken
+	EventFinalBackupFailed = "FinalBackupFailed"
+
+	// EventVolumeRetained is the event reason utilized when a volume is kept after its
+	// cluster is deleted
+	EventVolumeRetained = "VolumeRetained"
+)
+
 // +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=patch
 
 // handleDelete sets a finalizer on cluster and performs the finalization of
@@ -85,6 +105,22 @@ func (r *Reconciler) handleDelete(
 	// The cluster is being deleted and our finalizer is still set; run our
 	// finalizer logic.
 
+	// The validating webhook refuses to delete a protected cluster, but it may
+	// not be installed. Keep the cluster running until protection is removed.
+	if cluster.Spec.DeletionProtection {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventDeletionProtected,
+			"Cluster is protected from deletion; set spec.deletionProtection to false to continue")
+		return &reconcile.Result{}, nil
+	}
+
+	if cluster.Spec.DeletionPolicy == v1beta1.DeletionPolicySnapshot {
+		if result, err := r.reconcileFinalBackup(ctx, cluster); err != nil {
+			return nil, err
+		} else if result != nil {
+			return result, nil
+		}
+	}
+
 	if result, err := r.deleteInstances(ctx, cluster); err != nil {
 		return nil, err
 	} else if result != nil {
@@ -96,6 +132,12 @@ func (r *Reconciler) handleDelete(
 		return nil, err
 	}
 
+	// Release any volumes that should outlive cluster before the garbage
+	// collector deletes them.
+	if err := r.retainVolumes(ctx, cluster); err != nil {
+		return nil, err
+	}
+
 	// Our finalizer logic is finished; remove our finalizer.
 	// The Finalizers field is shared by multiple controllers, but the
 	// server-side merge strategy does not work on our custom resource due to a
@@ -113,3 +155,185 @@ func (r *Reconciler) handleDelete(
 	// The caller should wait for further events or requeue upon error.
 	return &reconcile.Result{}, err
 }
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;patch
+
+// reconcileFinalBackup takes a full pgBackRest backup in the first repository of cluster
+// before its instances are stopped. It returns (nil, nil) once the backup is complete. The
+// cluster is not deleted while the backup cannot be taken or has failed.
+func (r *Reconciler) reconcileFinalBackup(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (*reconcile.Result, error) {
+	if len(cluster.Spec.Backups.PGBackRest.Repos) == 0 {
+		return nil, nil
+	}
+	repoName := cluster.Spec.Backups.PGBackRest.Repos[0].Name
+
+	existing := &batchv1.Job{ObjectMeta: naming.PGBackRestFinalBackupJob(cluster)}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	if err == nil {
+		switch {
+		case jobCompleted(existing):
+			return nil, nil
+		case jobFailed(existing):
+			// The Job is left in place until it is deleted, which starts another backup.
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventFinalBackupFailed,
+				"Backup before deletion failed; delete Job %s to try again "+
+					"or change spec.deletionPolicy", existing.Name)
+		}
+		return &reconcile.Result{}, nil
+	}
+
+	// pgBackRest connects to a PostgreSQL instance that is not in recovery to
+	// initiate a backup.
+	instances, err := r.observeInstances(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	var primary *Instance
+	for i, instance := range instances.forCluster {
+		if writable, known := instance.IsWritable(); writable && known {
+			primary = instances.forCluster[i]
+			break
+		}
+	}
+
+	var stanzaCreated bool
+	if cluster.Status.PGBackRest != nil {
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			if repo.Name == repoName {
+				stanzaCreated = repo.StanzaCreated
+			}
+		}
+	}
+
+	// Instances start and stop without notifying the reconciler. Check again later.
+	switch {
+	case primary == nil && cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown:
+		// Instances are not started while the cluster is deleted. Changing the
+		// deletion policy triggers another reconcile.
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventFinalBackupFailed,
+			"Cluster is shut down and cannot back up before deletion; "+
+				"change spec.deletionPolicy to continue")
+		return &reconcile.Result{}, nil
+
+	case primary == nil:
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventFinalBackupFailed,
+			"Waiting for a writable primary to back up before deletion")
+		return &reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+
+	case !stanzaCreated:
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventFinalBackupFailed,
+			"Waiting for the stanza of %q to back up before deletion", repoName)
+		return &reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// set the name of the pgbackrest config file that will be mounted to the backup Job
+	configName := primary.Name + ".conf"
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		configName = pgbackrest.CMRepoKey
+	}
+
+	serviceAccount, err := r.reconcilePGBackRestRBAC(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	backupJob := &batchv1.Job{ObjectMeta: naming.PGBackRestFinalBackupJob(cluster)}
+	backupJob.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestBackupJobLabels(cluster.GetName(), repoName, naming.BackupFinal))
+	backupJob.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+
+	spec, err := generateBackupJobSpecIntent(cluster, selector.String(), containerName,
+		repoName, serviceAccount.GetName(), configName,
+		backupJob.Labels, backupJob.Annotations, "--type=full")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	backupJob.Spec = *spec
+
+	backupJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	if err := r.setControllerReference(cluster, backupJob); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The Job belongs to cluster, so its completion triggers another reconcile.
+	return &reconcile.Result{}, errors.WithStack(r.apply(ctx, backupJob))
+}
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=list;patch
+
+// retainVolumes releases the volumes of cluster that its deletion policy keeps. They
+// remain labeled for cluster so that a new cluster with the same name can use them.
+func (r *Reconciler) retainVolumes(ctx context.Context, cluster *v1beta1.PostgresCluster) error {
+	policy := cluster.Spec.DeletionPolicy
+	if policy != v1beta1.DeletionPolicyRetain && policy != v1beta1.DeletionPolicySnapshot {
+		return nil
+	}
+
+	volumes := &corev1.PersistentVolumeClaimList{}
+	selector, err := naming.AsSelector(naming.Cluster(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, volumes,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+
+	for i := range volumes.Items {
+		pvc := &volumes.Items[i]
+		_, repo := pvc.Labels[naming.LabelPGBackRestRepoVolume]
+		role := pvc.Labels[naming.LabelRole]
+		data := role == naming.RolePostgresData || role == naming.RolePostgresWAL
+
+		// Repository volumes hold the backups of both policies. Data volumes
+		// are kept only when retained.
+		if err == nil && pvc.DeletionTimestamp == nil &&
+			(repo || (data && policy == v1beta1.DeletionPolicyRetain)) {
+			err = r.retainVolume(ctx, cluster, pvc)
+		}
+	}
+
+	return err
+}
+
+// retainVolume removes the owner reference to cluster from pvc so that the garbage
+// collector keeps it after cluster is deleted.
+func (r *Reconciler) retainVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, pvc *corev1.PersistentVolumeClaim,
+) error {
+	var owners []metav1.OwnerReference
+	for _, owner := range pvc.OwnerReferences {
+		if owner.UID != cluster.GetUID() {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == len(pvc.OwnerReferences) && pvc.Labels[naming.LabelRetained] != "" {
+		return nil
+	}
+
+	before := pvc.DeepCopy()
+	pvc.OwnerReferences = owners
+	pvc.Labels = naming.Merge(pvc.Labels, map[string]string{
+		naming.LabelRetained: "true",
+	})
+
+	err := errors.WithStack(r.patch(ctx, pvc,
+		client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})))
+	if err == nil {
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, EventVolumeRetained,
+			fmt.Sprintf("volume %q is kept after the cluster is deleted", pvc.Name))
+	}
+	return client.IgnoreNotFound(err)
+}
@@ -31,20 +31,25 @@ import (
 	"go.opentelemetry.io/otel"
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -482,3 +487,262 @@ func TestReconcilerHandleDeleteNamespace(t *testing.T) {
 		return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
 	}), "expected namespace to be deleted")
 }
+
+func TestRetainVolumes(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = "ns1", "hippo", types.UID("abc")
+
+	volume := func(name string, labels map[string]string) *v1.PersistentVolumeClaim {
+		pvc := &v1.PersistentVolumeClaim{}
+		pvc.Namespace, pvc.Name = "ns1", name
+		pvc.Labels = naming.Merge(labels, map[string]string{naming.LabelCluster: "hippo"})
+		pvc.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "xyz"},
+			{APIVersion: v1beta1.GroupVersion.String(), Kind: "PostgresCluster",
+				Name: "hippo", UID: "abc"},
+		}
+		return pvc
+	}
+
+	setup := func() (*Reconciler, *record.FakeRecorder) {
+		recorder := record.NewFakeRecorder(10)
+		return &Reconciler{
+			Client: fake.NewClientBuilder().WithObjects(
+				volume("hippo-00-abcd-pgdata", map[string]string{
+					naming.LabelRole: naming.RolePostgresData}),
+				volume("hippo-00-abcd-pgwal", map[string]string{
+					naming.LabelRole: naming.RolePostgresWAL}),
+				volume("hippo-repo1", naming.PGBackRestRepoVolumeLabels("hippo", "repo1")),
+				volume("hippo-other", nil),
+			).Build(),
+			Recorder: recorder,
+		}, recorder
+	}
+
+	retained := func(t *testing.T, r *Reconciler, name string) bool {
+		t.Helper()
+		pvc := &v1.PersistentVolumeClaim{}
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKey{Namespace: "ns1", Name: name}, pvc))
+
+		for _, owner := range pvc.OwnerReferences {
+			if owner.UID == cluster.UID {
+				assert.Equal(t, pvc.Labels[naming.LabelRetained], "")
+				return false
+			}
+		}
+		assert.Equal(t, len(pvc.OwnerReferences), 1, "expected other owners to remain")
+		assert.Equal(t, pvc.Labels[naming.LabelRetained], "true")
+		assert.Equal(t, pvc.Labels[naming.LabelCluster], "hippo")
+		return true
+	}
+
+	t.Run("Delete", func(t *testing.T) {
+		r, recorder := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+
+		assert.NilError(t, r.retainVolumes(ctx, cluster))
+		assert.Assert(t, !retained(t, r, "hippo-00-abcd-pgdata"))
+		assert.Assert(t, !retained(t, r, "hippo-repo1"))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Retain", func(t *testing.T) {
+		r, recorder := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+
+		assert.NilError(t, r.retainVolumes(ctx, cluster))
+		assert.Assert(t, retained(t, r, "hippo-00-abcd-pgdata"))
+		assert.Assert(t, retained(t, r, "hippo-00-abcd-pgwal"))
+		assert.Assert(t, retained(t, r, "hippo-repo1"))
+		assert.Assert(t, !retained(t, r, "hippo-other"))
+		assert.Equal(t, len(recorder.Events), 3)
+
+		// Nothing changes the second time.
+		assert.NilError(t, r.retainVolumes(ctx, cluster))
+		assert.Equal(t, len(recorder.Events), 3)
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		r, recorder := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.DeletionPolicy = v1beta1.DeletionPolicySnapshot
+
+		assert.NilError(t, r.retainVolumes(ctx, cluster))
+		assert.Assert(t, !retained(t, r, "hippo-00-abcd-pgdata"))
+		assert.Assert(t, !retained(t, r, "hippo-00-abcd-pgwal"))
+		assert.Assert(t, retained(t, r, "hippo-repo1"))
+		assert.Equal(t, <-recorder.Events, `Normal VolumeRetained volume "hippo-repo1" is kept after the cluster is deleted`)
+	})
+}
+
+func TestReconcilerHandleDeleteProtected(t *testing.T) {
+	ctx := context.Background()
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Recorder: recorder}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Finalizers = []string{naming.Finalizer}
+	cluster.Spec.DeletionProtection = true
+	now := metav1.Now()
+	cluster.DeletionTimestamp = &now
+
+	// Nothing is stopped or removed while the cluster is protected.
+	result, err := r.handleDelete(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.DeepEqual(t, cluster.Finalizers, []string{naming.Finalizer})
+}
+
+func TestReconcileFinalBackup(t *testing.T) {
+	ctx := context.Background()
+
+	clientScheme := runtime.NewScheme()
+	assert.NilError(t, scheme.AddToScheme(clientScheme))
+	assert.NilError(t, v1beta1.AddToScheme(clientScheme))
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = "ns1", "hippo", types.UID("abc")
+	cluster.Spec.DeletionPolicy = v1beta1.DeletionPolicySnapshot
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo1", Volume: &v1beta1.RepoPVC{},
+	}}
+	cluster.Default()
+
+	pod := &v1.Pod{}
+	pod.Namespace, pod.Name = "ns1", "hippo-00-abcd-0"
+	pod.Labels = map[string]string{
+		naming.LabelCluster:     "hippo",
+		naming.LabelInstanceSet: "00",
+		naming.LabelInstance:    "hippo-00-abcd",
+	}
+	pod.Annotations = map[string]string{"status": `{"role":"replica"}`}
+
+	setup := func(objects ...client.Object) (*Reconciler, *applyRecorder, *record.FakeRecorder) {
+		cc := &applyRecorder{
+			Client: fake.NewClientBuilder().WithScheme(clientScheme).WithObjects(objects...).Build(),
+		}
+		recorder := record.NewFakeRecorder(10)
+		return &Reconciler{
+			Client: cc, Owner: client.FieldOwner(t.Name()), Recorder: recorder,
+		}, cc, recorder
+	}
+
+	t.Run("NoRepository", func(t *testing.T) {
+		r, cc, _ := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = nil
+
+		result, err := r.reconcileFinalBackup(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result == nil)
+		assert.Equal(t, len(cc.applied), 0)
+	})
+
+	t.Run("NoPrimary", func(t *testing.T) {
+		r, cc, recorder := setup(pod.DeepCopy())
+		cluster := cluster.DeepCopy()
+
+		result, err := r.reconcileFinalBackup(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+		assert.Assert(t, result.RequeueAfter > 0)
+		assert.Equal(t, len(cc.applied), 0)
+		assert.Equal(t, <-recorder.Events,
+			"Warning FinalBackupFailed Waiting for a writable primary to back up before deletion")
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		r, cc, recorder := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Shutdown = initialize.Bool(true)
+
+		// Nothing starts the instances again, so there is no reason to requeue.
+		result, err := r.reconcileFinalBackup(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+		assert.Equal(t, result.RequeueAfter, time.Duration(0))
+		assert.Equal(t, len(cc.applied), 0)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "spec.deletionPolicy"))
+	})
+
+	primary := pod.DeepCopy()
+	primary.Annotations["status"] = `{"role":"master"}`
+
+	t.Run("NoStanza", func(t *testing.T) {
+		r, cc, recorder := setup(primary.DeepCopy())
+		cluster := cluster.DeepCopy()
+
+		result, err := r.reconcileFinalBackup(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+		assert.Assert(t, result.RequeueAfter > 0)
+		assert.Equal(t, len(cc.applied), 0)
+		assert.Equal(t, <-recorder.Events,
+			`Warning FinalBackupFailed Waiting for the stanza of "repo1" to back up before deletion`)
+	})
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+	}
+
+	t.Run("Backup", func(t *testing.T) {
+		r, cc, _ := setup(primary.DeepCopy())
+		cluster := cluster.DeepCopy()
+
+		result, err := r.reconcileFinalBackup(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+
+		var job *batchv1.Job
+		for _, object := range cc.applied {
+			if applied, ok := object.(*batchv1.Job); ok {
+				job = applied
+			}
+		}
+		assert.Assert(t, job != nil, "expected a backup Job")
+		assert.Equal(t, job.Name, naming.PGBackRestFinalBackupJob(cluster).Name)
+		assert.Equal(t, job.Labels[naming.LabelPGBackRestBackup], string(naming.BackupFinal))
+		assert.Assert(t, metav1.IsControlledBy(job, cluster))
+
+		var options string
+		for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "COMMAND_OPTS" {
+				options = env.Value
+			}
+		}
+		assert.Assert(t, strings.Contains(options, "--type=full"), options)
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		job := &batchv1.Job{ObjectMeta: naming.PGBackRestFinalBackupJob(cluster)}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: v1.ConditionTrue,
+		}}
+		r, cc, _ := setup(job)
+
+		result, err := r.reconcileFinalBackup(ctx, cluster.DeepCopy())
+		assert.NilError(t, err)
+		assert.Assert(t, result == nil, "expected deletion to continue")
+		assert.Equal(t, len(cc.applied), 0)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		job := &batchv1.Job{ObjectMeta: naming.PGBackRestFinalBackupJob(cluster)}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: v1.ConditionTrue,
+		}}
+		r, cc, recorder := setup(job)
+
+		result, err := r.reconcileFinalBackup(ctx, cluster.DeepCopy())
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+		assert.Equal(t, len(cc.applied), 0)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "delete Job hippo-backup-final"))
+	})
+}
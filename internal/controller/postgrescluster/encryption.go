@@ -0,0 +1,195 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventRepoEncryptionUnchanged is the event reason utilized when the encryption of a
+	// pgBackRest repository is not changed because the repository has a stanza already
+	EventRepoEncryptionUnchanged = "RepoEncryptionUnchanged"
+
+	// EventInvalidCipherPass is the event reason utilized when the passphrase of a pgBackRest
+	// repository cannot be read from the Secret referenced in its spec
+	EventInvalidCipherPass = "InvalidCipherPass"
+
+	// defaultCipherType is the cipher of an encrypted repository that does not specify one.
+	defaultCipherType = "aes-256-cbc"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create;delete;patch
+
+// reconcilePGBackRestCipherSecret reconciles the Secret that holds the cipher options of the
+// encrypted repositories of cluster and records the cipher of each repository in its status.
+// The options of a repository with a stanza do not change; pgBackRest cannot read the backups
+// in that repository using other options.
+//
+// The Secret also holds configHash once every encrypted repository without a stanza has its
+// options in the Secret. Stanzas are not created until that hash reaches the Pods, so that a
+// new repository is never initialized without its cipher options.
+func (r *Reconciler) reconcilePGBackRestCipherSecret(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, configHash string,
+) error {
+	existing := &corev1.Secret{ObjectMeta: naming.PGBackRestCipherSecret(cluster)}
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing))
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	intent := &corev1.Secret{ObjectMeta: naming.PGBackRestCipherSecret(cluster)}
+	intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	intent.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+	intent.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestConfigLabels(cluster.GetName()))
+	intent.Data = make(map[string][]byte)
+
+	options := make(map[string]string)
+	pending := false
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		var status *v1beta1.RepoStatus
+		if cluster.Status.PGBackRest != nil {
+			for i := range cluster.Status.PGBackRest.Repos {
+				if cluster.Status.PGBackRest.Repos[i].Name == repo.Name {
+					status = &cluster.Status.PGBackRest.Repos[i]
+				}
+			}
+		}
+
+		typeKey, passKey := repo.Name+"-cipher-type", repo.Name+"-cipher-pass"
+		cipherType, pass := existing.Data[typeKey], existing.Data[passKey]
+
+		desiredType, desiredPass, cipherErr := r.repoCipher(ctx, cluster, repo, pass)
+		if cipherErr != nil {
+			return cipherErr
+		}
+
+		switch {
+		case desiredPass == nil && repo.Encryption != nil:
+			// The Secret could not be read; an event was emitted already.
+		case status != nil && status.StanzaCreated &&
+			(!bytes.Equal(cipherType, desiredType) || !bytes.Equal(pass, desiredPass)):
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventRepoEncryptionUnchanged,
+				"The encryption of %s cannot change because its stanza exists", repo.Name)
+		default:
+			cipherType, pass = desiredType, desiredPass
+		}
+
+		if len(cipherType) > 0 && len(pass) > 0 {
+			intent.Data[typeKey], intent.Data[passKey] = cipherType, pass
+			options[typeKey], options[passKey] = string(cipherType), string(pass)
+		} else if repo.Encryption != nil && (status == nil || !status.StanzaCreated) {
+			pending = true
+		}
+		if status != nil {
+			status.CipherType = options[typeKey]
+		}
+	}
+
+	if len(options) == 0 {
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, existing))
+		}
+		return client.IgnoreNotFound(err)
+	}
+	intent.Data[pgbackrest.CipherConfigKey] = []byte(pgbackrest.CipherConfigString(options))
+	if !pending {
+		intent.Data[pgbackrest.CipherHashKey] = []byte(configHash)
+	}
+
+	err = errors.WithStack(r.setControllerReference(cluster, intent))
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, intent))
+	}
+	return err
+}
+
+// repoCipher returns the cipher type and passphrase that repo should be encrypted with
+// according to its spec, or nil when it should not be encrypted or its passphrase cannot be
+// read. A generated passphrase is used when the spec does not reference one; current is that
+// passphrase when it was generated already.
+func (r *Reconciler) repoCipher(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	repo v1beta1.PGBackRestRepo, current []byte,
+) ([]byte, []byte, error) {
+	if repo.Encryption == nil {
+		return nil, nil, nil
+	}
+
+	cipherType := []byte(repo.Encryption.CipherType)
+	if len(cipherType) == 0 {
+		cipherType = []byte(defaultCipherType)
+	}
+
+	reference := repo.Encryption.CipherPassSecret
+	if reference == nil {
+		if len(current) > 0 {
+			return cipherType, current, nil
+		}
+
+		// pgBackRest recommends a passphrase of 64 random characters.
+		// - https://pgbackrest.org/user-guide.html#quickstart/configure-encryption
+		random := make([]byte, 48)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return cipherType, []byte(base64.StdEncoding.EncodeToString(random)), nil
+	}
+
+	secret := &corev1.Secret{}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKey{
+		Namespace: cluster.GetNamespace(), Name: reference.Name,
+	}, secret))
+	if client.IgnoreNotFound(err) != nil {
+		return nil, nil, err
+	}
+
+	// A line break would end the option in the pgBackRest configuration file.
+	pass := bytes.TrimRight(secret.Data[reference.Key], "\r\n")
+	var message string
+	switch {
+	case err != nil:
+		message = fmt.Sprintf("Secret %q not found", reference.Name)
+	case len(pass) == 0:
+		message = fmt.Sprintf("Secret %q has no %q", reference.Name, reference.Key)
+	case bytes.ContainsAny(pass, "\r\n"):
+		message = fmt.Sprintf("%q in Secret %q contains a line break", reference.Key, reference.Name)
+	default:
+		return cipherType, pass, nil
+	}
+	r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventInvalidCipherPass,
+		"Unable to read the cipher pass of %s: %s", repo.Name, message)
+	return nil, nil, nil
+}
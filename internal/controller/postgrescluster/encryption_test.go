@@ -0,0 +1,182 @@
+// +build envtest
+
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcilePGBackRestCipherSecret(t *testing.T) {
+	ctx := context.Background()
+	env, cc, _ := setupTestEnv(t, ControllerName)
+	t.Cleanup(func() { teardownTestEnv(t, env) })
+
+	ns := &corev1.Namespace{}
+	ns.GenerateName = "postgres-operator-test-"
+	ns.Labels = labels.Set{"postgres-operator-test": ""}
+	assert.NilError(t, cc.Create(ctx, ns))
+	t.Cleanup(func() { assert.Check(t, cc.Delete(ctx, ns)) })
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = ns.Name, "hippo", types.UID("abc")
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo1", Encryption: &v1beta1.PGBackRestRepoEncryption{},
+	}, {
+		Name: "repo2", Encryption: &v1beta1.PGBackRestRepoEncryption{
+			CipherPassSecret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "cipher"}, Key: "pass",
+			},
+		},
+	}, {
+		Name: "repo3",
+	}}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{Repos: []v1beta1.RepoStatus{
+		{Name: "repo1"}, {Name: "repo2"}, {Name: "repo3"},
+	}}
+
+	passphrase := &corev1.Secret{}
+	passphrase.Namespace, passphrase.Name = ns.Name, "cipher"
+	passphrase.Data = map[string][]byte{"pass": []byte("first\n")}
+	assert.NilError(t, cc.Create(ctx, passphrase))
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+	read := func() *corev1.Secret {
+		secret := &corev1.Secret{ObjectMeta: naming.PGBackRestCipherSecret(cluster)}
+		assert.NilError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+		return secret
+	}
+
+	assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash1"))
+	assert.Equal(t, len(recorder.Events), 0)
+
+	secret := read()
+	generated := string(secret.Data["repo1-cipher-pass"])
+	assert.Equal(t, len(generated), 64)
+	assert.Equal(t, string(secret.Data["repo2-cipher-pass"]), "first",
+		"expected the trailing line break to be removed")
+	assert.Equal(t, secret.Labels[naming.LabelPGBackRestConfig], "")
+	assert.Equal(t, string(secret.Data[pgbackrest.CipherConfigKey]), strings.Join([]string{
+		"[global]",
+		"repo1-cipher-pass=" + generated,
+		"repo1-cipher-type=aes-256-cbc",
+		"repo2-cipher-pass=first",
+		"repo2-cipher-type=aes-256-cbc",
+		"",
+	}, "\n"))
+	assert.Equal(t, string(secret.Data[pgbackrest.CipherHashKey]), "hash1")
+
+	repos := cluster.Status.PGBackRest.Repos
+	assert.Equal(t, repos[0].CipherType, "aes-256-cbc")
+	assert.Equal(t, repos[1].CipherType, "aes-256-cbc")
+	assert.Equal(t, repos[2].CipherType, "")
+
+	t.Run("StanzaCreated", func(t *testing.T) {
+		repos[0].StanzaCreated, repos[1].StanzaCreated = true, true
+		passphrase.Data["pass"] = []byte("second")
+		assert.NilError(t, reconciler.Client.Update(ctx, passphrase))
+
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash1"))
+		assert.Equal(t, string(read().Data["repo1-cipher-pass"]), generated)
+		assert.Equal(t, string(read().Data["repo2-cipher-pass"]), "first",
+			"expected the passphrase of a repo with a stanza to stay the same")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, EventRepoEncryptionUnchanged))
+
+		repos[1].StanzaCreated = false
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash1"))
+		assert.Equal(t, string(read().Data["repo2-cipher-pass"]), "second")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("InvalidCipherPass", func(t *testing.T) {
+		passphrase.Data["pass"] = []byte("multiple\nlines")
+		assert.NilError(t, reconciler.Client.Update(ctx, passphrase))
+
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash1"))
+		assert.Equal(t, string(read().Data["repo2-cipher-pass"]), "second")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "contains a line break"))
+	})
+
+	t.Run("NewRepository", func(t *testing.T) {
+		passphrase.Data["pass"] = []byte("second")
+		assert.NilError(t, reconciler.Client.Update(ctx, passphrase))
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = append(cluster.Spec.Backups.PGBackRest.Repos,
+			v1beta1.PGBackRestRepo{Name: "repo4", Encryption: &v1beta1.PGBackRestRepoEncryption{
+				CipherPassSecret: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "cipher4"}, Key: "pass",
+				},
+			}})
+		cluster.Status.PGBackRest.Repos = append(cluster.Status.PGBackRest.Repos,
+			v1beta1.RepoStatus{Name: "repo4"})
+
+		// Stanzas cannot be created before the options of the new repository are in the Secret.
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash2"))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, `Secret "cipher4" not found`))
+
+		secret := read()
+		assert.Assert(t, !strings.Contains(string(secret.Data[pgbackrest.CipherConfigKey]), "repo4"))
+		_, ok := secret.Data[pgbackrest.CipherHashKey]
+		assert.Assert(t, !ok, "expected no cipher hash while repo4 has no options")
+
+		fourth := &corev1.Secret{}
+		fourth.Namespace, fourth.Name = ns.Name, "cipher4"
+		fourth.Data = map[string][]byte{"pass": []byte("fourth")}
+		assert.NilError(t, cc.Create(ctx, fourth))
+
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash2"))
+		assert.Equal(t, len(recorder.Events), 0)
+
+		secret = read()
+		assert.Assert(t, strings.Contains(string(secret.Data[pgbackrest.CipherConfigKey]),
+			"repo4-cipher-pass=fourth"))
+		assert.Equal(t, string(secret.Data[pgbackrest.CipherHashKey]), "hash2")
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Repos = cluster.Spec.Backups.PGBackRest.Repos[2:]
+
+		assert.NilError(t, reconciler.reconcilePGBackRestCipherSecret(ctx, cluster, "hash1"))
+		err := reconciler.Client.Get(ctx,
+			naming.AsObjectKey(naming.PGBackRestCipherSecret(cluster)), &corev1.Secret{})
+		assert.Assert(t, apierrors.IsNotFound(err), "expected the Secret to be deleted, got %v", err)
+	})
+}
@@ -66,6 +66,16 @@ func (i Instance) IsAvailable() (available bool, known bool) {
 	return ready && !terminating, knownReady && knownTerminating
 }
 
+// IsPendingRestart returns whether or not PostgreSQL of this instance must
+// restart to apply changes to its parameters.
+func (i Instance) IsPendingRestart() (pending bool, known bool) {
+	if len(i.Pods) != 1 {
+		return false, false
+	}
+
+	return patroni.PodRequiresRestart(i.Pods[0])
+}
+
 // IsPrimary returns whether or not this instance is the Patroni leader.
 func (i Instance) IsPrimary() (primary bool, known bool) {
 	if len(i.Pods) != 1 {
@@ -178,7 +188,9 @@ func (s *instanceSorter) Swap(i, j int) {
 
 // byPriority returns a sort.Interface that sorts instances by how much we want
 // each to keep running. The primary instance, when known, is always the highest
-// priority. Two instances with otherwise-identical priority are ranked by Name.
+// priority. Instances that can become primary are ranked by the failover priority
+// of their instance set above those that cannot. Two instances with
+// otherwise-identical priority are ranked by Name.
 func byPriority(instances []*Instance) sort.Interface {
 	return &instanceSorter{instances: instances, less: func(a, b *Instance) bool {
 		// The primary instance is the highest priority.
@@ -190,17 +202,38 @@ func byPriority(instances []*Instance) sort.Interface {
 		}
 
 		// An available instance is a higher priority than not.
-		if available, known := a.IsAvailable(); known && available {
-			return false
+		aAvailable, aKnown := a.IsAvailable()
+		bAvailable, bKnown := b.IsAvailable()
+		if a, b := aAvailable && aKnown, bAvailable && bKnown; a != b {
+			return b
 		}
-		if available, known := b.IsAvailable(); known && available {
-			return true
+
+		// An instance that can become primary is a higher priority than not.
+		if a, b := instanceFailoverCandidate(a), instanceFailoverCandidate(b); a != b {
+			return b
+		}
+		if a, b := instanceFailoverPriority(a), instanceFailoverPriority(b); a != b {
+			return a < b
 		}
 
 		return a.Name < b.Name
 	}}
 }
 
+// instanceFailoverCandidate returns true when instance may become primary
+// according to its instance set.
+func instanceFailoverCandidate(instance *Instance) bool {
+	return instance.Spec == nil || instance.Spec.Patroni.FailoverCandidate()
+}
+
+// instanceFailoverPriority returns the failover priority of the instance set of instance.
+func instanceFailoverPriority(instance *Instance) int32 {
+	if instance.Spec == nil {
+		return 0
+	}
+	return instance.Spec.Patroni.GetFailoverPriority()
+}
+
 // observedInstances represents all the PostgreSQL instances of a single PostgresCluster.
 type observedInstances struct {
 	byName     map[string]*Instance
@@ -322,6 +355,17 @@ func (r *Reconciler) observeInstances(
 		return observed, err
 	}
 
+	// The same is true for a major upgrade, which starts only the upgraded instance until the
+	// pgBackRest stanza is upgraded.
+	upgradeCondition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGUpgradeProgressing)
+	if upgradeCondition != nil && upgradeCondition.Status == metav1.ConditionTrue {
+		return observed, err
+	}
+	if upgradeAwaitingReplicaCreate(cluster) {
+		return observed, err
+	}
+
 	// Go through the observed instances and check if a primary has been determined.
 	// If the cluster is being shutdown and this instance is the primary, store
 	// the instance name as the startup instance. If the primary can be determined
@@ -501,6 +545,7 @@ func (r *Reconciler) reconcileInstanceSets(
 	patroniLeaderService *v1.Service,
 	primaryCertificate *v1.SecretProjection,
 	clusterVolumes []v1.PersistentVolumeClaim,
+	pgParameters postgres.Parameters,
 ) error {
 	// get the number of instance pods from the observedInstance information
 	var numInstancePods int
@@ -518,7 +563,7 @@ func (r *Reconciler) reconcileInstanceSets(
 			rootCA, clusterPodService, instanceServiceAccount,
 			patroniLeaderService, primaryCertificate,
 			findAvailableInstanceNames(set, instances, clusterVolumes),
-			numInstancePods)
+			numInstancePods, pgParameters)
 		if err != nil {
 			return err
 		}
@@ -533,11 +578,16 @@ func (r *Reconciler) reconcileInstanceSets(
 	}
 
 	// Rollout changes to instances by calling rolloutInstance.
+	setPendingRestartCondition(cluster, instances)
 	err = r.rolloutInstances(ctx, cluster, instances,
 		func(ctx context.Context, instance *Instance) error {
 			return r.rolloutInstance(ctx, cluster, instances, instance)
 		})
 
+	if err == nil {
+		err = r.reconcileInstanceSetPodDisruptionBudgets(ctx, cluster, instances)
+	}
+
 	return err
 }
 
@@ -610,8 +660,9 @@ func findAvailableInstanceNames(set v1beta1.PostgresInstanceSetSpec,
 // +kubebuilder:rbac:groups="",resources=pods,verbs=delete
 
 // rolloutInstance redeploys the Pod of instance by deleting it. Its StatefulSet
-// will recreate it according to its current PodTemplate. When instance is the
-// primary of a cluster with failover, it is demoted instead.
+// will recreate it according to its current PodTemplate. When the Pod is
+// current but PostgreSQL is pending restart, PostgreSQL is restarted instead.
+// When instance is the primary of a cluster with failover, it is demoted first.
 func (r *Reconciler) rolloutInstance(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
 	instances *observedInstances, instance *Instance,
@@ -634,8 +685,9 @@ func (r *Reconciler) rolloutInstance(
 	primary = primary && known
 
 	// When the cluster has more than one instance participating in failover,
-	// perform a controlled switchover to one of those instances. Patroni will
-	// choose the best candidate and demote the primary. It stops PostgreSQL
+	// perform a controlled switchover to one of those instances. The operator
+	// chooses a candidate by failover priority, or Patroni chooses when there
+	// is none, and Patroni demotes the primary. It stops PostgreSQL
 	// using what it calls "graceful" mode: it takes an immediate checkpoint in
 	// the background then uses "pg_ctl" to perform a "fast" shutdown when the
 	// checkpoint completes.
@@ -649,7 +701,12 @@ func (r *Reconciler) rolloutInstance(
 		ctx, span = r.Tracer.Start(ctx, "patroni-change-primary")
 		defer span.End()
 
-		success, err := patroni.Executor(exec).ChangePrimaryAndWait(ctx, pod.Name, "")
+		var next string
+		if candidate, _ := switchoverCandidate(&v1beta1.PatroniSwitchover{}, instances); candidate != nil {
+			next = candidate.Pods[0].Name
+		}
+
+		success, err := patroni.Executor(exec).ChangePrimaryAndWait(ctx, pod.Name, next)
 		if err = errors.WithStack(err); err == nil && !success {
 			err = errors.New("unable to switchover")
 		}
@@ -658,6 +715,27 @@ func (r *Reconciler) rolloutInstance(
 		return err
 	}
 
+	// When the Pod matches its template, only PostgreSQL needs to restart to
+	// apply its parameters. Patroni takes a checkpoint then performs a "fast"
+	// shutdown of PostgreSQL before starting it again.
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/postgresql/__init__.py
+	if matches, known := instance.PodMatchesPodTemplate(); known && matches {
+		if pending, known := instance.IsPendingRestart(); known && pending {
+			var span trace.Span
+			ctx, span = r.Tracer.Start(ctx, "patroni-restart")
+			defer span.End()
+
+			success, err := patroni.Executor(exec).RestartPendingMember(
+				ctx, naming.PatroniScope(cluster), pod.Name)
+			if err = errors.WithStack(err); err == nil && !success {
+				err = errors.New("unable to restart")
+			}
+
+			span.RecordError(err)
+			return err
+		}
+	}
+
 	// When the cluster has only one instance for failover, perform a series of
 	// immediate checkpoints to increase the likelihood that a "fast" shutdown
 	// will complete before the SIGKILL near TerminationGracePeriodSeconds.
@@ -726,8 +804,8 @@ func (r *Reconciler) rolloutInstance(
 }
 
 // rolloutInstances compares instances to cluster and calls redeploy on those
-// that need their Pod recreated. It considers the overall availability of
-// cluster and minimizes Patroni failovers.
+// that need their Pod recreated or PostgreSQL restarted. It considers the
+// overall availability of cluster and minimizes Patroni failovers.
 func (r *Reconciler) rolloutInstances(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
@@ -767,6 +845,11 @@ func (r *Reconciler) rolloutInstances(
 			consider = append(consider, instance)
 			continue
 		}
+
+		if pending, known := instance.IsPendingRestart(); known && pending {
+			consider = append(consider, instance)
+			continue
+		}
 	}
 
 	const maxUnavailable = 1
@@ -910,6 +993,7 @@ func (r *Reconciler) scaleUpInstances(
 	primaryCertificate *v1.SecretProjection,
 	availableInstanceNames []string,
 	numInstancePods int,
+	pgParameters postgres.Parameters,
 ) ([]*appsv1.StatefulSet, error) {
 	log := logging.FromContext(ctx)
 
@@ -953,7 +1037,7 @@ func (r *Reconciler) scaleUpInstances(
 			clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount,
 			patroniLeaderService, primaryCertificate, instances[i],
-			numInstancePods,
+			numInstancePods, pgParameters,
 		)
 	}
 	if err == nil {
@@ -981,6 +1065,7 @@ func (r *Reconciler) reconcileInstance(
 	primaryCertificate *v1.SecretProjection,
 	instance *appsv1.StatefulSet,
 	numInstancePods int,
+	pgParameters postgres.Parameters,
 ) error {
 	log := logging.FromContext(ctx).WithValues("instance", instance.Name)
 	ctx = logging.NewContext(ctx, log)
@@ -1004,7 +1089,8 @@ func (r *Reconciler) reconcileInstance(
 	)
 
 	if err == nil {
-		instanceConfigMap, err = r.reconcileInstanceConfigMap(ctx, cluster, spec, instance)
+		instanceConfigMap, err = r.reconcileInstanceConfigMap(
+			ctx, cluster, spec, instance, pgParameters)
 	}
 	if err == nil {
 		instanceCertificates, err = r.reconcileInstanceCertificates(
@@ -1216,7 +1302,7 @@ func addPGBackRestToInstancePodSpec(cluster *v1beta1.PostgresCluster,
 // files (etc) that apply to instance of cluster.
 func (r *Reconciler) reconcileInstanceConfigMap(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, spec *v1beta1.PostgresInstanceSetSpec,
-	instance *appsv1.StatefulSet,
+	instance *appsv1.StatefulSet, pgParameters postgres.Parameters,
 ) (*v1.ConfigMap, error) {
 	instanceConfigMap := &v1.ConfigMap{ObjectMeta: naming.InstanceConfigMap(instance)}
 	instanceConfigMap.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ConfigMap"))
@@ -1237,7 +1323,7 @@ func (r *Reconciler) reconcileInstanceConfigMap(
 		})
 
 	if err == nil {
-		err = patroni.InstanceConfigMap(ctx, cluster, spec, instanceConfigMap)
+		err = patroni.InstanceConfigMap(ctx, cluster, spec, pgParameters, instanceConfigMap)
 	}
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, instanceConfigMap))
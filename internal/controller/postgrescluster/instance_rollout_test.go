@@ -171,6 +171,81 @@ func TestReconcilerRolloutInstance(t *testing.T) {
 			assert.ErrorContains(t, err, "switchover")
 		})
 	})
+
+	t.Run("PendingRestart", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Name = "hippo"
+
+		instances := []*Instance{
+			{
+				Name: "replica",
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "ns1",
+						Name:      "the-pod",
+						Annotations: map[string]string{
+							"status": `{"role":"replica","pending_restart":true}`,
+						},
+						Labels: map[string]string{
+							"controller-revision-hash":               "gamma",
+							"postgres-operator.crunchydata.com/role": "replica",
+						},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					Status: appsv1.StatefulSetStatus{UpdateRevision: "gamma"},
+				},
+			},
+			{
+				Name:   "primary",
+				Pods:   []*corev1.Pod{{}},
+				Runner: &appsv1.StatefulSet{},
+			},
+		}
+		observed := &observedInstances{forCluster: instances}
+
+		key := client.ObjectKey{Namespace: "ns1", Name: "the-pod"}
+		reconciler := &Reconciler{}
+		reconciler.Client = fake.NewClientBuilder().WithObjects(instances[0].Pods[0]).Build()
+		reconciler.Tracer = oteltest.DefaultTracer()
+
+		execCalls := 0
+		reconciler.PodExec = func(
+			namespace, pod, container string, _ io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			execCalls++
+
+			// Execute on the Pod.
+			assert.Equal(t, namespace, "ns1")
+			assert.Equal(t, pod, "the-pod")
+			assert.Equal(t, container, "database")
+
+			// Restart PostgreSQL through Patroni.
+			assert.DeepEqual(t, command, strings.Fields(
+				`patronictl restart --pending --force hippo-ha the-pod`))
+
+			_, _ = stdout.Write([]byte("Success: restart on member the-pod"))
+			return nil
+		}
+
+		assert.NilError(t, reconciler.rolloutInstance(ctx, cluster, observed, instances[0]))
+		assert.Equal(t, execCalls, 1, "expected PodExec to be called")
+
+		// The Pod is not deleted.
+		assert.NilError(t, reconciler.Client.Get(ctx, key, &corev1.Pod{}))
+
+		t.Run("Failure", func(t *testing.T) {
+			reconciler.PodExec = func(
+				_, _, _ string, _ io.Reader, _, _ io.Writer, _ ...string,
+			) error {
+				// Nothing useful in stdout.
+				return nil
+			}
+
+			err := reconciler.rolloutInstance(ctx, cluster, observed, instances[0])
+			assert.ErrorContains(t, err, "restart")
+		})
+	})
 }
 
 func TestReconcilerRolloutInstances(t *testing.T) {
@@ -255,6 +330,54 @@ func TestReconcilerRolloutInstances(t *testing.T) {
 			}))
 	})
 
+	// Single healthy instance, PostgreSQL must restart.
+	t.Run("SingletonPendingRestart", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "00", Replicas: initialize.Int32(1)},
+		}
+		instances := []*Instance{
+			{
+				Name: "one",
+				Spec: &cluster.Spec.InstanceSets[0],
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"status": `{"role":"master","pending_restart":true}`,
+						},
+						Labels: map[string]string{
+							"controller-revision-hash":               "gamma",
+							"postgres-operator.crunchydata.com/role": "master",
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{
+							Type:   corev1.PodReady,
+							Status: corev1.ConditionTrue,
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Generation: 1,
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						UpdateRevision:     "gamma",
+					},
+				},
+			},
+		}
+		observed := &observedInstances{forCluster: instances}
+
+		var redeploys []*Instance
+
+		logSpanAttributes(t)
+		assert.NilError(t, reconciler.rolloutInstances(ctx, cluster, observed, accumulate(&redeploys)))
+		assert.Equal(t, len(redeploys), 1)
+		assert.Equal(t, redeploys[0].Name, "one")
+	})
+
 	// Single healthy instance, Pod does not match PodTemplate.
 	t.Run("SingletonOutdated", func(t *testing.T) {
 		cluster := new(v1beta1.PostgresCluster)
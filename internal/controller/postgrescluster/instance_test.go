@@ -137,6 +137,41 @@ func TestInstanceIsWritable(t *testing.T) {
 	assert.Assert(t, !writable)
 }
 
+func TestByPriority(t *testing.T) {
+	instance := func(name, role string, set *v1beta1.PostgresInstanceSetSpec) *Instance {
+		pod := &corev1.Pod{}
+		pod.Labels = map[string]string{naming.LabelRole: role}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionTrue,
+		}}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}, Spec: set}
+	}
+
+	reporting := &v1beta1.PostgresInstanceSetSpec{Name: "reporting",
+		Patroni: &v1beta1.PatroniInstanceSetSpec{FailoverRole: "Replica", FailoverPriority: 9}}
+	preferred := &v1beta1.PostgresInstanceSetSpec{Name: "preferred",
+		Patroni: &v1beta1.PatroniInstanceSetSpec{FailoverPriority: 1}}
+
+	instances := []*Instance{
+		instance("a", naming.RolePatroniReplica, preferred),
+		instance("b", naming.RolePatroniLeader, reporting),
+		instance("c", naming.RolePatroniReplica, nil),
+		instance("d", naming.RolePatroniReplica, reporting),
+		instance("e", naming.RolePatroniReplica, nil),
+		instance("f", naming.RolePatroniReplica, preferred),
+	}
+	instances[5].Pods[0].Status.Conditions[0].Status = corev1.ConditionFalse
+	sort.Sort(byPriority(instances))
+
+	var names []string
+	for _, instance := range instances {
+		names = append(names, instance.Name)
+	}
+
+	// The lowest priority instances are first.
+	assert.DeepEqual(t, names, []string{"f", "d", "c", "e", "a", "b"})
+}
+
 func TestNewObservedInstances(t *testing.T) {
 	t.Run("Empty", func(t *testing.T) {
 		cluster := new(v1beta1.PostgresCluster)
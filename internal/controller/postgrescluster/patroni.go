@@ -18,10 +18,13 @@ package postgrescluster
 import (
 	"context"
 	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +39,10 @@ import (
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// EventQuorumCommitUnsupported is the event reason utilized when quorum commit is requested
+// but some instance of the cluster runs a version of Patroni that does not manage it
+const EventQuorumCommitUnsupported = "QuorumCommitUnsupported"
+
 // +kubebuilder:rbac:groups="",resources=endpoints,verbs=deletecollection
 
 func (r *Reconciler) deletePatroniArtifacts(
@@ -95,7 +102,57 @@ func (r *Reconciler) reconcilePatroniDistributedConfiguration(
 	}
 
 	// TODO(cbandy): DCS "failover_path"; `failover` and `switchover` create "{scope}-failover" endpoints.
-	// TODO(cbandy): DCS "sync_path"; `synchronous_mode` uses "{scope}-sync" endpoints.
+
+	if err == nil {
+		err = r.reconcilePatroniSynchronousState(ctx, cluster)
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;delete
+
+// reconcilePatroniSynchronousState manages the Service of the "{scope}-sync"
+// Endpoints Patroni uses for synchronous replication. Like the DCS Service
+// above, it keeps Kubernetes from removing those Endpoints, but Patroni does
+// not create it. When synchronous replication is disabled, the Service is
+// deleted and Kubernetes removes the Endpoints that Patroni leaves behind.
+// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/dcs/kubernetes.py
+func (r *Reconciler) reconcilePatroniSynchronousState(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	syncService := &v1.Service{ObjectMeta: naming.PatroniSynchronousState(cluster)}
+	syncService.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Service"))
+
+	if !patroni.SynchronousModeEnabled(cluster) {
+		// Delete the Service if it exists. Check the client cache first using Get.
+		key := client.ObjectKeyFromObject(syncService)
+		err := errors.WithStack(r.Client.Get(ctx, key, syncService))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, syncService))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, syncService))
+
+	syncService.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil())
+	syncService.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelPatroni: naming.PatroniScope(cluster),
+		})
+
+	// Allocate no IP address (headless) and create no Endpoints.
+	// - https://docs.k8s.io/concepts/services-networking/service/#headless-services
+	syncService.Spec.ClusterIP = v1.ClusterIPNone
+	syncService.Spec.Selector = nil
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, syncService))
+	}
 
 	return err
 }
@@ -112,6 +169,8 @@ func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 		return nil
 	}
 
+	r.setQuorumCommitCondition(cluster)
+
 	var pod *v1.Pod
 	for _, instance := range instances.forCluster {
 		if terminating, known := instance.IsTerminating(); !terminating && known {
@@ -148,6 +207,41 @@ func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 		patroni.Executor(exec).ReplaceConfiguration(ctx, configuration))
 }
 
+// setQuorumCommitCondition describes whether or not the quorum commit requested in the
+// spec of cluster is in effect. Quorum commit is configured only once every instance
+// runs a version of Patroni that manages it; until then, Patroni chooses synchronous
+// standbys by priority. An event is emitted when the condition becomes False.
+func (r *Reconciler) setQuorumCommitCondition(cluster *v1beta1.PostgresCluster) {
+	if sync := cluster.Spec.Patroni.Synchronous; sync == nil ||
+		sync.Mode != v1beta1.PatroniSynchronousModeQuorum {
+		// TODO: remove guard with move to controller-runtime 0.9.0 https://issue.k8s.io/99714
+		if len(cluster.Status.Conditions) > 0 {
+			meta.RemoveStatusCondition(&cluster.Status.Conditions, v1beta1.QuorumCommit)
+		}
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               v1beta1.QuorumCommit,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Enabled",
+		Message:            "Patroni chooses synchronous standbys by quorum",
+		ObservedGeneration: cluster.GetGeneration(),
+	}
+	if !patroni.QuorumCommitSupported(cluster) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = EventQuorumCommitUnsupported
+		condition.Message = "Quorum commit requires Patroni 4.0 or later in every instance; " +
+			"synchronous standbys are chosen by priority until then"
+
+		if !meta.IsStatusConditionFalse(cluster.Status.Conditions, v1beta1.QuorumCommit) {
+			r.Recorder.Event(cluster, v1.EventTypeWarning, EventQuorumCommitUnsupported,
+				condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
 // +kubebuilder:rbac:groups="",resources=services,verbs=create;patch
 
 // reconcilePatroniLeaderLease sets labels and ownership on the objects Patroni
@@ -165,10 +259,17 @@ func (r *Reconciler) reconcilePatroniLeaderLease(
 
 	err := errors.WithStack(r.setControllerReference(cluster, leaderService))
 
+	var serviceMetadata *v1beta1.Metadata
+	if cluster.Spec.Service != nil {
+		serviceMetadata = cluster.Spec.Service.Metadata
+	}
+
 	leaderService.Annotations = naming.Merge(
-		cluster.Spec.Metadata.GetAnnotationsOrNil())
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		serviceMetadata.GetAnnotationsOrNil())
 	leaderService.Labels = naming.Merge(
 		cluster.Spec.Metadata.GetLabelsOrNil(),
+		serviceMetadata.GetLabelsOrNil(),
 		map[string]string{
 			naming.LabelCluster: cluster.Name,
 			naming.LabelPatroni: naming.PatroniScope(cluster),
@@ -177,7 +278,6 @@ func (r *Reconciler) reconcilePatroniLeaderLease(
 	// Allocate an IP address and let Patroni manage the Endpoints. Patroni will
 	// ensure that they always route to the elected leader.
 	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
-	leaderService.Spec.Type = v1.ServiceTypeClusterIP
 	leaderService.Spec.Selector = nil
 
 	// The TargetPort must be the name (not the number) of the PostgreSQL
@@ -190,6 +290,10 @@ func (r *Reconciler) reconcilePatroniLeaderLease(
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
 	}}
 
+	// This Service is the one the primary Service resolves to, so it is the
+	// one exposed outside of Kubernetes when the spec asks for it.
+	setServiceSpec(leaderService, cluster.Spec.Service)
+
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, leaderService))
 	}
@@ -221,8 +325,31 @@ func (r *Reconciler) reconcilePatroniStatus(
 	if err == nil {
 		if dcs.Annotations["initialize"] != "" {
 			// After bootstrap, Patroni writes the cluster system identifier to DCS.
-			cluster.Status.Patroni = &v1beta1.PatroniStatus{
-				SystemIdentifier: dcs.Annotations["initialize"],
+			if cluster.Status.Patroni == nil {
+				cluster.Status.Patroni = &v1beta1.PatroniStatus{}
+			}
+			cluster.Status.Patroni.SystemIdentifier = dcs.Annotations["initialize"]
+			cluster.Status.Patroni.SynchronousStandbys = nil
+
+			// Keep the last known timeline while the leader is not reporting one.
+			if timeline, known := leaderTimeline(observedInstances); known {
+				cluster.Status.Patroni.Timeline = timeline
+			}
+
+			// Keep the last known version while some instance is not reporting one, unless
+			// an older version is reported.
+			if version, all := patroniVersion(observedInstances); version != "" && (all ||
+				patroni.CompareVersions(version, cluster.Status.Patroni.Version) < 0) {
+				cluster.Status.Patroni.Version = version
+			}
+
+			sync := &v1.Endpoints{ObjectMeta: naming.PatroniSynchronousState(cluster)}
+			err = errors.WithStack(client.IgnoreNotFound(
+				r.Client.Get(ctx, client.ObjectKeyFromObject(sync), sync)))
+
+			if err == nil && patroni.SynchronousModeEnabled(cluster) {
+				cluster.Status.Patroni.SynchronousStandbys = synchronousStandbys(
+					sync, observedInstances)
 			}
 		} else if readyInstance {
 			// While we typically expect a value for the initialize key to be present in the
@@ -241,6 +368,74 @@ func (r *Reconciler) reconcilePatroniStatus(
 	return result, err
 }
 
+// resetPatroniStatus clears the Patroni status of cluster when its data directory is
+// replaced. The record of any switchover is kept so that it does not happen again.
+func resetPatroniStatus(cluster *v1beta1.PostgresCluster) {
+	if cluster.Status.Patroni != nil {
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{
+			Switchover: cluster.Status.Patroni.Switchover,
+		}
+	}
+}
+
+// patroniVersion returns the oldest version of Patroni reported by instances. The bool is
+// false when some instance with a Pod has not reported a version.
+func patroniVersion(instances *observedInstances) (string, bool) {
+	var oldest string
+	all := true
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) != 1 {
+			continue
+		}
+		version, known := patroni.PodVersion(instance.Pods[0])
+		if !known {
+			all = false
+			continue
+		}
+		if oldest == "" || patroni.CompareVersions(version, oldest) < 0 {
+			oldest = version
+		}
+	}
+	return oldest, all
+}
+
+// leaderTimeline returns the PostgreSQL timeline reported by the Patroni leader, whether
+// it is a primary or a standby leader. The bool is false when no leader reports one.
+func leaderTimeline(instances *observedInstances) (int64, bool) {
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) != 1 {
+			continue
+		}
+		if primary, _ := instance.IsPrimary(); primary || patroni.PodIsStandbyLeader(instance.Pods[0]) {
+			if timeline, known := patroni.PodTimeline(instance.Pods[0]); known {
+				return timeline, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// synchronousStandbys returns the names of the instances that Patroni records as
+// synchronous standbys in sync. Patroni names its members after their Pods, so
+// those that are not observed instances are returned as they are.
+func synchronousStandbys(sync *v1.Endpoints, instances *observedInstances) []string {
+	var names []string
+	for _, member := range strings.Split(sync.Annotations["sync_standby"], ",") {
+		if member = strings.TrimSpace(member); member == "" {
+			continue
+		}
+		name := member
+		for _, instance := range instances.forCluster {
+			if len(instance.Pods) > 0 && instance.Pods[0].Name == member {
+				name = instance.Name
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // reconcileReplicationSecret creates a secret containing the TLS
 // certificate, key and CA certificate for use with the replication and
 // pg_rewind accounts in Postgres.
@@ -33,9 +33,11 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -281,3 +283,93 @@ func TestReconcilePatroniStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestSynchronousStandbys(t *testing.T) {
+	instances := &observedInstances{forCluster: []*Instance{
+		{Name: "hippo-00-aaaa", Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-00-aaaa-0"},
+		}}},
+		{Name: "hippo-00-bbbb", Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-00-bbbb-0"},
+		}}},
+	}}
+
+	sync := &corev1.Endpoints{}
+	assert.Assert(t, synchronousStandbys(sync, instances) == nil)
+
+	// Pods are reported as their instances, and unknown members as they are.
+	sync.Annotations = map[string]string{
+		"leader":       "hippo-00-cccc-0",
+		"sync_standby": "hippo-00-bbbb-0,unknown, hippo-00-aaaa-0",
+	}
+	assert.DeepEqual(t, synchronousStandbys(sync, instances),
+		[]string{"hippo-00-aaaa", "hippo-00-bbbb", "unknown"})
+}
+
+func TestPatroniVersion(t *testing.T) {
+	instance := func(name, status string) *Instance {
+		pod := &corev1.Pod{}
+		pod.Annotations = map[string]string{"status": status}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}}
+	}
+
+	version, all := patroniVersion(&observedInstances{})
+	assert.Equal(t, version, "")
+	assert.Assert(t, all)
+
+	// The oldest version is reported.
+	instances := &observedInstances{forCluster: []*Instance{
+		instance("hippo-00-aaaa", `{"role":"master","version":"4.0.2"}`),
+		instance("hippo-00-bbbb", `{"role":"replica","version":"3.3.2"}`),
+		{Name: "hippo-00-cccc"},
+	}}
+	version, all = patroniVersion(instances)
+	assert.Equal(t, version, "3.3.2")
+	assert.Assert(t, all, "expected instances without Pods to be skipped")
+
+	// An instance that has not reported is noticed.
+	instances.forCluster = append(instances.forCluster,
+		instance("hippo-00-dddd", `{"role":"replica","state":"starting"}`))
+	version, all = patroniVersion(instances)
+	assert.Equal(t, version, "3.3.2")
+	assert.Assert(t, !all)
+}
+
+func TestSetQuorumCommitCondition(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Patroni = &v1beta1.PatroniSpec{}
+	cluster.Status.Patroni = &v1beta1.PatroniStatus{Version: "3.3.2"}
+
+	// Nothing happens without quorum commit.
+	reconciler.setQuorumCommitCondition(cluster)
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.QuorumCommit) == nil)
+
+	cluster.Spec.Patroni.Synchronous = &v1beta1.PatroniSynchronous{
+		Mode: v1beta1.PatroniSynchronousModeQuorum,
+	}
+	reconciler.setQuorumCommitCondition(cluster)
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.QuorumCommit)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "QuorumCommitUnsupported")
+	assert.Equal(t, len(recorder.Events), 1)
+	event := <-recorder.Events
+	assert.Assert(t, strings.HasPrefix(event, "Warning QuorumCommitUnsupported"), "got %q", event)
+
+	// The event is emitted only when the condition changes.
+	reconciler.setQuorumCommitCondition(cluster)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	cluster.Status.Patroni.Version = "4.0.2"
+	reconciler.setQuorumCommitCondition(cluster)
+	condition = meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.QuorumCommit)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	cluster.Spec.Patroni.Synchronous.Mode = v1beta1.PatroniSynchronousModeOn
+	reconciler.setQuorumCommitCondition(cluster)
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.QuorumCommit) == nil)
+}
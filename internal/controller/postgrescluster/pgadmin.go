@@ -0,0 +1,458 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgadmin"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// reconcilePGAdmin writes the objects necessary to run a pgAdmin Pod.
+func (r *Reconciler) reconcilePGAdmin(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	var (
+		configmap *corev1.ConfigMap
+		dataVol   *corev1.PersistentVolumeClaim
+	)
+
+	configmap, err := r.reconcilePGAdminConfigMap(ctx, cluster)
+	if err == nil {
+		err = r.reconcilePGAdminService(ctx, cluster)
+	}
+	if err == nil {
+		dataVol, err = r.reconcilePGAdminDataVolume(ctx, cluster)
+	}
+	if err == nil {
+		err = r.reconcilePGAdminStatefulSet(ctx, cluster, configmap, dataVol)
+	}
+	if err == nil {
+		err = r.reconcilePGAdminUsers(ctx, cluster)
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;delete;patch
+
+// reconcilePGAdminConfigMap writes the ConfigMap for a pgAdmin Pod.
+func (r *Reconciler) reconcilePGAdminConfigMap(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (*corev1.ConfigMap, error) {
+	configmap := &corev1.ConfigMap{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+	configmap.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	if cluster.Spec.UserInterface == nil || cluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; delete the ConfigMap if it exists. Check the
+		// client cache first using Get.
+		key := client.ObjectKeyFromObject(configmap)
+		err := errors.WithStack(r.Client.Get(ctx, key, configmap))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, configmap))
+		}
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, configmap))
+
+	configmap.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetAnnotationsOrNil())
+	configmap.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		})
+
+	if err == nil {
+		pgadmin.ConfigMap(cluster, configmap)
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, configmap))
+	}
+
+	return configmap, err
+}
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;delete;patch
+
+// reconcilePGAdminDataVolume writes the PersistentVolumeClaim for pgAdmin's
+// data volume.
+func (r *Reconciler) reconcilePGAdminDataVolume(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (*corev1.PersistentVolumeClaim, error) {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	if cluster.Spec.UserInterface == nil || cluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; delete the PVC if it exists. Check the client
+		// cache first using Get. The PVC will continue to exist until the
+		// pgAdmin Pod using it is also deleted.
+		// - https://docs.k8s.io/concepts/storage/persistent-volumes/#storage-object-in-use-protection
+		key := client.ObjectKeyFromObject(pvc)
+		err := errors.WithStack(r.Client.Get(ctx, key, pvc))
+		if err == nil && pvc.DeletionTimestamp == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, pvc))
+		}
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, pvc))
+
+	pvc.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetAnnotationsOrNil())
+	pvc.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		})
+
+	pvc.Spec = cluster.Spec.UserInterface.PGAdmin.DataVolumeClaimSpec
+
+	if err == nil {
+		err = r.handlePersistentVolumeClaimError(cluster,
+			errors.WithStack(r.apply(ctx, pvc)))
+	}
+
+	return pvc, err
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get
+// +kubebuilder:rbac:groups="",resources=services,verbs=create;delete;patch
+
+// reconcilePGAdminService writes the Service that resolves to pgAdmin.
+func (r *Reconciler) reconcilePGAdminService(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	service := &corev1.Service{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+	service.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+
+	if cluster.Spec.UserInterface == nil || cluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; delete the Service if it exists. Check the client
+		// cache first using Get.
+		key := client.ObjectKeyFromObject(service)
+		err := errors.WithStack(r.Client.Get(ctx, key, service))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, service))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, service))
+
+	service.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetAnnotationsOrNil())
+	service.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		})
+
+	// Allocate an IP address and let Kubernetes manage the Endpoints by selecting
+	// Pods with the pgAdmin role.
+	// - https://docs.k8s.io/concepts/services-networking/service/#defining-a-service
+	service.Spec.Type = corev1.ServiceTypeClusterIP
+	service.Spec.Selector = map[string]string{
+		naming.LabelCluster: cluster.Name,
+		naming.LabelRole:    naming.RolePGAdmin,
+	}
+
+	// The TargetPort must be the name (not the number) of the pgAdmin
+	// ContainerPort. This name allows the port number to differ between Pods,
+	// which can happen during a rolling update.
+	service.Spec.Ports = []corev1.ServicePort{{
+		Name:       naming.PortPGAdmin,
+		Port:       *cluster.Spec.UserInterface.PGAdmin.Port,
+		Protocol:   corev1.ProtocolTCP,
+		TargetPort: intstr.FromString(naming.PortPGAdmin),
+	}}
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, service))
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=create;delete;patch
+
+// reconcilePGAdminStatefulSet writes the StatefulSet that runs pgAdmin.
+func (r *Reconciler) reconcilePGAdminStatefulSet(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	configmap *corev1.ConfigMap, dataVolume *corev1.PersistentVolumeClaim,
+) error {
+	sts := &appsv1.StatefulSet{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+	sts.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+
+	if cluster.Spec.UserInterface == nil || cluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; delete the StatefulSet if it exists. Check the
+		// client cache first using Get. Its status goes with it.
+		cluster.Status.UserInterface = nil
+
+		key := client.ObjectKeyFromObject(sts)
+		err := errors.WithStack(r.Client.Get(ctx, key, sts))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, sts))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	if cluster.Status.UserInterface == nil {
+		cluster.Status.UserInterface = new(v1beta1.PostgresUserInterfaceStatus)
+	}
+
+	// Set observations whether the StatefulSet exists or not.
+	defer func() {
+		cluster.Status.UserInterface.PGAdmin.Replicas = sts.Status.Replicas
+		cluster.Status.UserInterface.PGAdmin.ReadyReplicas = sts.Status.ReadyReplicas
+	}()
+
+	err := errors.WithStack(r.setControllerReference(cluster, sts))
+
+	sts.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetAnnotationsOrNil())
+	sts.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		})
+	sts.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		},
+	}
+	sts.Spec.Template.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetAnnotationsOrNil())
+	sts.Spec.Template.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.UserInterface.PGAdmin.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePGAdmin,
+		})
+
+	// if the shutdown flag is set, set pgAdmin replicas to 0
+	if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown {
+		sts.Spec.Replicas = initialize.Int32(0)
+	} else {
+		sts.Spec.Replicas = cluster.Spec.UserInterface.PGAdmin.Replicas
+	}
+
+	// Use the cluster's Pod Service for the network identity of the Pod.
+	sts.Spec.ServiceName = naming.ClusterPodService(cluster).Name
+
+	// Don't clutter the namespace with extra ControllerRevisions.
+	sts.Spec.RevisionHistoryLimit = initialize.Int32(0)
+
+	// There is only one pgAdmin Pod and it owns the data volume. Replace it
+	// during updates.
+	// - https://docs.k8s.io/concepts/workloads/controllers/statefulset/#rolling-updates
+	sts.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+
+	// Use scheduling constraints from the cluster spec.
+	sts.Spec.Template.Spec.Affinity = cluster.Spec.UserInterface.PGAdmin.Affinity
+	sts.Spec.Template.Spec.Tolerations = cluster.Spec.UserInterface.PGAdmin.Tolerations
+
+	// Restart containers any time they stop, die, are killed, etc.
+	// - https://docs.k8s.io/concepts/workloads/pods/pod-lifecycle/#restart-policy
+	sts.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+
+	// pgAdmin does not make any Kubernetes API calls. Use the default
+	// ServiceAccount and do not mount its credentials.
+	sts.Spec.Template.Spec.AutomountServiceAccountToken = initialize.Bool(false)
+
+	podSecurityContext := initialize.RestrictedPodSecurityContext()
+	// set fsGroups if not OpenShift so that pgAdmin can write to its data volume
+	if cluster.Spec.OpenShift == nil || !*cluster.Spec.OpenShift {
+		podSecurityContext.FSGroup = initialize.Int64(26)
+	}
+	sts.Spec.Template.Spec.SecurityContext = podSecurityContext
+
+	// set the image pull secrets, if any exist
+	sts.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+
+	if err == nil {
+		pgadmin.Pod(cluster, configmap, dataVolume, &sts.Spec.Template.Spec)
+
+		// pgAdmin and Python write temporary files
+		addTMPEmptyDir(&sts.Spec.Template)
+	}
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, sts))
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=list
+
+// reconcilePGAdminUsers creates a pgAdmin login for each PostgreSQL user of
+// cluster using the password in their Secret. Each login has server definitions
+// for the PostgreSQL primary and PgBouncer Services.
+func (r *Reconciler) reconcilePGAdminUsers(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	if cluster.Spec.UserInterface == nil || cluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; there is nothing to do.
+		return nil
+	}
+
+	// Find the pgAdmin Pod that is running and ready. When there is none,
+	// return early.
+
+	pods := &corev1.PodList{}
+	err := errors.WithStack(
+		r.Client.List(ctx, pods,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabels{
+				naming.LabelCluster: cluster.Name,
+				naming.LabelRole:    naming.RolePGAdmin,
+			}))
+	if err != nil {
+		return err
+	}
+
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pods.Items[i].Status.ContainerStatuses {
+			if status.Name == naming.ContainerPGAdmin &&
+				status.State.Running != nil && status.Ready {
+				pod = &pods.Items[i]
+			}
+		}
+	}
+	if pod == nil {
+		return nil
+	}
+
+	// Read the password of every PostgreSQL user from their Secret. Skip the
+	// deprecated default Secret; it has been copied to the current one.
+
+	secrets := &corev1.SecretList{}
+	selector, err := naming.AsSelector(naming.ClusterPostgresUsers(cluster.Name))
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, secrets,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
+	if err != nil {
+		return err
+	}
+
+	users := []v1beta1.PostgresUserSpec{}
+	passwords := make(map[string]string, len(secrets.Items))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		username := secret.Labels[naming.LabelPostgresUser]
+
+		if secret.Name != naming.PostgresUserSecret(cluster, username).Name {
+			continue
+		}
+
+		user := v1beta1.PostgresUserSpec{Name: v1beta1.PostgresIdentifier(username)}
+		if database := string(secret.Data["dbname"]); database != "" {
+			user.Databases = []v1beta1.PostgresIdentifier{v1beta1.PostgresIdentifier(database)}
+		}
+		users = append(users, user)
+		passwords[username] = string(secret.Data["password"])
+	}
+
+	// The list above is in no particular order. Sort the users so that the
+	// revision below is deterministic.
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	write := func(ctx context.Context, exec pgadmin.Executor) error {
+		return pgadmin.WriteUsersInPGAdmin(ctx, cluster, exec, users, passwords)
+	}
+
+	// Calculate a hash of the commands that should be executed in pgAdmin.
+
+	revision, err := safeHash32(func(hasher io.Writer) error {
+		// Discard log messages about executing commands.
+		return write(logging.NewContext(ctx, logging.Discard()), func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			_, err := fmt.Fprint(hasher, command)
+			if err == nil && stdin != nil {
+				_, err = io.Copy(hasher, stdin)
+			}
+			return err
+		})
+	})
+
+	if err == nil && revision == cluster.Status.UserInterface.PGAdmin.UsersRevision {
+		// The necessary commands have already been executed; there's nothing
+		// more to do.
+		return nil
+	}
+
+	// Execute the necessary commands and record their hash in cluster.Status.
+	// Include the hash in any log messages.
+
+	if err == nil {
+		log := logging.FromContext(ctx).WithValues("revision", revision)
+		ctx := logging.NewContext(ctx, log.WithValues("pod", pod.Name))
+
+		err = errors.WithStack(write(ctx, func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return r.PodExec(pod.Namespace, pod.Name, naming.ContainerPGAdmin,
+				stdin, stdout, stderr, command...)
+		}))
+	}
+	if err == nil {
+		cluster.Status.UserInterface.PGAdmin.UsersRevision = revision
+	}
+
+	return err
+}
@@ -0,0 +1,242 @@
+// +build envtest
+
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcilePGAdmin(t *testing.T) {
+	ctx := context.Background()
+	env, cc, _ := setupTestEnv(t, ControllerName)
+	t.Cleanup(func() { teardownTestEnv(t, env) })
+
+	ns := &corev1.Namespace{}
+	ns.GenerateName = "postgres-operator-test-"
+	ns.Labels = labels.Set{"postgres-operator-test": ""}
+	assert.NilError(t, cc.Create(ctx, ns))
+	t.Cleanup(func() { assert.Check(t, cc.Delete(ctx, ns)) })
+
+	reconciler := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name, cluster.UID = ns.Name, "hippo", types.UID("abc")
+	cluster.Spec.Metadata = &v1beta1.Metadata{Labels: map[string]string{"global": "label"}}
+	cluster.Spec.UserInterface = &v1beta1.PostgresUserInterfaceSpec{
+		PGAdmin: &v1beta1.PGAdminPodSpec{
+			Metadata: &v1beta1.Metadata{Labels: map[string]string{"pgadmin": "label"}},
+			Image:    "example.com/crunchy-pgadmin4:test",
+			DataVolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+				},
+			},
+		},
+	}
+	cluster.Default()
+
+	assert.NilError(t, reconciler.reconcilePGAdmin(ctx, cluster))
+
+	key := naming.AsObjectKey(naming.ClusterPGAdmin(cluster))
+	expectedLabels := map[string]string{
+		"global":            "label",
+		"pgadmin":           "label",
+		naming.LabelCluster: "hippo",
+		naming.LabelRole:    naming.RolePGAdmin,
+	}
+
+	configmap := &corev1.ConfigMap{}
+	assert.NilError(t, cc.Get(ctx, key, configmap))
+	assert.DeepEqual(t, configmap.Labels, expectedLabels)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	assert.NilError(t, cc.Get(ctx, key, pvc))
+	assert.DeepEqual(t, pvc.Labels, expectedLabels)
+	assert.DeepEqual(t, pvc.Spec.AccessModes,
+		[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce})
+
+	service := &corev1.Service{}
+	assert.NilError(t, cc.Get(ctx, key, service))
+	assert.DeepEqual(t, service.Labels, expectedLabels)
+	assert.DeepEqual(t, service.Spec.Selector, map[string]string{
+		naming.LabelCluster: "hippo",
+		naming.LabelRole:    naming.RolePGAdmin,
+	})
+	assert.Equal(t, len(service.Spec.Ports), 1)
+	assert.Equal(t, service.Spec.Ports[0].Port, int32(5050))
+	assert.Equal(t, service.Spec.Ports[0].TargetPort, intstr.FromString(naming.PortPGAdmin))
+
+	sts := &appsv1.StatefulSet{}
+	assert.NilError(t, cc.Get(ctx, key, sts))
+	assert.DeepEqual(t, sts.Labels, expectedLabels)
+	assert.DeepEqual(t, sts.Spec.Template.Labels, expectedLabels)
+	assert.Equal(t, *sts.Spec.Replicas, int32(1))
+	assert.Equal(t, sts.Spec.ServiceName, naming.ClusterPodService(cluster).Name)
+	assert.Assert(t, metav1.IsControlledBy(sts, cluster))
+	assert.Assert(t, cluster.Status.UserInterface != nil)
+
+	t.Run("Shutdown", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Shutdown = initialize.Bool(true)
+
+		assert.NilError(t, reconciler.reconcilePGAdmin(ctx, cluster))
+		assert.NilError(t, cc.Get(ctx, key, sts))
+		assert.Equal(t, *sts.Spec.Replicas, int32(0))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.UserInterface = nil
+
+		assert.NilError(t, reconciler.reconcilePGAdmin(ctx, cluster))
+		assert.Assert(t, cluster.Status.UserInterface == nil)
+
+		for _, object := range []client.Object{
+			&corev1.ConfigMap{}, &corev1.Service{}, &appsv1.StatefulSet{},
+		} {
+			err := cc.Get(ctx, key, object)
+			assert.Assert(t, apierrors.IsNotFound(err), "expected %T to be deleted, got %v", object, err)
+		}
+
+		// The volume is kept until the Pod using it is gone.
+		// - https://docs.k8s.io/concepts/storage/persistent-volumes/#storage-object-in-use-protection
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := cc.Get(ctx, key, pvc)
+		assert.Assert(t, apierrors.IsNotFound(err) || pvc.DeletionTimestamp != nil,
+			"expected the PVC to be deleted, got %v", err)
+
+		// Nothing happens when pgAdmin is disabled again.
+		assert.NilError(t, reconciler.reconcilePGAdmin(ctx, cluster))
+	})
+
+	t.Run("NotControlled", func(t *testing.T) {
+		other := &corev1.ConfigMap{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+		assert.NilError(t, cc.Create(ctx, other))
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.UserInterface = nil
+
+		assert.NilError(t, reconciler.reconcilePGAdmin(ctx, cluster))
+		assert.NilError(t, cc.Get(ctx, key, other),
+			"expected a ConfigMap that is not controlled by the cluster to be kept")
+	})
+}
+
+func TestReconcilePGAdminUsers(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.UserInterface = &v1beta1.PostgresUserInterfaceSpec{PGAdmin: &v1beta1.PGAdminPodSpec{}}
+	cluster.Default()
+	cluster.Status.UserInterface = &v1beta1.PostgresUserInterfaceStatus{}
+
+	pod := &corev1.Pod{}
+	pod.Namespace, pod.Name = "ns1", "hippo-pgadmin-0"
+	pod.Labels = map[string]string{
+		naming.LabelCluster: "hippo",
+		naming.LabelRole:    naming.RolePGAdmin,
+	}
+
+	user := &corev1.Secret{ObjectMeta: naming.PostgresUserSecret(cluster, "hippo")}
+	user.Labels = map[string]string{
+		naming.LabelCluster:      "hippo",
+		naming.LabelPostgresUser: "hippo",
+	}
+	user.Data = map[string][]byte{"password": []byte("first"), "dbname": []byte("zoo")}
+
+	// The deprecated default Secret is skipped.
+	deprecated := &corev1.Secret{}
+	deprecated.Namespace, deprecated.Name = "ns1", "hippo-pguser"
+	deprecated.Labels = user.Labels
+	deprecated.Data = map[string][]byte{"password": []byte("deprecated")}
+
+	var calls []string
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithObjects(pod, user, deprecated).Build(),
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, _, _ io.Writer, _ ...string,
+		) error {
+			assert.Equal(t, namespace, "ns1")
+			assert.Equal(t, pod, "hippo-pgadmin-0")
+			assert.Equal(t, container, naming.ContainerPGAdmin)
+
+			b, err := ioutil.ReadAll(stdin)
+			calls = append(calls, string(b))
+			return err
+		},
+	}
+
+	t.Run("NotRunning", func(t *testing.T) {
+		assert.NilError(t, reconciler.reconcilePGAdminUsers(ctx, cluster))
+		assert.Equal(t, len(calls), 0, "expected nothing to happen without a ready Pod")
+		assert.Equal(t, cluster.Status.UserInterface.PGAdmin.UsersRevision, "")
+	})
+
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: naming.ContainerPGAdmin, Ready: true,
+		State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+	}}
+	assert.NilError(t, reconciler.Client.Update(ctx, pod))
+
+	t.Run("Running", func(t *testing.T) {
+		assert.NilError(t, reconciler.reconcilePGAdminUsers(ctx, cluster))
+		assert.Assert(t, len(calls) > 0)
+		assert.Assert(t, strings.Contains(strings.Join(calls, ""), `"password":"first"`))
+		assert.Assert(t, !strings.Contains(strings.Join(calls, ""), "deprecated"))
+
+		revision := cluster.Status.UserInterface.PGAdmin.UsersRevision
+		assert.Assert(t, revision != "")
+
+		// Nothing is executed when the users have not changed.
+		calls = nil
+		assert.NilError(t, reconciler.reconcilePGAdminUsers(ctx, cluster))
+		assert.Equal(t, len(calls), 0)
+
+		user.Data["password"] = []byte("second")
+		assert.NilError(t, reconciler.Client.Update(ctx, user))
+
+		assert.NilError(t, reconciler.reconcilePGAdminUsers(ctx, cluster))
+		assert.Assert(t, strings.Contains(strings.Join(calls, ""), `"password":"second"`))
+		assert.Assert(t, cluster.Status.UserInterface.PGAdmin.UsersRevision != revision)
+	})
+}
@@ -30,6 +30,7 @@ import (
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -111,6 +112,12 @@ const (
 	incremental  = "incr"
 )
 
+// scheduled Job types that are not backups
+const (
+	verify      = "verify"
+	restoreTest = "restore-test"
+)
+
 // regexRepoIndex is the regex used to obtain the repo index from a pgBackRest repo name
 var regexRepoIndex = regexp.MustCompile(`\d+`)
 
@@ -162,6 +169,27 @@ func (r *Reconciler) applyRepoVolumeIntent(ctx context.Context,
 		return nil, errors.WithStack(err)
 	}
 
+	// a repository volume adopted from the data source has a name of its own
+	adopted := naming.AdoptedVolume(postgresCluster, repo.ObjectMeta).Name
+	if adopted != repo.Name {
+		repo.Name = adopted
+		if err := r.keepAdoptedVolumeSpec(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+
+	// keep the parts of an existing repository volume that cannot change, including the
+	// storage request of a volume that grew automatically
+	var grown bool
+	for _, repoSpec := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		if repoSpec.Name == repoName && repoSpec.Volume != nil && repoSpec.Volume.AutoGrow != nil {
+			grown = true
+		}
+	}
+	if _, err := r.keepVolumeSpec(ctx, repo, grown); err != nil {
+		return nil, err
+	}
+
 	if err := r.apply(ctx, repo); err != nil {
 		return nil, r.handlePersistentVolumeClaimError(postgresCluster,
 			errors.WithStack(err))
@@ -369,10 +397,13 @@ func backupScheduleFound(repo v1beta1.PGBackRestRepo, backupType string) bool {
 			return repo.BackupSchedules.Differential != nil
 		case incremental:
 			return repo.BackupSchedules.Incremental != nil
-		default:
-			return false
+		case verify:
+			return repo.BackupSchedules.Verify != nil
 		}
 	}
+	if backupType == restoreTest {
+		return repo.RestoreTest != nil
+	}
 	return false
 }
 
@@ -506,6 +537,12 @@ func (r *Reconciler) setScheduledJobStatus(ctx context.Context,
 		postgresCluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{}
 	}
 	postgresCluster.Status.PGBackRest.ScheduledBackups = scheduledStatus
+
+	// record the most recent restore test of each repo that finished
+	for i := range postgresCluster.Status.PGBackRest.Repos {
+		r.setRestoreTestStatus(postgresCluster,
+			&postgresCluster.Status.PGBackRest.Repos[i], jobList.Items)
+	}
 }
 
 // generateRepoHostIntent creates and populates StatefulSet with the PostgresCluster's full intent
@@ -644,6 +681,15 @@ func (r *Reconciler) generateRepoVolumeIntent(postgresCluster *v1beta1.PostgresC
 func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster, selector,
 	containerName, repoName, serviceAccountName, configName string,
 	labels, annotations map[string]string, opts ...string) (*batchv1.JobSpec, error) {
+	return generatePGBackRestJobSpecIntent(postgresCluster, "backup", selector, containerName,
+		repoName, serviceAccountName, configName, labels, annotations, opts...)
+}
+
+// generatePGBackRestJobSpecIntent generates a JobSpec that runs the pgBackRest command in the
+// container selected by selector, e.g. "backup" or "verify", for the repo named repoName.
+func generatePGBackRestJobSpecIntent(postgresCluster *v1beta1.PostgresCluster, command,
+	selector, containerName, repoName, serviceAccountName, configName string,
+	labels, annotations map[string]string, opts ...string) (*batchv1.JobSpec, error) {
 
 	repoIndex := regexRepoIndex.FindString(repoName)
 	cmdOpts := []string{
@@ -659,7 +705,7 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster, selec
 				Containers: []v1.Container{{
 					Command: []string{"/opt/crunchy/bin/pgbackrest"},
 					Env: []v1.EnvVar{
-						{Name: "COMMAND", Value: "backup"},
+						{Name: "COMMAND", Value: command},
 						{Name: "COMMAND_OPTS", Value: strings.Join(cmdOpts, " ")},
 						{Name: "COMPARE_HASH", Value: "true"},
 						{Name: "CONTAINER", Value: containerName},
@@ -858,7 +904,7 @@ func (r *Reconciler) prepareForRestore(ctx context.Context,
 		if !clusterRunning {
 			clusterRunning, _ = instance.IsRunning(naming.ContainerDatabase)
 		}
-		if instance.Runner != nil {
+		if instance.Runner != nil && instanceFailoverCandidate(instance) {
 			runners = append(runners, instance.Runner)
 		}
 		if isPrimary, _ := instance.IsPrimary(); isPrimary {
@@ -873,6 +919,14 @@ func (r *Reconciler) prepareForRestore(ctx context.Context,
 	// runner we find.  If no runner can be identified, then a new instance name is
 	// generated, which means a non-delta restore will occur into an empty data volume (note that
 	// a new name/empty volume is always used when the restore is to bootstrap a new cluster).
+	// Patroni only starts a cluster on an instance that can become primary, so runners and
+	// instance sets that cannot are skipped.
+	startupSet := -1
+	for i := range cluster.Spec.InstanceSets {
+		if startupSet < 0 && cluster.Spec.InstanceSets[i].Patroni.FailoverCandidate() {
+			startupSet = i
+		}
+	}
 	if cluster.Status.StartupInstance == "" {
 		if primary != nil {
 			cluster.Status.StartupInstance = primary.Name
@@ -881,12 +935,12 @@ func (r *Reconciler) prepareForRestore(ctx context.Context,
 			cluster.Status.StartupInstance = runners[0].GetName()
 			cluster.Status.StartupInstanceSet =
 				runners[0].GetLabels()[naming.LabelInstanceSet]
-		} else if len(cluster.Spec.InstanceSets) > 0 {
+		} else if startupSet >= 0 {
 			// Generate a hash that will be used make sure that the startup
 			// instance is named consistently
 			cluster.Status.StartupInstance = naming.GenerateStartupInstance(cluster,
-				&cluster.Spec.InstanceSets[0]).Name
-			cluster.Status.StartupInstanceSet = cluster.Spec.InstanceSets[0].Name
+				&cluster.Spec.InstanceSets[startupSet]).Name
+			cluster.Status.StartupInstanceSet = cluster.Spec.InstanceSets[startupSet].Name
 		} else {
 			return errors.New("unable to determine startup instance for restore")
 		}
@@ -928,7 +982,7 @@ func (r *Reconciler) prepareForRestore(ctx context.Context,
 			Message:            "Restoring cluster in-place",
 		})
 		// the cluster is no longer bootstrapped
-		cluster.Status.Patroni = nil
+		resetPatroniStatus(cluster)
 		// the restore will change the contents of the database, so the pgbouncer and exporter hashes
 		// are no longer valid
 		cluster.Status.Proxy.PGBouncer.PostgreSQLRevision = ""
@@ -1006,7 +1060,7 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 	var foundTarget, foundTargetAction bool
 	for _, opt := range options {
 		switch {
-		case strings.Contains(opt, "--target"):
+		case strings.Contains(opt, "--target"), strings.Contains(opt, "--type=immediate"):
 			foundTarget = true
 		case strings.Contains(opt, "--target-action"):
 			foundTargetAction = true
@@ -1029,6 +1083,34 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 	// to do any escaping or use eval.
 	cmd := pgbackrest.RestoreCommand(pgdata, strings.Join(opts, " "))
 
+	// A data volume cloned from a VolumeSnapshot already has its files. Only the WAL
+	// that follows the snapshot is fetched from the repository.
+	if dataSource.VolumeSnapshotName != "" {
+		source := pgdataVolume.Spec.DataSource
+		if source == nil || source.Kind != volumeSnapshotGVK.Kind ||
+			source.Name != dataSource.VolumeSnapshotName {
+			r.Recorder.Eventf(cluster, v1.EventTypeWarning, "InvalidDataSource",
+				"PostgreSQL data volume %q was not created from VolumeSnapshot %q",
+				pgdataVolume.GetName(), dataSource.VolumeSnapshotName)
+			return nil
+		}
+
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		if err := r.Client.Get(ctx, client.ObjectKey{
+			Namespace: cluster.GetNamespace(), Name: dataSource.VolumeSnapshotName,
+		}, snapshot); err != nil {
+			return errors.WithStack(err)
+		}
+
+		cmd = pgbackrest.SnapshotRestoreCommand(pgdata, cluster.Spec.PostgresVersion,
+			snapshot.GetAnnotations()[naming.VolumeSnapshotBackupLabel],
+			strings.Join([]string{
+				"--stanza=" + pgbackrest.DefaultStanzaName,
+				"--repo=" + regexRepoIndex.FindString(repoName),
+			}, " "))
+	}
+
 	// create the volume resources required for the postgres data directory
 	dataVolumeMount := postgres.DataVolumeMount()
 	dataVolume := v1.Volume{
@@ -1202,6 +1284,12 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		meta.RemoveStatusCondition(&postgresCluster.Status.Conditions, ConditionRepoHostReady)
 	}
 
+	// reconcile the PodDisruptionBudget of the pgbackrest repository host
+	if err := r.reconcileRepoHostPodDisruptionBudget(ctx, postgresCluster); err != nil {
+		log.Error(err, "unable to reconcile pgBackRest repo host PodDisruptionBudget")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	// calculate hashes for the external repository configurations in the spec (e.g. for Azure,
 	// GCS and/or S3 repositories) as needed to properly detect changes to external repository
 	// configuration (and then execute stanza create commands accordingly)
@@ -1218,6 +1306,12 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
 	}
 
+	// reconcile the cipher options of encrypted repos before the configuration that uses them
+	if err := r.reconcilePGBackRestCipherSecret(ctx, postgresCluster, configHash); err != nil {
+		log.Error(err, "unable to reconcile pgBackRest cipher Secret")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
 	// gather instance names and reconcile all pgbackrest configuration and secrets
 	instanceNames := []string{}
 	for _, instance := range instances.forCluster {
@@ -1291,6 +1385,21 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
 	}
 
+	// Reconcile the backups requested using PostgresBackup objects
+	if err := r.reconcilePostgresBackups(ctx, postgresCluster, instances, sa); err != nil {
+		log.Error(err, "unable to reconcile PostgresBackups")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
+	// Read the backups in each repository into the status. Backups are taken without
+	// notifying the reconciler, so requeue to read them again later.
+	next, err := r.reconcileBackupCatalog(ctx, postgresCluster)
+	if err != nil {
+		log.Error(err, "unable to read pgBackRest backup info")
+		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: 10 * time.Second})
+	}
+	result = updateReconcileResult(result, reconcile.Result{RequeueAfter: next})
+
 	return result, nil
 }
 
@@ -1463,6 +1572,16 @@ func (r *Reconciler) reconcilePostgresClusterDataSource(ctx context.Context,
 		Name:      instanceName,
 		Namespace: cluster.GetNamespace(),
 	}}
+	// Clone the PGDATA volume from a VolumeSnapshot when one is specified
+	if dataSource.VolumeSnapshotName != "" {
+		instanceSet = instanceSet.DeepCopy()
+		instanceSet.DataVolumeClaimSpec.DataSource = &v1.TypedLocalObjectReference{
+			APIGroup: initialize.String(volumeSnapshotGVK.Group),
+			Kind:     volumeSnapshotGVK.Kind,
+			Name:     dataSource.VolumeSnapshotName,
+		}
+	}
+
 	// Reconcile the PGDATA and WAL volumes for the restore
 	pgdata, err := r.reconcilePostgresDataVolume(ctx, cluster, instanceSet, fakeSTS)
 	if err != nil {
@@ -1534,6 +1653,26 @@ func (r *Reconciler) copyRestoreConfiguration(ctx context.Context,
 		return errors.WithStack(err)
 	}
 	restoreSSHConfig.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+
+	// copy the cipher options of any encrypted repos in the source cluster
+	sourceCipher := &v1.Secret{}
+	if err := r.Client.Get(ctx,
+		naming.AsObjectKey(naming.PGBackRestCipherSecret(origSourceCluster)),
+		sourceCipher); client.IgnoreNotFound(err) != nil {
+		return errors.WithStack(err)
+	} else if err == nil {
+		restoreCipher := &v1.Secret{
+			ObjectMeta: naming.PGBackRestCipherSecret(sourceCluster),
+			Data:       sourceCipher.Data,
+		}
+		restoreCipher.Labels = metadata.Labels
+		restoreCipher.Annotations = metadata.Annotations
+		restoreCipher.OwnerReferences = restoreSSHConfig.OwnerReferences
+		restoreCipher.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+		if err := r.apply(ctx, restoreCipher); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	// Create metadata that can be used to override metadata (labels, annotations and ownership
 	// refs) in pgBackRest configuration resources.  This allows us to copy resources from
 	// another cluster, but ensure pertinent metadata details are set according to the cluster
@@ -1733,11 +1872,9 @@ func (r *Reconciler) reconcileDedicatedRepoHost(ctx context.Context,
 	}()
 	var isCreate bool
 	if len(repoResources.hosts) == 0 {
-		name := fmt.Sprintf("%s-%s", postgresCluster.GetName(), "repo-host")
 		repoResources.hosts = append(repoResources.hosts, &appsv1.StatefulSet{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: name,
-			}})
+			ObjectMeta: naming.PGBackRestRepoHost(postgresCluster),
+		})
 		isCreate = true
 	} else {
 		sort.Slice(repoResources.hosts, func(i, j int) bool {
@@ -2219,6 +2356,24 @@ func (r *Reconciler) reconcileRepos(ctx context.Context,
 		getRepoVolumeStatus(postgresCluster.Status.PGBackRest.Repos, repoVols, extConfigHashes,
 			replicaCreateRepoName)
 
+	// report the retention policy of each repo, whether it is typed or in global, and forget
+	// the restore tests of repos that are no longer tested
+	for i := range postgresCluster.Status.PGBackRest.Repos {
+		status := &postgresCluster.Status.PGBackRest.Repos[i]
+		status.Retention = nil
+		tested := false
+		for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+			if repo.Name == status.Name {
+				status.Retention = pgbackrest.RepoRetention(repo,
+					postgresCluster.Spec.Backups.PGBackRest.Global)
+				tested = repo.RestoreTest != nil
+			}
+		}
+		if !tested {
+			status.RestoreTest = nil
+		}
+	}
+
 	if len(errors) > 0 {
 		return "", utilerrors.NewAggregate(errors)
 	}
@@ -2335,7 +2490,12 @@ func (r *Reconciler) reconcileStanzaCreate(ctx context.Context,
 		return r.PodExec(postgresCluster.GetNamespace(), pods.Items[0].GetName(), containerName,
 			stdin, stdout, stderr, command...)
 	}
-	configHashMismatch, err := pgbackrest.Executor(exec).StanzaCreate(ctx, configHash)
+	// the cipher options of encrypted repos are verified using the hash stored alongside them
+	cipherHash := ""
+	if pgbackrest.CipherEnabled(postgresCluster) {
+		cipherHash = configHash
+	}
+	configHashMismatch, err := pgbackrest.Executor(exec).StanzaCreate(ctx, configHash, cipherHash)
 	if err != nil {
 		// record and log any errors resulting from running the stanza-create command
 		r.Recorder.Event(postgresCluster, v1.EventTypeWarning, EventUnableToCreateStanzas,
@@ -2400,6 +2560,41 @@ func getPGBackRestExecSelector(
 	return podSelector, containerName, nil
 }
 
+// reconcileRepoHostPodDisruptionBudget reconciles the PodDisruptionBudget of the pgBackRest
+// dedicated repository host. Backups run in the repository host, so it is protected from
+// voluntary disruptions, such as node drains, while a backup is in progress.
+func (r *Reconciler) reconcileRepoHostPodDisruptionBudget(ctx context.Context,
+	postgresCluster *v1beta1.PostgresCluster) error {
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: naming.PGBackRestRepoHost(postgresCluster),
+	}
+	pdb.Annotations = naming.Merge(
+		postgresCluster.Spec.Metadata.GetAnnotationsOrNil(),
+		postgresCluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+	pdb.Labels = naming.Merge(
+		postgresCluster.Spec.Metadata.GetLabelsOrNil(),
+		postgresCluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestDedicatedLabels(postgresCluster.GetName()),
+	)
+	pdb.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: naming.PGBackRestDedicatedLabels(postgresCluster.GetName()),
+	}
+
+	var minAvailable int32
+	if pgbackrest.DedicatedRepoHostEnabled(postgresCluster) {
+		running, err := r.pgBackRestBackupRunning(ctx, postgresCluster)
+		if err != nil {
+			return err
+		}
+		if running {
+			minAvailable = 1
+		}
+	}
+
+	return r.reconcilePodDisruptionBudget(ctx, postgresCluster, pdb, minAvailable)
+}
+
 // getRepoHostStatus is responsible for returning the pgBackRest status for the provided pgBackRest
 // repository host
 func getRepoHostStatus(repoHost *appsv1.StatefulSet) *v1beta1.RepoHostStatus {
@@ -2547,6 +2742,20 @@ func (r *Reconciler) reconcileScheduledBackups(
 					requeue = true
 				}
 			}
+			if repo.BackupSchedules.Verify != nil {
+				if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+					verify, repo.BackupSchedules.Verify, instances, sa); err != nil {
+					log.Error(err, "unable to reconcile verify for "+repo.Name)
+					requeue = true
+				}
+			}
+		}
+		if repo.RestoreTest != nil {
+			if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+				restoreTest, &repo.RestoreTest.Schedule, instances, sa); err != nil {
+				log.Error(err, "unable to reconcile restore test for "+repo.Name)
+				requeue = true
+			}
 		}
 	}
 	return requeue
@@ -2648,8 +2857,23 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 		configName = pgbackrest.CMRepoKey
 	}
 
-	jobSpec, err := generateBackupJobSpecIntent(cluster, selector.String(), containerName,
-		repo.Name, serviceAccount.GetName(), configName, labels, annotations, backupOpts...)
+	var jobSpec *batchv1.JobSpec
+	switch backupType {
+	case verify:
+		jobSpec, err = generatePGBackRestJobSpecIntent(cluster, verify, selector.String(),
+			containerName, repo.Name, serviceAccount.GetName(), configName, labels, annotations)
+	case restoreTest:
+		// the restore test runs in a Pod of its own rather than exec'ing into another
+		var volume *v1.PersistentVolumeClaim
+		volume, err = r.reconcileRestoreTestVolume(ctx, cluster, repo, labels, annotations)
+		if err == nil {
+			jobSpec, err = r.generateRestoreTestJobSpecIntent(cluster, repo, volume,
+				primaryInstance+".conf", labels, annotations)
+		}
+	default:
+		jobSpec, err = generateBackupJobSpecIntent(cluster, selector.String(), containerName,
+			repo.Name, serviceAccount.GetName(), configName, labels, annotations, backupOpts...)
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -2675,6 +2899,11 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 		},
 	}
 
+	// A restore test reuses its volume, so only one may run at a time.
+	if backupType == restoreTest {
+		pgBackRestCronJob.Spec.ConcurrencyPolicy = batchv1beta1.ForbidConcurrent
+	}
+
 	// Set the image pull secrets, if any exist.
 	// This is set here rather than using the service account due to the lack
 	// of propagation to existing pods when the CRD is updated:
@@ -537,11 +537,19 @@ func TestReconcilePGBackRest(t *testing.T) {
 				Full:         &testCronSchedule,
 				Differential: &testCronSchedule,
 				Incremental:  &testCronSchedule,
+				Verify:       &testCronSchedule,
 			}}
 
 		assert.Assert(t, backupScheduleFound(testrepo, "full"))
 		assert.Assert(t, backupScheduleFound(testrepo, "diff"))
 		assert.Assert(t, backupScheduleFound(testrepo, "incr"))
+		assert.Assert(t, backupScheduleFound(testrepo, "verify"))
+
+		testrepo = v1beta1.PGBackRestRepo{
+			Name:        "repo1",
+			RestoreTest: &v1beta1.PGBackRestRestoreTest{Schedule: testCronSchedule},
+		}
+		assert.Assert(t, backupScheduleFound(testrepo, "restore-test"))
 
 	})
 
@@ -551,6 +559,7 @@ func TestReconcilePGBackRest(t *testing.T) {
 
 		noscheduletestrepo := v1beta1.PGBackRestRepo{Name: "repo1"}
 		assert.Assert(t, !backupScheduleFound(noscheduletestrepo, "full"))
+		assert.Assert(t, !backupScheduleFound(noscheduletestrepo, "restore-test"))
 
 	})
 
@@ -2914,7 +2923,13 @@ func TestPrepareForRestore(t *testing.T) {
 				}
 				clusterUID := clusterName
 				cluster := fakePostgresCluster(clusterName, namespace, clusterUID, dedicated)
-				cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "abcde12345"}
+				cluster.Status.Patroni = &v1beta1.PatroniStatus{
+					SystemIdentifier:    "abcde12345",
+					Timeline:            3,
+					SynchronousStandbys: []string{"hippo-00-abcd"},
+					Version:             "3.0.2",
+					Switchover:          &v1beta1.PatroniSwitchoverStatus{ID: "one"},
+				}
 				cluster.Status.Proxy.PGBouncer.PostgreSQLRevision = "abcde12345"
 				cluster.Status.Monitoring.ExporterConfiguration = "abcde12345"
 				meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
@@ -2988,7 +3003,10 @@ func TestPrepareForRestore(t *testing.T) {
 						assert.Equal(t, tc.result.expectedClusterCondition.Message, condition.Message)
 					}
 					if tc.result.expectedClusterCondition.Reason == ReasonReadyForRestore {
-						assert.Assert(t, cluster.Status.Patroni == nil)
+						// Only the record of the switchover remains.
+						assert.DeepEqual(t, cluster.Status.Patroni, &v1beta1.PatroniStatus{
+							Switchover: &v1beta1.PatroniSwitchoverStatus{ID: "one"},
+						})
 						assert.Assert(t, cluster.Status.Proxy.PGBouncer.PostgreSQLRevision == "")
 						assert.Assert(t, cluster.Status.Monitoring.ExporterConfiguration == "")
 						assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -58,6 +59,9 @@ func (r *Reconciler) reconcilePGBouncer(
 	if err == nil {
 		err = r.reconcilePGBouncerDeployment(ctx, cluster, primaryCertificate, configmap, secret)
 	}
+	if err == nil {
+		err = r.reconcilePGBouncerPodDisruptionBudget(ctx, cluster)
+	}
 	if err == nil {
 		err = r.reconcilePGBouncerInPostgreSQL(ctx, cluster, instances, secret)
 	}
@@ -265,12 +269,19 @@ func (r *Reconciler) reconcilePGBouncerService(
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
 
+	var serviceMetadata *v1beta1.Metadata
+	if cluster.Spec.Proxy.PGBouncer.Service != nil {
+		serviceMetadata = cluster.Spec.Proxy.PGBouncer.Service.Metadata
+	}
+
 	service.Annotations = naming.Merge(
 		cluster.Spec.Metadata.GetAnnotationsOrNil(),
-		cluster.Spec.Proxy.PGBouncer.Metadata.GetAnnotationsOrNil())
+		cluster.Spec.Proxy.PGBouncer.Metadata.GetAnnotationsOrNil(),
+		serviceMetadata.GetAnnotationsOrNil())
 	service.Labels = naming.Merge(
 		cluster.Spec.Metadata.GetLabelsOrNil(),
 		cluster.Spec.Proxy.PGBouncer.Metadata.GetLabelsOrNil(),
+		serviceMetadata.GetLabelsOrNil(),
 		map[string]string{
 			naming.LabelCluster: cluster.Name,
 			naming.LabelRole:    naming.RolePGBouncer,
@@ -279,7 +290,6 @@ func (r *Reconciler) reconcilePGBouncerService(
 	// Allocate an IP address and let Kubernetes manage the Endpoints by selecting
 	// Pods with the PgBouncer role.
 	// - https://docs.k8s.io/concepts/services-networking/service/#defining-a-service
-	service.Spec.Type = corev1.ServiceTypeClusterIP
 	service.Spec.Selector = map[string]string{
 		naming.LabelCluster: cluster.Name,
 		naming.LabelRole:    naming.RolePGBouncer,
@@ -294,6 +304,7 @@ func (r *Reconciler) reconcilePGBouncerService(
 		Protocol:   corev1.ProtocolTCP,
 		TargetPort: intstr.FromString(naming.PortPGBouncer),
 	}}
+	setServiceSpec(service, cluster.Spec.Proxy.PGBouncer.Service)
 
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, service))
@@ -302,6 +313,41 @@ func (r *Reconciler) reconcilePGBouncerService(
 	return service, err
 }
 
+// reconcilePGBouncerPodDisruptionBudget writes the PodDisruptionBudget that keeps at least one
+// PgBouncer Pod running during voluntary disruptions when there is more than one.
+func (r *Reconciler) reconcilePGBouncerPodDisruptionBudget(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	pdb := &policyv1beta1.PodDisruptionBudget{ObjectMeta: naming.ClusterPGBouncer(cluster)}
+
+	var minAvailable int32
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		pdb.Annotations = naming.Merge(
+			cluster.Spec.Metadata.GetAnnotationsOrNil(),
+			cluster.Spec.Proxy.PGBouncer.Metadata.GetAnnotationsOrNil())
+		pdb.Labels = naming.Merge(
+			cluster.Spec.Metadata.GetLabelsOrNil(),
+			cluster.Spec.Proxy.PGBouncer.Metadata.GetLabelsOrNil(),
+			map[string]string{
+				naming.LabelCluster: cluster.Name,
+				naming.LabelRole:    naming.RolePGBouncer,
+			})
+		pdb.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				naming.LabelCluster: cluster.Name,
+				naming.LabelRole:    naming.RolePGBouncer,
+			},
+		}
+
+		if replicas := cluster.Spec.Proxy.PGBouncer.Replicas; replicas != nil &&
+			*replicas > 1 && (cluster.Spec.Shutdown == nil || !*cluster.Spec.Shutdown) {
+			minAvailable = 1
+		}
+	}
+
+	return r.reconcilePodDisruptionBudget(ctx, cluster, pdb, minAvailable)
+}
+
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=create;delete;patch
 
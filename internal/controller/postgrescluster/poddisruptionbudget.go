@@ -0,0 +1,167 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=create;delete;patch
+
+// reconcilePodDisruptionBudget writes pdb so that at least minAvailable of the Pods it selects
+// remain during voluntary disruptions, such as node drains. A budget that allows every Pod to
+// be disrupted has no effect, so pdb is deleted when minAvailable is less than one.
+func (r *Reconciler) reconcilePodDisruptionBudget(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	pdb *policyv1beta1.PodDisruptionBudget, minAvailable int32,
+) error {
+	pdb.SetGroupVersionKind(policyv1beta1.SchemeGroupVersion.WithKind("PodDisruptionBudget"))
+
+	if minAvailable < 1 {
+		// Delete the PodDisruptionBudget if it exists. Check the client cache
+		// first using Get.
+		key := client.ObjectKeyFromObject(pdb)
+		err := errors.WithStack(r.Client.Get(ctx, key, pdb))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, pdb))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, pdb))
+
+	min := intstr.FromInt(int(minAvailable))
+	pdb.Spec.MinAvailable = &min
+
+	if err == nil {
+		err = errors.WithStack(r.apply(ctx, pdb))
+	}
+	return err
+}
+
+// reconcileInstanceSetPodDisruptionBudgets writes a PodDisruptionBudget for every instance
+// set of cluster and deletes those of instance sets that are no longer in the spec.
+func (r *Reconciler) reconcileInstanceSetPodDisruptionBudgets(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+
+		pdb := &policyv1beta1.PodDisruptionBudget{ObjectMeta: naming.InstanceSet(cluster, set)}
+		pdb.Annotations = naming.Merge(
+			cluster.Spec.Metadata.GetAnnotationsOrNil(),
+			set.Metadata.GetAnnotationsOrNil())
+		pdb.Labels = naming.Merge(
+			cluster.Spec.Metadata.GetLabelsOrNil(),
+			set.Metadata.GetLabelsOrNil(),
+			map[string]string{
+				naming.LabelCluster:     cluster.Name,
+				naming.LabelInstanceSet: set.Name,
+			})
+
+		selector := naming.ClusterInstanceSet(cluster.Name, set.Name)
+		pdb.Spec.Selector = &selector
+
+		if err := r.reconcilePodDisruptionBudget(ctx, cluster, pdb,
+			instanceSetMinAvailable(cluster, set, instances)); err != nil {
+			return err
+		}
+	}
+
+	selector, err := naming.AsSelector(naming.ClusterInstanceSets(cluster.Name))
+	pdbs := &policyv1beta1.PodDisruptionBudgetList{}
+	if err == nil {
+		err = errors.WithStack(r.Client.List(ctx, pdbs,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+		))
+	}
+
+	for i := range pdbs.Items {
+		if err == nil &&
+			!specHasInstanceSet(cluster, pdbs.Items[i].Labels[naming.LabelInstanceSet]) {
+			err = errors.WithStack(client.IgnoreNotFound(
+				r.deleteControlled(ctx, cluster, &pdbs.Items[i])))
+		}
+	}
+
+	return err
+}
+
+// instanceSetMinAvailable returns the number of Pods in set that should remain during
+// voluntary disruptions. When set has more than one replica, at least one remains. When set
+// also contains the current primary, only one Pod at a time may be disrupted so that the
+// primary and its replicas in set are not evicted together. The primary of a set with one
+// replica is not disrupted while other instance sets have replicas that could be evicted
+// with it; it can be moved to another instance with a switchover.
+func instanceSetMinAvailable(
+	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresInstanceSetSpec,
+	instances *observedInstances,
+) int32 {
+	if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown {
+		return 0
+	}
+
+	var replicas, total int32
+	for i := range cluster.Spec.InstanceSets {
+		var n int32 = 1
+		if cluster.Spec.InstanceSets[i].Replicas != nil {
+			n = *cluster.Spec.InstanceSets[i].Replicas
+		}
+		if cluster.Spec.InstanceSets[i].Name == set.Name {
+			replicas = n
+		}
+		total += n
+	}
+
+	primary := false
+	if instances != nil {
+		for _, instance := range instances.bySet[set.Name] {
+			if isPrimary, known := instance.IsPrimary(); isPrimary && known {
+				primary = true
+			}
+		}
+	}
+
+	switch {
+	case replicas > 1 && primary:
+		return replicas - 1
+	case replicas > 1:
+		return 1
+	case replicas == 1 && primary && total > 1:
+		return 1
+	}
+	return 0
+}
+
+// specHasInstanceSet returns true when the spec of cluster has an instance set named name.
+func specHasInstanceSet(cluster *v1beta1.PostgresCluster, name string) bool {
+	for _, set := range cluster.Spec.InstanceSets {
+		if set.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestInstanceSetMinAvailable(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+		{Name: "00", Replicas: initialize.Int32(3)},
+		{Name: "01", Replicas: initialize.Int32(3)},
+		{Name: "02", Replicas: initialize.Int32(1)},
+	}
+
+	pod := func(instance, set, role string) corev1.Pod {
+		pod := corev1.Pod{}
+		pod.Labels = map[string]string{
+			naming.LabelInstance:    instance,
+			naming.LabelInstanceSet: set,
+			naming.LabelRole:        role,
+		}
+		return pod
+	}
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{
+		pod("hippo-00-aaaa", "00", naming.RolePatroniLeader),
+		pod("hippo-00-bbbb", "00", naming.RolePatroniReplica),
+		pod("hippo-01-cccc", "01", naming.RolePatroniReplica),
+	})
+
+	// Only one Pod at a time may be disrupted in the set of the primary.
+	assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[0], instances),
+		int32(2))
+
+	// At least one replica remains in other sets.
+	assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[1], instances),
+		int32(1))
+
+	// A set with one Pod does not block disruptions.
+	assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[2], instances),
+		int32(0))
+
+	// Without observations, the primary is unknown.
+	assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[0], nil),
+		int32(1))
+
+	t.Run("OneReplicaSets", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "00", Replicas: initialize.Int32(1)},
+			{Name: "01", Replicas: initialize.Int32(1)},
+		}
+		instances := newObservedInstances(cluster, nil, []corev1.Pod{
+			pod("hippo-00-aaaa", "00", naming.RolePatroniLeader),
+			pod("hippo-01-bbbb", "01", naming.RolePatroniReplica),
+		})
+
+		// The primary and its only replica are not evicted together.
+		assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[0], instances),
+			int32(1))
+		assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[1], instances),
+			int32(0))
+
+		// A cluster of one instance does not block disruptions.
+		cluster.Spec.InstanceSets = cluster.Spec.InstanceSets[:1]
+		assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[0], instances),
+			int32(0))
+	})
+
+	// Nothing is protected during shutdown.
+	cluster.Spec.Shutdown = initialize.Bool(true)
+	assert.Equal(t, instanceSetMinAvailable(cluster, &cluster.Spec.InstanceSets[0], instances),
+		int32(0))
+}
+
+func TestSpecHasInstanceSet(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+
+	assert.Assert(t, specHasInstanceSet(cluster, "00"))
+	assert.Assert(t, !specHasInstanceSet(cluster, "01"))
+	assert.Assert(t, !specHasInstanceSet(cluster, ""))
+}
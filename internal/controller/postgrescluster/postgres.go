@@ -35,6 +35,7 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
 	"github.com/crunchydata/postgres-operator/internal/util"
@@ -101,6 +102,21 @@ func (r *Reconciler) generatePostgresUserSecret(
 		}).String())
 	}
 
+	// Include values for connecting through the replica Service.
+	replicas := naming.ClusterReplicaService(cluster)
+	replicaHostname := replicas.Name + "." + replicas.Namespace + ".svc"
+
+	intent.Data["replica-host"] = []byte(replicaHostname)
+
+	if len(spec.Databases) > 0 {
+		intent.Data["replica-uri"] = []byte((&url.URL{
+			Scheme: "postgresql",
+			User:   url.UserPassword(username, string(intent.Data["password"])),
+			Host:   net.JoinHostPort(replicaHostname, port),
+			Path:   string(spec.Databases[0]),
+		}).String())
+	}
+
 	// When PgBouncer is enabled, include values for connecting through it.
 	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
 		pgBouncer := naming.ClusterPGBouncer(cluster)
@@ -452,9 +468,13 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
 	instanceSpec *v1beta1.PostgresInstanceSetSpec, instance *appsv1.StatefulSet,
 ) (*corev1.PersistentVolumeClaim, error) {
-	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.InstancePostgresDataVolume(instance)}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.AdoptedVolume(cluster,
+		naming.InstancePostgresDataVolume(instance))}
 	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
 
+	// A volume adopted from the data source has a name of its own.
+	adopted := pvc.Name != naming.InstancePostgresDataVolume(instance).Name
+
 	err := errors.WithStack(r.setControllerReference(cluster, pvc))
 
 	pvc.Annotations = naming.Merge(
@@ -473,6 +493,20 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 
 	pvc.Spec = instanceSpec.DataVolumeClaimSpec
 
+	var exists bool
+	if err == nil {
+		exists, err = r.keepVolumeSpec(ctx, pvc, instanceSpec.DataVolumeAutoGrow != nil)
+	}
+	if err == nil && adopted {
+		err = r.keepAdoptedVolumeSpec(ctx, pvc)
+	}
+
+	// The data volume of a new replica can be cloned from a snapshot so that
+	// it does not copy every file from a pgBackRest backup.
+	if err == nil && !exists && pvc.Spec.DataSource == nil && patroni.ClusterBootstrapped(cluster) {
+		pvc.Spec.DataSource = replicaVolumeSnapshot(cluster,
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage])
+	}
 	if err == nil {
 		err = r.handlePersistentVolumeClaimError(cluster,
 			errors.WithStack(r.apply(ctx, pvc)))
@@ -491,7 +525,8 @@ func (r *Reconciler) reconcilePostgresWALVolume(
 	instanceSpec *v1beta1.PostgresInstanceSetSpec, instance *appsv1.StatefulSet,
 	observed *Instance,
 ) (*corev1.PersistentVolumeClaim, error) {
-	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.InstancePostgresWALVolume(instance)}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: naming.AdoptedVolume(cluster,
+		naming.InstancePostgresWALVolume(instance))}
 	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
 
 	if instanceSpec.WALVolumeClaimSpec == nil {
@@ -540,6 +575,9 @@ func (r *Reconciler) reconcilePostgresWALVolume(
 		return pvc, err
 	}
 
+	// A volume adopted from the data source has a name of its own.
+	adopted := pvc.Name != naming.InstancePostgresWALVolume(instance).Name
+
 	err := errors.WithStack(r.setControllerReference(cluster, pvc))
 
 	pvc.Annotations = naming.Merge(
@@ -558,6 +596,12 @@ func (r *Reconciler) reconcilePostgresWALVolume(
 
 	pvc.Spec = *instanceSpec.WALVolumeClaimSpec
 
+	if err == nil {
+		_, err = r.keepVolumeSpec(ctx, pvc, instanceSpec.WALVolumeAutoGrow != nil)
+	}
+	if err == nil && adopted {
+		err = r.keepAdoptedVolumeSpec(ctx, pvc)
+	}
 	if err == nil {
 		err = r.handlePersistentVolumeClaimError(cluster,
 			errors.WithStack(r.apply(ctx, pvc)))
@@ -77,6 +77,28 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 		}
 	})
 
+	t.Run("Replicas", func(t *testing.T) {
+		secret, err := reconciler.generatePostgresUserSecret(cluster, spec, nil)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Equal(t, string(secret.Data["replica-host"]), "hippo2-replicas.ns1.svc")
+			assert.Assert(t, secret.Data["replica-uri"] == nil)
+		}
+
+		// Includes a URI when possible.
+		spec := *spec
+		spec.Databases = []v1beta1.PostgresIdentifier{"yes", "no"}
+
+		secret, err = reconciler.generatePostgresUserSecret(cluster, &spec, nil)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Assert(t, cmp.Regexp(`postgresql://some-user-name:[^@]+@hippo2-replicas.ns1.svc:9999/yes`,
+				string(secret.Data["replica-uri"])))
+		}
+	})
+
 	t.Run("Password", func(t *testing.T) {
 		// Generated when no existing Secret.
 		secret, err := reconciler.generatePostgresUserSecret(cluster, spec, nil)
@@ -231,6 +253,49 @@ volumeMode: Filesystem
 		`))
 	})
 
+	t.Run("AdoptedDataVolume", func(t *testing.T) {
+		existing := &corev1.PersistentVolumeClaim{}
+		existing.Namespace, existing.Name = ns.Name, "old-pgdata"
+		assert.NilError(t, yaml.Unmarshal([]byte(`{
+			spec: {
+				accessModes: [ReadWriteOnce],
+				resources: { requests: { storage: 1Gi } },
+				storageClassName: "storage-class-from-before",
+			},
+		}`), existing))
+		assert.NilError(t, tClient.Create(ctx, existing))
+
+		cluster := cluster.DeepCopy()
+		cluster.Status.AdoptedVolumes = map[string]string{
+			naming.InstancePostgresDataVolume(instance).Name: existing.Name,
+		}
+
+		spec := spec.DeepCopy()
+		spec.Metadata = &v1beta1.Metadata{
+			Annotations: map[string]string{"some": "annotation"},
+		}
+
+		pvc, err := reconciler.reconcilePostgresDataVolume(ctx, cluster, spec, instance)
+		assert.NilError(t, err)
+		assert.Equal(t, pvc.Name, existing.Name)
+
+		// The spec of the cluster is applied to the adopted volume, except the
+		// settings it was created with.
+		assert.Equal(t, pvc.Annotations["some"], "annotation")
+		assert.Equal(t, pvc.Labels[naming.LabelInstance], instance.Name)
+		assert.Equal(t, pvc.Labels[naming.LabelRole], "pgdata")
+
+		assert.Assert(t, marshalMatches(pvc.Spec, `
+accessModes:
+- ReadWriteOnce
+resources:
+  requests:
+    storage: 1Gi
+storageClassName: storage-class-from-before
+volumeMode: Filesystem
+		`))
+	})
+
 	t.Run("WALVolume", func(t *testing.T) {
 		observed := &Instance{}
 
@@ -0,0 +1,391 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventPostgresBackupComplete is the event reason utilized when the backup of a
+	// PostgresBackup completes successfully
+	EventPostgresBackupComplete = "BackupComplete"
+
+	// EventPostgresBackupFailed is the event reason utilized when the backup Job of a
+	// PostgresBackup fails
+	EventPostgresBackupFailed = "BackupFailed"
+)
+
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresbackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresbackups/status,verbs=patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get
+
+// reconcilePostgresBackups runs a backup Job for each PostgresBackup of cluster and records
+// the results in the status of each PostgresBackup. pgBackRest runs one backup at a time, so
+// Jobs are created one at a time in the order the PostgresBackups were created, and only when
+// no other backup Job of cluster is running.
+func (r *Reconciler) reconcilePostgresBackups(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, instances *observedInstances,
+	serviceAccount *corev1.ServiceAccount) error {
+
+	backups := &v1beta1.PostgresBackupList{}
+	if err := r.Client.List(ctx, backups,
+		client.InNamespace(cluster.GetNamespace())); err != nil {
+		return errors.WithStack(err)
+	}
+
+	sort.Slice(backups.Items, func(i, j int) bool {
+		a, b := backups.Items[i], backups.Items[j]
+		if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.Name < b.Name
+		}
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	})
+
+	// pgBackRest takes one backup of a stanza at a time. Wait for the other backups of the
+	// cluster, e.g. manual, scheduled, or replica create backups, to finish.
+	running, err := r.pgBackRestBackupRunning(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+
+		// Finished backups are kept as history and no longer reconciled.
+		if backup.Spec.PostgresCluster != cluster.GetName() || backup.Status.Finished ||
+			backup.GetDeletionTimestamp() != nil {
+			continue
+		}
+		before := backup.DeepCopy()
+
+		job := &batchv1.Job{}
+		err := r.Client.Get(ctx, naming.AsObjectKey(naming.PostgresBackupJob(backup)), job)
+		if client.IgnoreNotFound(err) != nil {
+			return errors.WithStack(err)
+		}
+		if err != nil {
+			job, err = nil, nil
+		}
+
+		if job == nil && !running {
+			job, err = r.reconcilePostgresBackupJob(ctx, cluster, backup, instances,
+				serviceAccount)
+		}
+		if err == nil && job != nil {
+			running = running || !(jobCompleted(job) || jobFailed(job))
+			err = r.observePostgresBackupJob(ctx, cluster, backup, job)
+		}
+
+		if err == nil && !equality.Semantic.DeepEqual(before.Status, backup.Status) {
+			err = errors.WithStack(r.Client.Status().Patch(
+				ctx, backup, client.MergeFrom(before), r.Owner))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=list
+
+// pgBackRestBackupRunning returns true when a Job of cluster that takes a pgBackRest backup
+// has not finished. Verifications and restore tests are skipped; they read backups that
+// already exist and do not take one.
+func (r *Reconciler) pgBackRestBackupRunning(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (bool, error) {
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(ctx, jobs, client.InNamespace(cluster.GetNamespace()),
+		client.MatchingLabelsSelector{
+			Selector: naming.PGBackRestSelector(cluster.GetName()),
+		}); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		labels := job.GetLabels()
+		backup := labels[naming.LabelPGBackRestBackup] != "" ||
+			(labels[naming.LabelPGBackRestCronJob] != "" &&
+				labels[naming.LabelPGBackRestCronJob] != verify &&
+				labels[naming.LabelPGBackRestCronJob] != restoreTest)
+
+		if backup && !(jobCompleted(job) || jobFailed(job)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;patch
+
+// reconcilePostgresBackupJob creates the backup Job for backup once cluster is able to take a
+// backup in the repo of backup. It returns nil when the Job cannot be created yet.
+func (r *Reconciler) reconcilePostgresBackupJob(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PostgresBackup,
+	instances *observedInstances, serviceAccount *corev1.ServiceAccount,
+) (*batchv1.Job, error) {
+
+	// pgBackRest connects to a PostgreSQL instance that is not in recovery to
+	// initiate a backup. Similar to "writable" but not exactly.
+	clusterWritable := false
+	for _, instance := range instances.forCluster {
+		writable, known := instance.IsWritable()
+		if writable && known {
+			clusterWritable = true
+			break
+		}
+	}
+	if !clusterWritable || serviceAccount == nil {
+		return nil, nil
+	}
+
+	// determine if the dedicated repository host is ready (if enabled) using the repo host ready
+	// condition, and return if not
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionRepoHostReady)
+		if condition == nil || condition.Status != metav1.ConditionTrue {
+			return nil, nil
+		}
+	}
+
+	// The same as manual backups, wait for the replica create backup to complete.
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReplicaCreate)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		return nil, nil
+	}
+
+	// Verify that status exists for the repo of the backup and that its stanza has been
+	// created. Subsequent events, e.g. successful stanza creation, trigger another attempt.
+	var statusFound, stanzaCreated bool
+	repoName := backup.Spec.RepoName
+	for _, repo := range cluster.Status.PGBackRest.Repos {
+		if repo.Name == repoName {
+			statusFound = true
+			stanzaCreated = repo.StanzaCreated
+		}
+	}
+	if !statusFound {
+		r.Recorder.Eventf(backup, corev1.EventTypeWarning, "InvalidBackupRepo",
+			"Unable to find status for %q.  Please ensure this repo is defined in the "+
+				"spec of PostgresCluster %q.", repoName, cluster.GetName())
+		return nil, nil
+	}
+	if !stanzaCreated {
+		r.Recorder.Eventf(backup, corev1.EventTypeWarning, "StanzaNotCreated",
+			"Stanza not created for %q", repoName)
+		return nil, nil
+	}
+
+	// The repo and type of the backup have their own fields in the spec.
+	for _, opt := range backup.Spec.Options {
+		if strings.Contains(opt, "--repo") || strings.Contains(opt, "--type") {
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidBackupOptions",
+				"Options '--repo' and '--type' are not allowed: please use the 'repoName' "+
+					"and 'type' fields instead.")
+			return nil, nil
+		}
+	}
+	backupOpts := append([]string{}, backup.Spec.Options...)
+	if backup.Spec.Type != "" {
+		backupOpts = append([]string{"--type=" + backup.Spec.Type}, backupOpts...)
+	}
+
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The primary determines the configuration file mounted to the backup Job when running
+	// without a dedicated repo host.
+	var primaryInstance string
+	for _, instance := range instances.forCluster {
+		if isPrimary, _ := instance.IsPrimary(); isPrimary {
+			primaryInstance = instance.Name
+			break
+		}
+	}
+	if primaryInstance == "" {
+		return nil, errors.WithStack(
+			errors.New("unable to find primary when reconciling PostgresBackup Job"))
+	}
+	configName := primaryInstance + ".conf"
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		configName = pgbackrest.CMRepoKey
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.PostgresBackupJob(backup)}
+	job.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestBackupJobLabels(cluster.GetName(), repoName,
+			naming.BackupPostgresBackup),
+		map[string]string{
+			naming.LabelPostgresBackup: backup.GetName(),
+		})
+	job.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+
+	spec, err := generateBackupJobSpecIntent(cluster, selector.String(), containerName,
+		repoName, serviceAccount.GetName(), configName, job.Labels, job.Annotations,
+		backupOpts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	job.Spec = *spec
+
+	// The Job belongs to the PostgresBackup so that it remains as long as the history does.
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	if err := controllerutil.SetControllerReference(backup, job,
+		r.Client.Scheme()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return job, errors.WithStack(r.apply(ctx, job))
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// observePostgresBackupJob copies the status of job into the status of backup. Once job
+// completes, it also records the backup as reported by pgBackRest.
+func (r *Reconciler) observePostgresBackupJob(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PostgresBackup, job *batchv1.Job,
+) error {
+	backup.Status.JobName = job.GetName()
+	backup.Status.StartTime = job.Status.StartTime
+	backup.Status.CompletionTime = job.Status.CompletionTime
+	backup.Status.Active = job.Status.Active
+	backup.Status.Succeeded = job.Status.Succeeded
+	backup.Status.Failed = job.Status.Failed
+
+	if jobFailed(job) {
+		backup.Status.Finished = true
+		r.Recorder.Event(backup, corev1.EventTypeWarning, EventPostgresBackupFailed,
+			"Backup Job did not complete successfully")
+		return nil
+	}
+	if !jobCompleted(job) {
+		return nil
+	}
+
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(cluster.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(pods.Items) != 1 {
+		return errors.WithStack(
+			errors.New("invalid number of Pods found when attempting to read backup info"))
+	}
+
+	exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(cluster.GetNamespace(), pods.Items[0].GetName(), containerName,
+			stdin, stdout, stderr, command...)
+	}
+	info, err := pgbackrest.Executor(exec).Info(ctx,
+		regexRepoIndex.FindString(backup.Spec.RepoName))
+	if err != nil {
+		return err
+	}
+
+	// The backup of the Job is the first one that ran while the Job did. Scheduled backups
+	// may have started since.
+	var started, completed time.Time
+	if job.Status.StartTime != nil {
+		started = job.Status.StartTime.Time
+	}
+	if job.Status.CompletionTime != nil {
+		completed = job.Status.CompletionTime.Time
+	}
+	backup.Status.Backup = postgresBackupInfo(info.Backup, started, completed)
+	backup.Status.Finished = true
+	recordLastBackupTime(cluster, backup.Status.CompletionTime)
+
+	if backup.Status.Backup == nil {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, EventPostgresBackupComplete,
+			"Backup Job completed, but pgBackRest does not report a backup taken by it")
+	} else {
+		r.Recorder.Eventf(backup, corev1.EventTypeNormal, EventPostgresBackupComplete,
+			"Backup %q completed successfully", backup.Status.Backup.Label)
+	}
+
+	return nil
+}
+
+// postgresBackupInfo returns the earliest of infos that started at or after started and stopped
+// at or before completed, or nil when there is none. A zero completed does not limit when the
+// backup stopped.
+func postgresBackupInfo(
+	infos []pgbackrest.BackupInfo, started, completed time.Time,
+) *v1beta1.PGBackRestBackupInfo {
+	var found *pgbackrest.BackupInfo
+	for i := range infos {
+		if infos[i].Timestamp.Start >= started.Unix() &&
+			(completed.IsZero() || infos[i].Timestamp.Stop <= completed.Unix()) &&
+			(found == nil || infos[i].Timestamp.Start < found.Timestamp.Start) {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		return nil
+	}
+
+	status := backupInfoStatus(*found)
+	return &status
+}
+
+// backupInfoStatus converts info as reported by pgBackRest into its API representation.
+func backupInfoStatus(info pgbackrest.BackupInfo) v1beta1.PGBackRestBackupInfo {
+	start := metav1.NewTime(time.Unix(info.Timestamp.Start, 0).UTC())
+	stop := metav1.NewTime(time.Unix(info.Timestamp.Stop, 0).UTC())
+
+	return v1beta1.PGBackRestBackupInfo{
+		Label:     info.Label,
+		Type:      info.Type,
+		Size:      info.Info.Size,
+		RepoSize:  info.Info.Repository.Size,
+		WALStart:  info.Archive.Start,
+		WALStop:   info.Archive.Stop,
+		StartTime: &start,
+		StopTime:  &stop,
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// applyRecorder records the objects that are applied rather than sending them to the
+// embedded Client. The fake client does not implement server-side apply.
+type applyRecorder struct {
+	client.Client
+	applied []client.Object
+}
+
+func (c *applyRecorder) Patch(
+	ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption,
+) error {
+	if patch.Type() == types.ApplyPatchType {
+		c.applied = append(c.applied, obj)
+		return nil
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestReconcilePostgresBackups(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo1", Volume: &v1beta1.RepoPVC{},
+	}}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionReplicaCreate, Status: metav1.ConditionTrue, Reason: "Test",
+	})
+
+	primary := &corev1.Pod{}
+	primary.Namespace, primary.Name = "ns1", "hippo-00-abcd-0"
+	primary.Labels = map[string]string{naming.LabelRole: naming.RolePatroniLeader}
+	primary.Annotations = map[string]string{"status": `{"role":"master"}`}
+	instances := &observedInstances{forCluster: []*Instance{{
+		Name: "hippo-00-abcd", Pods: []*corev1.Pod{primary},
+	}}}
+
+	serviceAccount := &corev1.ServiceAccount{}
+	serviceAccount.Name = "hippo-pgbackrest"
+
+	backup := &v1beta1.PostgresBackup{}
+	backup.Namespace, backup.Name = "ns1", "before-upgrade"
+	backup.Spec.PostgresCluster, backup.Spec.RepoName = "hippo", "repo1"
+
+	// Another backup of the cluster is running.
+	other := &batchv1.Job{}
+	other.Namespace, other.Name = "ns1", "hippo-backup-abcd"
+	other.Labels = naming.PGBackRestBackupJobLabels("hippo", "repo1", naming.BackupManual)
+	other.Status.Active = 1
+
+	cc := &applyRecorder{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(backup, other).Build(),
+	}
+	reconciler := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: record.NewFakeRecorder(10),
+	}
+	reconcile := func(t *testing.T) *v1beta1.PostgresBackup {
+		t.Helper()
+		assert.NilError(t, reconciler.reconcilePostgresBackups(ctx, cluster, instances,
+			serviceAccount))
+
+		stored := &v1beta1.PostgresBackup{}
+		assert.NilError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(backup), stored))
+		return stored
+	}
+
+	t.Run("ManualBackupRunning", func(t *testing.T) {
+		assert.Equal(t, reconcile(t).Status.JobName, "",
+			"expected no Job while another backup runs")
+		assert.Equal(t, len(cc.applied), 0)
+	})
+
+	t.Run("ScheduledBackupRunning", func(t *testing.T) {
+		other.Labels = naming.PGBackRestCronJobLabels("hippo", "repo1", full)
+		assert.NilError(t, reconciler.Client.Update(ctx, other))
+
+		assert.Equal(t, reconcile(t).Status.JobName, "",
+			"expected no Job while another backup runs")
+		assert.Equal(t, len(cc.applied), 0)
+	})
+
+	t.Run("RestoreTestRunning", func(t *testing.T) {
+		other.Labels = naming.PGBackRestCronJobLabels("hippo", "repo1", restoreTest)
+		assert.NilError(t, reconciler.Client.Update(ctx, other))
+
+		running, err := reconciler.pgBackRestBackupRunning(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, !running, "expected a restore test to be ignored")
+	})
+
+	t.Run("VerifyRunning", func(t *testing.T) {
+		other.Labels = naming.PGBackRestCronJobLabels("hippo", "repo1", verify)
+		assert.NilError(t, reconciler.Client.Update(ctx, other))
+
+		running, err := reconciler.pgBackRestBackupRunning(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, !running, "expected a verification to be ignored")
+	})
+
+	t.Run("OtherBackupRunning", func(t *testing.T) {
+		for _, backupType := range []naming.BackupJobType{
+			naming.BackupReplicaCreate, naming.BackupPGUpgrade,
+			naming.BackupPostgresBackup, naming.BackupFinal,
+		} {
+			other.Labels = naming.PGBackRestBackupJobLabels("hippo", "repo1", backupType)
+			assert.NilError(t, reconciler.Client.Update(ctx, other))
+
+			running, err := reconciler.pgBackRestBackupRunning(ctx, cluster)
+			assert.NilError(t, err)
+			assert.Assert(t, running, "expected a %s backup to be running", backupType)
+		}
+	})
+
+	t.Run("OtherBackupFinished", func(t *testing.T) {
+		other.Labels = naming.PGBackRestBackupJobLabels("hippo", "repo1",
+			naming.BackupReplicaCreate)
+		other.Status.Active = 0
+		other.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+		assert.NilError(t, reconciler.Client.Update(ctx, other))
+
+		assert.Equal(t, reconcile(t).Status.JobName, naming.PostgresBackupJob(backup).Name)
+		assert.Equal(t, len(cc.applied), 1)
+		assert.Equal(t, cc.applied[0].GetName(), naming.PostgresBackupJob(backup).Name)
+	})
+}
+
+func TestPostgresBackupInfo(t *testing.T) {
+	infos := make([]pgbackrest.BackupInfo, 4)
+	infos[0].Label = "20210607-175000F"
+	infos[0].Timestamp.Start, infos[0].Timestamp.Stop = 1623088200, 1623088210
+	infos[1].Label = "20210607-175000F_20210607-180000I"
+	infos[1].Type = "incr"
+	infos[1].Archive.Start, infos[1].Archive.Stop = "000000010000000000000004", "000000010000000000000005"
+	infos[1].Info.Size, infos[1].Info.Repository.Size = 24592192, 3053512
+	infos[1].Timestamp.Start, infos[1].Timestamp.Stop = 1623088800, 1623088805
+	infos[2].Label = "20210607-175000F_20210607-170000D"
+	infos[2].Timestamp.Start, infos[2].Timestamp.Stop = 1623085200, 1623085260
+	infos[3].Label = "20210607-175000F_20210607-181000I"
+	infos[3].Timestamp.Start, infos[3].Timestamp.Stop = 1623089400, 1623089405
+
+	t.Run("None", func(t *testing.T) {
+		assert.Assert(t, postgresBackupInfo(nil, time.Unix(1623088000, 0), time.Time{}) == nil)
+		assert.Assert(t, postgresBackupInfo(infos, time.Unix(1623090000, 0), time.Time{}) == nil)
+
+		// A backup that stopped after the Job completed was not taken by it.
+		assert.Assert(t, postgresBackupInfo(infos,
+			time.Unix(1623088700, 0), time.Unix(1623088803, 0)) == nil)
+	})
+
+	t.Run("Taken", func(t *testing.T) {
+		info := postgresBackupInfo(infos, time.Unix(1623088700, 0), time.Unix(1623088810, 0))
+		assert.Assert(t, info != nil)
+
+		assert.Equal(t, info.Label, "20210607-175000F_20210607-180000I")
+		assert.Equal(t, info.Type, "incr")
+		assert.Equal(t, info.Size, int64(24592192))
+		assert.Equal(t, info.RepoSize, int64(3053512))
+		assert.Equal(t, info.WALStart, "000000010000000000000004")
+		assert.Equal(t, info.WALStop, "000000010000000000000005")
+		assert.Equal(t, info.StartTime.Unix(), int64(1623088800))
+		assert.Equal(t, info.StopTime.Unix(), int64(1623088805))
+	})
+
+	t.Run("Earliest", func(t *testing.T) {
+		// A scheduled backup ran after the Job finished but before it was observed.
+		info := postgresBackupInfo(infos, time.Unix(1623088700, 0), time.Unix(1623088810, 0))
+		assert.Assert(t, info != nil)
+		assert.Equal(t, info.Label, "20210607-175000F_20210607-180000I")
+
+		// Without a completion time, the first backup to start is taken.
+		info = postgresBackupInfo(infos, time.Unix(1623088700, 0), time.Time{})
+		assert.Assert(t, info != nil)
+		assert.Equal(t, info.Label, "20210607-175000F_20210607-180000I")
+	})
+}
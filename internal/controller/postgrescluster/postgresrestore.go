@@ -0,0 +1,230 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventPostgresRestoreComplete is the event reason utilized when the restore of a
+	// PostgresRestore completes successfully
+	EventPostgresRestoreComplete = "RestoreComplete"
+
+	// EventPostgresRestoreFailed is the event reason utilized when the restore Job of a
+	// PostgresRestore fails
+	EventPostgresRestoreFailed = "RestoreFailed"
+)
+
+// postgresRestoreID returns the ID recorded in the restore status of a PostgresCluster while
+// it is restored according to restore.
+func postgresRestoreID(restore *v1beta1.PostgresRestore) string {
+	return postgresRestoreIDPrefix + string(restore.GetUID())
+}
+
+// postgresRestoreIDPrefix is the beginning of every ID returned by postgresRestoreID.
+const postgresRestoreIDPrefix = "~pgo-postgresrestore-"
+
+// postgresRestoreDataSource returns the data source that restores the backups of restore,
+// including the options of its target.
+func postgresRestoreDataSource(
+	restore *v1beta1.PostgresRestore,
+) (*v1beta1.PostgresClusterDataSource, error) {
+	dataSource := restore.Spec.PostgresClusterDataSource.DeepCopy()
+
+	targetOpts, err := pgbackrest.RestoreTargetOptions(restore.Spec.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	// The target has its own field in the spec.
+	if len(targetOpts) > 0 {
+		for _, opt := range dataSource.Options {
+			if strings.Contains(opt, "--type") || strings.Contains(opt, "--target") ||
+				strings.Contains(opt, "--set") {
+				return nil, errors.New("options '--type', '--target' and '--set' are not " +
+					"allowed with a target: please use the 'target' field instead")
+			}
+		}
+	}
+
+	dataSource.Options = append(targetOpts, dataSource.Options...)
+
+	// Recovery from a snapshot replays WAL until the end of the archive.
+	if dataSource.VolumeSnapshotName != "" && len(dataSource.Options) > 0 {
+		return nil, errors.New("a target and options are not allowed with a " +
+			"volumeSnapshotName")
+	}
+	return dataSource, nil
+}
+
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresrestores,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresrestores/status,verbs=patch
+
+// observePostgresRestores records the progress of the restore Job in the status of the
+// PostgresRestore being restored, if any. It returns the PostgresRestore that should drive
+// the data source of cluster: the one being restored, or else the oldest that is not
+// finished, or else one whose restore Job failed. It returns nil when there is none.
+//
+// A PostgresRestore replaces the data of a cluster that has data only when the cluster is
+// annotated with its name. This keeps a PostgresRestore that names the wrong cluster from
+// tearing that cluster down.
+func (r *Reconciler) observePostgresRestores(ctx context.Context,
+	cluster *v1beta1.PostgresCluster,
+) (*v1beta1.PostgresRestore, *v1beta1.PostgresClusterDataSource, error) {
+
+	var status *v1beta1.PGBackRestJobStatus
+	if cluster.Status.PGBackRest != nil {
+		status = cluster.Status.PGBackRest.Restore
+	}
+	restoring := meta.IsStatusConditionTrue(cluster.Status.Conditions,
+		ConditionPGBackRestRestoreProgressing)
+	initialized := patroni.ClusterBootstrapped(cluster) ||
+		meta.IsStatusConditionTrue(cluster.Status.Conditions,
+			ConditionPostgresDataInitialized)
+	confirmed := cluster.GetAnnotations()[naming.PostgresRestore]
+
+	// There is nothing to do when the cluster has data, no PostgresRestore is allowed to
+	// replace it, and none has done so.
+	if initialized && confirmed == "" &&
+		(status == nil || !strings.HasPrefix(status.ID, postgresRestoreIDPrefix)) {
+		return nil, nil, nil
+	}
+
+	restores := &v1beta1.PostgresRestoreList{}
+	if err := r.Client.List(ctx, restores,
+		client.InNamespace(cluster.GetNamespace())); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	sort.Slice(restores.Items, func(i, j int) bool {
+		a, b := restores.Items[i], restores.Items[j]
+		if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.Name < b.Name
+		}
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	})
+
+	var failed, next *v1beta1.PostgresRestore
+	var failedSource, nextSource *v1beta1.PostgresClusterDataSource
+	for i := range restores.Items {
+		restore := &restores.Items[i]
+		if restore.Spec.PostgresCluster != cluster.GetName() ||
+			restore.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		dataSource, err := postgresRestoreDataSource(restore)
+		if err != nil {
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "InvalidRestoreTarget",
+				err.Error())
+			continue
+		}
+
+		if status != nil && status.ID == postgresRestoreID(restore) {
+			if err := r.recordPostgresRestore(ctx, cluster, restore, dataSource,
+				status); err != nil {
+				return nil, nil, err
+			}
+
+			// Keep driving a restore that has started. When its Job has failed, keep
+			// driving it until another restore is requested so that the cluster does not
+			// start with a partially restored data directory.
+			if restoring && !restore.Status.Finished {
+				return restore, dataSource, nil
+			}
+			if restoring {
+				failed, failedSource = restore, dataSource
+				continue
+			}
+		}
+
+		if next == nil && !restore.Status.Finished {
+			if initialized && restore.GetName() != confirmed {
+				r.Recorder.Eventf(restore, corev1.EventTypeWarning, "RestoreNotConfirmed",
+					"PostgresCluster %q has data. Annotate it with %s=%s to restore it in-place.",
+					cluster.GetName(), naming.PostgresRestore, restore.GetName())
+				continue
+			}
+			next, nextSource = restore, dataSource
+		}
+	}
+
+	if next == nil {
+		return failed, failedSource, nil
+	}
+	return next, nextSource, nil
+}
+
+// recordPostgresRestore copies the restore status of cluster into the status of restore,
+// along with the source of the restore.
+func (r *Reconciler) recordPostgresRestore(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore,
+	dataSource *v1beta1.PostgresClusterDataSource, status *v1beta1.PGBackRestJobStatus,
+) error {
+	before := restore.DeepCopy()
+
+	// The source is recorded when the restore begins and not changed after that.
+	if restore.Status.RepoName == "" {
+		restore.Status.ClusterName = dataSource.ClusterName
+		if restore.Status.ClusterName == "" {
+			restore.Status.ClusterName = cluster.GetName()
+		}
+		restore.Status.ClusterNamespace = dataSource.ClusterNamespace
+		if restore.Status.ClusterNamespace == "" {
+			restore.Status.ClusterNamespace = cluster.GetNamespace()
+		}
+		restore.Status.RepoName = dataSource.RepoName
+		restore.Status.Options = dataSource.Options
+	}
+
+	restore.Status.JobName = naming.PGBackRestRestoreJob(cluster).Name
+	restore.Status.StartTime = status.StartTime
+	restore.Status.CompletionTime = status.CompletionTime
+	restore.Status.Active = status.Active
+	restore.Status.Succeeded = status.Succeeded
+	restore.Status.Failed = status.Failed
+	restore.Status.Finished = status.Finished
+
+	if restore.Status.Finished && !before.Status.Finished {
+		if restore.Status.CompletionTime != nil {
+			r.Recorder.Event(restore, corev1.EventTypeNormal, EventPostgresRestoreComplete,
+				"pgBackRest restore completed successfully")
+		} else {
+			r.Recorder.Event(restore, corev1.EventTypeWarning, EventPostgresRestoreFailed,
+				"pgBackRest restore failed")
+		}
+	}
+
+	if equality.Semantic.DeepEqual(before.Status, restore.Status) {
+		return nil
+	}
+	return errors.WithStack(r.Client.Status().Patch(
+		ctx, restore, client.MergeFrom(before), r.Owner))
+}
@@ -0,0 +1,204 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestPostgresRestoreID(t *testing.T) {
+	restore := &v1beta1.PostgresRestore{}
+	restore.UID = types.UID("some-uid")
+
+	assert.Equal(t, postgresRestoreID(restore), "~pgo-postgresrestore-some-uid")
+}
+
+func TestPostgresRestoreDataSource(t *testing.T) {
+	restore := &v1beta1.PostgresRestore{}
+	restore.Spec.ClusterName = "source"
+	restore.Spec.RepoName = "repo2"
+	restore.Spec.Options = []string{"--process-max=2"}
+
+	t.Run("NoTarget", func(t *testing.T) {
+		dataSource, err := postgresRestoreDataSource(restore)
+		assert.NilError(t, err)
+		assert.Equal(t, dataSource.ClusterName, "source")
+		assert.Equal(t, dataSource.RepoName, "repo2")
+		assert.DeepEqual(t, dataSource.Options, []string{"--process-max=2"})
+	})
+
+	t.Run("Target", func(t *testing.T) {
+		restore := restore.DeepCopy()
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{XID: "1234"}
+
+		dataSource, err := postgresRestoreDataSource(restore)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, dataSource.Options, []string{
+			"--type=xid", `--target='1234'`, "--process-max=2",
+		})
+
+		// The spec is not changed.
+		assert.DeepEqual(t, restore.Spec.Options, []string{"--process-max=2"})
+	})
+
+	t.Run("TargetAndOptions", func(t *testing.T) {
+		restore := restore.DeepCopy()
+		restore.Spec.Options = []string{"--type=time"}
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{XID: "1234"}
+
+		_, err := postgresRestoreDataSource(restore)
+		assert.ErrorContains(t, err, "not allowed")
+	})
+
+	t.Run("VolumeSnapshot", func(t *testing.T) {
+		restore := restore.DeepCopy()
+		restore.Spec.VolumeSnapshotName = "source-pgdata-abc"
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{XID: "1234"}
+		restore.Spec.Options = nil
+
+		_, err := postgresRestoreDataSource(restore)
+		assert.ErrorContains(t, err, "not allowed")
+
+		restore.Spec.Target = nil
+		dataSource, err := postgresRestoreDataSource(restore)
+		assert.NilError(t, err)
+		assert.Equal(t, dataSource.VolumeSnapshotName, "source-pgdata-abc")
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		restore := restore.DeepCopy()
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{XID: "1234", Name: "before"}
+
+		_, err := postgresRestoreDataSource(restore)
+		assert.ErrorContains(t, err, "only one")
+	})
+}
+
+func TestObservePostgresRestores(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	restore := &v1beta1.PostgresRestore{}
+	restore.Namespace, restore.Name, restore.UID = "ns1", "yesterday", types.UID("some-uid")
+	restore.Spec.PostgresCluster, restore.Spec.RepoName = "hippo", "repo1"
+
+	other := &v1beta1.PostgresRestore{}
+	other.Namespace, other.Name = "ns1", "other"
+	other.Spec.PostgresCluster, other.Spec.RepoName = "rhino", "repo1"
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(restore, other).Build(),
+		Recorder: recorder,
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	t.Run("NoData", func(t *testing.T) {
+		found, dataSource, err := reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found != nil, "expected a cluster without data to be restored")
+		assert.Equal(t, found.Name, "yesterday")
+		assert.Equal(t, dataSource.RepoName, "repo1")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Bootstrapped", func(t *testing.T) {
+		// A cluster created with initdb has data without the condition.
+		cluster := cluster.DeepCopy()
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "12345"}
+
+		found, _, err := reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found == nil, "expected a cluster with data to be kept")
+
+		cluster.Annotations = map[string]string{naming.PostgresRestore: "today"}
+
+		found, _, err = reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found == nil, "expected a cluster with data to be kept")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "RestoreNotConfirmed"))
+
+		cluster.Annotations = map[string]string{naming.PostgresRestore: "yesterday"}
+
+		found, _, err = reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found != nil)
+		assert.Equal(t, found.Name, "yesterday")
+	})
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionPostgresDataInitialized, Status: metav1.ConditionTrue, Reason: "Test",
+	})
+
+	t.Run("NotConfirmed", func(t *testing.T) {
+		found, _, err := reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found == nil, "expected a cluster with data to be kept")
+
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PostgresRestore: "today"}
+
+		found, _, err = reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found == nil, "expected a cluster with data to be kept")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "RestoreNotConfirmed"))
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Annotations = map[string]string{naming.PostgresRestore: "yesterday"}
+
+		found, _, err := reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found != nil)
+		assert.Equal(t, found.Name, "yesterday")
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Restore: &v1beta1.PGBackRestJobStatus{ID: postgresRestoreID(restore)},
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionPGBackRestRestoreProgressing, Status: metav1.ConditionTrue,
+			Reason: "Test",
+		})
+
+		found, _, err := reconciler.observePostgresRestores(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, found != nil, "expected a restore that started to continue")
+		assert.Equal(t, found.Name, "yesterday")
+	})
+}
@@ -0,0 +1,267 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventInvalidRestoreTarget is the event reason utilized when an in-place restore is
+	// refused because its target cannot be reached using the backups in the repository
+	EventInvalidRestoreTarget = "InvalidRestoreTarget"
+
+	// walSegmentSize is the size of a WAL file. PGO initializes every cluster with the
+	// PostgreSQL default.
+	walSegmentSize = 16 * 1024 * 1024
+)
+
+// validateRestoreTarget checks that the target of dataSource can be reached using the backups
+// and WAL archive of its repository, as read from pgBackRest just before. When it cannot, the restore
+// is refused in the PGBackRestoreProgressing condition of cluster and it returns false. A refused
+// restore is checked against the backups in status until they are due to be read again.
+func (r *Reconciler) validateRestoreTarget(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, dataSource *v1beta1.PostgresClusterDataSource,
+) (bool, error) {
+	// Recovery from a snapshot replays WAL until the end of the archive.
+	if dataSource.VolumeSnapshotName != "" {
+		return true, nil
+	}
+
+	source := cluster
+	if (dataSource.ClusterName != "" && dataSource.ClusterName != cluster.GetName()) ||
+		(dataSource.ClusterNamespace != "" && dataSource.ClusterNamespace != cluster.GetNamespace()) {
+		source = &v1beta1.PostgresCluster{}
+		key := client.ObjectKey{Namespace: dataSource.ClusterNamespace, Name: dataSource.ClusterName}
+		if key.Namespace == "" {
+			key.Namespace = cluster.GetNamespace()
+		}
+		if key.Name == "" {
+			key.Name = cluster.GetName()
+		}
+
+		// A missing source cluster is reported when the restore Job is reconciled.
+		if err := r.Client.Get(ctx, key, source); apierrors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			return false, errors.WithStack(err)
+		}
+	}
+
+	var repo *v1beta1.RepoStatus
+	if source.Status.PGBackRest != nil {
+		for i := range source.Status.PGBackRest.Repos {
+			if source.Status.PGBackRest.Repos[i].Name == dataSource.RepoName {
+				repo = &source.Status.PGBackRest.Repos[i]
+			}
+		}
+	}
+
+	now := time.Now()
+	complete := repo != nil && len(repo.Backups) < backupCatalogLimit
+
+	// A restore is refused on every reconcile until it changes. Do not read the repository
+	// again while the backups in status are recent and still explain the refusal.
+	if refused := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGBackRestRestoreProgressing); refused != nil &&
+		refused.Status == metav1.ConditionFalse &&
+		refused.Reason == EventInvalidRestoreTarget &&
+		repo != nil && backupCatalogWait(repo, source.Status.PGBackRest.LastBackupTime, now) > 0 {
+		if err := restoreTargetError(repo, complete, dataSource.Options, now); err != nil &&
+			refused.Message == restoreTargetMessage(err) {
+			return false, nil
+		}
+	}
+
+	// The backups in status may have been read minutes ago. Read them again so that
+	// recent backups and WAL are considered. Use those in status when the repository
+	// cannot be read, e.g. when the source cluster is not running.
+	if repo != nil && repo.StanzaCreated {
+		exec, err := r.backupCatalogExecutor(ctx, source)
+		if err == nil {
+			var info pgbackrest.StanzaInfo
+			if info, err = exec.Info(ctx, regexRepoIndex.FindString(repo.Name)); err == nil {
+				// Record what was read when it is the repository of cluster.
+				if source == cluster {
+					setBackupCatalog(repo, info, now, backupCatalogLimit)
+				}
+				repo = repo.DeepCopy()
+				setBackupCatalog(repo, info, now, len(info.Backup))
+				complete = true
+			}
+		}
+		if err != nil {
+			logging.FromContext(ctx).V(1).Info("unable to read backups before restore",
+				"error", err.Error())
+		}
+	}
+
+	err := restoreTargetError(repo, complete, dataSource.Options, now)
+	if err == nil {
+		return true, nil
+	}
+
+	message := restoreTargetMessage(err)
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPGBackRestRestoreProgressing,
+		Status:             metav1.ConditionFalse,
+		Reason:             EventInvalidRestoreTarget,
+		Message:            message,
+	})
+	r.Recorder.Event(cluster, corev1.EventTypeWarning, EventInvalidRestoreTarget, message)
+
+	return false, nil
+}
+
+// restoreTargetMessage returns the message that refuses a restore because of err.
+func restoreTargetMessage(err error) string {
+	return fmt.Sprintf("Unable to restore in-place: %v", err)
+}
+
+// restoreTargetError returns an error describing why the pgBackRest restore options cannot
+// succeed using the backups and WAL archive in repo at now. When complete is false, repo may
+// not list its oldest backups. It returns nil when the options might succeed, including when
+// the backups in repo have not been read.
+func restoreTargetError(
+	repo *v1beta1.RepoStatus, complete bool, options []string, now time.Time,
+) error {
+	if repo == nil || repo.InfoTime == nil {
+		return nil
+	}
+
+	kind, target, set := pgbackrest.RestoreTarget(options)
+
+	// Older backups and the targets they can reach are not refused.
+	partial := !complete
+
+	backups, describe := repo.Backups, "any backup in "+repo.Name
+	if set != "" {
+		backups, describe = nil, fmt.Sprintf("backup %q", set)
+		for i := range repo.Backups {
+			if repo.Backups[i].Label == set {
+				backups = repo.Backups[i : i+1]
+			}
+		}
+		if len(backups) == 0 && partial {
+			return nil
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("backup %q is not in %s", set, repo.Name)
+		}
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("there are no backups in %s", repo.Name)
+	}
+
+	switch kind {
+	case "time":
+		when, ok := parseRestoreTargetTime(target)
+		if !ok {
+			return nil
+		}
+		if when.After(now) {
+			return fmt.Errorf("target time %q is in the future", target)
+		}
+
+		// pgBackRest does not report when the newest WAL in the archive was written, and
+		// WAL that is not yet archived is archived when the cluster stops. A time after
+		// the most recent backup may or may not be reached.
+		for _, backup := range backups {
+			if backup.StopTime != nil && !backup.StopTime.Time.After(when) {
+				return nil
+			}
+		}
+		if partial && set == "" {
+			return nil
+		}
+		return fmt.Errorf("target time %q is before %s finished", target, describe)
+
+	case "lsn":
+		lsn, ok := parseLSN(target)
+		if !ok {
+			return nil
+		}
+		if start, ok := walSegmentStart(repo.WALArchiveMax); ok && lsn >= start+walSegmentSize {
+			return fmt.Errorf("target lsn %q is after the last WAL archived in %s, %s",
+				target, repo.Name, repo.WALArchiveMax)
+		}
+		for _, backup := range backups {
+			if start, ok := walSegmentStart(backup.WALStop); !ok || start <= lsn {
+				return nil
+			}
+		}
+		if partial && set == "" {
+			return nil
+		}
+		return fmt.Errorf("target lsn %q is before %s finished", target, describe)
+	}
+
+	return nil
+}
+
+// parseRestoreTargetTime interprets s as a PostgreSQL timestamp with time zone, such as those
+// from pgbackrest.RestoreTargetOptions. It returns false when s has no time zone or is in a
+// format that is not understood.
+func parseRestoreTargetTime(s string) (time.Time, bool) {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999-07",
+		"2006-01-02 15:04:05.999999999-07:00",
+		time.RFC3339Nano,
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseLSN interprets s as a PostgreSQL write-ahead log location, e.g. "0/3000060".
+func parseLSN(s string) (uint64, bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	hi, err1 := strconv.ParseUint(parts[0], 16, 32)
+	lo, err2 := strconv.ParseUint(parts[1], 16, 32)
+	return hi<<32 | lo, err1 == nil && err2 == nil
+}
+
+// walSegmentStart returns the first write-ahead log location in the WAL file named name,
+// e.g. "000000010000000000000003".
+func walSegmentStart(name string) (uint64, bool) {
+	if len(name) != 24 {
+		return 0, false
+	}
+	log, err1 := strconv.ParseUint(name[8:16], 16, 32)
+	seg, err2 := strconv.ParseUint(name[16:24], 16, 32)
+	return log<<32 | seg*walSegmentSize, err1 == nil && err2 == nil
+}
@@ -0,0 +1,308 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestRestoreTargetError(t *testing.T) {
+	now := time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+
+	repo := &v1beta1.RepoStatus{Name: "repo1"}
+	options := []string{"--type=time", `--target="2021-06-07 17:00:00+00"`}
+
+	assert.NilError(t, restoreTargetError(nil, true, options, now))
+	assert.NilError(t, restoreTargetError(repo, true, options, now),
+		"expected no error when the backups have not been read")
+
+	repo.InfoTime = at(0)
+	assert.ErrorContains(t, restoreTargetError(repo, true, nil, now), "no backups in repo1")
+
+	repo.WALArchiveMax = "000000010000000000000008"
+	repo.Backups = []v1beta1.PGBackRestBackupInfo{{
+		Label: "20210607-150000F", StopTime: at(3 * time.Hour),
+		WALStop: "000000010000000000000002",
+	}, {
+		Label: "20210607-170000F_20210607-173000I", StopTime: at(30 * time.Minute),
+		WALStop: "000000010000000000000006",
+	}}
+	assert.NilError(t, restoreTargetError(repo, true, nil, now))
+	assert.NilError(t, restoreTargetError(repo, true, options, now))
+
+	t.Run("Set", func(t *testing.T) {
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--set=20210607-150000F", "--type=immediate"}, now))
+
+		assert.ErrorContains(t, restoreTargetError(repo, true,
+			[]string{"--set=20210601-000000F"}, now), `backup "20210601-000000F" is not in repo1`)
+
+		err := restoreTargetError(repo, true,
+			append([]string{"--set=20210607-170000F_20210607-173000I"}, options...), now)
+		assert.ErrorContains(t, err, `"2021-06-07 17:00:00+00" is before backup "20210607-170000F_20210607-173000I" finished`)
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		err := restoreTargetError(repo, true,
+			[]string{"--type=time", "--target='2021-06-07 14:00:00+00'"}, now)
+		assert.ErrorContains(t, err, `"2021-06-07 14:00:00+00" is before any backup in repo1 finished`)
+
+		err = restoreTargetError(repo, true,
+			[]string{"--type=time", "--target='2021-06-07 20:00:00+02:00'"}, now)
+		assert.NilError(t, err, "expected the time zone to be considered")
+
+		err = restoreTargetError(repo, true,
+			[]string{"--type=time", "--target='2021-06-08 00:00:00+00'"}, now)
+		assert.ErrorContains(t, err, "is in the future")
+
+		// Times without a time zone are not checked.
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--type=time", "--target='2021-06-07 14:00:00'"}, now))
+	})
+
+	t.Run("LSN", func(t *testing.T) {
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--type=lsn", "--target=0/2000060"}, now))
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--type=lsn", "--target=0/8FFFFFF"}, now))
+
+		err := restoreTargetError(repo, true,
+			[]string{"--type=lsn", "--target=0/1000060"}, now)
+		assert.ErrorContains(t, err, `"0/1000060" is before any backup in repo1 finished`)
+
+		err = restoreTargetError(repo, true,
+			[]string{"--type=lsn", "--target=0/9000000"}, now)
+		assert.ErrorContains(t, err, "after the last WAL archived in repo1, 000000010000000000000008")
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		// Backups older than those in status may exist in the repository.
+		assert.NilError(t, restoreTargetError(repo, false,
+			[]string{"--set=20210601-000000F"}, now))
+		assert.NilError(t, restoreTargetError(repo, false,
+			[]string{"--type=time", "--target='2021-06-07 14:00:00+00'"}, now))
+		assert.NilError(t, restoreTargetError(repo, false,
+			[]string{"--type=lsn", "--target=0/1000060"}, now))
+
+		err := restoreTargetError(repo, false,
+			[]string{"--type=time", "--target='2021-06-08 00:00:00+00'"}, now)
+		assert.ErrorContains(t, err, "is in the future")
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--type=xid", "--target=1234"}, now))
+		assert.NilError(t, restoreTargetError(repo, true,
+			[]string{"--type=name", "--target=before-upgrade"}, now))
+	})
+}
+
+func TestValidateRestoreTarget(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	read := metav1.NewTime(time.Now())
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", InfoTime: &read}},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Recorder: recorder,
+	}
+
+	t.Run("Refused", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		valid, err := reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo1"})
+		assert.NilError(t, err)
+		assert.Assert(t, !valid)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGBackRestRestoreProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "InvalidRestoreTarget")
+		assert.Assert(t, strings.Contains(condition.Message, "no backups in repo1"), condition.Message)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "InvalidRestoreTarget"))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		valid, err := reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo2"})
+		assert.NilError(t, err)
+		assert.Assert(t, valid, "expected a repo without status to be allowed")
+		assert.Equal(t, len(cluster.Status.Conditions), 0)
+	})
+
+	t.Run("ReadAgain", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.Repos[0].StanzaCreated = true
+
+		primary := &corev1.Pod{}
+		primary.Namespace, primary.Name = "ns1", "hippo-00-abcd-0"
+		primary.Labels = map[string]string{
+			naming.LabelCluster:  "hippo",
+			naming.LabelInstance: "hippo-00-abcd",
+			naming.LabelRole:     naming.RolePatroniLeader,
+		}
+
+		// A backup finished after the repository was last read.
+		var calls int
+		reconciler := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(primary).Build(),
+			Recorder: recorder,
+			PodExec: func(
+				namespace, pod, container string,
+				stdin io.Reader, stdout, _ io.Writer, command ...string,
+			) error {
+				calls++
+				assert.Equal(t, pod, "hippo-00-abcd-0")
+				assert.Assert(t, strings.Contains(strings.Join(command, " "), "info"))
+
+				_, _ = io.WriteString(stdout, `[{
+	"archive": [{"id": "13-1", "max": "000000010000000000000004", "min": "000000010000000000000001"}],
+	"backup": [{
+		"archive": {"start": "000000010000000000000002", "stop": "000000010000000000000002"},
+		"label": "20210607-175000F",
+		"timestamp": {"start": 1623088200, "stop": 1623088210},
+		"type": "full"
+	}],
+	"name": "db"
+}]`)
+				return nil
+			},
+		}
+
+		valid, err := reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo1", Options: []string{
+				"--type=lsn", "--target=0/4000060",
+			}})
+		assert.NilError(t, err)
+		assert.Assert(t, valid, "expected the backups to be read again")
+		assert.Equal(t, calls, 1)
+		assert.Equal(t, len(cluster.Status.Conditions), 0)
+
+		// The target is checked against the WAL that was just read.
+		valid, err = reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo1", Options: []string{
+				"--type=lsn", "--target=0/5000000",
+			}})
+		assert.NilError(t, err)
+		assert.Assert(t, !valid)
+		assert.Equal(t, calls, 2)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "000000010000000000000004"))
+
+		// The backups that were read are recorded. They explain the refusal until they
+		// are due to be read again.
+		assert.Equal(t, len(cluster.Status.PGBackRest.Repos[0].Backups), 1)
+
+		valid, err = reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo1", Options: []string{
+				"--type=lsn", "--target=0/5000000",
+			}})
+		assert.NilError(t, err)
+		assert.Assert(t, !valid)
+		assert.Equal(t, calls, 2, "expected the backups in status to be used")
+		assert.Equal(t, len(recorder.Events), 0)
+
+		// A different target is checked against the repository.
+		valid, err = reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{RepoName: "repo1", Options: []string{
+				"--type=lsn", "--target=0/6000000",
+			}})
+		assert.NilError(t, err)
+		assert.Assert(t, !valid)
+		assert.Equal(t, calls, 3)
+		assert.Equal(t, len(recorder.Events), 1)
+		<-recorder.Events
+	})
+
+	t.Run("OtherCluster", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		valid, err := reconciler.validateRestoreTarget(ctx, cluster,
+			&v1beta1.PostgresClusterDataSource{ClusterName: "rhino", RepoName: "repo1"})
+		assert.NilError(t, err)
+		assert.Assert(t, valid, "expected a missing cluster to be reported elsewhere")
+	})
+}
+
+func TestReconcileDataSourceValidatesTarget(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Recorder: recorder,
+	}
+
+	// A cluster created by initdb is bootstrapped without a data source.
+	read := metav1.NewTime(time.Now())
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Annotations = map[string]string{naming.PGBackRestRestore: "one"}
+	cluster.Spec.Backups.PGBackRest.Restore = &v1beta1.PGBackRestRestore{
+		Enabled: initialize.Bool(true),
+		PostgresClusterDataSource: &v1beta1.PostgresClusterDataSource{
+			RepoName: "repo1",
+		},
+	}
+	cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "12345"}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1", InfoTime: &read}},
+	}
+
+	returnEarly, err := reconciler.reconcileDataSource(ctx, cluster, &observedInstances{})
+	assert.NilError(t, err)
+	assert.Assert(t, !returnEarly, "expected the cluster to keep running")
+	assert.Assert(t, cluster.Status.PGBackRest.Restore == nil,
+		"expected the cluster not to be prepared for restore")
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGBackRestRestoreProgressing)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Reason, "InvalidRestoreTarget")
+	assert.Equal(t, len(recorder.Events), 1)
+}
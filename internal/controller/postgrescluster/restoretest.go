@@ -0,0 +1,189 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventRestoreTestFailed is the event reason utilized when a scheduled restore test of a
+	// pgBackRest repository fails
+	EventRestoreTestFailed = "RestoreTestFailed"
+)
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;patch
+
+// reconcileRestoreTestVolume applies the scratch PersistentVolumeClaim that the restore test of
+// repo restores into. It has the labels of the restore test CronJob so that it is removed along
+// with the CronJob.
+func (r *Reconciler) reconcileRestoreTestVolume(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo,
+	labels, annotations map[string]string,
+) (*corev1.PersistentVolumeClaim, error) {
+	volume := &corev1.PersistentVolumeClaim{
+		ObjectMeta: naming.PGBackRestCronJob(cluster, restoreTest, repo.Name),
+		Spec:       repo.RestoreTest.VolumeClaimSpec,
+	}
+	volume.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+	volume.Labels = labels
+	volume.Annotations = annotations
+
+	err := errors.WithStack(r.setControllerReference(cluster, volume))
+
+	// keep the parts of an existing volume that cannot change
+	if err == nil {
+		_, err = r.keepVolumeSpec(ctx, volume, false)
+	}
+	if err == nil {
+		err = r.handlePersistentVolumeClaimError(cluster,
+			errors.WithStack(r.apply(ctx, volume)))
+	}
+	return volume, err
+}
+
+// generateRestoreTestJobSpecIntent generates a JobSpec that restores the most recent backup in
+// repo into volume, starts PostgreSQL on it, and runs the SQL check of the restore test. The Pod
+// is built like that of a restore Job using configName, the pgBackRest configuration of an
+// instance.
+func (r *Reconciler) generateRestoreTestJobSpecIntent(cluster *v1beta1.PostgresCluster,
+	repo v1beta1.PGBackRestRepo, volume *corev1.PersistentVolumeClaim, configName string,
+	labels, annotations map[string]string,
+) (*batchv1.JobSpec, error) {
+	test := repo.RestoreTest
+
+	// The files of the previous test are kept, so only those that changed are restored.
+	pgdata := postgres.DataDirectory(cluster)
+	opts := []string{
+		"--stanza=" + pgbackrest.DefaultStanzaName, "--pg1-path=" + pgdata,
+		"--repo=" + regexRepoIndex.FindString(repo.Name),
+		"--delta", "--type=immediate", "--target-action=promote",
+		"--link-map=pg_wal=" + postgres.WALDirectory(cluster,
+			&v1beta1.PostgresInstanceSetSpec{}),
+	}
+	cmd := pgbackrest.RestoreTestCommand(pgdata, test.SQL, strings.Join(opts, " "))
+
+	dataVolumeMount := postgres.DataVolumeMount()
+	volumes := []corev1.Volume{{
+		Name: dataVolumeMount.Name,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: volume.GetName(),
+			},
+		},
+	}}
+
+	job := &batchv1.Job{}
+	if err := r.generateRestoreJobIntent(cluster, "", "", cmd,
+		[]corev1.VolumeMount{dataVolumeMount}, volumes,
+		&v1beta1.PostgresClusterDataSource{Resources: test.Resources}, job); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	job.Spec.Template.ObjectMeta = metav1.ObjectMeta{
+		Annotations: annotations,
+		Labels:      labels,
+	}
+
+	if pgbackrest.RepoHostEnabled(cluster) {
+		if err := pgbackrest.AddSSHToPod(cluster, &job.Spec.Template, false,
+			test.Resources, naming.PGBackRestRestoreContainerName); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := pgbackrest.AddConfigsToPod(cluster, &job.Spec.Template,
+		configName, naming.PGBackRestRestoreContainerName); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addNSSWrapper(config.PGBackRestContainerImage(cluster), &job.Spec.Template)
+	addTMPEmptyDir(&job.Spec.Template)
+
+	return &job.Spec, nil
+}
+
+// setRestoreTestStatus records in repo the most recent restore test that finished among jobs,
+// the Jobs created by the scheduled CronJobs of cluster. An event is emitted when that test
+// failed and has not been recorded before.
+func (r *Reconciler) setRestoreTestStatus(cluster *v1beta1.PostgresCluster,
+	repo *v1beta1.RepoStatus, jobs []batchv1.Job) {
+
+	var latest *v1beta1.PGBackRestRestoreTestStatus
+	for i := range jobs {
+		if jobs[i].GetLabels()[naming.LabelPGBackRestCronJob] != restoreTest ||
+			jobs[i].GetLabels()[naming.LabelPGBackRestRepo] != repo.Name {
+			continue
+		}
+		if status := restoreTestStatus(&jobs[i]); status != nil &&
+			(latest == nil || latest.StartTime.Before(status.StartTime)) {
+			latest = status
+		}
+	}
+
+	// Jobs are removed by the history limits of the CronJob, so keep the last result.
+	if latest == nil {
+		return
+	}
+	if !latest.Succeeded && (repo.RestoreTest == nil || repo.RestoreTest.JobName != latest.JobName) {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventRestoreTestFailed,
+			"Restore test %q of %s failed", latest.JobName, repo.Name)
+	}
+	repo.RestoreTest = latest
+}
+
+// restoreTestStatus returns the result of the restore test that job ran, or nil when job has
+// not started or finished.
+func restoreTestStatus(job *batchv1.Job) *v1beta1.PGBackRestRestoreTestStatus {
+	if job.Status.StartTime == nil {
+		return nil
+	}
+
+	status := &v1beta1.PGBackRestRestoreTestStatus{
+		JobName:   job.GetName(),
+		StartTime: job.Status.StartTime,
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			status.Succeeded = true
+			status.FinishTime = job.Status.CompletionTime
+			if status.FinishTime == nil {
+				status.FinishTime = &condition.LastTransitionTime
+			}
+		case batchv1.JobFailed:
+			status.FinishTime = &condition.LastTransitionTime
+		}
+	}
+	if status.FinishTime == nil {
+		return nil
+	}
+
+	status.Duration = &metav1.Duration{Duration: status.FinishTime.Sub(status.StartTime.Time)}
+	return status
+}
@@ -0,0 +1,159 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestRestoreTestStatus(t *testing.T) {
+	start := metav1.NewTime(time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC))
+	finish := metav1.NewTime(start.Add(90 * time.Second))
+
+	job := &batchv1.Job{}
+	job.Name = "hippo-pgbackrest-repo1-restore-test-1623088800"
+	assert.Assert(t, restoreTestStatus(job) == nil, "expected no status before the Job starts")
+
+	job.Status.StartTime = &start
+	assert.Assert(t, restoreTestStatus(job) == nil, "expected no status while the Job runs")
+
+	job.Status.CompletionTime = &finish
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+	}}
+	status := restoreTestStatus(job)
+	assert.Assert(t, status != nil)
+	assert.Equal(t, status.JobName, job.Name)
+	assert.Assert(t, status.Succeeded)
+	assert.Assert(t, status.FinishTime.Equal(&finish))
+	assert.Equal(t, status.Duration.Duration, 90*time.Second)
+
+	job.Status.CompletionTime = nil
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(start.Add(time.Minute)),
+	}}
+	status = restoreTestStatus(job)
+	assert.Assert(t, status != nil)
+	assert.Assert(t, !status.Succeeded)
+	assert.Equal(t, status.Duration.Duration, time.Minute)
+}
+
+func TestSetRestoreTestStatus(t *testing.T) {
+	start := metav1.NewTime(time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC))
+	job := func(name, repo, kind string, offset time.Duration, condition batchv1.JobConditionType) batchv1.Job {
+		job := batchv1.Job{}
+		job.Name = name
+		job.Labels = map[string]string{
+			naming.LabelPGBackRestRepo:    repo,
+			naming.LabelPGBackRestCronJob: kind,
+		}
+		started := metav1.NewTime(start.Add(offset))
+		job.Status.StartTime = &started
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: condition, Status: corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(started.Add(time.Minute)),
+		}}
+		return job
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	repo := &v1beta1.RepoStatus{Name: "repo1"}
+	reconciler.setRestoreTestStatus(cluster, repo, []batchv1.Job{
+		job("backup", "repo1", full, 2*time.Hour, batchv1.JobFailed),
+		job("other", "repo2", restoreTest, 2*time.Hour, batchv1.JobFailed),
+	})
+	assert.Assert(t, repo.RestoreTest == nil)
+
+	reconciler.setRestoreTestStatus(cluster, repo, []batchv1.Job{
+		job("older", "repo1", restoreTest, 0, batchv1.JobComplete),
+		job("newer", "repo1", restoreTest, time.Hour, batchv1.JobFailed),
+	})
+	assert.Assert(t, repo.RestoreTest != nil)
+	assert.Equal(t, repo.RestoreTest.JobName, "newer")
+	assert.Assert(t, !repo.RestoreTest.Succeeded)
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Assert(t, strings.Contains(<-recorder.Events, EventRestoreTestFailed))
+
+	// A failure is reported once, and kept after its Job is removed.
+	reconciler.setRestoreTestStatus(cluster, repo, []batchv1.Job{
+		job("newer", "repo1", restoreTest, time.Hour, batchv1.JobFailed),
+	})
+	reconciler.setRestoreTestStatus(cluster, repo, nil)
+	assert.Equal(t, repo.RestoreTest.JobName, "newer")
+	assert.Equal(t, len(recorder.Events), 0)
+}
+
+func TestGenerateRestoreTestJobSpecIntent(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.PostgresVersion = 13
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo2",
+		S3:   &v1beta1.RepoS3{Bucket: "bucket", Endpoint: "endpoint", Region: "region"},
+		RestoreTest: &v1beta1.PGBackRestRestoreTest{
+			Schedule: "0 3 * * *",
+			SQL:      "SELECT true",
+		},
+	}}
+
+	volume := &corev1.PersistentVolumeClaim{}
+	volume.Name = "hippo-pgbackrest-repo2-restore-test"
+	labels := map[string]string{"cron": "labels"}
+
+	reconciler := &Reconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	spec, err := reconciler.generateRestoreTestJobSpecIntent(cluster,
+		cluster.Spec.Backups.PGBackRest.Repos[0], volume, "hippo-00-abcd.conf", labels, nil)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, spec.Template.Labels, labels)
+	assert.Equal(t, spec.Template.Spec.RestartPolicy, corev1.RestartPolicyNever)
+
+	container := spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.Name, naming.PGBackRestRestoreContainerName)
+	assert.Equal(t, container.Command[len(container.Command)-2], "SELECT true")
+	assert.Equal(t, container.Command[len(container.Command)-1], strings.Join([]string{
+		"--stanza=db", "--pg1-path=/pgdata/pg13", "--repo=2", "--delta",
+		"--type=immediate", "--target-action=promote", "--link-map=pg_wal=/pgdata/pg13_wal",
+	}, " "))
+
+	var claim string
+	for _, v := range spec.Template.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			claim = v.PersistentVolumeClaim.ClaimName
+		}
+	}
+	assert.Equal(t, claim, volume.Name)
+}
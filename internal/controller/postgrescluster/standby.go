@@ -0,0 +1,253 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventStandbyPromoted is the event reason utilized when the leader of a standby
+	// cluster becomes a primary
+	EventStandbyPromoted = "StandbyPromoted"
+
+	// EventStandbyDemoted is the event reason utilized when the primary of a cluster
+	// becomes the leader of a standby cluster
+	EventStandbyDemoted = "StandbyDemoted"
+)
+
+const (
+	// standbyStatusInterval is how often the replication lag of a standby cluster
+	// is measured.
+	standbyStatusInterval = 30 * time.Second
+
+	// standbyTransitionInterval is how often the leader is checked while a cluster
+	// is being promoted or demoted.
+	standbyTransitionInterval = 5 * time.Second
+)
+
+// standbyStatusSQL reports the replication lag of a standby as a single JSON
+// object with the fields of v1beta1.PostgresStandbyStatus.
+// - https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-RECOVERY-CONTROL
+// - https://www.postgresql.org/docs/current/monitoring-stats.html#MONITORING-PG-STAT-WAL-RECEIVER-VIEW
+const standbyStatusSQL = `
+\set QUIET on
+\pset format unaligned
+\pset tuples_only on
+SELECT pg_catalog.json_build_object(
+  'walReceiverStatus', (SELECT status FROM pg_catalog.pg_stat_wal_receiver),
+  'replayLagBytes', pg_catalog.pg_wal_lsn_diff(
+    pg_catalog.pg_last_wal_receive_lsn(), pg_catalog.pg_last_wal_replay_lsn())::bigint,
+  'replayLagSeconds', EXTRACT(epoch FROM
+    pg_catalog.clock_timestamp() - pg_catalog.pg_last_xact_replay_timestamp())::bigint
+);
+`
+
+// reconcileStandbyStatus measures the replication lag of the standby leader of
+// cluster and records it in the status of cluster. The status is removed when
+// cluster is not a standby.
+func (r *Reconciler) reconcileStandbyStatus(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) (reconcile.Result, error) {
+	var result reconcile.Result
+
+	cluster.Status.Standby = nil
+	if cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled {
+		// Replication lag changes without notifying the reconciler. Check again later.
+		result.RequeueAfter = standbyStatusInterval
+		cluster.Status.Standby = r.measureStandbyStatus(ctx, instances)
+	}
+
+	// Promotion and demotion happen without notifying the reconciler. Check again soon.
+	if r.setStandbyTransitionCondition(cluster, instances) {
+		result.RequeueAfter = standbyTransitionInterval
+	}
+
+	return result, nil
+}
+
+// measureStandbyStatus returns the replication lag of the running standby leader
+// in instances. It returns nil when there is no such leader or the lag cannot be
+// measured.
+func (r *Reconciler) measureStandbyStatus(
+	ctx context.Context, instances *observedInstances,
+) *v1beta1.PostgresStandbyStatus {
+	var leader *Instance
+	if instances != nil {
+		for _, instance := range instances.forCluster {
+			if len(instance.Pods) == 1 && patroni.PodIsStandbyLeader(instance.Pods[0]) &&
+				instanceRunning(instance) {
+				leader = instance
+			}
+		}
+	}
+	if leader == nil {
+		return nil
+	}
+
+	pod := leader.Pods[0]
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+
+	stdout, stderr, err := postgres.Executor(exec).Exec(ctx,
+		strings.NewReader(standbyStatusSQL), nil)
+
+	status := new(v1beta1.PostgresStandbyStatus)
+	if err == nil {
+		err = errors.WithStack(json.Unmarshal([]byte(strings.TrimSpace(stdout)), status))
+	}
+	if err != nil {
+		// PostgreSQL may be starting or stopping. Try again later.
+		logging.FromContext(ctx).V(1).Info("unable to measure standby lag",
+			"error", err.Error(), "stderr", stderr)
+		status = nil
+	}
+
+	return status
+}
+
+// setStandbyTransitionCondition describes the promotion of a standby cluster or the
+// demotion of a primary cluster in the StandbyTransition condition of cluster. Each
+// begins when the spec changes. Promotion ends when Patroni reports a primary, and
+// demotion ends when the standby leader is streaming from its remote host or, without
+// one, running. A primary cannot be demoted without a remote host; that is reported
+// as False with a reason of DemotionRequiresHost. It returns true while either is in
+// progress.
+func (r *Reconciler) setStandbyTransitionCondition(
+	cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) bool {
+	if !patroni.ClusterBootstrapped(cluster) || instances == nil {
+		return false
+	}
+
+	var primary, standbyLeader *Instance
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) != 1 {
+			continue
+		}
+		if patroni.PodIsStandbyLeader(instance.Pods[0]) {
+			standbyLeader = instance
+		} else if isPrimary, _ := instance.IsPrimary(); isPrimary {
+			primary = instance
+		}
+	}
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.StandbyTransition)
+	inProgress := func(reason string) bool {
+		return condition != nil &&
+			condition.Status == metav1.ConditionTrue && condition.Reason == reason
+	}
+	set := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:               v1beta1.StandbyTransition,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: cluster.GetGeneration(),
+		})
+	}
+
+	timeline := func(instance *Instance) string {
+		if value, known := patroni.PodTimeline(instance.Pods[0]); known {
+			return fmt.Sprintf(" on timeline %d", value)
+		}
+		return ""
+	}
+
+	if spec := cluster.Spec.Standby; spec != nil && spec.Enabled {
+		source := spec.Host
+		if source == "" {
+			source = spec.RepoName
+		}
+
+		// A primary that cannot be rewound is not demoted. The webhook rejects this
+		// spec, but it may not be installed.
+		invalid := condition != nil &&
+			condition.Status == metav1.ConditionFalse && condition.Reason == "DemotionRequiresHost"
+
+		switch {
+		case primary != nil && spec.Host == "":
+			// pg_rewind reads from a running PostgreSQL server, so a repository
+			// cannot be the source of a demotion.
+			set(metav1.ConditionFalse, "DemotionRequiresHost", fmt.Sprintf(
+				"%s cannot follow %s; spec.standby.host is required to demote a primary cluster",
+				primary.Name, source))
+			return false
+
+		case primary != nil:
+			set(metav1.ConditionTrue, "Demoting", fmt.Sprintf(
+				"Waiting for %s to stop accepting writes and follow %s", primary.Name, source))
+			return true
+
+		case standbyLeader != nil && (inProgress("Demoting") || invalid) &&
+			spec.Host != "" && !standbyStreaming(cluster):
+			// Patroni reports the standby leader before pg_rewind has finished and
+			// PostgreSQL has connected to the remote host.
+			set(metav1.ConditionTrue, "Demoting", fmt.Sprintf(
+				"Waiting for %s to stream from %s", standbyLeader.Name, source))
+
+		case standbyLeader != nil && (inProgress("Demoting") || invalid) &&
+			spec.Host == "" && !instanceRunning(standbyLeader):
+			// A standby leader without a remote host replays WAL from the repository.
+			set(metav1.ConditionTrue, "Demoting", fmt.Sprintf(
+				"Waiting for %s to replay from %s", standbyLeader.Name, source))
+
+		case standbyLeader != nil && (inProgress("Demoting") || invalid):
+			message := fmt.Sprintf("%s follows %s%s", standbyLeader.Name, source, timeline(standbyLeader))
+			set(metav1.ConditionFalse, "Demoted", message)
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, EventStandbyDemoted, message)
+		}
+		return inProgress("Demoting")
+	}
+
+	switch {
+	case standbyLeader != nil:
+		set(metav1.ConditionTrue, "Promoting", fmt.Sprintf(
+			"Waiting for %s to become primary", standbyLeader.Name))
+		return true
+
+	case primary != nil && inProgress("Promoting"):
+		message := fmt.Sprintf("%s was promoted%s", primary.Name, timeline(primary))
+		set(metav1.ConditionFalse, "Promoted", message)
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, EventStandbyPromoted, message)
+	}
+	return inProgress("Promoting")
+}
+
+// standbyStreaming returns whether or not the standby leader of cluster was last
+// measured streaming WAL from its remote host.
+func standbyStreaming(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Status.Standby != nil &&
+		cluster.Status.Standby.WALReceiverStatus == "streaming"
+}
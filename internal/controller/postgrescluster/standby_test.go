@@ -0,0 +1,238 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileStandbyStatus(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{
+		Enabled: true, Host: "rhino-primary.west.svc",
+	}
+
+	pod := &corev1.Pod{}
+	pod.Namespace, pod.Name = "ns1", "hippo-00-abcd-0"
+	pod.Annotations = map[string]string{"status": `{"role":"standby_leader"}`}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  naming.ContainerDatabase,
+		State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+	}}
+	instances := &observedInstances{forCluster: []*Instance{
+		{Name: "hippo-00-abcd", Pods: []*corev1.Pod{pod}},
+	}}
+
+	var output string
+	var failure error
+	reconciler := &Reconciler{PodExec: func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, _ io.Writer, command ...string,
+	) error {
+		assert.Equal(t, namespace, "ns1")
+		assert.Equal(t, pod, "hippo-00-abcd-0")
+		assert.Equal(t, container, naming.ContainerDatabase)
+
+		b, err := ioutil.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(string(b), "pg_last_wal_replay_lsn"))
+
+		_, _ = io.WriteString(stdout, output)
+		return failure
+	}}
+
+	t.Run("Streaming", func(t *testing.T) {
+		output = `{"walReceiverStatus" : "streaming", "replayLagBytes" : 8192, "replayLagSeconds" : 3}` + "\n"
+
+		result, err := reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, standbyStatusInterval)
+
+		status := cluster.Status.Standby
+		assert.Assert(t, status != nil)
+		assert.Equal(t, status.WALReceiverStatus, "streaming")
+		assert.Equal(t, *status.ReplayLagBytes, int64(8192))
+		assert.Equal(t, *status.ReplayLagSeconds, int64(3))
+	})
+
+	t.Run("NotStreaming", func(t *testing.T) {
+		output = `{"walReceiverStatus" : null, "replayLagBytes" : null, "replayLagSeconds" : null}`
+
+		_, err := reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cluster.Status.Standby, &v1beta1.PostgresStandbyStatus{})
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		output, failure = "", errors.New("boom")
+		defer func() { failure = nil }()
+
+		_, err := reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Assert(t, cluster.Status.Standby == nil)
+	})
+
+	t.Run("Demotion", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		reconciler := *reconciler
+		reconciler.Recorder = recorder
+
+		cluster := cluster.DeepCopy()
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "6952526174828511264"}
+		cluster.Status.Conditions = []metav1.Condition{{
+			Type: v1beta1.StandbyTransition, Status: metav1.ConditionTrue, Reason: "Demoting",
+		}}
+		condition := func() *metav1.Condition {
+			return meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.StandbyTransition)
+		}
+
+		// Patroni reports a standby leader while pg_rewind is running.
+		output = `{"walReceiverStatus" : null, "replayLagBytes" : null, "replayLagSeconds" : null}`
+
+		result, err := reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, standbyTransitionInterval)
+		assert.Equal(t, condition().Reason, "Demoting")
+		assert.Equal(t, condition().Message, "Waiting for hippo-00-abcd to stream from rhino-primary.west.svc")
+		assert.Equal(t, len(recorder.Events), 0)
+
+		output = `{"walReceiverStatus" : "streaming", "replayLagBytes" : 0, "replayLagSeconds" : 0}`
+
+		result, err = reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, standbyStatusInterval)
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, "Demoted")
+		assert.Equal(t, <-recorder.Events,
+			"Normal StandbyDemoted hippo-00-abcd follows rhino-primary.west.svc")
+	})
+
+	t.Run("NotStandby", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Standby.Enabled = false
+		cluster.Status.Standby = &v1beta1.PostgresStandbyStatus{}
+
+		result, err := reconciler.reconcileStandbyStatus(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter.Seconds(), float64(0))
+		assert.Assert(t, cluster.Status.Standby == nil)
+	})
+}
+
+func TestStandbyTransitionCondition(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "6952526174828511264"}
+
+	pod := &corev1.Pod{}
+	pod.Labels = map[string]string{naming.LabelRole: naming.RolePatroniLeader}
+	pod.Annotations = map[string]string{"status": `{"role":"master","timeline":4}`}
+	instances := &observedInstances{forCluster: []*Instance{
+		{Name: "hippo-00-abcd", Pods: []*corev1.Pod{pod}},
+	}}
+
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.StandbyTransition)
+	}
+
+	// Nothing happens to a primary cluster.
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Assert(t, condition() == nil)
+
+	// The primary has not yet become a standby leader.
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true, Host: "rhino-primary"}
+	assert.Assert(t, reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "Demoting")
+	assert.Assert(t, strings.Contains(condition().Message, "rhino-primary"), condition().Message)
+
+	// The standby leader is not yet streaming.
+	pod.Labels[naming.LabelRole] = "standby_leader"
+	pod.Annotations["status"] = `{"role":"standby_leader","timeline":5}`
+	assert.Assert(t, reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "Demoting")
+	assert.Equal(t, len(recorder.Events), 0)
+
+	cluster.Status.Standby = &v1beta1.PostgresStandbyStatus{WALReceiverStatus: "streaming"}
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "Demoted")
+	assert.Equal(t, condition().Message, "hippo-00-abcd follows rhino-primary on timeline 5")
+	assert.Equal(t, <-recorder.Events, "Normal StandbyDemoted hippo-00-abcd follows rhino-primary on timeline 5")
+
+	// Nothing changes while the cluster remains a standby.
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Reason, "Demoted")
+	assert.Equal(t, len(recorder.Events), 0)
+
+	// The standby leader has not yet been promoted.
+	cluster.Spec.Standby.Enabled = false
+	assert.Assert(t, reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "Promoting")
+
+	pod.Labels[naming.LabelRole] = naming.RolePatroniLeader
+	pod.Annotations["status"] = `{"role":"master","timeline":6}`
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "Promoted")
+	assert.Equal(t, <-recorder.Events, "Normal StandbyPromoted hippo-00-abcd was promoted on timeline 6")
+
+	// A primary cannot be demoted without a remote host.
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true, RepoName: "repo1"}
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "DemotionRequiresHost")
+	assert.Assert(t, strings.Contains(condition().Message, "spec.standby.host"), condition().Message)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	// A standby leader that follows only a repository is demoted once it is running.
+	cluster.Status.Standby = nil
+	pod.Labels[naming.LabelRole] = "standby_leader"
+	pod.Annotations["status"] = `{"role":"standby_leader","timeline":6}`
+	assert.Assert(t, reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "Demoting")
+	assert.Assert(t, strings.Contains(condition().Message, "replay from repo1"), condition().Message)
+
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  naming.ContainerDatabase,
+		State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+	}}
+	assert.Assert(t, !reconciler.setStandbyTransitionCondition(cluster, instances))
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "Demoted")
+	assert.Equal(t, <-recorder.Events, "Normal StandbyDemoted hippo-00-abcd follows repo1 on timeline 6")
+}
@@ -0,0 +1,209 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// recordLastBackupTime stores completed as the time of the most recent backup of cluster when
+// it is later than the time already stored.
+func recordLastBackupTime(cluster *v1beta1.PostgresCluster, completed *metav1.Time) {
+	if completed == nil || cluster.Status.PGBackRest == nil {
+		return
+	}
+	if last := cluster.Status.PGBackRest.LastBackupTime; last == nil || last.Before(completed) {
+		cluster.Status.PGBackRest.LastBackupTime = completed.DeepCopy()
+	}
+}
+
+// updateReadyStatus summarizes the status of cluster and its instances in the Ready condition
+// and phase of cluster. It also records the Pod of the primary and the number of ready
+// instances. When instances is nil, the instances of cluster could not be observed and the
+// status is left as it was.
+func updateReadyStatus(cluster *v1beta1.PostgresCluster, instances *observedInstances) {
+	if instances == nil {
+		return
+	}
+
+	var primary *Instance
+	for _, instance := range instances.forCluster {
+		if isPrimary, known := instance.IsPrimary(); isPrimary && known {
+			primary = instance
+		}
+	}
+
+	cluster.Status.Primary = ""
+	if primary != nil {
+		cluster.Status.Primary = primary.Pods[0].GetName()
+	}
+
+	var desiredInstances int32
+	for _, set := range cluster.Spec.InstanceSets {
+		if set.Replicas != nil {
+			desiredInstances += *set.Replicas
+		}
+	}
+	cluster.Status.ReadyInstances = 0
+	for _, set := range cluster.Status.InstanceSets {
+		cluster.Status.ReadyInstances += set.ReadyReplicas
+	}
+
+	if cluster.Status.PGBackRest != nil {
+		if manual := cluster.Status.PGBackRest.ManualBackup; manual != nil {
+			recordLastBackupTime(cluster, manual.CompletionTime)
+		}
+		for _, scheduled := range cluster.Status.PGBackRest.ScheduledBackups {
+			if scheduled.Type != verify && scheduled.Type != restoreTest {
+				recordLastBackupTime(cluster, scheduled.CompletionTime)
+			}
+		}
+	}
+
+	ready := metav1.Condition{
+		Type:   v1beta1.Ready,
+		Status: metav1.ConditionFalse,
+
+		ObservedGeneration: cluster.GetGeneration(),
+	}
+	phase := v1beta1.PhaseNotReady
+
+	switch {
+	case cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown:
+		phase = v1beta1.PhaseShutdown
+		ready.Reason = "Shutdown"
+		ready.Message = "The cluster is shut down"
+
+	case meta.IsStatusConditionTrue(cluster.Status.Conditions,
+		ConditionPGBackRestRestoreProgressing):
+		phase = v1beta1.PhaseRestoring
+		ready.Reason = "Restoring"
+		ready.Message = "PostgreSQL data is being restored"
+
+	case meta.IsStatusConditionTrue(cluster.Status.Conditions, ConditionPGUpgradeProgressing):
+		phase = v1beta1.PhaseUpgrading
+		ready.Reason = "Upgrading"
+		ready.Message = "PostgreSQL is being upgraded to a new major version"
+
+	case cluster.Status.Patroni == nil || cluster.Status.Patroni.SystemIdentifier == "":
+		phase = v1beta1.PhaseInitializing
+		ready.Reason = "Initializing"
+		ready.Message = "PostgreSQL is not yet initialized"
+
+	case primary == nil:
+		ready.Reason = "NoPrimary"
+		ready.Message = "There is no PostgreSQL primary"
+
+	case !instanceReady(primary):
+		ready.Reason = "PrimaryNotReady"
+		ready.Message = fmt.Sprintf("The PostgreSQL primary %q is not ready",
+			cluster.Status.Primary)
+
+	case cluster.Status.ReadyInstances < desiredInstances:
+		ready.Reason = "InstancesNotReady"
+		ready.Message = fmt.Sprintf("%d of %d PostgreSQL instances are ready",
+			cluster.Status.ReadyInstances, desiredInstances)
+
+	case !repoStanzasCreated(cluster):
+		ready.Reason = "StanzaNotCreated"
+		ready.Message = "The pgBackRest stanza is not created in every repo"
+
+	case cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.RepoHost != nil &&
+		!cluster.Status.PGBackRest.RepoHost.Ready:
+		ready.Reason = "RepoHostNotReady"
+		ready.Message = "The pgBackRest repo host is not ready"
+
+	case cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil &&
+		!meta.IsStatusConditionTrue(cluster.Status.Conditions, v1beta1.ProxyAvailable):
+		ready.Reason = "ProxyNotAvailable"
+		ready.Message = "The PostgreSQL proxy is not available"
+
+	case meta.IsStatusConditionTrue(cluster.Status.Conditions,
+		v1beta1.PersistentVolumeResizing):
+		ready.Reason = v1beta1.PersistentVolumeResizing
+		ready.Message = "One or more volumes are changing size"
+
+	default:
+		phase = v1beta1.PhaseReady
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "AllComponentsReady"
+		ready.Message = "The cluster is ready"
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, ready)
+	cluster.Status.Phase = phase
+}
+
+// instanceReady returns true when the Pod of instance is known to be ready.
+func instanceReady(instance *Instance) bool {
+	ready, known := instance.IsReady()
+	return ready && known
+}
+
+// repoStanzasCreated returns true when a pgBackRest stanza has been created in every repo of
+// cluster. The stanza of a standby cluster belongs to its primary cluster, so it is not
+// considered.
+func repoStanzasCreated(cluster *v1beta1.PostgresCluster) bool {
+	if cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled {
+		return true
+	}
+	if cluster.Status.PGBackRest == nil ||
+		len(cluster.Status.PGBackRest.Repos) < len(cluster.Spec.Backups.PGBackRest.Repos) {
+		return false
+	}
+	for _, repo := range cluster.Status.PGBackRest.Repos {
+		if !repo.StanzaCreated {
+			return false
+		}
+	}
+	return true
+}
+
+// setPendingRestartCondition sets the PendingRestart condition of cluster to
+// list the instances whose PostgreSQL must restart to apply changes to its
+// parameters. The condition is removed when there are none.
+func setPendingRestartCondition(cluster *v1beta1.PostgresCluster, instances *observedInstances) {
+	var pending []string
+	for _, instance := range instances.forCluster {
+		if restart, known := instance.IsPendingRestart(); restart && known {
+			pending = append(pending, instance.Name)
+		}
+	}
+	sort.Strings(pending)
+
+	if len(pending) > 0 {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:   v1beta1.PendingRestart,
+			Status: metav1.ConditionTrue,
+			Reason: "ParametersChanged",
+			Message: fmt.Sprintf("PostgreSQL must restart to apply parameters on: %s",
+				strings.Join(pending, ", ")),
+
+			ObservedGeneration: cluster.Generation,
+		})
+	} else if len(cluster.Status.Conditions) > 0 {
+		// Avoid a panic! Fixed in Kubernetes v1.21.0 and controller-runtime v0.9.0-alpha.0.
+		// - https://issue.k8s.io/99714
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1beta1.PendingRestart)
+	}
+}
@@ -0,0 +1,254 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestRecordLastBackupTime(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	earlier := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Hour))
+
+	// Nothing is recorded without pgBackRest status.
+	recordLastBackupTime(cluster, &later)
+	assert.Assert(t, cluster.Status.PGBackRest == nil)
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{}
+	recordLastBackupTime(cluster, nil)
+	assert.Assert(t, cluster.Status.PGBackRest.LastBackupTime == nil)
+
+	recordLastBackupTime(cluster, &later)
+	assert.Assert(t, cluster.Status.PGBackRest.LastBackupTime.Equal(&later))
+
+	// The time does not move backward.
+	recordLastBackupTime(cluster, &earlier)
+	assert.Assert(t, cluster.Status.PGBackRest.LastBackupTime.Equal(&later))
+}
+
+func TestUpdateReadyStatus(t *testing.T) {
+	instance := func(name string, primary, ready bool) *Instance {
+		pod := &corev1.Pod{}
+		pod.Name = name + "-0"
+		pod.Labels = map[string]string{naming.LabelRole: naming.RolePatroniReplica}
+		if primary {
+			pod.Labels[naming.LabelRole] = naming.RolePatroniLeader
+		}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionFalse,
+		}}
+		if ready {
+			pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}}
+	}
+
+	readyCluster := func() *v1beta1.PostgresCluster {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Generation = 3
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{
+			Name: "00", Replicas: initialize.Int32(2),
+		}}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+		cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{
+			Name: "00", ReadyReplicas: 2, Replicas: 2,
+		}}
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "6952"}
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+		}
+		return cluster
+	}
+	readyInstances := func() *observedInstances {
+		return &observedInstances{forCluster: []*Instance{
+			instance("hippo-00-abcd", true, true),
+			instance("hippo-00-efgh", false, true),
+		}}
+	}
+
+	t.Run("Ready", func(t *testing.T) {
+		cluster := readyCluster()
+		completed := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+		cluster.Status.PGBackRest.ScheduledBackups = []v1beta1.PGBackRestScheduledBackupStatus{
+			{CompletionTime: &completed},
+		}
+
+		updateReadyStatus(cluster, readyInstances())
+
+		assert.Equal(t, cluster.Status.Phase, v1beta1.PhaseReady)
+		assert.Equal(t, cluster.Status.Primary, "hippo-00-abcd-0")
+		assert.Equal(t, cluster.Status.ReadyInstances, int32(2))
+		assert.Assert(t, cluster.Status.PGBackRest.LastBackupTime.Equal(&completed))
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.Ready)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.ObservedGeneration, int64(3))
+	})
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*v1beta1.PostgresCluster, *observedInstances)
+		phase  string
+		reason string
+	}{
+		{
+			name: "Shutdown", phase: v1beta1.PhaseShutdown, reason: "Shutdown",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Spec.Shutdown = initialize.Bool(true)
+				cluster.Status.InstanceSets = nil
+			},
+		},
+		{
+			name: "Restoring", phase: v1beta1.PhaseRestoring, reason: "Restoring",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+					Type: ConditionPGBackRestRestoreProgressing, Reason: "Test",
+					Status: metav1.ConditionTrue,
+				})
+			},
+		},
+		{
+			name: "Upgrading", phase: v1beta1.PhaseUpgrading, reason: "Upgrading",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+					Type: ConditionPGUpgradeProgressing, Reason: "Test",
+					Status: metav1.ConditionTrue,
+				})
+			},
+		},
+		{
+			name: "Initializing", phase: v1beta1.PhaseInitializing, reason: "Initializing",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Status.Patroni = nil
+			},
+		},
+		{
+			name: "NoPrimary", phase: v1beta1.PhaseNotReady, reason: "NoPrimary",
+			modify: func(_ *v1beta1.PostgresCluster, instances *observedInstances) {
+				instances.forCluster[0].Pods[0].Labels[naming.LabelRole] = naming.RolePatroniReplica
+			},
+		},
+		{
+			name: "PrimaryNotReady", phase: v1beta1.PhaseNotReady, reason: "PrimaryNotReady",
+			modify: func(_ *v1beta1.PostgresCluster, instances *observedInstances) {
+				instances.forCluster[0].Pods[0].Status.Conditions[0].Status = corev1.ConditionFalse
+			},
+		},
+		{
+			name: "InstancesNotReady", phase: v1beta1.PhaseNotReady, reason: "InstancesNotReady",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Status.InstanceSets[0].ReadyReplicas = 1
+			},
+		},
+		{
+			name: "StanzaNotCreated", phase: v1beta1.PhaseNotReady, reason: "StanzaNotCreated",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Status.PGBackRest.Repos[0].StanzaCreated = false
+			},
+		},
+		{
+			name: "RepoHostNotReady", phase: v1beta1.PhaseNotReady, reason: "RepoHostNotReady",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Status.PGBackRest.RepoHost = &v1beta1.RepoHostStatus{Ready: false}
+			},
+		},
+		{
+			name: "ProxyNotAvailable", phase: v1beta1.PhaseNotReady, reason: "ProxyNotAvailable",
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+					PGBouncer: &v1beta1.PGBouncerPodSpec{},
+				}
+			},
+		},
+		{
+			name: "PersistentVolumeResizing", phase: v1beta1.PhaseNotReady,
+			reason: v1beta1.PersistentVolumeResizing,
+			modify: func(cluster *v1beta1.PostgresCluster, _ *observedInstances) {
+				meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+					Type: v1beta1.PersistentVolumeResizing, Reason: "Test",
+					Status: metav1.ConditionTrue,
+				})
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, instances := readyCluster(), readyInstances()
+			tt.modify(cluster, instances)
+
+			updateReadyStatus(cluster, instances)
+			assert.Equal(t, cluster.Status.Phase, tt.phase)
+
+			condition := meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.Ready)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionFalse)
+			assert.Equal(t, condition.Reason, tt.reason)
+		})
+	}
+
+	t.Run("UnknownInstances", func(t *testing.T) {
+		cluster := readyCluster()
+		updateReadyStatus(cluster, readyInstances())
+		before := cluster.Status.DeepCopy()
+
+		// A transient error observing instances does not change the summary.
+		updateReadyStatus(cluster, nil)
+		assert.DeepEqual(t, cluster.Status, *before)
+		assert.Equal(t, cluster.Status.Phase, v1beta1.PhaseReady)
+	})
+}
+
+func TestSetPendingRestartCondition(t *testing.T) {
+	instance := func(name, status string) *Instance {
+		pod := &corev1.Pod{}
+		pod.Annotations = map[string]string{"status": status}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}}
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Generation = 2
+	instances := &observedInstances{forCluster: []*Instance{
+		instance("hippo-00-efgh", `{"role":"replica","pending_restart":true}`),
+		instance("hippo-00-abcd", `{"role":"master","pending_restart":true}`),
+		instance("hippo-00-ijkl", `{"role":"replica"}`),
+	}}
+
+	setPendingRestartCondition(cluster, instances)
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.PendingRestart)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.ObservedGeneration, int64(2))
+	assert.Assert(t, strings.HasSuffix(condition.Message, "hippo-00-abcd, hippo-00-efgh"),
+		"got %q", condition.Message)
+
+	// The condition is removed when nothing is pending.
+	instances.forCluster = instances.forCluster[2:]
+	setPendingRestartCondition(cluster, instances)
+	assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.PendingRestart) == nil)
+}
@@ -0,0 +1,239 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventSwitchoverComplete is the event reason utilized when a requested switchover
+	// changes the primary
+	EventSwitchoverComplete = "SwitchoverComplete"
+
+	// EventSwitchoverFailed is the event reason utilized when a requested switchover does not
+	// change the primary
+	EventSwitchoverFailed = "SwitchoverFailed"
+
+	// EventInvalidSwitchoverTarget is the event reason utilized when the target of a requested
+	// switchover cannot become primary
+	EventInvalidSwitchoverTarget = "InvalidSwitchoverTarget"
+)
+
+// switchoverCandidate returns the instance that should become primary according to spec. It
+// returns nil when Patroni should choose. Only instances that may become primary are chosen.
+// Without a target instance, the ready replica in the instance set with the highest failover
+// priority that has received the most WAL is chosen.
+func switchoverCandidate(
+	spec *v1beta1.PatroniSwitchover, instances *observedInstances,
+) (*Instance, error) {
+	replica := func(instance *Instance) bool {
+		primary, known := instance.IsPrimary()
+		return known && !primary
+	}
+
+	if spec.TargetInstance != nil {
+		instance := instances.byName[*spec.TargetInstance]
+		if instance == nil {
+			return nil, errors.Errorf("instance %q does not exist", *spec.TargetInstance)
+		}
+		if !replica(instance) {
+			return nil, errors.Errorf("instance %q is not a replica", instance.Name)
+		}
+		if !instanceFailoverCandidate(instance) {
+			return nil, errors.Errorf("instance %q is not a failover candidate", instance.Name)
+		}
+		if running, known := instance.IsRunning(naming.ContainerDatabase); !running || !known {
+			return nil, errors.Errorf("instance %q is not running", instance.Name)
+		}
+		return instance, nil
+	}
+
+	consider := instances.forCluster
+	if spec.TargetInstanceSet != nil {
+		consider = instances.bySet[*spec.TargetInstanceSet]
+	}
+
+	var best *Instance
+	var bestPosition int64
+	var bestPriority int32
+	for _, instance := range consider {
+		available, known := instance.IsAvailable()
+		if !replica(instance) || !available || !known || !instanceFailoverCandidate(instance) {
+			continue
+		}
+
+		priority := instanceFailoverPriority(instance)
+		position, _ := patroni.PodWALPosition(instance.Pods[0])
+		if best == nil || priority > bestPriority ||
+			(priority == bestPriority && position > bestPosition) ||
+			(priority == bestPriority && position == bestPosition && instance.Name < best.Name) {
+			best, bestPosition, bestPriority = instance, position, priority
+		}
+	}
+	if best == nil && spec.TargetInstanceSet != nil {
+		return nil, errors.Errorf("instance set %q has no ready replica that is a failover candidate",
+			*spec.TargetInstanceSet)
+	}
+	return best, nil
+}
+
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// reconcilePatroniSwitchover changes the primary of cluster once for every value of the
+// switchover annotation, when switchovers are enabled. The outcome is recorded in the
+// Patroni status of cluster and in an event.
+func (r *Reconciler) reconcilePatroniSwitchover(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	spec := cluster.Spec.Patroni.Switchover
+	trigger := cluster.GetAnnotations()[naming.PatroniSwitchover]
+
+	if spec == nil || !spec.Enabled || trigger == "" ||
+		!patroni.ClusterBootstrapped(cluster) {
+		return nil
+	}
+	if status := cluster.Status.Patroni.Switchover; status != nil && status.ID == trigger {
+		// This switchover already happened.
+		return nil
+	}
+
+	status := &v1beta1.PatroniSwitchoverStatus{ID: trigger, Type: spec.Type}
+	record := func(reason, message string) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Message = message
+		cluster.Status.Patroni.Switchover = status
+
+		eventType := corev1.EventTypeWarning
+		if status.Succeeded {
+			eventType = corev1.EventTypeNormal
+		}
+		r.Recorder.Event(cluster, eventType, reason, message)
+	}
+
+	var primary *Instance
+	for _, instance := range instances.forCluster {
+		if isPrimary, known := instance.IsPrimary(); isPrimary && known {
+			primary = instance
+		}
+	}
+	if primary != nil {
+		status.OldPrimary = primary.Name
+	}
+
+	candidate, err := switchoverCandidate(spec, instances)
+	if err == nil && candidate == nil && spec.Type == v1beta1.PatroniSwitchoverTypeFailover {
+		err = errors.New("a failover requires targetInstance or targetInstanceSet")
+	}
+	if err != nil {
+		record(EventInvalidSwitchoverTarget, err.Error())
+		return nil
+	}
+
+	// A switchover is coordinated by a healthy primary while a failover is not.
+	// Patroni can be reached through any of its members, so run commands in the
+	// candidate when there is one.
+	var pod *corev1.Pod
+	switch {
+	case candidate != nil:
+		pod = candidate.Pods[0]
+	case primary != nil:
+		pod = primary.Pods[0]
+	}
+	if spec.Type != v1beta1.PatroniSwitchoverTypeFailover && (primary == nil ||
+		!instanceRunning(primary)) {
+		record(EventSwitchoverFailed, "there is no running primary to switch over from")
+		return nil
+	}
+
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+
+	var span trace.Span
+	ctx, span = r.Tracer.Start(ctx, "patroni-switchover")
+	defer span.End()
+
+	var success bool
+	if spec.Type == v1beta1.PatroniSwitchoverTypeFailover {
+		success, err = patroni.Executor(exec).FailoverAndWait(ctx, pod.Name)
+	} else {
+		var next string
+		if candidate != nil {
+			next = candidate.Pods[0].Name
+		}
+		success, err = patroni.Executor(exec).ChangePrimaryAndWait(
+			ctx, primary.Pods[0].Name, next)
+	}
+	if err != nil {
+		// Patroni may have changed the primary before the command failed. Record
+		// the outcome so the same annotation value does not switch over again.
+		err = errors.WithStack(err)
+		span.RecordError(err)
+		record(EventSwitchoverFailed, fmt.Sprintf(
+			"%s from %q did not complete: %v", spec.Type, status.OldPrimary, err))
+		return nil
+	}
+
+	// Patroni names its members after their Pods. Report the instance instead.
+	leader, timeline, err := patroni.Executor(exec).GetLeader(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "unable to identify the primary after switchover")
+	}
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) > 0 && instance.Pods[0].Name == leader {
+			status.NewPrimary = instance.Name
+		}
+	}
+	if leader != "" {
+		status.Timeline = &timeline
+	}
+
+	status.Succeeded = success && status.NewPrimary != "" &&
+		status.NewPrimary != status.OldPrimary &&
+		(candidate == nil || status.NewPrimary == candidate.Name)
+
+	if status.Succeeded {
+		record(EventSwitchoverComplete, fmt.Sprintf(
+			"%s from %q to %q on timeline %d",
+			spec.Type, status.OldPrimary, status.NewPrimary, timeline))
+	} else {
+		record(EventSwitchoverFailed, fmt.Sprintf(
+			"%s did not change the primary from %q", spec.Type, status.OldPrimary))
+	}
+	return nil
+}
+
+// instanceRunning returns true when the database container of instance is known to be
+// running and its Pod is not terminating.
+func instanceRunning(instance *Instance) bool {
+	running, knownRunning := instance.IsRunning(naming.ContainerDatabase)
+	terminating, knownTerminating := instance.IsTerminating()
+	return running && knownRunning && !terminating && knownTerminating
+}
@@ -0,0 +1,286 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestSwitchoverCandidate(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}, {Name: "01"}}
+	instances := newObservedInstances(cluster, nil, []corev1.Pod{
+		switchoverPod("hippo-00-aaaa", "00", naming.RolePatroniLeader, `{"xlog_location":500}`),
+		switchoverPod("hippo-00-bbbb", "00", naming.RolePatroniReplica, `{"xlog_location":400}`),
+		switchoverPod("hippo-01-cccc", "01", naming.RolePatroniReplica, `{"xlog_location":300}`),
+		switchoverPod("hippo-01-dddd", "01", naming.RolePatroniReplica, `{"xlog_location":450}`),
+	})
+
+	t.Run("NoTarget", func(t *testing.T) {
+		// The replica with the most WAL is chosen.
+		candidate, err := switchoverCandidate(&v1beta1.PatroniSwitchover{}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-01-dddd")
+	})
+
+	t.Run("FailoverPriority", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		instances := newObservedInstances(cluster, nil, []corev1.Pod{
+			switchoverPod("hippo-00-aaaa", "00", naming.RolePatroniLeader, `{"xlog_location":500}`),
+			switchoverPod("hippo-00-bbbb", "00", naming.RolePatroniReplica, `{"xlog_location":400}`),
+			switchoverPod("hippo-01-cccc", "01", naming.RolePatroniReplica, `{"xlog_location":300}`),
+			switchoverPod("hippo-01-dddd", "01", naming.RolePatroniReplica, `{"xlog_location":450}`),
+		})
+
+		// Replicas in a set with higher priority are chosen first.
+		cluster.Spec.InstanceSets[0].Patroni = &v1beta1.PatroniInstanceSetSpec{FailoverPriority: 2}
+		candidate, err := switchoverCandidate(&v1beta1.PatroniSwitchover{}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-00-bbbb")
+
+		// Replicas that cannot become primary are never chosen.
+		cluster.Spec.InstanceSets[0].Patroni = &v1beta1.PatroniInstanceSetSpec{
+			FailoverRole: v1beta1.PatroniFailoverRoleReplica, FailoverPriority: 2,
+		}
+		candidate, err = switchoverCandidate(&v1beta1.PatroniSwitchover{}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-01-dddd")
+
+		_, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstance: initialize.String("hippo-00-bbbb"),
+		}, instances)
+		assert.ErrorContains(t, err, `"hippo-00-bbbb" is not a failover candidate`)
+
+		_, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstanceSet: initialize.String("00"),
+		}, instances)
+		assert.ErrorContains(t, err, `"00" has no ready replica`)
+	})
+
+	t.Run("TargetInstance", func(t *testing.T) {
+		candidate, err := switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstance: initialize.String("hippo-01-cccc"),
+		}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-01-cccc")
+
+		_, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstance: initialize.String("hippo-00-aaaa"),
+		}, instances)
+		assert.ErrorContains(t, err, `"hippo-00-aaaa" is not a replica`)
+
+		_, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstance: initialize.String("missing"),
+		}, instances)
+		assert.ErrorContains(t, err, `"missing" does not exist`)
+	})
+
+	t.Run("TargetInstanceSet", func(t *testing.T) {
+		// The replica with the most WAL is chosen.
+		candidate, err := switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstanceSet: initialize.String("01"),
+		}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-01-dddd")
+
+		// The primary is never chosen.
+		candidate, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstanceSet: initialize.String("00"),
+		}, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, candidate.Name, "hippo-00-bbbb")
+
+		_, err = switchoverCandidate(&v1beta1.PatroniSwitchover{
+			TargetInstanceSet: initialize.String("02"),
+		}, instances)
+		assert.ErrorContains(t, err, `"02" has no ready replica`)
+	})
+}
+
+func TestReconcilePatroniSwitchover(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(stdout string) (*Reconciler, *v1beta1.PostgresCluster, *[][]string) {
+		var commands [][]string
+		reconciler := &Reconciler{
+			Recorder: record.NewFakeRecorder(10),
+			Tracer:   otel.Tracer(t.Name()),
+			PodExec: func(
+				namespace, pod, container string,
+				stdin io.Reader, out, stderr io.Writer, command ...string,
+			) error {
+				commands = append(commands, append([]string{pod}, command...))
+				if command[1] == "list" {
+					_, _ = out.Write([]byte(`[
+						{"Member": "hippo-00-aaaa-0", "Role": "Replica", "TL": 2},
+						{"Member": "hippo-00-bbbb-0", "Role": "Leader", "TL": 2}
+					]`))
+				} else {
+					_, _ = out.Write([]byte(stdout))
+				}
+				return nil
+			},
+		}
+
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Annotations = map[string]string{naming.PatroniSwitchover: "one"}
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+			Switchover: &v1beta1.PatroniSwitchover{
+				Enabled: true, Type: v1beta1.PatroniSwitchoverTypeSwitchover,
+				TargetInstance: initialize.String("hippo-00-bbbb"),
+			},
+		}
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "6952"}
+		return reconciler, cluster, &commands
+	}
+
+	instances := func(cluster *v1beta1.PostgresCluster) *observedInstances {
+		return newObservedInstances(cluster, nil, []corev1.Pod{
+			switchoverPod("hippo-00-aaaa", "00", naming.RolePatroniLeader, ""),
+			switchoverPod("hippo-00-bbbb", "00", naming.RolePatroniReplica, ""),
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		reconciler, cluster, commands := setup("")
+		cluster.Spec.Patroni.Switchover.Enabled = false
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+		assert.Equal(t, len(*commands), 0)
+		assert.Assert(t, cluster.Status.Patroni.Switchover == nil)
+	})
+
+	t.Run("Switchover", func(t *testing.T) {
+		reconciler, cluster, commands := setup(`Successfully switched over to "hippo-00-bbbb-0"`)
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+		assert.DeepEqual(t, (*commands)[0], strings.Fields(
+			`hippo-00-bbbb-0 patronictl switchover --scheduled=now --force`+
+				` --master=hippo-00-aaaa-0 --candidate=hippo-00-bbbb-0`))
+
+		status := cluster.Status.Patroni.Switchover
+		assert.Assert(t, status != nil)
+		assert.Equal(t, status.ID, "one")
+		assert.Assert(t, status.Succeeded)
+		assert.Equal(t, status.OldPrimary, "hippo-00-aaaa")
+		assert.Equal(t, status.NewPrimary, "hippo-00-bbbb")
+		assert.Equal(t, *status.Timeline, int64(2))
+		assert.Assert(t, status.CompletionTime != nil)
+		assert.Equal(t, cluster.Status.Patroni.SystemIdentifier, "6952")
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Normal SwitchoverComplete"), "got %q", event)
+
+		// The same annotation value does not switch over again.
+		*commands = nil
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+		assert.Equal(t, len(*commands), 0)
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		reconciler, cluster, commands := setup(`Successfully failed over to "hippo-00-bbbb-0"`)
+		cluster.Spec.Patroni.Switchover.Type = v1beta1.PatroniSwitchoverTypeFailover
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+		assert.DeepEqual(t, (*commands)[0], strings.Fields(
+			`hippo-00-bbbb-0 patronictl failover --force --candidate=hippo-00-bbbb-0`))
+		assert.Assert(t, cluster.Status.Patroni.Switchover.Succeeded)
+	})
+
+	t.Run("Unsuccessful", func(t *testing.T) {
+		reconciler, cluster, _ := setup(`Switchover failed`)
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+
+		status := cluster.Status.Patroni.Switchover
+		assert.Assert(t, !status.Succeeded)
+		assert.Equal(t, status.ID, "one")
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Warning SwitchoverFailed"), "got %q", event)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		reconciler, cluster, _ := setup("")
+		reconciler.PodExec = func(
+			namespace, pod, container string,
+			stdin io.Reader, out, stderr io.Writer, command ...string,
+		) error {
+			return errors.New("connection refused")
+		}
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+
+		status := cluster.Status.Patroni.Switchover
+		assert.Assert(t, status != nil)
+		assert.Assert(t, !status.Succeeded)
+		assert.Equal(t, status.ID, "one")
+		assert.Assert(t, strings.Contains(status.Message, "connection refused"), "got %q", status.Message)
+		assert.Assert(t, status.CompletionTime != nil)
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Warning SwitchoverFailed"), "got %q", event)
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		reconciler, cluster, commands := setup("")
+		cluster.Spec.Patroni.Switchover.TargetInstance = initialize.String("hippo-00-aaaa")
+
+		assert.NilError(t, reconciler.reconcilePatroniSwitchover(ctx, cluster, instances(cluster)))
+		assert.Equal(t, len(*commands), 0)
+
+		status := cluster.Status.Patroni.Switchover
+		assert.Assert(t, !status.Succeeded)
+		assert.Assert(t, strings.Contains(status.Message, "not a replica"), "got %q", status.Message)
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Warning InvalidSwitchoverTarget"), "got %q", event)
+	})
+}
+
+// switchoverPod returns a ready and running instance Pod with the Patroni role and status.
+func switchoverPod(instance, set, role, status string) corev1.Pod {
+	pod := corev1.Pod{}
+	pod.Name = instance + "-0"
+	pod.Labels = map[string]string{
+		naming.LabelInstance:    instance,
+		naming.LabelInstanceSet: set,
+		naming.LabelRole:        role,
+	}
+	pod.Annotations = map[string]string{"status": status}
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type: corev1.PodReady, Status: corev1.ConditionTrue,
+	}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  naming.ContainerDatabase,
+		State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+	}}
+	return pod
+}
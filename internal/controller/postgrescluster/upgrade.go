@@ -0,0 +1,694 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionPGUpgradeProgressing is the type used in a condition to indicate that a major
+	// PostgreSQL upgrade is in progress. Its reason identifies the current step of the upgrade.
+	ConditionPGUpgradeProgressing = "PGUpgradeProgressing"
+
+	// ReasonPGUpgradeBackup is the reason utilized within ConditionPGUpgradeProgressing while
+	// a full pgBackRest backup is taken before the upgrade.
+	ReasonPGUpgradeBackup = "PGUpgradeBackup"
+
+	// ReasonPGUpgradeStopping is the reason utilized within ConditionPGUpgradeProgressing
+	// while all instances and Patroni Endpoints are removed.
+	ReasonPGUpgradeStopping = "PGUpgradeStopping"
+
+	// ReasonPGUpgradeRunning is the reason utilized within ConditionPGUpgradeProgressing while
+	// pg_upgrade runs against the data directory of the primary.
+	ReasonPGUpgradeRunning = "PGUpgradeRunning"
+
+	// ReasonPGUpgradeStanzaUpgrade is the reason utilized within ConditionPGUpgradeProgressing
+	// once the upgraded primary is started and the pgBackRest stanza needs to be upgraded.
+	ReasonPGUpgradeStanzaUpgrade = "PGUpgradeStanzaUpgrade"
+
+	// ReasonPGUpgradeComplete is the reason utilized within ConditionPGUpgradeProgressing once
+	// the upgrade is finished.
+	ReasonPGUpgradeComplete = "PGUpgradeComplete"
+
+	// EventPGUpgradeFailed is the event reason utilized when a step of a major PostgreSQL
+	// upgrade fails
+	EventPGUpgradeFailed = "PGUpgradeFailed"
+
+	// EventPGUpgradeCancelled is the event reason utilized when a major PostgreSQL upgrade is
+	// no longer requested before it completes
+	EventPGUpgradeCancelled = "PGUpgradeCancelled"
+
+	// EventPGUpgradeComplete is the event reason utilized when a major PostgreSQL upgrade
+	// completes successfully
+	EventPGUpgradeComplete = "PGUpgradeComplete"
+)
+
+// reconcileMajorUpgrade is responsible for upgrading the PostgreSQL data directory of the
+// PostgresCluster to a new major version using pg_upgrade. An upgrade is requested by enabling
+// "spec.upgrade" and changing "spec.postgresVersion" from "spec.upgrade.fromPostgresVersion".
+// The upgrade proceeds in steps, each identified by the reason of the "PGUpgradeProgressing"
+// condition:
+//  1. A full pgBackRest backup of the running cluster is taken.
+//  2. All instance runners and Patroni Endpoints are removed.
+//  3. pg_upgrade runs in a Job against the data volume of the former primary.
+//  4. The former primary is bootstrapped with the upgraded data directory, after which
+//     reconcileStanzaUpgrade upgrades the pgBackRest stanza. The other instances start once
+//     a new replica creation backup completes.
+//
+// The bool returned indicates that the controller should return early while the cluster is not
+// running, i.e. until pg_upgrade has completed.
+func (r *Reconciler) reconcileMajorUpgrade(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, observed *observedInstances) (bool, error) {
+
+	upgrade := cluster.Spec.Upgrade
+	requested := upgrade != nil && upgrade.Enabled != nil && *upgrade.Enabled &&
+		upgrade.FromPostgresVersion < cluster.Spec.PostgresVersion
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGUpgradeProgressing)
+	progressing := condition != nil && condition.Status == metav1.ConditionTrue
+
+	// Once pg_upgrade has completed, the data directory can only move forward. The remaining
+	// step happens after pgBackRest is reconciled.
+	if progressing && condition.Reason == ReasonPGUpgradeStanzaUpgrade {
+		return false, nil
+	}
+
+	status := cluster.Status.Upgrade
+	finished := status != nil && status.Finished &&
+		status.ToPostgresVersion == cluster.Spec.PostgresVersion
+
+	if !requested || finished {
+		if progressing {
+			return r.cancelMajorUpgrade(ctx, cluster)
+		}
+		return false, nil
+	}
+
+	// start a new upgrade when one is not already in progress for the requested versions
+	if !progressing || status == nil ||
+		status.FromPostgresVersion != upgrade.FromPostgresVersion ||
+		status.ToPostgresVersion != cluster.Spec.PostgresVersion {
+
+		now := metav1.Now()
+		cluster.Status.Upgrade = &v1beta1.PGUpgradeStatus{
+			FromPostgresVersion: upgrade.FromPostgresVersion,
+			ToPostgresVersion:   cluster.Spec.PostgresVersion,
+			StartTime:           &now,
+		}
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeBackup,
+			"Taking a full backup before upgrading")
+
+		return true, nil
+	}
+
+	var err error
+	switch condition.Reason {
+	case ReasonPGUpgradeBackup:
+		err = r.reconcileUpgradeBackup(ctx, cluster, observed)
+	case ReasonPGUpgradeStopping:
+		err = r.prepareForUpgrade(ctx, cluster, observed)
+	case ReasonPGUpgradeRunning:
+		var running bool
+		running, err = r.reconcileUpgradeJob(ctx, cluster)
+		if err == nil && !running {
+			// pg_upgrade has completed, so continue reconciling to start the upgraded primary
+			return false, nil
+		}
+	}
+
+	return true, err
+}
+
+// setPGUpgradeCondition sets the "PGUpgradeProgressing" condition to true with the provided
+// reason and message.
+func setPGUpgradeCondition(cluster *v1beta1.PostgresCluster, reason, message string) {
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPGUpgradeProgressing,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;delete
+
+// cancelMajorUpgrade stops an upgrade that is no longer requested before pg_upgrade runs. Until
+// then, the data directory of the former primary is unchanged and the cluster starts as it was.
+// Once pg_upgrade has run, the former data directory is gone or unusable and the upgrade cannot
+// be cancelled: a successful upgrade moves forward when the cluster still asks for the upgraded
+// version of PostgreSQL, and otherwise waits. The bool returned indicates that the controller
+// should return early while the cluster is not running.
+func (r *Reconciler) cancelMajorUpgrade(ctx context.Context,
+	cluster *v1beta1.PostgresCluster) (bool, error) {
+
+	upgradeJob := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(upgradeJob), upgradeJob); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return true, errors.WithStack(err)
+		}
+		upgradeJob = nil
+	}
+
+	if upgradeJob != nil {
+		status := cluster.Status.Upgrade
+		if jobCompleted(upgradeJob) && status != nil &&
+			status.ToPostgresVersion == cluster.Spec.PostgresVersion {
+			return r.reconcileUpgradeJob(ctx, cluster)
+		}
+
+		message := "pg_upgrade has run and the upgrade cannot be cancelled"
+		if status != nil {
+			message = fmt.Sprintf("%s; upgrade to PostgreSQL %d to continue",
+				message, status.ToPostgresVersion)
+		}
+		if condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing); condition == nil || condition.Message != message {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, EventPGUpgradeFailed, message)
+		}
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning, message)
+		return true, nil
+	}
+
+	// Delete the Pods of the backup Job along with it.
+	backupJob := &batchv1.Job{ObjectMeta: naming.PGUpgradeBackupJob(cluster)}
+	if err := r.Client.Delete(ctx, backupJob, client.PropagationPolicy(
+		metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return true, errors.WithStack(err)
+	}
+
+	// TODO: remove guard with move to controller-runtime 0.9.0 https://issue.k8s.io/99714
+	if len(cluster.Status.Conditions) > 0 {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionPGUpgradeProgressing)
+	}
+	cluster.Status.Upgrade = nil
+
+	r.Recorder.Event(cluster, corev1.EventTypeWarning, EventPGUpgradeCancelled,
+		"Major PostgreSQL upgrade cancelled before pg_upgrade ran")
+
+	return false, nil
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;patch
+
+// reconcileUpgradeBackup takes a full pgBackRest backup of the cluster before it is upgraded,
+// and records the primary whose data directory will be upgraded.
+func (r *Reconciler) reconcileUpgradeBackup(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, observed *observedInstances) error {
+
+	existing := &batchv1.Job{ObjectMeta: naming.PGUpgradeBackupJob(cluster)}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.WithStack(err)
+		}
+		existing = nil
+	}
+
+	if existing != nil {
+		switch {
+		case jobCompleted(existing):
+			setPGUpgradeCondition(cluster, ReasonPGUpgradeStopping,
+				"Stopping the cluster for pg_upgrade")
+		case jobFailed(existing):
+			// The Job is left in place until it is deleted, which starts another backup.
+			setPGUpgradeCondition(cluster, ReasonPGUpgradeBackup,
+				"Backup before upgrading failed; delete Job "+existing.Name+" to try again")
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventPGUpgradeFailed,
+				"Backup before upgrading failed: %s", existing.Name)
+		}
+		return nil
+	}
+
+	// pgBackRest connects to a PostgreSQL instance that is not in recovery to initiate a
+	// backup, and that instance is the one that will be upgraded.
+	var primary *Instance
+	for i, instance := range observed.forCluster {
+		if writable, known := instance.IsWritable(); writable && known {
+			primary = observed.forCluster[i]
+			break
+		}
+	}
+	if primary == nil {
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeBackup,
+			"Waiting for a writable primary to back up before upgrading")
+		return nil
+	}
+	cluster.Status.Upgrade.PrimaryInstance = primary.Name
+	cluster.Status.Upgrade.PrimaryInstanceSet = primary.Spec.Name
+
+	repoName := cluster.Spec.Upgrade.RepoName
+	if repoName == "" && len(cluster.Spec.Backups.PGBackRest.Repos) > 0 {
+		repoName = cluster.Spec.Backups.PGBackRest.Repos[0].Name
+	}
+
+	var stanzaCreated bool
+	if cluster.Status.PGBackRest != nil {
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			if repo.Name == repoName {
+				stanzaCreated = repo.StanzaCreated
+			}
+		}
+	}
+	if !stanzaCreated {
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeBackup,
+			fmt.Sprintf("Waiting for the stanza of %q to back up before upgrading", repoName))
+		return nil
+	}
+
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// set the name of the pgbackrest config file that will be mounted to the backup Job
+	configName := primary.Name + ".conf"
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		configName = pgbackrest.CMRepoKey
+	}
+
+	serviceAccount, err := r.reconcilePGBackRestRBAC(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	backupJob := &batchv1.Job{ObjectMeta: naming.PGUpgradeBackupJob(cluster)}
+	backupJob.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestBackupJobLabels(cluster.GetName(), repoName, naming.BackupPGUpgrade))
+	backupJob.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+
+	spec, err := generateBackupJobSpecIntent(cluster, selector.String(), containerName,
+		repoName, serviceAccount.GetName(), configName,
+		backupJob.Labels, backupJob.Annotations, "--type="+full)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	backupJob.Spec = *spec
+
+	backupJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	if err := r.setControllerReference(cluster, backupJob); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(r.apply(ctx, backupJob))
+}
+
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=delete
+
+// prepareForUpgrade removes all instance runners and any Endpoints created by Patroni so that
+// pg_upgrade can run against the stopped data directory of the former primary. Only that
+// instance is started again once the upgrade is complete.
+func (r *Reconciler) prepareForUpgrade(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, observed *observedInstances) error {
+
+	if cluster.Status.Upgrade.PrimaryInstance == "" {
+		return errors.New("unable to determine the primary instance to upgrade")
+	}
+	cluster.Status.StartupInstance = cluster.Status.Upgrade.PrimaryInstance
+	cluster.Status.StartupInstanceSet = cluster.Status.Upgrade.PrimaryInstanceSet
+
+	var clusterRunning bool
+	runners := []*appsv1.StatefulSet{}
+	for _, instance := range observed.forCluster {
+		if !clusterRunning {
+			clusterRunning, _ = instance.IsRunning(naming.ContainerDatabase)
+		}
+		if instance.Runner != nil {
+			runners = append(runners, instance.Runner)
+		}
+	}
+
+	if clusterRunning || len(runners) > 0 {
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeStopping,
+			"Stopping the cluster for pg_upgrade: removing runners")
+		for _, runner := range runners {
+			err := r.Client.Delete(ctx, runner,
+				client.PropagationPolicy(metav1.DeletePropagationForeground))
+			if client.IgnoreNotFound(err) != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	}
+
+	endpoints, _, err := r.observeRestoreEnv(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) > 0 {
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeStopping,
+			"Stopping the cluster for pg_upgrade: removing DCS")
+		for i := range endpoints {
+			if err := r.Client.Delete(ctx, &endpoints[i]); client.IgnoreNotFound(err) != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	}
+
+	setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning,
+		fmt.Sprintf("Upgrading PostgreSQL from %d to %d",
+			cluster.Status.Upgrade.FromPostgresVersion, cluster.Status.Upgrade.ToPostgresVersion))
+
+	return nil
+}
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;patch
+
+// reconcileUpgradeJob runs pg_upgrade in a Job against the data volume of the former primary.
+// It returns true while the Job has not yet completed successfully. Once it has, the cluster
+// is no longer bootstrapped and will be bootstrapped again using the upgraded data directory.
+func (r *Reconciler) reconcileUpgradeJob(ctx context.Context,
+	cluster *v1beta1.PostgresCluster) (bool, error) {
+
+	existing := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return true, errors.WithStack(err)
+		}
+		existing = nil
+	}
+
+	if existing != nil {
+		switch {
+		case jobCompleted(existing):
+			setPGUpgradeCondition(cluster, ReasonPGUpgradeStanzaUpgrade,
+				"Starting the upgraded cluster")
+			// the cluster is no longer bootstrapped
+			resetPatroniStatus(cluster)
+			// the contents of the database changed, so the pgbouncer and exporter hashes are no
+			// longer valid
+			cluster.Status.Proxy.PGBouncer.PostgreSQLRevision = ""
+			cluster.Status.Monitoring.ExporterConfiguration = ""
+			return false, nil
+
+		case jobFailed(existing):
+			// The Job is left in place until it is deleted, which runs pg_upgrade again.
+			setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning,
+				"pg_upgrade failed; delete Job "+existing.Name+" to try again")
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventPGUpgradeFailed,
+				"pg_upgrade failed: %s", existing.Name)
+		}
+		return true, nil
+	}
+
+	var instanceSet *v1beta1.PostgresInstanceSetSpec
+	for i, set := range cluster.Spec.InstanceSets {
+		if set.Name == cluster.Status.Upgrade.PrimaryInstanceSet {
+			instanceSet = &cluster.Spec.InstanceSets[i]
+		}
+	}
+	if instanceSet == nil {
+		return true, errors.New("unable to determine the instance set to upgrade")
+	}
+
+	// The data volumes are named after the instance that was primary.
+	instance := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Status.Upgrade.PrimaryInstance,
+	}}
+	pgdata := &corev1.PersistentVolumeClaim{
+		ObjectMeta: naming.AdoptedVolume(cluster, naming.InstancePostgresDataVolume(instance)),
+	}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(pgdata), pgdata); err != nil {
+		return true, errors.WithStack(err)
+	}
+	pgwal := &corev1.PersistentVolumeClaim{
+		ObjectMeta: naming.AdoptedVolume(cluster, naming.InstancePostgresWALVolume(instance)),
+	}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(pgwal), pgwal); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return true, errors.WithStack(err)
+		}
+		pgwal = nil
+	}
+
+	job := &batchv1.Job{}
+	if err := r.generateUpgradeJobIntent(cluster, instanceSet, pgdata, pgwal, job); err != nil {
+		return true, err
+	}
+
+	return true, errors.WithStack(r.apply(ctx, job))
+}
+
+// generateUpgradeJobIntent populates job with the Job that runs pg_upgrade against the
+// provided data volumes.
+func (r *Reconciler) generateUpgradeJobIntent(cluster *v1beta1.PostgresCluster,
+	instanceSet *v1beta1.PostgresInstanceSetSpec,
+	pgdataVolume, pgwalVolume *corev1.PersistentVolumeClaim, job *batchv1.Job) error {
+
+	dataVolumeMount := postgres.DataVolumeMount()
+	volumes := []corev1.Volume{{
+		Name: dataVolumeMount.Name,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pgdataVolume.GetName(),
+			},
+		},
+	}}
+	volumeMounts := []corev1.VolumeMount{dataVolumeMount}
+
+	if pgwalVolume != nil {
+		walVolumeMount := postgres.WALVolumeMount()
+		volumes = append(volumes, corev1.Volume{
+			Name: walVolumeMount.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pgwalVolume.GetName(),
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, walVolumeMount)
+	}
+
+	job.ObjectMeta = naming.PGUpgradeJob(cluster)
+	job.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	job.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		naming.PGUpgradeJobLabels(cluster.Name),
+		map[string]string{naming.LabelStartupInstance: cluster.Status.Upgrade.PrimaryInstance},
+	)
+
+	upgrade := cluster.Spec.Upgrade
+	job.Spec = batchv1.JobSpec{
+		// pg_upgrade is not retried automatically. A failure is reported in the status
+		// of the PostgresCluster instead.
+		BackoffLimit: initialize.Int32(0),
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: job.Annotations,
+				Labels:      job.Labels,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Command: postgres.UpgradeCommand(cluster, instanceSet,
+						cluster.Status.Upgrade.FromPostgresVersion),
+					Image:           config.PGUpgradeContainerImage(cluster),
+					Name:            naming.ContainerPGUpgrade,
+					VolumeMounts:    volumeMounts,
+					SecurityContext: initialize.RestrictedSecurityContext(),
+					Resources:       upgrade.Resources,
+				}},
+				RestartPolicy: corev1.RestartPolicyNever,
+				Volumes:       volumes,
+				Affinity:      upgrade.Affinity,
+				Tolerations:   upgrade.Tolerations,
+			},
+		},
+	}
+
+	// Set the image pull secrets, if any exist.
+	// This is set here rather than using the service account due to the lack
+	// of propagation to existing pods when the CRD is updated:
+	// https://github.com/kubernetes/kubernetes/issues/88456
+	job.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+
+	podSecurityContext := initialize.RestrictedPodSecurityContext()
+	// set fsGroups if not OpenShift
+	if cluster.Spec.OpenShift == nil || !*cluster.Spec.OpenShift {
+		podSecurityContext.FSGroup = initialize.Int64(26)
+	}
+	job.Spec.Template.Spec.SecurityContext = podSecurityContext
+
+	// initdb and pg_upgrade need a user name for the current user ID
+	addNSSWrapper(config.PGUpgradeContainerImage(cluster), &job.Spec.Template)
+	addTMPEmptyDir(&job.Spec.Template)
+
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	return errors.WithStack(r.setControllerReference(cluster, job))
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=list;delete
+
+// reconcileStanzaUpgrade is responsible for finishing a major PostgreSQL upgrade once the
+// upgraded primary is running. It runs "pgbackrest stanza-upgrade" so that WAL can be archived
+// again, and then requires a new replica creation backup. Other instances are started once
+// that backup completes; see upgradeAwaitingReplicaCreate.
+func (r *Reconciler) reconcileStanzaUpgrade(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, instances *observedInstances) (reconcile.Result, error) {
+
+	condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionPGUpgradeProgressing)
+	if condition == nil || condition.Status != metav1.ConditionTrue ||
+		condition.Reason != ReasonPGUpgradeStanzaUpgrade || cluster.Status.Upgrade == nil {
+		return reconcile.Result{}, nil
+	}
+
+	// add some additional context about what component is being reconciled
+	log := logging.FromContext(ctx).WithValues("reconciler", "pgUpgrade")
+
+	clusterWritable := false
+	for _, instance := range instances.forCluster {
+		if writable, known := instance.IsWritable(); writable && known {
+			clusterWritable = true
+			break
+		}
+	}
+	if !patroni.ClusterBootstrapped(cluster) || !clusterWritable {
+		return reconcile.Result{}, nil
+	}
+
+	_, configHash, err := pgbackrest.CalculateConfigHashes(cluster)
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	selector, containerName, err := getPGBackRestExecSelector(cluster)
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(cluster.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+	if len(pods.Items) != 1 {
+		return reconcile.Result{}, errors.WithStack(
+			errors.New("invalid number of Pods found when attempting to upgrade stanzas"))
+	}
+
+	exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(cluster.GetNamespace(), pods.Items[0].GetName(), containerName,
+			stdin, stdout, stderr, command...)
+	}
+	configHashMismatch, err := pgbackrest.Executor(exec).StanzaUpgrade(ctx, configHash)
+	// Like stanza creation, do not return these errors. Requeue after some time so that
+	// configuration changes have a chance to propagate to the container.
+	if err != nil {
+		log.Error(err, "unable to upgrade stanza")
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventPGUpgradeFailed, err.Error())
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	if configHashMismatch {
+		log.Info("pgBackRest config hash mismatch detected, requeuing to reattempt stanza upgrade")
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	// Backups taken before the upgrade cannot create replicas of the upgraded cluster, so
+	// remove the replica creation backup Jobs and the Jobs of this upgrade.
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(ctx, jobs, client.InNamespace(cluster.GetNamespace()),
+		client.MatchingLabels{naming.LabelCluster: cluster.GetName()}); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+	for i := range jobs.Items {
+		labels := jobs.Items[i].GetLabels()
+		_, upgradeJob := labels[naming.LabelPGUpgrade]
+		backupType := labels[naming.LabelPGBackRestBackup]
+		if upgradeJob || backupType == string(naming.BackupReplicaCreate) ||
+			backupType == string(naming.BackupPGUpgrade) {
+			if err := r.Client.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(
+				metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				return reconcile.Result{}, errors.WithStack(err)
+			}
+		}
+	}
+	if cluster.Status.PGBackRest != nil {
+		for i := range cluster.Status.PGBackRest.Repos {
+			cluster.Status.PGBackRest.Repos[i].ReplicaCreateBackupComplete = false
+		}
+	}
+
+	now := metav1.Now()
+	cluster.Status.Upgrade.Finished = true
+	cluster.Status.Upgrade.CompletionTime = &now
+
+	message := fmt.Sprintf("PostgreSQL upgraded from %d to %d",
+		cluster.Status.Upgrade.FromPostgresVersion, cluster.Status.Upgrade.ToPostgresVersion)
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		ObservedGeneration: cluster.GetGeneration(),
+		Type:               ConditionPGUpgradeProgressing,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonPGUpgradeComplete,
+		Message:            message,
+	})
+	r.Recorder.Event(cluster, corev1.EventTypeNormal, EventPGUpgradeComplete, message)
+
+	return reconcile.Result{}, nil
+}
+
+// upgradeAwaitingReplicaCreate returns true when a major upgrade of cluster has finished but
+// the upgraded primary is still the only instance to start because no replica creation backup
+// of the upgraded cluster has completed. Replicas would otherwise be created from the primary
+// with pg_basebackup.
+//
+// NOTE: Replicas are created in a new data directory named for the upgraded version. The data
+// directory of the former version remains on their volumes and can be removed manually.
+func upgradeAwaitingReplicaCreate(cluster *v1beta1.PostgresCluster) bool {
+	upgrade := cluster.Status.Upgrade
+	if upgrade == nil || !upgrade.Finished ||
+		cluster.Status.StartupInstance == "" ||
+		cluster.Status.StartupInstance != upgrade.PrimaryInstance {
+		return false
+	}
+	if cluster.Status.PGBackRest != nil {
+		for _, repo := range cluster.Status.PGBackRest.Repos {
+			if repo.ReplicaCreateBackupComplete {
+				return false
+			}
+		}
+	}
+	return true
+}
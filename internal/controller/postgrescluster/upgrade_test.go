@@ -0,0 +1,321 @@
+// +build envtest
+
+package postgrescluster
+
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestGenerateUpgradeJobIntent(t *testing.T) {
+	env, cc, _ := setupTestEnv(t, ControllerName)
+	t.Cleanup(func() { teardownTestEnv(t, env) })
+
+	r := Reconciler{
+		Client: cc,
+	}
+
+	cluster := &v1beta1.PostgresCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hippo",
+			Namespace: "ns1",
+		},
+		Spec: v1beta1.PostgresClusterSpec{
+			PostgresVersion: 13,
+			Upgrade: &v1beta1.PGUpgradeSpec{
+				Enabled:             initialize.Bool(true),
+				FromPostgresVersion: 12,
+				Image:               "upgrade-image",
+				Tolerations: []corev1.Toleration{{
+					Key: "key", Operator: corev1.TolerationOpExists,
+				}},
+			},
+		},
+		Status: v1beta1.PostgresClusterStatus{
+			Upgrade: &v1beta1.PGUpgradeStatus{
+				FromPostgresVersion: 12,
+				ToPostgresVersion:   13,
+				PrimaryInstance:     "hippo-00-abcd",
+				PrimaryInstanceSet:  "00",
+			},
+		},
+	}
+	instanceSet := &v1beta1.PostgresInstanceSetSpec{Name: "00"}
+
+	pgdata := &corev1.PersistentVolumeClaim{}
+	pgdata.Name = "hippo-00-abcd-pgdata"
+
+	job := &batchv1.Job{}
+	assert.NilError(t, r.generateUpgradeJobIntent(cluster, instanceSet, pgdata, nil, job))
+
+	assert.Equal(t, job.Name, "hippo-pgupgrade")
+	assert.Equal(t, job.Namespace, "ns1")
+	assert.Equal(t, job.Labels[naming.LabelCluster], "hippo")
+	assert.Equal(t, job.Labels[naming.LabelStartupInstance], "hippo-00-abcd")
+	assert.Assert(t, naming.PGUpgradeJobSelector("hippo").Matches(
+		labels.Set(job.Spec.Template.Labels)))
+	assert.Equal(t, *job.Spec.BackoffLimit, int32(0))
+	assert.Equal(t, len(job.OwnerReferences), 1)
+
+	spec := job.Spec.Template.Spec
+	assert.Equal(t, spec.RestartPolicy, corev1.RestartPolicyNever)
+	assert.Equal(t, *spec.SecurityContext.FSGroup, int64(26))
+	assert.DeepEqual(t, spec.Tolerations, cluster.Spec.Upgrade.Tolerations)
+	assert.Equal(t, len(spec.InitContainers), 1, "expected the NSS wrapper init container")
+
+	assert.Equal(t, len(spec.Containers), 1)
+	container := spec.Containers[0]
+	assert.Equal(t, container.Name, naming.ContainerPGUpgrade)
+	assert.Equal(t, container.Image, "upgrade-image")
+	assert.DeepEqual(t, container.Command[4:], []string{
+		"upgrade", "12", "13", "/pgdata/pg12", "/pgdata/pg13_bootstrap", "/pgdata/pg13_wal",
+	})
+
+	var claims []string
+	for _, volume := range spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	assert.DeepEqual(t, claims, []string{"hippo-00-abcd-pgdata"})
+
+	t.Run("WALVolume", func(t *testing.T) {
+		instanceSet := instanceSet.DeepCopy()
+		instanceSet.WALVolumeClaimSpec = &corev1.PersistentVolumeClaimSpec{}
+
+		pgwal := &corev1.PersistentVolumeClaim{}
+		pgwal.Name = "hippo-00-abcd-pgwal"
+
+		job := &batchv1.Job{}
+		assert.NilError(t, r.generateUpgradeJobIntent(cluster, instanceSet, pgdata, pgwal, job))
+
+		var claims []string
+		for _, volume := range job.Spec.Template.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+		assert.DeepEqual(t, claims, []string{"hippo-00-abcd-pgdata", "hippo-00-abcd-pgwal"})
+		assert.Equal(t, job.Spec.Template.Spec.Containers[0].Command[9], "/pgwal/pg13_wal")
+	})
+
+	t.Run("OpenShift", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.OpenShift = initialize.Bool(true)
+
+		job := &batchv1.Job{}
+		assert.NilError(t, r.generateUpgradeJobIntent(cluster, instanceSet, pgdata, nil, job))
+		assert.Assert(t, job.Spec.Template.Spec.SecurityContext.FSGroup == nil)
+	})
+}
+
+func TestReconcileMajorUpgrade(t *testing.T) {
+	env, cc, _ := setupTestEnv(t, ControllerName)
+	t.Cleanup(func() { teardownTestEnv(t, env) })
+
+	ctx := context.Background()
+	r := Reconciler{
+		Client:   cc,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	t.Run("NotRequested", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.PostgresVersion = 13
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+		assert.Assert(t, cluster.Status.Upgrade == nil)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing) == nil)
+	})
+
+	t.Run("Start", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.PostgresVersion = 13
+		cluster.Spec.Upgrade = &v1beta1.PGUpgradeSpec{
+			Enabled: initialize.Bool(true), FromPostgresVersion: 12,
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly)
+
+		assert.Assert(t, cluster.Status.Upgrade != nil)
+		assert.Equal(t, cluster.Status.Upgrade.FromPostgresVersion, 12)
+		assert.Equal(t, cluster.Status.Upgrade.ToPostgresVersion, 13)
+		assert.Assert(t, !cluster.Status.Upgrade.Finished)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, ReasonPGUpgradeBackup)
+	})
+}
+
+func TestCancelMajorUpgrade(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	// An upgrade to 13 that is no longer enabled.
+	newCluster := func() *v1beta1.PostgresCluster {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Spec.PostgresVersion = 13
+		cluster.Spec.Upgrade = &v1beta1.PGUpgradeSpec{
+			Enabled: initialize.Bool(false), FromPostgresVersion: 12,
+		}
+		cluster.Status.Upgrade = &v1beta1.PGUpgradeStatus{
+			FromPostgresVersion: 12, ToPostgresVersion: 13,
+		}
+		return cluster
+	}
+
+	t.Run("BackupOnly", func(t *testing.T) {
+		cluster := newCluster()
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeBackup, "test")
+
+		backup := &batchv1.Job{ObjectMeta: naming.PGUpgradeBackupJob(cluster)}
+		r := Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(backup).Build(),
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+		assert.Assert(t, cluster.Status.Upgrade == nil)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing) == nil)
+
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected the backup Job to be deleted")
+	})
+
+	t.Run("UpgradeRunning", func(t *testing.T) {
+		cluster := newCluster()
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning, "test")
+
+		job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+		job.Status.Active = 1
+		r := Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(job).Build(),
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly, "expected the cluster to stay stopped")
+		assert.Assert(t, cluster.Status.Upgrade != nil)
+		assert.Assert(t, meta.IsStatusConditionTrue(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing))
+	})
+
+	t.Run("UpgradeSucceeded", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{
+			SystemIdentifier: "12345", Timeline: 2, Version: "3.0.2",
+			Switchover: &v1beta1.PatroniSwitchoverStatus{ID: "one"},
+		}
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning, "test")
+
+		job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+		r := Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(job).Build(),
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		// The former data directory is gone, so the upgrade moves forward.
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+		assert.Assert(t, cluster.Status.Upgrade != nil)
+
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, ReasonPGUpgradeStanzaUpgrade)
+		assert.DeepEqual(t, cluster.Status.Patroni, &v1beta1.PatroniStatus{
+			Switchover: &v1beta1.PatroniSwitchoverStatus{ID: "one"},
+		})
+
+		// The upgraded data directory cannot start as the former version.
+		cluster = newCluster()
+		cluster.Spec.PostgresVersion = 12
+		setPGUpgradeCondition(cluster, ReasonPGUpgradeRunning, "test")
+
+		returnEarly, err = r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{})
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly, "expected the cluster to stay stopped")
+		assert.Assert(t, cluster.Status.Upgrade != nil)
+
+		condition = meta.FindStatusCondition(cluster.Status.Conditions,
+			ConditionPGUpgradeProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Reason, ReasonPGUpgradeRunning)
+		assert.Assert(t, strings.Contains(condition.Message, "PostgreSQL 13"), condition.Message)
+	})
+}
+
+func TestUpgradeAwaitingReplicaCreate(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	assert.Assert(t, !upgradeAwaitingReplicaCreate(cluster))
+
+	cluster.Status.StartupInstance = "hippo-00-abcd"
+	cluster.Status.Upgrade = &v1beta1.PGUpgradeStatus{PrimaryInstance: "hippo-00-abcd"}
+	assert.Assert(t, !upgradeAwaitingReplicaCreate(cluster),
+		"expected other steps of the upgrade to hold the other instances")
+
+	cluster.Status.Upgrade.Finished = true
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}},
+	}
+	assert.Assert(t, upgradeAwaitingReplicaCreate(cluster))
+
+	cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete = true
+	assert.Assert(t, !upgradeAwaitingReplicaCreate(cluster))
+
+	cluster.Status.PGBackRest.Repos[0].ReplicaCreateBackupComplete = false
+	cluster.Status.StartupInstance = ""
+	assert.Assert(t, !upgradeAwaitingReplicaCreate(cluster),
+		"expected instances that already started to keep running")
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 var tmpDirSizeLimit = resource.MustParse("16Mi")
@@ -85,7 +86,7 @@ func addNSSWrapper(image string, template *v1.PodTemplateSpec) {
 	for i, c := range template.Spec.Containers {
 		switch c.Name {
 		case naming.ContainerDatabase, naming.PGBackRestRepoContainerName,
-			naming.PGBackRestRestoreContainerName:
+			naming.PGBackRestRestoreContainerName, naming.ContainerPGUpgrade:
 			passwd := fmt.Sprintf(nssWrapperDir, "postgres", "passwd")
 			group := fmt.Sprintf(nssWrapperDir, "postgres", "group")
 			template.Spec.Containers[i].Env = append(template.Spec.Containers[i].Env, []v1.EnvVar{
@@ -158,3 +159,27 @@ func updateReconcileResult(currResult, newResult reconcile.Result) reconcile.Res
 
 	return currResult
 }
+
+// setServiceSpec sets the type of service and the fields that expose it outside of Kubernetes
+// according to spec. When spec is nil, service is a ClusterIP Service. The ports of service
+// should be set first; a node port applies to the first of them. Fields that do not apply to
+// the type of Service are ignored.
+func setServiceSpec(service *v1.Service, spec *v1beta1.ServiceSpec) {
+	service.Spec.Type = v1.ServiceTypeClusterIP
+	if spec == nil || spec.Type == "" {
+		return
+	}
+	service.Spec.Type = v1.ServiceType(spec.Type)
+
+	if service.Spec.Type == v1.ServiceTypeNodePort ||
+		service.Spec.Type == v1.ServiceTypeLoadBalancer {
+		if spec.NodePort != nil && len(service.Spec.Ports) > 0 {
+			service.Spec.Ports[0].NodePort = *spec.NodePort
+		}
+		service.Spec.ExternalTrafficPolicy =
+			v1.ServiceExternalTrafficPolicyType(spec.ExternalTrafficPolicy)
+	}
+	if service.Spec.Type == v1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+	}
+}
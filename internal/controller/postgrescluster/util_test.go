@@ -27,7 +27,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestSafeHash32(t *testing.T) {
@@ -385,3 +387,56 @@ func TestJobFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestSetServiceSpec(t *testing.T) {
+	service := func() *corev1.Service {
+		service := &corev1.Service{}
+		service.Spec.Ports = []corev1.ServicePort{{Name: "postgres", Port: 5432}}
+		return service
+	}
+
+	t.Run("Nil", func(t *testing.T) {
+		s := service()
+		setServiceSpec(s, nil)
+		assert.Equal(t, s.Spec.Type, corev1.ServiceTypeClusterIP)
+		assert.Equal(t, s.Spec.Ports[0].NodePort, int32(0))
+	})
+
+	t.Run("ClusterIP", func(t *testing.T) {
+		// Fields for other types are ignored.
+		s := service()
+		setServiceSpec(s, &v1beta1.ServiceSpec{
+			Type: "ClusterIP", NodePort: initialize.Int32(30000),
+			ExternalTrafficPolicy:    "Local",
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		})
+		assert.Equal(t, s.Spec.Type, corev1.ServiceTypeClusterIP)
+		assert.Equal(t, s.Spec.Ports[0].NodePort, int32(0))
+		assert.Equal(t, s.Spec.ExternalTrafficPolicy, corev1.ServiceExternalTrafficPolicyType(""))
+		assert.Assert(t, s.Spec.LoadBalancerSourceRanges == nil)
+	})
+
+	t.Run("NodePort", func(t *testing.T) {
+		s := service()
+		setServiceSpec(s, &v1beta1.ServiceSpec{
+			Type: "NodePort", NodePort: initialize.Int32(30000),
+			ExternalTrafficPolicy:    "Local",
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		})
+		assert.Equal(t, s.Spec.Type, corev1.ServiceTypeNodePort)
+		assert.Equal(t, s.Spec.Ports[0].NodePort, int32(30000))
+		assert.Equal(t, s.Spec.ExternalTrafficPolicy, corev1.ServiceExternalTrafficPolicyTypeLocal)
+		assert.Assert(t, s.Spec.LoadBalancerSourceRanges == nil)
+	})
+
+	t.Run("LoadBalancer", func(t *testing.T) {
+		s := service()
+		setServiceSpec(s, &v1beta1.ServiceSpec{
+			Type:                     "LoadBalancer",
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		})
+		assert.Equal(t, s.Spec.Type, corev1.ServiceTypeLoadBalancer)
+		assert.Equal(t, s.Spec.Ports[0].NodePort, int32(0))
+		assert.DeepEqual(t, s.Spec.LoadBalancerSourceRanges, []string{"10.0.0.0/8"})
+	})
+}
@@ -121,6 +121,52 @@ func (r *Reconciler) observePersistentVolumeClaims(
 	return volumes.Items, err
 }
 
+// keepVolumeSpec copies from the existing PersistentVolumeClaim the parts of its
+// spec that pvc must not change, and it returns whether or not the claim exists.
+// The data source of a volume cannot change once it is created. Volumes cannot
+// shrink, so when grown is true the storage request of pvc is raised to that of
+// the existing claim, which may have grown automatically.
+func (r *Reconciler) keepVolumeSpec(
+	ctx context.Context, pvc *corev1.PersistentVolumeClaim, grown bool,
+) (bool, error) {
+	existing := &corev1.PersistentVolumeClaim{}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(pvc), existing))
+	if err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	pvc.Spec.DataSource = existing.Spec.DataSource
+
+	current := existing.Spec.Resources.Requests[corev1.ResourceStorage]
+	if request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; grown &&
+		current.Cmp(request) > 0 {
+		// The requests of pvc may be shared with the spec of the cluster.
+		pvc.Spec.Resources.Requests = pvc.Spec.Resources.Requests.DeepCopy()
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = current
+	}
+	return true, nil
+}
+
+// keepAdoptedVolumeSpec copies from the existing PersistentVolumeClaim the settings
+// it was created with. A claim adopted from the data source of a cluster was not
+// created from the spec of that cluster, so only its storage request follows it.
+func (r *Reconciler) keepAdoptedVolumeSpec(
+	ctx context.Context, pvc *corev1.PersistentVolumeClaim,
+) error {
+	existing := &corev1.PersistentVolumeClaim{}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(pvc), existing))
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	pvc.Spec.AccessModes = existing.Spec.AccessModes
+	pvc.Spec.Selector = existing.Spec.Selector
+	pvc.Spec.StorageClassName = existing.Spec.StorageClassName
+	pvc.Spec.VolumeMode = existing.Spec.VolumeMode
+	pvc.Spec.VolumeName = existing.Spec.VolumeName
+	return nil
+}
+
 // handlePersistentVolumeClaimError inspects err for expected Kubernetes API
 // responses to writing a PVC. It turns errors it understands into conditions
 // and events. When err is handled it returns nil. Otherwise it returns err.
@@ -0,0 +1,463 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventVolumeSnapshotCreated is the event reason utilized when a VolumeSnapshot
+	// of a PostgreSQL data volume is created
+	EventVolumeSnapshotCreated = "VolumeSnapshotCreated"
+
+	// EventVolumeSnapshotFailed is the event reason utilized when a VolumeSnapshot
+	// of a PostgreSQL data volume cannot be created
+	EventVolumeSnapshotFailed = "VolumeSnapshotFailed"
+)
+
+const (
+	// volumeSnapshotPollInterval is how often a new VolumeSnapshot is checked while
+	// PostgreSQL is in backup mode.
+	volumeSnapshotPollInterval = 2 * time.Second
+
+	// volumeSnapshotTimeout is how long PostgreSQL remains in backup mode while
+	// waiting for the storage provider to take a snapshot.
+	volumeSnapshotTimeout = 2 * time.Minute
+
+	// volumeSnapshotReadyInterval is how often VolumeSnapshots are checked while
+	// any are not ready to use.
+	volumeSnapshotReadyInterval = 10 * time.Second
+)
+
+// volumeSnapshotGVK is the GroupVersionKind of CSI VolumeSnapshots. The operator
+// does not depend on their Go types, so they are handled as unstructured objects.
+// - https://kubernetes-csi.github.io/docs/snapshot-restore-feature.html
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot",
+}
+
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;create;patch
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// reconcileVolumeSnapshots records the VolumeSnapshots of cluster in its status and
+// takes a new one whenever the VolumeSnapshot annotation of cluster changes. The
+// snapshot is taken of an available replica when there is one and of the primary
+// otherwise. PostgreSQL is in backup mode while the snapshot is taken.
+//
+// Taking a snapshot spans reconciles: PostgreSQL enters backup mode and the
+// VolumeSnapshot is created, then the VolumeSnapshot is checked until the storage
+// provider takes it or a deadline passes, and then PostgreSQL leaves backup mode.
+// The operator does not watch VolumeSnapshots, which may not be installed, so this
+// requeues while the snapshot is in progress.
+func (r *Reconciler) reconcileVolumeSnapshots(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) (reconcile.Result, error) {
+	var result reconcile.Result
+
+	if cluster.Spec.Backups.Snapshots == nil {
+		// Leave backup mode when snapshots are disabled in the middle of one.
+		if status := cluster.Status.VolumeSnapshots; status != nil &&
+			status.Attempt != nil && status.Attempt.CompletionTime == nil {
+			_, _ = r.stopVolumeSnapshotBackup(ctx, cluster, instances, status.Attempt)
+		}
+		cluster.Status.VolumeSnapshots = nil
+		return result, nil
+	}
+	if cluster.Status.VolumeSnapshots == nil {
+		cluster.Status.VolumeSnapshots = &v1beta1.VolumeSnapshotsStatus{}
+	}
+	status := cluster.Status.VolumeSnapshots
+
+	id := cluster.GetAnnotations()[naming.VolumeSnapshot]
+	if id != "" && id != status.ID && patroni.ClusterBootstrapped(cluster) &&
+		(status.Attempt == nil || status.Attempt.CompletionTime != nil) {
+		if instance := volumeSnapshotSource(instances); instance != nil {
+			// Take one snapshot per value of the annotation, whether or not it
+			// succeeds. Change the annotation again to try again.
+			status.ID = id
+			status.Attempt = &v1beta1.VolumeSnapshotAttemptStatus{
+				Name:     naming.ClusterVolumeSnapshot(cluster, id).Name,
+				Instance: instance.Name,
+			}
+
+			if err := r.startVolumeSnapshot(ctx, cluster, instance); err != nil {
+				r.finishVolumeSnapshot(cluster, false,
+					fmt.Sprintf("unable to snapshot instance %q: %v", instance.Name, err))
+			} else {
+				deadline := metav1.NewTime(time.Now().Add(volumeSnapshotTimeout))
+				status.Attempt.Deadline = &deadline
+			}
+		}
+	}
+
+	var err error
+	if attempt := status.Attempt; attempt != nil && attempt.CompletionTime == nil {
+		err = r.checkVolumeSnapshot(ctx, cluster, instances, attempt)
+
+		// The storage provider takes the snapshot without notifying the
+		// reconciler. Check again soon.
+		if err == nil && attempt.CompletionTime == nil {
+			result.RequeueAfter = volumeSnapshotPollInterval
+		}
+	}
+
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind(volumeSnapshotGVK.Kind + "List"))
+	var selector labels.Selector
+	if err == nil {
+		selector, err = naming.AsSelector(naming.ClusterPostgresData(cluster.Name))
+	}
+	if err == nil {
+		err = errors.WithStack(r.Client.List(ctx, snapshots,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+		))
+	}
+	if err == nil {
+		status.Snapshots = volumeSnapshotStatuses(snapshots.Items)
+	}
+
+	// Snapshots become ready without notifying the reconciler. Check again later.
+	for _, snapshot := range status.Snapshots {
+		if !snapshot.ReadyToUse && result.RequeueAfter == 0 {
+			result.RequeueAfter = volumeSnapshotReadyInterval
+		}
+	}
+
+	return result, err
+}
+
+// startVolumeSnapshot puts PostgreSQL of instance in backup mode and then creates a
+// VolumeSnapshot of its data volume. PostgreSQL leaves backup mode when the
+// VolumeSnapshot cannot be created.
+func (r *Reconciler) startVolumeSnapshot(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instance *Instance,
+) error {
+	log := logging.FromContext(ctx).WithValues("instance", instance.Name)
+	exec := volumeSnapshotExecutor(r.PodExec, instance.Pods[0])
+
+	meta := naming.ClusterVolumeSnapshot(cluster, cluster.Status.VolumeSnapshots.ID)
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(meta.Namespace)
+	snapshot.SetName(meta.Name)
+	snapshot.SetAnnotations(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	snapshot.SetLabels(naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		instance.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: instance.Spec.Name,
+			naming.LabelInstance:    instance.Name,
+			naming.LabelRole:        naming.RolePostgresData,
+		}))
+	snapshot.Object["spec"] = map[string]interface{}{
+		"volumeSnapshotClassName": cluster.Spec.Backups.Snapshots.VolumeSnapshotClassName,
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": naming.AdoptedVolume(cluster,
+				naming.InstancePostgresDataVolume(instance.Runner)).Name,
+		},
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, snapshot))
+
+	if err == nil {
+		err = errors.WithStack(postgres.Executor(exec).StartBackup(ctx,
+			cluster.Spec.PostgresVersion, meta.Name))
+
+		if err == nil {
+			err = errors.WithStack(r.patch(ctx, snapshot, client.Apply, client.ForceOwnership))
+
+			if err != nil {
+				_, _ = postgres.Executor(exec).StopBackup(ctx,
+					cluster.Spec.PostgresVersion, meta.Name)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Error(err, "unable to create VolumeSnapshot")
+	}
+	return err
+}
+
+// checkVolumeSnapshot takes PostgreSQL out of backup mode once the storage provider
+// has taken the VolumeSnapshot of attempt, reported an error, or the deadline of
+// attempt has passed. The backup label that PostgreSQL produces is stored in an
+// annotation on a VolumeSnapshot that was taken.
+func (r *Reconciler) checkVolumeSnapshot(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, attempt *v1beta1.VolumeSnapshotAttemptStatus,
+) error {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(cluster.Namespace)
+	snapshot.SetName(attempt.Name)
+
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot))
+
+	var failure string
+	switch {
+	case apierrors.IsNotFound(err):
+		err, failure = nil, "the VolumeSnapshot was deleted"
+
+	case err != nil:
+		return err
+
+	default:
+		message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+		_, taken, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+
+		switch {
+		case found:
+			failure = message
+		case taken || ready:
+		case attempt.Deadline == nil || time.Now().After(attempt.Deadline.Time):
+			failure = fmt.Sprintf("the storage provider did not take the snapshot within %v",
+				volumeSnapshotTimeout)
+		default:
+			return nil
+		}
+	}
+
+	label, stopErr := r.stopVolumeSnapshotBackup(ctx, cluster, instances, attempt)
+	if failure == "" && stopErr != nil {
+		failure = stopErr.Error()
+	}
+
+	// Store the backup label so that volumes restored from the snapshot can
+	// begin recovery at the correct point.
+	if failure == "" {
+		before := snapshot.DeepCopy()
+		annotations := snapshot.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[naming.VolumeSnapshotBackupLabel] = label
+		snapshot.SetAnnotations(annotations)
+
+		err = errors.WithStack(r.patch(ctx, snapshot, client.MergeFrom(before)))
+	}
+	if err != nil {
+		// PostgreSQL has left backup mode, so the label cannot be produced again.
+		failure = fmt.Sprintf("unable to store the backup label: %v", err)
+		err = nil
+	}
+
+	if failure == "" {
+		r.finishVolumeSnapshot(cluster, true,
+			fmt.Sprintf("created VolumeSnapshot of instance %q", attempt.Instance))
+	} else {
+		r.finishVolumeSnapshot(cluster, false,
+			fmt.Sprintf("unable to snapshot instance %q: %s", attempt.Instance, failure))
+	}
+	return err
+}
+
+// stopVolumeSnapshotBackup takes PostgreSQL of the instance in attempt out of backup
+// mode and returns the backup label that it produces.
+func (r *Reconciler) stopVolumeSnapshotBackup(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, attempt *v1beta1.VolumeSnapshotAttemptStatus,
+) (string, error) {
+	var pod *corev1.Pod
+	if instances != nil {
+		for _, instance := range instances.forCluster {
+			if instance.Name == attempt.Instance && len(instance.Pods) == 1 {
+				pod = instance.Pods[0]
+			}
+		}
+	}
+	if pod == nil {
+		// The backup ended along with its Pod.
+		return "", errors.Errorf("instance %q is no longer running", attempt.Instance)
+	}
+
+	label, err := postgres.Executor(volumeSnapshotExecutor(r.PodExec, pod)).StopBackup(ctx,
+		cluster.Spec.PostgresVersion, attempt.Name)
+	if err != nil {
+		logging.FromContext(ctx).Error(errors.WithStack(err),
+			"unable to stop backup", "instance", attempt.Instance)
+	}
+	return label, err
+}
+
+// finishVolumeSnapshot records the outcome of the snapshot in progress in the status
+// of cluster and in an event.
+func (r *Reconciler) finishVolumeSnapshot(
+	cluster *v1beta1.PostgresCluster, succeeded bool, message string,
+) {
+	now := metav1.Now()
+	attempt := cluster.Status.VolumeSnapshots.Attempt
+	attempt.CompletionTime = &now
+	attempt.Succeeded = succeeded
+	attempt.Message = message
+
+	if succeeded {
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, EventVolumeSnapshotCreated, message)
+	} else {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, EventVolumeSnapshotFailed, message)
+	}
+}
+
+// volumeSnapshotExecutor returns a function that runs commands in the database
+// container of pod.
+func volumeSnapshotExecutor(exec podExecutor, pod *corev1.Pod) func(
+	context.Context, io.Reader, io.Writer, io.Writer, ...string,
+) error {
+	return func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return exec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+}
+
+// volumeSnapshotSource returns the instance to snapshot. It prefers an available
+// replica over the primary so that the primary is not burdened by backup mode.
+// It returns nil when no instance is available.
+func volumeSnapshotSource(instances *observedInstances) *Instance {
+	var primary, replica *Instance
+
+	if instances != nil {
+		for _, instance := range instances.forCluster {
+			if instance.Runner == nil || instance.Spec == nil {
+				continue
+			}
+			available, _ := instance.IsAvailable()
+			running, _ := instance.IsRunning(naming.ContainerDatabase)
+			if !available || !running {
+				continue
+			}
+
+			if isPrimary, _ := instance.IsPrimary(); isPrimary {
+				primary = instance
+			} else if replica == nil || instance.Name < replica.Name {
+				replica = instance
+			}
+		}
+	}
+
+	if replica != nil {
+		return replica
+	}
+	return primary
+}
+
+// volumeSnapshotStatuses describes snapshots, oldest first. Snapshots that are
+// being deleted are omitted.
+func volumeSnapshotStatuses(snapshots []unstructured.Unstructured) []v1beta1.VolumeSnapshotStatus {
+	var statuses []v1beta1.VolumeSnapshotStatus
+
+	for i := range snapshots {
+		if snapshots[i].GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		status := v1beta1.VolumeSnapshotStatus{
+			Name:     snapshots[i].GetName(),
+			Instance: snapshots[i].GetLabels()[naming.LabelInstance],
+		}
+		if value, found, _ := unstructured.NestedString(
+			snapshots[i].Object, "status", "creationTime",
+		); found {
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				status.CreationTime = &metav1.Time{Time: t}
+			}
+		}
+		if value, found, _ := unstructured.NestedString(
+			snapshots[i].Object, "status", "restoreSize",
+		); found {
+			if q, err := resource.ParseQuantity(value); err == nil {
+				status.RestoreSize = &q
+			}
+		}
+		status.ReadyToUse, _, _ = unstructured.NestedBool(
+			snapshots[i].Object, "status", "readyToUse")
+
+		statuses = append(statuses, status)
+	}
+
+	// Snapshots that have not been taken yet sort last.
+	sort.SliceStable(statuses, func(i, j int) bool {
+		a, b := statuses[i].CreationTime, statuses[j].CreationTime
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		case !a.Equal(b):
+			return a.Before(b)
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// replicaVolumeSnapshot returns the VolumeSnapshot from which to create the data
+// volume of a new replica of cluster, if any. This is the most recent snapshot that
+// is ready to use and that fits in request.
+//
+// A replica starts from the checkpoint in the snapshot without its backup label,
+// which is only consistent when the WAL is on the same volume. Replicas are not
+// cloned when any instance set has a WAL volume.
+func replicaVolumeSnapshot(
+	cluster *v1beta1.PostgresCluster, request resource.Quantity,
+) *corev1.TypedLocalObjectReference {
+	if cluster.Spec.Backups.Snapshots == nil ||
+		!cluster.Spec.Backups.Snapshots.BootstrapReplicas ||
+		cluster.Status.VolumeSnapshots == nil {
+		return nil
+	}
+	for _, set := range cluster.Spec.InstanceSets {
+		if set.WALVolumeClaimSpec != nil {
+			return nil
+		}
+	}
+
+	snapshots := cluster.Status.VolumeSnapshots.Snapshots
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].ReadyToUse &&
+			(snapshots[i].RestoreSize == nil || snapshots[i].RestoreSize.Cmp(request) <= 0) {
+			return &corev1.TypedLocalObjectReference{
+				APIGroup: initialize.String(volumeSnapshotGVK.Group),
+				Kind:     volumeSnapshotGVK.Kind,
+				Name:     snapshots[i].Name,
+			}
+		}
+	}
+	return nil
+}
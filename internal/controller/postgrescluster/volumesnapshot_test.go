@@ -0,0 +1,328 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcileVolumeSnapshots(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	// The fake client lists only the kinds in its scheme.
+	scheme.AddKnownTypeWithName(volumeSnapshotGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(volumeSnapshotGVK.GroupVersion().WithKind(
+		volumeSnapshotGVK.Kind+"List"), &unstructured.UnstructuredList{})
+
+	pod := &corev1.Pod{}
+	pod.Namespace, pod.Name = "ns1", "hippo-00-abcd-0"
+	instances := &observedInstances{forCluster: []*Instance{
+		{Name: "hippo-00-abcd", Pods: []*corev1.Pod{pod}},
+	}}
+
+	// setup returns a cluster in the middle of taking a snapshot that has the
+	// status of the VolumeSnapshot so far.
+	setup := func(status map[string]interface{}, deadline time.Time) (
+		*Reconciler, *v1beta1.PostgresCluster, *[]string,
+	) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Spec.PostgresVersion = 13
+		cluster.Spec.Backups.Snapshots = &v1beta1.VolumeSnapshots{VolumeSnapshotClassName: "csi"}
+		cluster.Status.VolumeSnapshots = &v1beta1.VolumeSnapshotsStatus{
+			ID: "one",
+			Attempt: &v1beta1.VolumeSnapshotAttemptStatus{
+				Name: "hippo-pgdata-one", Instance: "hippo-00-abcd",
+				Deadline: &metav1.Time{Time: deadline},
+			},
+		}
+
+		snapshot := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		snapshot.SetNamespace("ns1")
+		snapshot.SetName("hippo-pgdata-one")
+		snapshot.SetLabels(naming.ClusterPostgresData("hippo").MatchLabels)
+		if status != nil {
+			snapshot.Object["status"] = status
+		}
+
+		var commands []string
+		reconciler := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(snapshot).Build(),
+			Recorder: record.NewFakeRecorder(10),
+			PodExec: func(
+				namespace, pod, container string,
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				assert.Equal(t, pod, "hippo-00-abcd-0")
+				commands = append(commands, strings.Join(command, " "))
+				_, _ = io.WriteString(stdout, "START WAL LOCATION: 0/2000028\n")
+				return nil
+			},
+		}
+		return reconciler, cluster, &commands
+	}
+
+	t.Run("InProgress", func(t *testing.T) {
+		reconciler, cluster, commands := setup(nil, time.Now().Add(time.Minute))
+
+		result, err := reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, volumeSnapshotPollInterval)
+		assert.Equal(t, len(*commands), 0, "expected PostgreSQL to remain in backup mode")
+
+		attempt := cluster.Status.VolumeSnapshots.Attempt
+		assert.Assert(t, attempt.CompletionTime == nil)
+		assert.Equal(t, len(cluster.Status.VolumeSnapshots.Snapshots), 1)
+	})
+
+	t.Run("Taken", func(t *testing.T) {
+		reconciler, cluster, commands := setup(map[string]interface{}{
+			"creationTime": "2021-06-01T00:00:00Z",
+		}, time.Now().Add(time.Minute))
+
+		result, err := reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, volumeSnapshotReadyInterval)
+		assert.Equal(t, len(*commands), 1)
+		assert.Assert(t, strings.Contains((*commands)[0], "pg_stop_backup"), (*commands)[0])
+
+		attempt := cluster.Status.VolumeSnapshots.Attempt
+		assert.Assert(t, attempt.CompletionTime != nil)
+		assert.Assert(t, attempt.Succeeded)
+
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		assert.NilError(t, reconciler.Client.Get(ctx,
+			client.ObjectKey{Namespace: "ns1", Name: "hippo-pgdata-one"}, snapshot))
+		assert.Equal(t, snapshot.GetAnnotations()[naming.VolumeSnapshotBackupLabel],
+			"START WAL LOCATION: 0/2000028\n")
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Normal VolumeSnapshotCreated"), "got %q", event)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		reconciler, cluster, commands := setup(nil, time.Now().Add(-time.Second))
+
+		_, err := reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, len(*commands), 1, "expected PostgreSQL to leave backup mode")
+
+		attempt := cluster.Status.VolumeSnapshots.Attempt
+		assert.Assert(t, attempt.CompletionTime != nil)
+		assert.Assert(t, !attempt.Succeeded)
+		assert.Assert(t, strings.Contains(attempt.Message, "did not take"), attempt.Message)
+
+		event := <-reconciler.Recorder.(*record.FakeRecorder).Events
+		assert.Assert(t, strings.HasPrefix(event, "Warning VolumeSnapshotFailed"), "got %q", event)
+	})
+
+	t.Run("ProviderError", func(t *testing.T) {
+		reconciler, cluster, commands := setup(map[string]interface{}{
+			"error": map[string]interface{}{"message": "volume is busy"},
+		}, time.Now().Add(time.Minute))
+
+		_, err := reconciler.reconcileVolumeSnapshots(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, len(*commands), 1, "expected PostgreSQL to leave backup mode")
+
+		attempt := cluster.Status.VolumeSnapshots.Attempt
+		assert.Assert(t, !attempt.Succeeded)
+		assert.Assert(t, strings.Contains(attempt.Message, "volume is busy"), attempt.Message)
+	})
+
+	t.Run("StartError", func(t *testing.T) {
+		reconciler, cluster, _ := setup(nil, time.Time{})
+		cluster.Annotations = map[string]string{naming.VolumeSnapshot: "two"}
+		cluster.Status.Patroni = &v1beta1.PatroniStatus{SystemIdentifier: "6952"}
+		cluster.Status.VolumeSnapshots.Attempt.CompletionTime = &metav1.Time{}
+		reconciler.PodExec = func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return errors.New("connection refused")
+		}
+
+		source := &Instance{
+			Name: "hippo-00-abcd", Pods: []*corev1.Pod{pod.DeepCopy()},
+			Runner: &appsv1.StatefulSet{}, Spec: &v1beta1.PostgresInstanceSetSpec{Name: "00"},
+		}
+		source.Pods[0].Labels = map[string]string{naming.LabelRole: naming.RolePatroniReplica}
+		source.Pods[0].Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionTrue,
+		}}
+		source.Pods[0].Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+
+		_, err := reconciler.reconcileVolumeSnapshots(ctx, cluster,
+			&observedInstances{forCluster: []*Instance{source}})
+		assert.NilError(t, err)
+
+		// The failure is recorded so the same annotation value is not tried again.
+		status := cluster.Status.VolumeSnapshots
+		assert.Equal(t, status.ID, "two")
+		assert.Equal(t, status.Attempt.Name, naming.ClusterVolumeSnapshot(cluster, "two").Name)
+		assert.Assert(t, status.Attempt.CompletionTime != nil)
+		assert.Assert(t, !status.Attempt.Succeeded)
+		assert.Assert(t, strings.Contains(status.Attempt.Message, "connection refused"),
+			status.Attempt.Message)
+	})
+}
+
+func TestVolumeSnapshotSource(t *testing.T) {
+	instance := func(name, role string, ready bool) *Instance {
+		pod := &corev1.Pod{}
+		pod.Labels = map[string]string{naming.LabelRole: role}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionFalse,
+		}}
+		if ready {
+			pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		return &Instance{
+			Name: name, Pods: []*corev1.Pod{pod},
+			Runner: &appsv1.StatefulSet{}, Spec: &v1beta1.PostgresInstanceSetSpec{},
+		}
+	}
+
+	assert.Assert(t, volumeSnapshotSource(nil) == nil)
+
+	instances := &observedInstances{forCluster: []*Instance{
+		instance("hippo-00-abcd", naming.RolePatroniLeader, true),
+		instance("hippo-00-wxyz", naming.RolePatroniReplica, true),
+		instance("hippo-00-efgh", naming.RolePatroniReplica, true),
+		instance("hippo-00-aaaa", naming.RolePatroniReplica, false),
+	}}
+
+	// The first available replica is preferred.
+	assert.Equal(t, volumeSnapshotSource(instances).Name, "hippo-00-efgh")
+
+	// The primary is used when no replica is available.
+	instances.forCluster = instances.forCluster[:1]
+	assert.Equal(t, volumeSnapshotSource(instances).Name, "hippo-00-abcd")
+
+	instances.forCluster[0].Pods[0].Status.Conditions[0].Status = corev1.ConditionFalse
+	assert.Assert(t, volumeSnapshotSource(instances) == nil)
+}
+
+func TestVolumeSnapshotStatuses(t *testing.T) {
+	snapshot := func(name, instance string, status map[string]interface{}) unstructured.Unstructured {
+		u := unstructured.Unstructured{Object: map[string]interface{}{}}
+		u.SetGroupVersionKind(volumeSnapshotGVK)
+		u.SetName(name)
+		u.SetLabels(map[string]string{naming.LabelInstance: instance})
+		if status != nil {
+			u.Object["status"] = status
+		}
+		return u
+	}
+
+	deleted := snapshot("hippo-pgdata-gone", "hippo-00-abcd", nil)
+	now := metav1.Now()
+	deleted.SetDeletionTimestamp(&now)
+
+	statuses := volumeSnapshotStatuses([]unstructured.Unstructured{
+		snapshot("hippo-pgdata-new", "hippo-00-abcd", nil),
+		snapshot("hippo-pgdata-later", "hippo-00-efgh", map[string]interface{}{
+			"creationTime": "2021-06-02T00:00:00Z",
+			"readyToUse":   false,
+		}),
+		deleted,
+		snapshot("hippo-pgdata-first", "hippo-00-efgh", map[string]interface{}{
+			"creationTime": "2021-06-01T00:00:00Z",
+			"readyToUse":   true,
+			"restoreSize":  "1Gi",
+		}),
+	})
+
+	assert.Equal(t, len(statuses), 3)
+	assert.Equal(t, statuses[0].Name, "hippo-pgdata-first")
+	assert.Equal(t, statuses[0].Instance, "hippo-00-efgh")
+	assert.Equal(t, statuses[0].CreationTime.UTC().Day(), 1)
+	assert.Assert(t, statuses[0].ReadyToUse)
+	assert.Equal(t, statuses[0].RestoreSize.String(), "1Gi")
+
+	assert.Equal(t, statuses[1].Name, "hippo-pgdata-later")
+	assert.Assert(t, !statuses[1].ReadyToUse)
+	assert.Assert(t, statuses[1].RestoreSize == nil)
+
+	assert.Equal(t, statuses[2].Name, "hippo-pgdata-new")
+	assert.Assert(t, statuses[2].CreationTime == nil)
+}
+
+func TestReplicaVolumeSnapshot(t *testing.T) {
+	size := func(s string) *resource.Quantity { q := resource.MustParse(s); return &q }
+
+	cluster := &v1beta1.PostgresCluster{}
+	request := resource.MustParse("2Gi")
+	assert.Assert(t, replicaVolumeSnapshot(cluster, request) == nil)
+
+	cluster.Spec.Backups.Snapshots = &v1beta1.VolumeSnapshots{VolumeSnapshotClassName: "csi"}
+	cluster.Status.VolumeSnapshots = &v1beta1.VolumeSnapshotsStatus{
+		Snapshots: []v1beta1.VolumeSnapshotStatus{
+			{Name: "one", ReadyToUse: true, RestoreSize: size("1Gi")},
+			{Name: "two", ReadyToUse: true},
+			{Name: "three", ReadyToUse: true, RestoreSize: size("3Gi")},
+			{Name: "four", ReadyToUse: false},
+		},
+	}
+
+	// Replicas are not cloned unless enabled.
+	assert.Assert(t, replicaVolumeSnapshot(cluster, request) == nil)
+
+	cluster.Spec.Backups.Snapshots.BootstrapReplicas = true
+	source := replicaVolumeSnapshot(cluster, request)
+	assert.Assert(t, source != nil)
+	assert.Equal(t, *source.APIGroup, "snapshot.storage.k8s.io")
+	assert.Equal(t, source.Kind, "VolumeSnapshot")
+	assert.Equal(t, source.Name, "two", "expected the latest snapshot that is ready and fits")
+
+	assert.Equal(t, replicaVolumeSnapshot(cluster, resource.MustParse("4Gi")).Name, "three")
+
+	// The WAL of a separate volume is not in the snapshot.
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+		{Name: "00"}, {Name: "01", WALVolumeClaimSpec: &corev1.PersistentVolumeClaimSpec{}},
+	}
+	assert.Assert(t, replicaVolumeSnapshot(cluster, request) == nil)
+}
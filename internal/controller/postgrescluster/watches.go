@@ -16,6 +16,7 @@
 package postgrescluster
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // watchPods returns a handler.EventHandler for Pods.
@@ -44,6 +46,120 @@ func (*Reconciler) watchPods() handler.Funcs {
 					Name:      cluster,
 				}})
 			}
+
+			// When a Patroni pod changes role or readiness, the Endpoints of
+			// the replica Service may need to change. Queue an event to
+			// update them.
+			if len(cluster) != 0 &&
+				(e.ObjectOld.GetLabels()[naming.LabelRole] != labels[naming.LabelRole] ||
+					podReady(e.ObjectOld) != podReady(e.ObjectNew)) {
+				q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+					Namespace: e.ObjectNew.GetNamespace(),
+					Name:      cluster,
+				}})
+			}
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			labels := e.Object.GetLabels()
+			cluster := labels[naming.LabelCluster]
+
+			// When a Patroni replica is deleted, its address must be removed
+			// from the Endpoints of the replica Service. Queue an event to
+			// update them.
+			if len(cluster) != 0 && labels[naming.LabelRole] == naming.RolePatroniReplica {
+				q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+					Namespace: e.Object.GetNamespace(),
+					Name:      cluster,
+				}})
+			}
+		},
+	}
+}
+
+// podReady returns whether or not object is a Pod with a true Ready condition.
+func podReady(object client.Object) bool {
+	if pod, ok := object.(*corev1.Pod); ok {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				return condition.Status == corev1.ConditionTrue
+			}
+		}
+	}
+	return false
+}
+
+// watchPostgresBackupJobs returns a handler.EventHandler for Jobs. The backup Jobs of
+// PostgresBackups are not controlled by their PostgresCluster, so queue an event for the
+// cluster when they change.
+func (*Reconciler) watchPostgresBackupJobs() handler.Funcs {
+	enqueue := func(object client.Object, q workqueue.RateLimitingInterface) {
+		labels := object.GetLabels()
+		if _, ok := labels[naming.LabelPostgresBackup]; ok && len(labels[naming.LabelCluster]) != 0 {
+			q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+				Namespace: object.GetNamespace(),
+				Name:      labels[naming.LabelCluster],
+			}})
+		}
+	}
+
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+	}
+}
+
+// watchPostgresBackups returns a handler.EventHandler for PostgresBackups. Events are queued
+// for the PostgresCluster named in the spec.
+func (*Reconciler) watchPostgresBackups() handler.Funcs {
+	enqueue := func(object client.Object, q workqueue.RateLimitingInterface) {
+		if backup, ok := object.(*v1beta1.PostgresBackup); ok &&
+			len(backup.Spec.PostgresCluster) != 0 {
+			q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+				Namespace: backup.GetNamespace(),
+				Name:      backup.Spec.PostgresCluster,
+			}})
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+	}
+}
+
+// watchPostgresRestores returns a handler.EventHandler for PostgresRestores. Events are queued
+// for the PostgresCluster named in the spec.
+func (*Reconciler) watchPostgresRestores() handler.Funcs {
+	enqueue := func(object client.Object, q workqueue.RateLimitingInterface) {
+		if restore, ok := object.(*v1beta1.PostgresRestore); ok &&
+			len(restore.Spec.PostgresCluster) != 0 {
+			q.Add(reconcile.Request{NamespacedName: client.ObjectKey{
+				Namespace: restore.GetNamespace(),
+				Name:      restore.Spec.PostgresCluster,
+			}})
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(e.Object, q)
 		},
 	}
 }
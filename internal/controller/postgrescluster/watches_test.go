@@ -19,12 +19,15 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestWatchPodsUpdate(t *testing.T) {
@@ -84,6 +87,161 @@ func TestWatchPodsUpdate(t *testing.T) {
 	}, queue)
 	assert.Equal(t, queue.Len(), 1)
 
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "some-ns"
+	expected.Name = "starfish"
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+
+	// Cluster replica became ready; one reconcile by label.
+	update(event.UpdateEvent{
+		ObjectOld: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "some-ns",
+				Labels: map[string]string{
+					"postgres-operator.crunchydata.com/cluster": "starfish",
+					"postgres-operator.crunchydata.com/role":    "replica",
+				},
+			},
+		},
+		ObjectNew: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "some-ns",
+				Labels: map[string]string{
+					"postgres-operator.crunchydata.com/cluster": "starfish",
+					"postgres-operator.crunchydata.com/role":    "replica",
+				},
+			},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:   corev1.PodReady,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+		},
+	}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ = queue.Get()
+	assert.Equal(t, item, expected)
+}
+
+func TestWatchPodsDelete(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	remove := reconciler.watchPods().DeleteFunc
+	assert.Assert(t, remove != nil)
+
+	// No metadata; no reconcile.
+	remove(event.DeleteEvent{Object: &corev1.Pod{}}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// Cluster label, but not a Patroni replica; no reconcile.
+	remove(event.DeleteEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/cluster": "starfish",
+			},
+		},
+	}}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// Cluster replica deleted; one reconcile by label.
+	remove(event.DeleteEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "some-ns",
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/cluster": "starfish",
+				"postgres-operator.crunchydata.com/role":    "replica",
+			},
+		},
+	}}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "some-ns"
+	expected.Name = "starfish"
+	assert.Equal(t, item, expected)
+}
+
+func TestWatchPostgresBackupJobs(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	update := reconciler.watchPostgresBackupJobs().UpdateFunc
+	assert.Assert(t, update != nil)
+
+	// Cluster label, but not for a PostgresBackup; no reconcile.
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "some-ns",
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/cluster": "starfish",
+			},
+		},
+	}
+	update(event.UpdateEvent{ObjectOld: job, ObjectNew: job}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// PostgresBackup Job; one reconcile by label.
+	job = job.DeepCopy()
+	job.Labels["postgres-operator.crunchydata.com/postgresbackup"] = "daily"
+	update(event.UpdateEvent{ObjectOld: job, ObjectNew: job}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "some-ns"
+	expected.Name = "starfish"
+	assert.Equal(t, item, expected)
+}
+
+func TestWatchPostgresBackups(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	create := reconciler.watchPostgresBackups().CreateFunc
+	assert.Assert(t, create != nil)
+
+	// No cluster; no reconcile.
+	create(event.CreateEvent{Object: &v1beta1.PostgresBackup{}}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// One reconcile of the cluster in the spec.
+	backup := &v1beta1.PostgresBackup{}
+	backup.Namespace = "some-ns"
+	backup.Spec.PostgresCluster = "starfish"
+	create(event.CreateEvent{Object: backup}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "some-ns"
+	expected.Name = "starfish"
+	assert.Equal(t, item, expected)
+}
+
+func TestWatchPostgresRestores(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	create := reconciler.watchPostgresRestores().CreateFunc
+	assert.Assert(t, create != nil)
+
+	// No cluster; no reconcile.
+	create(event.CreateEvent{Object: &v1beta1.PostgresRestore{}}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// One reconcile of the cluster in the spec.
+	restore := &v1beta1.PostgresRestore{}
+	restore.Namespace = "some-ns"
+	restore.Spec.PostgresCluster = "starfish"
+	create(event.CreateEvent{Object: restore}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
 	item, _ := queue.Get()
 	expected := reconcile.Request{}
 	expected.Namespace = "some-ns"
@@ -45,4 +45,30 @@ const (
 	// timestamp), which will be stored in the PostgresCluster status to properly track completion
 	// of the Job.
 	PGBackRestRestore = annotationPrefix + "pgbackrest-restore"
+
+	// PostgresRestore is the annotation that is added to a PostgresCluster to allow an in-place
+	// restore by a PostgresRestore. The value of the annotation is the name of the PostgresRestore
+	// that may replace the data of the cluster.
+	PostgresRestore = annotationPrefix + "postgres-restore"
+
+	// StandbyDemotion is the annotation that is added to a PostgresCluster to allow Patroni to
+	// remove the data directory of its standby leader when pg_rewind cannot bring it in line with
+	// the remote host it follows. The data is then copied again. The value is ignored.
+	StandbyDemotion = annotationPrefix + "standby-demotion"
+
+	// PatroniSwitchover is the annotation that is added to a PostgresCluster to initiate a
+	// switchover according to its Patroni switchover spec. The value of the annotation will be a
+	// unique identifier for the switchover (e.g. a timestamp), which will be stored in the
+	// PostgresCluster status so that each switchover happens only once.
+	PatroniSwitchover = annotationPrefix + "trigger-switchover"
+
+	// VolumeSnapshot is the annotation that is added to a PostgresCluster to take a CSI
+	// VolumeSnapshot of the data volume of one of its instances. The value of the annotation
+	// will be a unique identifier for the snapshot (e.g. a timestamp), which will be stored in
+	// the PostgresCluster status so that each snapshot is taken only once.
+	VolumeSnapshot = annotationPrefix + "volume-snapshot"
+
+	// VolumeSnapshotBackupLabel is the annotation that holds the contents of the PostgreSQL
+	// "backup_label" file that was returned when a VolumeSnapshot was taken.
+	VolumeSnapshotBackupLabel = annotationPrefix + "backup-label"
 )
@@ -28,4 +28,8 @@ func TestAnnotationsValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestConfigHash))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestCurrentConfig))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestRestore))
+	assert.Assert(t, nil == validation.IsQualifiedName(PostgresRestore))
+	assert.Assert(t, nil == validation.IsQualifiedName(StandbyDemotion))
+	assert.Assert(t, nil == validation.IsQualifiedName(VolumeSnapshot))
+	assert.Assert(t, nil == validation.IsQualifiedName(VolumeSnapshotBackupLabel))
 }
@@ -73,9 +73,18 @@ const (
 	// support discovery by Prometheus according to pgMonitor configuration
 	LabelPGMonitorDiscovery = labelPrefix + "crunchy-postgres-exporter"
 
+	// LabelPGUpgrade is used to indicate that a Job or Pod is for a major PostgreSQL upgrade
+	LabelPGUpgrade = labelPrefix + "pgupgrade"
+
+	// LabelPostgresBackup identifies the PostgresBackup a Job or Pod is for.
+	LabelPostgresBackup = labelPrefix + "postgresbackup"
+
 	// LabelPostgresUser identifies the PostgreSQL user an object is for or about.
 	LabelPostgresUser = labelPrefix + "pguser"
 
+	// LabelRetained is used to indicate that a volume was kept when its cluster was deleted
+	LabelRetained = labelPrefix + "retained"
+
 	// LabelStartupInstance is used to indicate the startup instance associated with a resource
 	LabelStartupInstance = labelPrefix + "startup-instance"
 
@@ -88,6 +97,9 @@ const (
 	// Patroni sets this LabelRole value on Pods that are following the leader.
 	RolePatroniReplica = "replica"
 
+	// RolePGAdmin is the LabelRole applied to pgAdmin objects.
+	RolePGAdmin = "pgadmin"
+
 	// RolePGBouncer is the LabelRole applied to PgBouncer objects.
 	RolePGBouncer = "pgbouncer"
 
@@ -115,6 +127,16 @@ const (
 	// BackupReplicaCreate is the backup type for the backup taken to enable pgBackRest replica
 	// creation
 	BackupReplicaCreate BackupJobType = "replica-create"
+
+	// BackupPGUpgrade is the backup type for the backup taken before a major PostgreSQL upgrade
+	BackupPGUpgrade BackupJobType = "pgupgrade"
+
+	// BackupPostgresBackup is the backup type for the backups requested using PostgresBackup
+	// objects
+	BackupPostgresBackup BackupJobType = "postgresbackup"
+
+	// BackupFinal is the backup type for the backup taken before a cluster is deleted
+	BackupFinal BackupJobType = "final"
 )
 
 // Merge takes sets of labels and merges them. The last set
@@ -251,3 +273,16 @@ func PGBackRestRepoVolumeLabels(clusterName, repoName string) labels.Set {
 	}
 	return labels.Merge(repoLabels, repoVolLabels)
 }
+
+// PGUpgradeJobLabels provides labels for the Job that runs pg_upgrade.
+func PGUpgradeJobLabels(clusterName string) labels.Set {
+	return map[string]string{
+		LabelCluster:   clusterName,
+		LabelPGUpgrade: "",
+	}
+}
+
+// PGUpgradeJobSelector provides a selector for querying the Job that runs pg_upgrade.
+func PGUpgradeJobSelector(clusterName string) labels.Selector {
+	return PGUpgradeJobLabels(clusterName).AsSelector()
+}
@@ -39,13 +39,17 @@ func TestLabelsValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestore))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestoreConfig))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGMonitorDiscovery))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGUpgrade))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelPostgresBackup))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPostgresUser))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelRetained))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelStartupInstance))
 }
 
 func TestLabelValuesValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePatroniLeader))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePatroniReplica))
+	assert.Assert(t, nil == validation.IsValidLabelValue(RolePGAdmin))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePGBouncer))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePostgresData))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePostgresUser))
@@ -53,6 +57,9 @@ func TestLabelValuesValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePrimary))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleReplica))
 	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupReplicaCreate)))
+	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupPGUpgrade)))
+	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupPostgresBackup)))
+	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupFinal)))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleMonitoring))
 }
 
@@ -222,3 +229,15 @@ func TestPGBackRestLabelFuncs(t *testing.T) {
 	pgBackRestRestoreConfigSelector := PGBackRestRestoreConfigSelector(clusterName)
 	assert.Check(t, pgBackRestRestoreConfigSelector.Matches(pgBackRestRestoreConfigLabels))
 }
+
+func TestPGUpgradeLabels(t *testing.T) {
+	clusterName := "hippo"
+
+	pgUpgradeJobLabels := PGUpgradeJobLabels(clusterName)
+	assert.Equal(t, pgUpgradeJobLabels.Get(LabelCluster), clusterName)
+	assert.Check(t, pgUpgradeJobLabels.Has(LabelPGUpgrade))
+	assert.Check(t, !pgUpgradeJobLabels.Has(LabelPGBackRest))
+
+	pgUpgradeJobSelector := PGUpgradeJobSelector(clusterName)
+	assert.Check(t, pgUpgradeJobSelector.Matches(pgUpgradeJobLabels))
+}
@@ -32,6 +32,9 @@ const (
 	// supporting tools: Patroni, pgBackRest, etc.
 	ContainerDatabase = "database"
 
+	// ContainerPGAdmin is the name of a container running pgAdmin.
+	ContainerPGAdmin = "pgadmin"
+
 	// ContainerPGBouncer is the name of a container running PgBouncer.
 	ContainerPGBouncer = "pgbouncer"
 	// ContainerPGBouncerConfig is the name of a container supporting PgBouncer.
@@ -54,10 +57,14 @@ const (
 
 	// ContainerPGMonitorExporter is the name of a container running postgres_exporter
 	ContainerPGMonitorExporter = "exporter"
+
+	// ContainerPGUpgrade is the name of the container that runs pg_upgrade
+	ContainerPGUpgrade = "pgupgrade"
 )
 
 const (
 	PortExporter   = "exporter"
+	PortPGAdmin    = "pgadmin"
 	PortPGBouncer  = "pgbouncer"
 	PortPostgreSQL = "postgres"
 )
@@ -169,8 +176,19 @@ func ClusterInstanceRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// ClusterPGAdmin returns the ObjectMeta necessary to lookup the ConfigMap,
+// PersistentVolumeClaim, Service, or StatefulSet that is cluster's pgAdmin
+// user interface.
+func ClusterPGAdmin(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-pgadmin",
+	}
+}
+
 // ClusterPGBouncer returns the ObjectMeta necessary to lookup the ConfigMap,
-// Deployment, Secret, or Service that is cluster's PgBouncer proxy.
+// Deployment, PodDisruptionBudget, Secret, or Service that is cluster's
+// PgBouncer proxy.
 func ClusterPGBouncer(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
@@ -200,6 +218,15 @@ func ClusterPrimaryService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// ClusterReplicaService returns the ObjectMeta necessary to lookup the Service
+// that exposes PostgreSQL replica instances.
+func ClusterReplicaService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-replicas",
+	}
+}
+
 // GenerateInstance returns a random name for a member of cluster and set.
 func GenerateInstance(
 	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresInstanceSetSpec,
@@ -228,6 +255,31 @@ func GenerateStartupInstance(
 	}
 }
 
+// ClusterVolumeSnapshot returns the ObjectMeta of the VolumeSnapshot of cluster that
+// is taken for id, the value of the VolumeSnapshot annotation. The name is stable so
+// that a snapshot is not taken twice for the same id.
+func ClusterVolumeSnapshot(cluster *v1beta1.PostgresCluster, id string) metav1.ObjectMeta {
+	// hash.Hash.Write never returns an error: https://pkg.go.dev/hash#Hash.
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte(id))
+
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-pgdata-" + rand.SafeEncodeString(fmt.Sprint(hash.Sum32())),
+	}
+}
+
+// InstanceSet returns the ObjectMeta necessary to lookup the PodDisruptionBudget
+// of set in cluster.
+func InstanceSet(
+	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresInstanceSetSpec,
+) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name + "-set-" + set.Name,
+	}
+}
+
 // InstanceConfigMap returns the ObjectMeta necessary to lookup
 // instance's shared ConfigMap.
 func InstanceConfigMap(instance metav1.Object) metav1.ObjectMeta {
@@ -264,6 +316,16 @@ func InstancePostgresWALVolume(instance *appsv1.StatefulSet) metav1.ObjectMeta {
 	}
 }
 
+// AdoptedVolume returns the ObjectMeta of the PersistentVolumeClaim that cluster uses
+// in place of volume. This is an existing claim adopted from the data source of cluster
+// or volume itself.
+func AdoptedVolume(cluster *v1beta1.PostgresCluster, volume metav1.ObjectMeta) metav1.ObjectMeta {
+	if name, ok := cluster.Status.AdoptedVolumes[volume.Name]; ok {
+		volume.Name = name
+	}
+	return volume
+}
+
 // MonitoringUserSecret returns ObjectMeta necessary to lookup the Secret
 // containing authentication credentials for monitoring tools.
 func MonitoringUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -317,6 +379,17 @@ func PatroniScope(cluster *v1beta1.PostgresCluster) string {
 	return cluster.Name + "-ha"
 }
 
+// PatroniSynchronousState returns the ObjectMeta necessary to lookup the
+// Endpoints created by Patroni for the synchronous replication state of cluster.
+// The same name is used for the Service that preserves those Endpoints.
+// See Patroni DCS "sync_path".
+func PatroniSynchronousState(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.Namespace,
+		Name:      PatroniScope(cluster) + "-sync",
+	}
+}
+
 // PatroniTrigger returns the ObjectMeta necessary to lookup the ConfigMap or
 // Endpoints Patroni creates for cluster to initiate a controlled change of the
 // leader. See Patroni DCS "failover_path".
@@ -335,6 +408,15 @@ func PGBackRestConfig(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestRepoHost returns the ObjectMeta for the pgBackRest dedicated repository host
+// StatefulSet and its PodDisruptionBudget
+func PGBackRestRepoHost(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.GetName() + "-repo-host",
+	}
+}
+
 // PGBackRestBackupJob returns the ObjectMeta for the pgBackRest backup Job utilized
 // to create replicas using pgBackRest
 func PGBackRestBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -360,6 +442,49 @@ func PGBackRestRestoreJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestFinalBackupJob returns the ObjectMeta for the pgBackRest backup Job taken
+// before a cluster is deleted
+func PGBackRestFinalBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.Name + "-backup-final",
+	}
+}
+
+// DataSourceVolumesJob returns the ObjectMeta for the Job that checks the PostgreSQL data
+// in volumes adopted from the data source of a cluster
+func DataSourceVolumesJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.Name + "-pgdata-adopt",
+	}
+}
+
+// PGUpgradeBackupJob returns the ObjectMeta for the pgBackRest backup Job taken before a
+// major PostgreSQL upgrade
+func PGUpgradeBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.Name + "-backup-pgupgrade",
+	}
+}
+
+// PGUpgradeJob returns the ObjectMeta for the Job that runs pg_upgrade
+func PGUpgradeJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.Name + "-pgupgrade",
+	}
+}
+
+// PostgresBackupJob returns the ObjectMeta for the pgBackRest backup Job of a PostgresBackup
+func PostgresBackupJob(backup *v1beta1.PostgresBackup) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: backup.GetNamespace(),
+		Name:      backup.Name + "-backup",
+	}
+}
+
 // PGBackRestRBAC returns the ObjectMeta necessary to lookup the ServiceAccount, Role, and
 // RoleBinding for pgBackRest Jobs
 func PGBackRestRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
@@ -394,6 +519,15 @@ func PGBackRestSSHSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestCipherSecret returns the ObjectMeta for the Secret that holds the passphrases of
+// encrypted pgBackRest repositories
+func PGBackRestCipherSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      cluster.GetName() + "-pgbackrest-cipher",
+		Namespace: cluster.GetNamespace(),
+	}
+}
+
 // DeprecatedPostgresUserSecret returns the ObjectMeta necessary to lookup the
 // old Secret containing the default Postgres user and connection information.
 // Use PostgresUserSecret instead.
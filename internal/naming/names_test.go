@@ -45,8 +45,10 @@ func TestContainerNamesUniqueAndValid(t *testing.T) {
 	for _, name := range []string{
 		ContainerDatabase,
 		ContainerNSSWrapperInit,
+		ContainerPGAdmin,
 		ContainerPGBouncer,
 		ContainerPGBouncerConfig,
+		ContainerPGUpgrade,
 		ContainerPostgresStartup,
 		ContainerPGMonitorExporter,
 	} {
@@ -86,6 +88,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 	t.Run("ConfigMaps", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"ClusterConfigMap", ClusterConfigMap(cluster)},
+			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
 			{"ClusterPGBouncer", ClusterPGBouncer(cluster)},
 			{"PatroniDistributedConfiguration", PatroniDistributedConfiguration(cluster)},
 			{"PatroniLeaderConfigMap", PatroniLeaderConfigMap(cluster)},
@@ -113,7 +116,14 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 	t.Run("Jobs", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"PGBackRestBackupJob", PGBackRestBackupJob(cluster)},
+			{"PGBackRestFinalBackupJob", PGBackRestFinalBackupJob(cluster)},
+			{"DataSourceVolumesJob", DataSourceVolumesJob(cluster)},
 			{"PGBackRestRestoreJob", PGBackRestRestoreJob(cluster)},
+			{"PGUpgradeBackupJob", PGUpgradeBackupJob(cluster)},
+			{"PGUpgradeJob", PGUpgradeJob(cluster)},
+			{"PostgresBackupJob", PostgresBackupJob(&v1beta1.PostgresBackup{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pg0"},
+			})},
 		})
 	})
 
@@ -138,6 +148,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 			{"PostgresTLSSecret", PostgresTLSSecret(cluster)},
 			{"ReplicationClientCertSecret", ReplicationClientCertSecret(cluster)},
 			{"PGBackRestSSHSecret", PGBackRestSSHSecret(cluster)},
+			{"PGBackRestCipherSecret", PGBackRestCipherSecret(cluster)},
 			{"MonitoringUserSecret", MonitoringUserSecret(cluster)},
 		})
 
@@ -154,6 +165,14 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 		})
 	})
 
+	t.Run("PodDisruptionBudgets", func(t *testing.T) {
+		testUniqueAndValid(t, []test{
+			{"ClusterPGBouncer", ClusterPGBouncer(cluster)},
+			{"InstanceSet", InstanceSet(cluster, &v1beta1.PostgresInstanceSetSpec{Name: "00"})},
+			{"PGBackRestRepoHost", PGBackRestRepoHost(cluster)},
+		})
+	})
+
 	t.Run("ServiceAccounts", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"ClusterInstanceRBAC", ClusterInstanceRBAC(cluster)},
@@ -163,21 +182,41 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 
 	t.Run("Services", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
+			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
 			{"ClusterPGBouncer", ClusterPGBouncer(cluster)},
 			{"ClusterPodService", ClusterPodService(cluster)},
 			{"ClusterPrimaryService", ClusterPrimaryService(cluster)},
+			{"ClusterReplicaService", ClusterReplicaService(cluster)},
 			// Patroni can use Endpoints which relate directly to a Service.
 			{"PatroniDistributedConfiguration", PatroniDistributedConfiguration(cluster)},
 			{"PatroniLeaderEndpoints", PatroniLeaderEndpoints(cluster)},
+			{"PatroniSynchronousState", PatroniSynchronousState(cluster)},
 			{"PatroniTrigger", PatroniTrigger(cluster)},
 		})
 	})
 
+	t.Run("StatefulSets", func(t *testing.T) {
+		testUniqueAndValid(t, []test{
+			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
+		})
+	})
+
 	t.Run("Volumes", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
+			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
 			{"PGBackRestRepoVolume", PGBackRestRepoVolume(cluster, repoName)},
 		})
 	})
+
+	t.Run("VolumeSnapshots", func(t *testing.T) {
+		testUniqueAndValid(t, []test{
+			{"ClusterVolumeSnapshot", ClusterVolumeSnapshot(cluster, "2021-06-01T00:00:00Z")},
+			{"ClusterVolumeSnapshot", ClusterVolumeSnapshot(cluster, "2021-06-02T00:00:00Z")},
+		})
+
+		// The name is the same for the same identifier.
+		assert.DeepEqual(t, ClusterVolumeSnapshot(cluster, "x"), ClusterVolumeSnapshot(cluster, "x"))
+	})
 }
 
 func TestInstanceNamesUniqueAndValid(t *testing.T) {
@@ -273,3 +312,17 @@ func TestGenerateStartupInstance(t *testing.T) {
 	assert.DeepEqual(t, instanceOne, instanceTwo)
 
 }
+
+func TestAdoptedVolume(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "pg0"
+
+	volume := metav1.ObjectMeta{Namespace: "ns1", Name: "pg0-one-abcd-pgdata"}
+	assert.DeepEqual(t, AdoptedVolume(cluster, volume), volume)
+
+	cluster.Status.AdoptedVolumes = map[string]string{"pg0-one-abcd-pgdata": "old-pgdata"}
+	assert.DeepEqual(t, AdoptedVolume(cluster, volume),
+		metav1.ObjectMeta{Namespace: "ns1", Name: "old-pgdata"})
+	assert.DeepEqual(t, AdoptedVolume(cluster, PGBackRestRepoVolume(cluster, "repo1")),
+		PGBackRestRepoVolume(cluster, "repo1"))
+}
@@ -78,6 +78,18 @@ func ClusterInstanceSet(cluster, set string) metav1.LabelSelector {
 	}
 }
 
+// ClusterInstanceSets selects things for sets in cluster.
+func ClusterInstanceSets(cluster string) metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelCluster: cluster,
+		},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: LabelInstanceSet, Operator: metav1.LabelSelectorOpExists},
+		},
+	}
+}
+
 // ClusterPatronis selects things labeled for Patroni in cluster.
 func ClusterPatronis(cluster *v1beta1.PostgresCluster) metav1.LabelSelector {
 	return metav1.LabelSelector{
@@ -102,6 +114,17 @@ func ClusterPostgresUsers(cluster string) metav1.LabelSelector {
 	}
 }
 
+// ClusterPostgresData selects the PostgreSQL data volumes of cluster and their
+// VolumeSnapshots.
+func ClusterPostgresData(cluster string) metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelCluster: cluster,
+			LabelRole:    RolePostgresData,
+		},
+	}
+}
+
 // ClusterPrimary selects things for the Primary PostgreSQL instance.
 func ClusterPrimary(cluster string) metav1.LabelSelector {
 	s := ClusterInstances(cluster)
@@ -79,6 +79,18 @@ func TestClusterInstanceSet(t *testing.T) {
 	assert.ErrorContains(t, err, "invalid")
 }
 
+func TestClusterInstanceSets(t *testing.T) {
+	s, err := AsSelector(ClusterInstanceSets("something"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, s.String(), strings.Join([]string{
+		"postgres-operator.crunchydata.com/cluster=something",
+		"postgres-operator.crunchydata.com/instance-set",
+	}, ","))
+
+	_, err = AsSelector(ClusterInstanceSets("--whoa/yikes"))
+	assert.ErrorContains(t, err, "invalid")
+}
+
 func TestClusterPatronis(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.Name = "something"
@@ -107,6 +119,18 @@ func TestClusterPostgresUsers(t *testing.T) {
 	assert.ErrorContains(t, err, "invalid")
 }
 
+func TestClusterPostgresData(t *testing.T) {
+	s, err := AsSelector(ClusterPostgresData("something"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, s.String(), strings.Join([]string{
+		"postgres-operator.crunchydata.com/cluster=something",
+		"postgres-operator.crunchydata.com/role=pgdata",
+	}, ","))
+
+	_, err = AsSelector(ClusterPostgresData("--nope--"))
+	assert.ErrorContains(t, err, "invalid")
+}
+
 func TestClusterPrimary(t *testing.T) {
 	s, err := AsSelector(ClusterPrimary("something"))
 	assert.NilError(t, err)
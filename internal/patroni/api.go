@@ -31,8 +31,20 @@ type API interface {
 	// paused, next cannot be blank.
 	ChangePrimaryAndWait(ctx context.Context, current, next string) (bool, error)
 
+	// FailoverAndWait promotes next whether or not the current Patroni leader
+	// is healthy. It returns true when an election completes successfully.
+	FailoverAndWait(ctx context.Context, next string) (bool, error)
+
+	// GetLeader returns the name of the current Patroni leader and the timeline
+	// it reports.
+	GetLeader(ctx context.Context) (string, int64, error)
+
 	// ReplaceConfiguration replaces Patroni's entire dynamic configuration.
 	ReplaceConfiguration(ctx context.Context, configuration map[string]interface{}) error
+
+	// RestartPendingMember restarts PostgreSQL of member when it is pending
+	// restart. It returns true when the restart completes successfully.
+	RestartPendingMember(ctx context.Context, scope, member string) (bool, error)
 }
 
 // Executor implements API by calling "patronictl".
@@ -69,6 +81,50 @@ func (exec Executor) ChangePrimaryAndWait(
 	return strings.Contains(stdout.String(), "switched over"), err
 }
 
+// FailoverAndWait promotes next by calling "patronictl". Unlike a switchover,
+// this does not require a healthy leader. It returns true when an election
+// completes successfully.
+func (exec Executor) FailoverAndWait(ctx context.Context, next string) (bool, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := exec(ctx, nil, &stdout, &stderr,
+		"patronictl", "failover", "--force", "--candidate="+next)
+
+	log := logging.FromContext(ctx)
+	log.V(1).Info("failed over",
+		"stdout", stdout.String(),
+		"stderr", stderr.String(),
+	)
+
+	// Like switchover, the command exits zero even when the API says failover
+	// did not occur. Check for the text that indicates success.
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/api.py
+	return strings.Contains(stdout.String(), "failed over"), err
+}
+
+// GetLeader returns the name of the current Patroni leader and the timeline it
+// reports by calling "patronictl". The name is blank when there is no leader.
+func (exec Executor) GetLeader(ctx context.Context) (string, int64, error) {
+	var stdout, stderr bytes.Buffer
+	var members []struct {
+		Member   string `json:"Member"`
+		Role     string `json:"Role"`
+		Timeline int64  `json:"TL"`
+	}
+
+	err := exec(ctx, nil, &stdout, &stderr, "patronictl", "list", "--format=json")
+	if err == nil {
+		err = json.Unmarshal(stdout.Bytes(), &members)
+	}
+
+	for _, member := range members {
+		if member.Role == "Leader" || member.Role == "Standby Leader" {
+			return member.Member, member.Timeline, err
+		}
+	}
+	return "", 0, err
+}
+
 // ReplaceConfiguration replaces Patroni's entire dynamic configuration by
 // calling "patronictl".
 func (exec Executor) ReplaceConfiguration(
@@ -90,3 +146,26 @@ func (exec Executor) ReplaceConfiguration(
 
 	return err
 }
+
+// RestartPendingMember restarts PostgreSQL of member in the Patroni cluster
+// scope by calling "patronictl". Patroni does nothing when member is not
+// pending restart. It returns true when the restart completes successfully.
+func (exec Executor) RestartPendingMember(
+	ctx context.Context, scope, member string,
+) (bool, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := exec(ctx, nil, &stdout, &stderr,
+		"patronictl", "restart", "--pending", "--force", scope, member)
+
+	log := logging.FromContext(ctx)
+	log.V(1).Info("restarted member",
+		"stdout", stdout.String(),
+		"stderr", stderr.String(),
+	)
+
+	// The command exits zero even when the API says restart did not occur.
+	// Check for the text that indicates success.
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/ctl.py
+	return strings.Contains(stdout.String(), "Success: restart"), err
+}
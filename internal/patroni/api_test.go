@@ -91,6 +91,125 @@ func TestExecutorChangePrimaryAndWait(t *testing.T) {
 	})
 }
 
+func TestExecutorFailoverAndWait(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			called = true
+			assert.DeepEqual(t, command, strings.Fields(
+				`patronictl failover --force --candidate=new`,
+			))
+			assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			return nil
+		}
+
+		_, _ = Executor(exec).FailoverAndWait(context.Background(), "new")
+		assert.Assert(t, called)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("bang")
+		_, actual := Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return expected
+		}).FailoverAndWait(context.Background(), "any")
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		success, _ := Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`Failover failed`))
+			return nil
+		}).FailoverAndWait(context.Background(), "any")
+
+		assert.Assert(t, !success, "expected failure message to become false")
+
+		success, _ = Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`Successfully failed over to "any"`))
+			return nil
+		}).FailoverAndWait(context.Background(), "any")
+
+		assert.Assert(t, success, "expected success message to become true")
+	})
+}
+
+func TestExecutorGetLeader(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			called = true
+			assert.DeepEqual(t, command, strings.Fields(`patronictl list --format=json`))
+			assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			_, _ = stdout.Write([]byte(`[]`))
+			return nil
+		}
+
+		_, _, err := Executor(exec).GetLeader(context.Background())
+		assert.NilError(t, err)
+		assert.Assert(t, called)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("bang")
+		_, _, actual := Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return expected
+		}).GetLeader(context.Background())
+
+		assert.Equal(t, expected, actual)
+
+		_, _, actual = Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`not json`))
+			return nil
+		}).GetLeader(context.Background())
+
+		assert.ErrorContains(t, actual, "invalid character")
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		leader, timeline, err := Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`[
+				{"Cluster": "hippo-ha", "Member": "hippo-00-abcd-0", "Role": "Replica", "TL": 3},
+				{"Cluster": "hippo-ha", "Member": "hippo-00-efgh-0", "Role": "Leader", "TL": 4}
+			]`))
+			return nil
+		}).GetLeader(context.Background())
+
+		assert.NilError(t, err)
+		assert.Equal(t, leader, "hippo-00-efgh-0")
+		assert.Equal(t, timeline, int64(4))
+
+		leader, _, err = Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`[{"Member": "hippo-00-abcd-0", "Role": "Replica"}]`))
+			return nil
+		}).GetLeader(context.Background())
+
+		assert.NilError(t, err)
+		assert.Equal(t, leader, "", "expected no leader")
+	})
+}
+
 func TestExecutorReplaceConfiguration(t *testing.T) {
 	expected := errors.New("bang")
 	exec := func(
@@ -112,3 +231,55 @@ func TestExecutorReplaceConfiguration(t *testing.T) {
 
 	assert.Equal(t, expected, actual, "should call exec")
 }
+
+func TestExecutorRestartPendingMember(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			called = true
+			assert.DeepEqual(t, command, strings.Fields(
+				`patronictl restart --pending --force hippo-ha hippo-00-abcd-0`,
+			))
+			assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			return nil
+		}
+
+		_, _ = Executor(exec).RestartPendingMember(context.Background(), "hippo-ha", "hippo-00-abcd-0")
+		assert.Assert(t, called)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("bang")
+		_, actual := Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return expected
+		}).RestartPendingMember(context.Background(), "any", "any")
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		success, _ := Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`Failed: restart for member any, status code=503`))
+			return nil
+		}).RestartPendingMember(context.Background(), "any", "any")
+
+		assert.Assert(t, !success, "expected failure message to become false")
+
+		success, _ = Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`Success: restart on member any`))
+			return nil
+		}).RestartPendingMember(context.Background(), "any", "any")
+
+		assert.Assert(t, success, "expected success message to become true")
+	})
+}
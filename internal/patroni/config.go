@@ -227,6 +227,23 @@ func DynamicConfiguration(
 			parameters[k] = v
 		}
 	}
+	// Override the above with the synchronous replication settings of the spec.
+	// - https://patroni.readthedocs.io/en/latest/replication_modes.html
+	if sync := cluster.Spec.Patroni.Synchronous; sync != nil {
+		enabled := sync.Mode != v1beta1.PatroniSynchronousModeOff
+
+		root["synchronous_mode"] = enabled
+		root["synchronous_mode_strict"] = sync.Mode == v1beta1.PatroniSynchronousModeStrict
+		if sync.Mode == v1beta1.PatroniSynchronousModeQuorum && QuorumCommitSupported(cluster) {
+			root["synchronous_mode"] = "quorum"
+		}
+		if sync.NumberOfStandbys != nil {
+			root["synchronous_node_count"] = *sync.NumberOfStandbys
+		}
+		if enabled && sync.Commit != "" {
+			parameters["synchronous_commit"] = sync.Commit
+		}
+	}
 	// Override the above with mandatory parameters.
 	if pgParameters.Mandatory != nil {
 		for k, v := range pgParameters.Mandatory.AsMap() {
@@ -278,15 +295,45 @@ func DynamicConfiguration(
 			}
 		}
 
-		// NOTE(cbandy): pgBackRest is the only supported standby source.
+		var methods []string
+		spec := cluster.Spec.Standby
 
-		// Do not fallback to other methods when creating the standby leader.
-		standby["create_replica_methods"] = []string{pgBackRestCreateReplicaMethod}
+		if spec.RepoName != "" {
+			// Populate the standby leader by shipping logs through pgBackRest.
+			// This also overrides the "restore_command" used by standby replicas.
+			// - https://www.postgresql.org/docs/current/warm-standby.html
+			methods = append(methods, pgBackRestCreateReplicaMethod)
+			standby["restore_command"] = pgParameters.Mandatory.Value("restore_command")
+		}
+
+		if spec.Host != "" {
+			// Stream from the remote host using the replication credentials
+			// of this cluster. A repository, when there is one, is still
+			// preferred for the initial copy of the data directory.
+			// - https://patroni.readthedocs.io/en/latest/replica_bootstrap.html#standby-cluster
+			methods = append(methods, "basebackup")
+			standby["host"] = spec.Host
+			if spec.Port != nil {
+				standby["port"] = *spec.Port
+			}
+			if spec.PrimarySlotName != "" {
+				standby["primary_slot_name"] = spec.PrimarySlotName
+			}
+		}
 
-		// Populate the standby leader by shipping logs through pgBackRest.
-		// This also overrides the "restore_command" used by standby replicas.
-		// - https://www.postgresql.org/docs/current/warm-standby.html
-		standby["restore_command"] = pgParameters.Mandatory.Value("restore_command")
+		// Do not fallback to other methods when creating the standby leader.
+		standby["create_replica_methods"] = methods
+
+		// The data of a standby cluster is a copy of another cluster. When this
+		// cluster was demoted and has diverged from the one it now follows,
+		// Patroni rewinds it with pg_rewind. When that is not possible and the
+		// user allows it, Patroni removes the data and copies it again using the
+		// methods above.
+		// - https://patroni.readthedocs.io/en/latest/SETTINGS.html#postgresql
+		if _, allowed := cluster.Annotations[naming.StandbyDemotion]; allowed {
+			postgresql["remove_data_directory_on_rewind_failure"] = true
+			postgresql["remove_data_directory_on_diverged_timelines"] = true
+		}
 
 		root["standby_cluster"] = standby
 	}
@@ -294,6 +341,47 @@ func DynamicConfiguration(
 	return root
 }
 
+// SynchronousModeEnabled returns whether or not Patroni should manage synchronous
+// replication for cluster, according to its synchronous spec or, when that is
+// omitted, its dynamic configuration.
+func SynchronousModeEnabled(cluster *v1beta1.PostgresCluster) bool {
+	if cluster.Spec.Patroni == nil {
+		return false
+	}
+	if sync := cluster.Spec.Patroni.Synchronous; sync != nil {
+		return sync.Mode != v1beta1.PatroniSynchronousModeOff
+	}
+
+	// Patroni interprets many values as boolean. These are the ones that are true.
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/utils.py
+	var configuration struct {
+		Mode interface{} `json:"synchronous_mode"`
+	}
+	_ = yaml.Unmarshal(cluster.Spec.Patroni.DynamicConfiguration.Raw, &configuration)
+
+	switch mode := configuration.Mode.(type) {
+	case bool:
+		return mode
+	case string:
+		switch strings.ToLower(mode) {
+		case "on", "true", "yes", "quorum":
+			return true
+		}
+	}
+	return false
+}
+
+// quorumCommitVersion is the first version of Patroni that manages quorum commit.
+// - https://github.com/zalando/patroni/blob/v4.0.0/docs/releases.rst
+const quorumCommitVersion = "4.0.0"
+
+// QuorumCommitSupported returns whether or not every instance of cluster last reported a
+// version of Patroni that manages quorum commit.
+func QuorumCommitSupported(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Status.Patroni != nil && cluster.Status.Patroni.Version != "" &&
+		CompareVersions(cluster.Status.Patroni.Version, quorumCommitVersion) >= 0
+}
+
 // instanceEnvironment returns the environment variables needed by Patroni's
 // instance container.
 func instanceEnvironment(
@@ -448,10 +536,32 @@ func instanceConfigFiles(cluster, instance *v1.ConfigMap) []v1.VolumeProjection
 	}
 }
 
+// instanceTags returns the Patroni tags of the instances in instance set.
+// - https://patroni.readthedocs.io/en/latest/SETTINGS.html#tags
+func instanceTags(instance *v1beta1.PostgresInstanceSetSpec) map[string]interface{} {
+	tags := map[string]interface{}{}
+
+	// An instance that cannot become primary should not be a synchronous
+	// standby either. Patroni promotes only synchronous standbys when the
+	// primary fails during synchronous replication.
+	if !instance.Patroni.FailoverCandidate() {
+		tags["nofailover"] = true
+		tags["nosync"] = true
+	}
+	if instance.Patroni != nil && instance.Patroni.NoLoadBalance {
+		tags["noloadbalance"] = true
+	}
+	if instance.Patroni != nil && instance.Patroni.CloneFrom {
+		tags["clonefrom"] = true
+	}
+
+	return tags
+}
+
 // instanceYAML returns Patroni settings that apply to instance.
 func instanceYAML(
 	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
-	pgbackrestReplicaCreateCommand []string,
+	pgParameters postgres.Parameters, pgbackrestReplicaCreateCommand []string,
 ) (string, error) {
 	root := map[string]interface{}{
 		// Missing here is "name" which cannot be known until the instance Pod is
@@ -476,10 +586,7 @@ func instanceYAML(
 			// See the PATRONI_RESTAPI_LISTEN environment variable.
 		},
 
-		"tags": map[string]interface{}{
-			// TODO(cbandy): "nofailover"
-			// TODO(cbandy): "nosync"
-		},
+		"tags": instanceTags(instance),
 	}
 
 	postgresql := map[string]interface{}{
@@ -503,6 +610,20 @@ func instanceYAML(
 	}
 	root["postgresql"] = postgresql
 
+	// Parameters of the instance set take precedence over those in DCS, except
+	// those that Patroni requires to be the same on every instance. Mandatory
+	// parameters are in DCS and take precedence over everything.
+	// - https://patroni.readthedocs.io/en/latest/SETTINGS.html#postgresql
+	parameters := make(map[string]interface{})
+	for k, v := range postgres.InstanceParameters(instance).AsMap() {
+		if pgParameters.Mandatory == nil || !pgParameters.Mandatory.Has(k) {
+			parameters[k] = v
+		}
+	}
+	if len(parameters) > 0 {
+		postgresql["parameters"] = parameters
+	}
+
 	// The "basebackup" replica method is configured differently from others.
 	// Patroni prepends "--" before it calls `pg_basebackup`.
 	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/postgresql/bootstrap.py#L45
@@ -551,8 +672,11 @@ func instanceYAML(
 	postgresql["create_replica_methods"] = methods
 
 	if !ClusterBootstrapped(cluster) {
-		// if restore status exists, then a restore occurred an the "existing" method is used
-		if cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.Restore != nil {
+		// if restore status exists, then a restore occurred an the "existing" method is used.
+		// The same is true when pg_upgrade prepared the data directory of a major upgrade
+		// that has not yet finished.
+		if (cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.Restore != nil) ||
+			(cluster.Status.Upgrade != nil && !cluster.Status.Upgrade.Finished) {
 			data_dir := postgres.DataDirectory(cluster)
 			root["bootstrap"] = map[string]interface{}{
 				"method": "existing",
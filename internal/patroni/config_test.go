@@ -27,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -504,7 +505,8 @@ func TestDynamicConfiguration(t *testing.T) {
 			cluster: &v1beta1.PostgresCluster{
 				Spec: v1beta1.PostgresClusterSpec{
 					Standby: &v1beta1.PostgresStandbySpec{
-						Enabled: true,
+						Enabled:  true,
+						RepoName: "repo1",
 					},
 				},
 			},
@@ -537,6 +539,175 @@ func TestDynamicConfiguration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "standby_cluster: remote host",
+			cluster: &v1beta1.PostgresCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{naming.StandbyDemotion: ""},
+				},
+				Spec: v1beta1.PostgresClusterSpec{
+					Standby: &v1beta1.PostgresStandbySpec{
+						Enabled:         true,
+						Host:            "rhino-primary.west.svc",
+						Port:            newInt32(5433),
+						PrimarySlotName: "hippo",
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"standby_cluster": map[string]interface{}{
+					"host": "overridden",
+				},
+			},
+			params: postgres.Parameters{
+				Mandatory: parameters(map[string]string{
+					"restore_command": "mandatory",
+				}),
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"restore_command": "mandatory",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+
+					"remove_data_directory_on_rewind_failure":     true,
+					"remove_data_directory_on_diverged_timelines": true,
+				},
+				"standby_cluster": map[string]interface{}{
+					"create_replica_methods": []string{"basebackup"},
+					"host":                   "rhino-primary.west.svc",
+					"port":                   int32(5433),
+					"primary_slot_name":      "hippo",
+				},
+			},
+		},
+		{
+			name: "synchronous: spec overrides input",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						Synchronous: &v1beta1.PatroniSynchronous{
+							Mode:             "strict",
+							NumberOfStandbys: newInt32(2),
+							Commit:           "remote_apply",
+						},
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"synchronous_mode":       false,
+				"synchronous_node_count": 5,
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_commit": "local",
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        true,
+				"synchronous_mode_strict": true,
+				"synchronous_node_count":  int32(2),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_commit": "remote_apply",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "synchronous: off",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						Synchronous: &v1beta1.PatroniSynchronous{
+							Mode: "off", Commit: "remote_apply",
+						},
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"synchronous_mode": true,
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        false,
+				"synchronous_mode_strict": false,
+				"synchronous_node_count":  int32(1),
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "synchronous: quorum",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						Synchronous: &v1beta1.PatroniSynchronous{
+							Mode: "quorum", NumberOfStandbys: newInt32(2),
+						},
+					},
+				},
+				Status: v1beta1.PostgresClusterStatus{
+					Patroni: &v1beta1.PatroniStatus{Version: "4.0.2"},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        "quorum",
+				"synchronous_mode_strict": false,
+				"synchronous_node_count":  int32(2),
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "synchronous: quorum unsupported",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						Synchronous: &v1beta1.PatroniSynchronous{
+							Mode: "quorum", NumberOfStandbys: newInt32(2),
+						},
+					},
+				},
+				Status: v1beta1.PostgresClusterStatus{
+					Patroni: &v1beta1.PatroniStatus{Version: "2.1.4"},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        true,
+				"synchronous_mode_strict": false,
+				"synchronous_node_count":  int32(2),
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			cluster := tt.cluster
@@ -550,6 +721,40 @@ func TestDynamicConfiguration(t *testing.T) {
 	}
 }
 
+func TestSynchronousModeEnabled(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	assert.Assert(t, !SynchronousModeEnabled(cluster))
+
+	cluster.Default()
+	assert.Assert(t, !SynchronousModeEnabled(cluster))
+
+	for _, tt := range []struct {
+		raw     string
+		enabled bool
+	}{
+		{raw: `{}`, enabled: false},
+		{raw: `{"synchronous_mode": true}`, enabled: true},
+		{raw: `{"synchronous_mode": false}`, enabled: false},
+		{raw: `{"synchronous_mode": "on"}`, enabled: true},
+		{raw: `{"synchronous_mode": "off"}`, enabled: false},
+		{raw: `{"synchronous_mode": "quorum"}`, enabled: true},
+	} {
+		cluster.Spec.Patroni.DynamicConfiguration.Raw = []byte(tt.raw)
+		assert.Equal(t, SynchronousModeEnabled(cluster), tt.enabled, "raw: %s", tt.raw)
+	}
+
+	// The spec takes precedence over the dynamic configuration.
+	cluster.Spec.Patroni.DynamicConfiguration.Raw = []byte(`{"synchronous_mode": true}`)
+	cluster.Spec.Patroni.Synchronous = &v1beta1.PatroniSynchronous{Mode: "off"}
+	assert.Assert(t, !SynchronousModeEnabled(cluster))
+
+	cluster.Spec.Patroni.DynamicConfiguration.Raw = nil
+	cluster.Spec.Patroni.Synchronous.Mode = "strict"
+	assert.Assert(t, SynchronousModeEnabled(cluster))
+}
+
 func TestInstanceConfigFiles(t *testing.T) {
 	t.Parallel()
 
@@ -664,7 +869,7 @@ func TestInstanceYAML(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{Spec: v1beta1.PostgresClusterSpec{PostgresVersion: 12}}
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 
-	data, err := instanceYAML(cluster, instance, nil)
+	data, err := instanceYAML(cluster, instance, postgres.Parameters{}, nil)
 	assert.NilError(t, err)
 	assert.Equal(t, data, strings.Trim(`
 # Generated by postgres-operator. DO NOT EDIT.
@@ -687,7 +892,7 @@ restapi: {}
 tags: {}
 	`, "\t\n")+"\n")
 
-	dataWithReplicaCreate, err := instanceYAML(cluster, instance, []string{"some", "backrest", "cmd"})
+	dataWithReplicaCreate, err := instanceYAML(cluster, instance, postgres.Parameters{}, []string{"some", "backrest", "cmd"})
 	assert.NilError(t, err)
 	assert.Equal(t, dataWithReplicaCreate, strings.Trim(`
 # Generated by postgres-operator. DO NOT EDIT.
@@ -716,6 +921,89 @@ postgresql:
 restapi: {}
 tags: {}
 	`, "\t\n")+"\n")
+
+	// A major upgrade leaves an existing data directory for bootstrap.
+	upgraded := cluster.DeepCopy()
+	upgraded.Spec.PostgresVersion = 13
+	upgraded.Status.Upgrade = &v1beta1.PGUpgradeStatus{
+		FromPostgresVersion: 12, ToPostgresVersion: 13,
+	}
+
+	dataUpgraded, err := instanceYAML(upgraded, instance, postgres.Parameters{}, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(dataUpgraded, `
+bootstrap:
+  existing:
+    command: mv "/pgdata/pg13_bootstrap" "/pgdata/pg13"
+    no_params: "true"
+  method: existing
+`), "got:\n%s", dataUpgraded)
+
+	// A finished upgrade does not affect later bootstraps.
+	upgraded.Status.Upgrade.Finished = true
+
+	dataUpgraded, err = instanceYAML(upgraded, instance, postgres.Parameters{}, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(dataUpgraded, `
+  method: initdb
+`), "got:\n%s", dataUpgraded)
+}
+
+func TestInstanceYAMLParameters(t *testing.T) {
+	t.Parallel()
+
+	cluster := &v1beta1.PostgresCluster{Spec: v1beta1.PostgresClusterSpec{PostgresVersion: 12}}
+	instance := new(v1beta1.PostgresInstanceSetSpec)
+	instance.Config = &v1beta1.PostgresInstanceConfiguration{
+		Parameters: map[string]string{
+			"work_mem":  "64MB",
+			"wal_level": "minimal",
+		},
+	}
+
+	parameters := postgres.NewParameters()
+	data, err := instanceYAML(cluster, instance, parameters, nil)
+	assert.NilError(t, err)
+
+	// Mandatory parameters cannot be changed.
+	assert.Assert(t, strings.Contains(data, `
+  parameters:
+    work_mem: 64MB
+`), "got:\n%s", data)
+	assert.Assert(t, !strings.Contains(data, "wal_level"), "got:\n%s", data)
+}
+
+func TestInstanceTags(t *testing.T) {
+	t.Parallel()
+
+	instance := new(v1beta1.PostgresInstanceSetSpec)
+	assert.DeepEqual(t, instanceTags(instance), map[string]interface{}{})
+
+	instance.Patroni = &v1beta1.PatroniInstanceSetSpec{
+		FailoverRole: v1beta1.PatroniFailoverRoleCandidate,
+		CloneFrom:    true,
+	}
+	assert.DeepEqual(t, instanceTags(instance), map[string]interface{}{
+		"clonefrom": true,
+	})
+
+	instance.Patroni = &v1beta1.PatroniInstanceSetSpec{
+		FailoverRole:  v1beta1.PatroniFailoverRoleReplica,
+		NoLoadBalance: true,
+	}
+	assert.DeepEqual(t, instanceTags(instance), map[string]interface{}{
+		"nofailover": true, "noloadbalance": true, "nosync": true,
+	})
+
+	cluster := &v1beta1.PostgresCluster{Spec: v1beta1.PostgresClusterSpec{PostgresVersion: 12}}
+	data, err := instanceYAML(cluster, instance, postgres.Parameters{}, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(data, `
+tags:
+  nofailover: true
+  noloadbalance: true
+  nosync: true
+`), "got:\n%s", data)
 }
 
 func TestPGBackRestCreateReplicaCommand(t *testing.T) {
@@ -733,7 +1021,7 @@ func TestPGBackRestCreateReplicaCommand(t *testing.T) {
 	cluster := new(v1beta1.PostgresCluster)
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 
-	data, err := instanceYAML(cluster, instance, []string{"some", "backrest", "cmd"})
+	data, err := instanceYAML(cluster, instance, postgres.Parameters{}, []string{"some", "backrest", "cmd"})
 	assert.NilError(t, err)
 
 	var parsed struct {
@@ -867,3 +1155,25 @@ func TestProbeTiming(t *testing.T) {
 		assert.Assert(t, actual.FailureThreshold >= 1) // Minimum value is 1.
 	}
 }
+
+func TestQuorumCommitSupported(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	assert.Assert(t, !QuorumCommitSupported(cluster))
+
+	cluster.Status.Patroni = &v1beta1.PatroniStatus{}
+	assert.Assert(t, !QuorumCommitSupported(cluster))
+
+	for _, tt := range []struct {
+		version   string
+		supported bool
+	}{
+		{version: "2.1.4", supported: false},
+		{version: "3.3.2", supported: false},
+		{version: "4.0.0", supported: true},
+		{version: "4.0.2", supported: true},
+		{version: "10.1", supported: true},
+	} {
+		cluster.Status.Patroni.Version = tt.version
+		assert.Equal(t, QuorumCommitSupported(cluster), tt.supported, "version: %s", tt.version)
+	}
+}
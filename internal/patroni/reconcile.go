@@ -17,7 +17,9 @@ package patroni
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
@@ -61,6 +63,7 @@ func ClusterConfigMap(ctx context.Context,
 func InstanceConfigMap(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
 	inInstanceSpec *v1beta1.PostgresInstanceSetSpec,
+	inParameters postgres.Parameters,
 	outInstanceConfigMap *v1.ConfigMap,
 ) error {
 	var err error
@@ -70,7 +73,7 @@ func InstanceConfigMap(ctx context.Context,
 	command := pgbackrest.ReplicaCreateCommand(inCluster, inInstanceSpec)
 
 	outInstanceConfigMap.Data[configMapFileKey], err = instanceYAML(
-		inCluster, inInstanceSpec, command)
+		inCluster, inInstanceSpec, inParameters, command)
 
 	return err
 }
@@ -259,3 +262,106 @@ func PodIsStandbyLeader(pod metav1.Object) bool {
 	status := pod.GetAnnotations()["status"]
 	return strings.Contains(status, `"role":"standby_leader"`)
 }
+
+// PodWALPosition returns the write-ahead log location, in bytes, that Patroni last reported
+// for pod. For a leader this is the location being written; for a replica it is the location
+// received or replayed. The bool is false when pod has not reported a location.
+func PodWALPosition(pod metav1.Object) (int64, bool) {
+	if pod == nil {
+		return 0, false
+	}
+
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/ha.py
+	var status struct {
+		Location *int64 `json:"xlog_location"`
+	}
+	if err := json.Unmarshal([]byte(pod.GetAnnotations()["status"]), &status); err != nil ||
+		status.Location == nil {
+		return 0, false
+	}
+	return *status.Location, true
+}
+
+// PodTimeline returns the PostgreSQL timeline that Patroni last reported for pod. The bool
+// is false when pod has not reported a timeline.
+func PodTimeline(pod metav1.Object) (int64, bool) {
+	if pod == nil {
+		return 0, false
+	}
+
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/ha.py
+	var status struct {
+		Timeline *int64 `json:"timeline"`
+	}
+	if err := json.Unmarshal([]byte(pod.GetAnnotations()["status"]), &status); err != nil ||
+		status.Timeline == nil {
+		return 0, false
+	}
+	return *status.Timeline, true
+}
+
+// PodRequiresRestart returns whether or not Patroni last reported that PostgreSQL in pod must
+// restart to apply changes to its parameters. The second bool is false when pod has not
+// reported its status.
+func PodRequiresRestart(pod metav1.Object) (bool, bool) {
+	if pod == nil {
+		return false, false
+	}
+
+	// Patroni omits this field when it is false.
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/ha.py#L190
+	var status struct {
+		PendingRestart bool `json:"pending_restart"`
+	}
+	if err := json.Unmarshal([]byte(pod.GetAnnotations()["status"]), &status); err != nil {
+		return false, false
+	}
+	return status.PendingRestart, true
+}
+
+// PodVersion returns the version of Patroni that last reported the status of pod. The bool is
+// false when pod has not reported a version.
+func PodVersion(pod metav1.Object) (string, bool) {
+	if pod == nil {
+		return "", false
+	}
+
+	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/ha.py#L190
+	var status struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(pod.GetAnnotations()["status"]), &status); err != nil ||
+		status.Version == "" {
+		return "", false
+	}
+	return status.Version, true
+}
+
+// CompareVersions compares two Patroni versions, such as "2.1.4", part by part. It returns a
+// negative number when a is older than b, a positive number when a is newer than b, and zero
+// when they are the same. Parts that are not numbers compare as zero.
+func CompareVersions(a, b string) int {
+	number := func(part string) int {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		n, _ := strconv.Atoi(part[:end])
+		return n
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an = number(as[i])
+		}
+		if i < len(bs) {
+			bn = number(bs[i])
+		}
+		if an != bn {
+			return an - bn
+		}
+	}
+	return 0
+}
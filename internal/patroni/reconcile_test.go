@@ -87,15 +87,15 @@ func TestInstanceConfigMap(t *testing.T) {
 	cluster := new(v1beta1.PostgresCluster)
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 	config := new(v1.ConfigMap)
-	data, _ := instanceYAML(cluster, instance, nil)
+	data, _ := instanceYAML(cluster, instance, postgres.Parameters{}, nil)
 
-	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, config))
+	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, postgres.Parameters{}, config))
 
 	assert.DeepEqual(t, config.Data["patroni.yaml"], data)
 
 	// No change when called again.
 	before := config.DeepCopy()
-	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, config))
+	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, postgres.Parameters{}, config))
 	assert.DeepEqual(t, config, before)
 }
 
@@ -412,3 +412,111 @@ func TestPodIsStandbyLeader(t *testing.T) {
 	pod.Annotations["status"] = `{"role":"standby_leader"}`
 	assert.Assert(t, PodIsStandbyLeader(pod))
 }
+
+func TestPodWALPosition(t *testing.T) {
+	// No object
+	_, known := PodWALPosition(nil)
+	assert.Assert(t, !known)
+
+	// No annotations
+	pod := &v1.Pod{}
+	_, known = PodWALPosition(pod)
+	assert.Assert(t, !known)
+
+	// No location
+	pod.Annotations = map[string]string{"status": `{"role":"replica"}`}
+	_, known = PodWALPosition(pod)
+	assert.Assert(t, !known)
+
+	// Malformed
+	pod.Annotations["status"] = `{"xlog_location":"asdf"}`
+	_, known = PodWALPosition(pod)
+	assert.Assert(t, !known)
+
+	pod.Annotations["status"] = `{"role":"replica","state":"running","xlog_location":50331744}`
+	position, known := PodWALPosition(pod)
+	assert.Assert(t, known)
+	assert.Equal(t, position, int64(50331744))
+}
+
+func TestPodTimeline(t *testing.T) {
+	_, known := PodTimeline(nil)
+	assert.Assert(t, !known)
+
+	pod := &v1.Pod{}
+	_, known = PodTimeline(pod)
+	assert.Assert(t, !known)
+
+	pod.Annotations = map[string]string{"status": `{"role":"replica","state":"starting"}`}
+	_, known = PodTimeline(pod)
+	assert.Assert(t, !known)
+
+	pod.Annotations["status"] = `{"role":"master","state":"running","timeline":3}`
+	timeline, known := PodTimeline(pod)
+	assert.Assert(t, known)
+	assert.Equal(t, timeline, int64(3))
+}
+
+func TestPodVersion(t *testing.T) {
+	_, known := PodVersion(nil)
+	assert.Assert(t, !known)
+
+	pod := &v1.Pod{}
+	_, known = PodVersion(pod)
+	assert.Assert(t, !known)
+
+	pod.Annotations = map[string]string{"status": `{"role":"replica","state":"starting"}`}
+	_, known = PodVersion(pod)
+	assert.Assert(t, !known)
+
+	pod.Annotations["status"] = `{"role":"master","state":"running","version":"2.1.4"}`
+	version, known := PodVersion(pod)
+	assert.Assert(t, known)
+	assert.Equal(t, version, "2.1.4")
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b   string
+		result int
+	}{
+		{a: "2.1.4", b: "2.1.4", result: 0},
+		{a: "2.1", b: "2.1.0", result: 0},
+		{a: "2.1.4", b: "4.0.0", result: -1},
+		{a: "4.0.0", b: "3.3.2", result: 1},
+		{a: "10.0", b: "9.9.9", result: 1},
+		{a: "4.0.0rc1", b: "4.0.0", result: 0},
+	} {
+		result := CompareVersions(tt.a, tt.b)
+		switch {
+		case tt.result < 0:
+			assert.Assert(t, result < 0, "%s < %s", tt.a, tt.b)
+		case tt.result > 0:
+			assert.Assert(t, result > 0, "%s > %s", tt.a, tt.b)
+		default:
+			assert.Equal(t, result, 0, "%s = %s", tt.a, tt.b)
+		}
+	}
+}
+
+func TestPodRequiresRestart(t *testing.T) {
+	// No object
+	_, known := PodRequiresRestart(nil)
+	assert.Assert(t, !known)
+
+	// No annotations
+	pod := &v1.Pod{}
+	_, known = PodRequiresRestart(pod)
+	assert.Assert(t, !known)
+
+	// Not pending
+	pod.Annotations = map[string]string{"status": `{"role":"replica","state":"running"}`}
+	pending, known := PodRequiresRestart(pod)
+	assert.Assert(t, known)
+	assert.Assert(t, !pending)
+
+	pod.Annotations["status"] = `{"role":"replica","state":"running","pending_restart":true}`
+	pending, known = PodRequiresRestart(pod)
+	assert.Assert(t, known)
+	assert.Assert(t, pending)
+}
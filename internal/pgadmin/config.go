@@ -0,0 +1,120 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgadmin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// pgAdmin reads this file, when it exists, after its own configuration.
+	// - https://www.pgadmin.org/docs/pgadmin4/latest/config_py.html
+	configDirectory        = "/etc/pgadmin"
+	settingsProjectionPath = "config_system.py"
+	settingsConfigMapKey   = "pgadmin-settings.py"
+
+	// dataDirectory is where pgAdmin keeps its logins, server definitions,
+	// sessions, and logs. The data volume is mounted here.
+	dataDirectory = "/var/lib/pgadmin"
+
+	// installDirectory is where the pgAdmin image installs pgAdmin.
+	installDirectory = "/usr/lib/python3.6/site-packages/pgadmin4-web"
+
+	// loginEmailDomain is appended to PostgreSQL user names to form pgAdmin logins.
+	loginEmailDomain = "pgo"
+
+	// serverGroupName is the pgAdmin server group that holds the server
+	// definitions written by the operator.
+	serverGroupName = "Crunchy PostgreSQL Operator"
+)
+
+const (
+	settingsGeneratedWarning = "" +
+		"# Generated by postgres-operator. DO NOT EDIT.\n" +
+		"# Your changes will not be saved.\n"
+)
+
+// loginEmail returns the pgAdmin login for the PostgreSQL user username.
+// pgAdmin identifies its users by email address.
+func loginEmail(username string) string {
+	return username + "@" + loginEmailDomain
+}
+
+// systemSettings returns the contents of a pgAdmin "config_system.py" file
+// that runs pgAdmin as a multi-user web application and keeps all its state
+// on the data volume.
+func systemSettings(cluster *v1beta1.PostgresCluster) string {
+	settings := map[string]string{
+		// Listen on all interfaces so that the Service can reach pgAdmin.
+		"DEFAULT_SERVER":      `'0.0.0.0'`,
+		"DEFAULT_SERVER_PORT": fmt.Sprint(*cluster.Spec.UserInterface.PGAdmin.Port),
+
+		// Require a login for every browser session.
+		"SERVER_MODE": `True`,
+
+		// Server passwords are stored using the login password of each user.
+		// There is no other password to prompt for.
+		"MASTER_PASSWORD_REQUIRED": `False`,
+
+		// pgAdmin cannot reach the internet to check for updates.
+		"UPGRADE_CHECK_ENABLED": `False`,
+
+		"DATA_DIR":        `'` + dataDirectory + `'`,
+		"LOG_FILE":        `'` + dataDirectory + `/logs/pgadmin.log'`,
+		"SESSION_DB_PATH": `'` + dataDirectory + `/sessions'`,
+		"SQLITE_PATH":     `'` + dataDirectory + `/pgadmin.db'`,
+		"STORAGE_DIR":     `'` + dataDirectory + `/storage'`,
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	_, _ = b.WriteString(settingsGeneratedWarning)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(&b, "%s = %s\n", k, settings[k])
+	}
+	return b.String()
+}
+
+// startupCommand returns an entrypoint that prepares the data volume, creates
+// or upgrades the pgAdmin database, and then runs pgAdmin.
+func startupCommand() []string {
+	script := strings.Join([]string{
+		`declare -r install_directory="$1" data_directory="$2"`,
+		`mkdir -p "${data_directory}/logs" "${data_directory}/sessions" "${data_directory}/storage"`,
+
+		// "setup.py" prompts for an initial administrator when the database
+		// does not exist yet. That login is never used, so give it a password
+		// nobody knows. Logins for PostgreSQL users are written by the operator.
+		// - https://www.pgadmin.org/docs/pgadmin4/latest/server_deployment.html
+		`export PGADMIN_SETUP_EMAIL='admin@` + loginEmailDomain + `'`,
+		`export PGADMIN_SETUP_PASSWORD="$(head -c 24 /dev/urandom | base64)"`,
+
+		`cd "${install_directory}"`,
+		`python3 setup.py`,
+		`exec python3 pgAdmin4.py`,
+	}, "\n")
+
+	return []string{"bash", "-ceu", "--", script, "startup", installDirectory, dataDirectory}
+}
@@ -0,0 +1,116 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgadmin
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// ConfigMap populates the pgAdmin ConfigMap.
+func ConfigMap(
+	inCluster *v1beta1.PostgresCluster,
+	outConfigMap *corev1.ConfigMap,
+) {
+	if inCluster.Spec.UserInterface == nil || inCluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; there is nothing to do.
+		return
+	}
+
+	initialize.StringMap(&outConfigMap.Data)
+
+	outConfigMap.Data[settingsConfigMapKey] = systemSettings(inCluster)
+}
+
+// Pod populates a PodSpec with the container and volumes needed to run pgAdmin.
+func Pod(
+	inCluster *v1beta1.PostgresCluster,
+	inConfigMap *corev1.ConfigMap,
+	inDataVolume *corev1.PersistentVolumeClaim,
+	outPod *corev1.PodSpec,
+) {
+	if inCluster.Spec.UserInterface == nil || inCluster.Spec.UserInterface.PGAdmin == nil {
+		// pgAdmin is disabled; there is nothing to do.
+		return
+	}
+
+	configVol := corev1.Volume{Name: "pgadmin-config"}
+	configVol.Projected = &corev1.ProjectedVolumeSource{
+		Sources: []corev1.VolumeProjection{{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: inConfigMap.Name,
+				},
+				Items: []corev1.KeyToPath{{
+					Key:  settingsConfigMapKey,
+					Path: settingsProjectionPath,
+				}},
+			},
+		}},
+	}
+
+	dataVol := corev1.Volume{Name: "pgadmin-data"}
+	dataVol.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+		ClaimName: inDataVolume.Name,
+	}
+
+	container := corev1.Container{
+		Name: naming.ContainerPGAdmin,
+
+		Command:   startupCommand(),
+		Image:     config.PGAdminContainerImage(inCluster),
+		Resources: inCluster.Spec.UserInterface.PGAdmin.Resources,
+
+		SecurityContext: initialize.RestrictedSecurityContext(),
+
+		Ports: []corev1.ContainerPort{{
+			Name:          naming.PortPGAdmin,
+			ContainerPort: *inCluster.Spec.UserInterface.PGAdmin.Port,
+			Protocol:      corev1.ProtocolTCP,
+		}},
+	}
+
+	container.VolumeMounts = []corev1.VolumeMount{
+		{
+			Name:      configVol.Name,
+			MountPath: configDirectory,
+			ReadOnly:  true,
+		},
+		{
+			Name:      dataVol.Name,
+			MountPath: dataDirectory,
+		},
+	}
+
+	// pgAdmin answers this endpoint without a login once it is serving requests.
+	container.ReadinessProbe = &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/misc/ping",
+				Port: intstr.FromString(naming.PortPGAdmin),
+			},
+		},
+	}
+
+	outPod.Containers = []corev1.Container{container}
+
+	outPod.Volumes = []corev1.Volume{configVol, dataVol}
+}
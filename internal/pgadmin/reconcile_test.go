@@ -0,0 +1,158 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgadmin
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestConfigMap(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	config := new(corev1.ConfigMap)
+
+	t.Run("Disabled", func(t *testing.T) {
+		before := config.DeepCopy()
+		ConfigMap(cluster, config)
+
+		// No change when pgAdmin is not requested in the spec.
+		assert.DeepEqual(t, before, config)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		cluster.Spec.UserInterface = new(v1beta1.PostgresUserInterfaceSpec)
+		cluster.Spec.UserInterface.PGAdmin = new(v1beta1.PGAdminPodSpec)
+		cluster.Default()
+
+		ConfigMap(cluster, config)
+
+		assert.Assert(t, marshalEquals(config.Data, strings.Trim(`
+pgadmin-settings.py: |
+  # Generated by postgres-operator. DO NOT EDIT.
+  # Your changes will not be saved.
+  DATA_DIR = '/var/lib/pgadmin'
+  DEFAULT_SERVER = '0.0.0.0'
+  DEFAULT_SERVER_PORT = 5050
+  LOG_FILE = '/var/lib/pgadmin/logs/pgadmin.log'
+  MASTER_PASSWORD_REQUIRED = False
+  SERVER_MODE = True
+  SESSION_DB_PATH = '/var/lib/pgadmin/sessions'
+  SQLITE_PATH = '/var/lib/pgadmin/pgadmin.db'
+  STORAGE_DIR = '/var/lib/pgadmin/storage'
+  UPGRADE_CHECK_ENABLED = False
+		`, "\t\n")+"\n"))
+	})
+}
+
+func TestPod(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	config := new(corev1.ConfigMap)
+	config.Name = "some-cm"
+	volume := new(corev1.PersistentVolumeClaim)
+	volume.Name = "some-pvc"
+	pod := new(corev1.PodSpec)
+
+	call := func() { Pod(cluster, config, volume, pod) }
+
+	t.Run("Disabled", func(t *testing.T) {
+		before := pod.DeepCopy()
+		call()
+
+		// No change when pgAdmin is not requested in the spec.
+		assert.DeepEqual(t, before, pod)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		cluster.Spec.UserInterface = new(v1beta1.PostgresUserInterfaceSpec)
+		cluster.Spec.UserInterface.PGAdmin = new(v1beta1.PGAdminPodSpec)
+		cluster.Default()
+
+		call()
+
+		assert.Assert(t, marshalEquals(pod, strings.Trim(`
+containers:
+- command:
+  - bash
+  - -ceu
+  - --
+  - |-
+    declare -r install_directory="$1" data_directory="$2"
+    mkdir -p "${data_directory}/logs" "${data_directory}/sessions" "${data_directory}/storage"
+    export PGADMIN_SETUP_EMAIL='admin@pgo'
+    export PGADMIN_SETUP_PASSWORD="$(head -c 24 /dev/urandom | base64)"
+    cd "${install_directory}"
+    python3 setup.py
+    exec python3 pgAdmin4.py
+  - startup
+  - /usr/lib/python3.6/site-packages/pgadmin4-web
+  - /var/lib/pgadmin
+  name: pgadmin
+  ports:
+  - containerPort: 5050
+    name: pgadmin
+    protocol: TCP
+  readinessProbe:
+    httpGet:
+      path: /misc/ping
+      port: pgadmin
+  resources: {}
+  securityContext:
+    allowPrivilegeEscalation: false
+    privileged: false
+    readOnlyRootFilesystem: true
+    runAsNonRoot: true
+  volumeMounts:
+  - mountPath: /etc/pgadmin
+    name: pgadmin-config
+    readOnly: true
+  - mountPath: /var/lib/pgadmin
+    name: pgadmin-data
+volumes:
+- name: pgadmin-config
+  projected:
+    sources:
+    - configMap:
+        items:
+        - key: pgadmin-settings.py
+          path: config_system.py
+        name: some-cm
+- name: pgadmin-data
+  persistentVolumeClaim:
+    claimName: some-pvc
+		`, "\t\n")+"\n"))
+	})
+
+	t.Run("Customizations", func(t *testing.T) {
+		cluster.Spec.UserInterface.PGAdmin.Image = "custom-image"
+		cluster.Spec.UserInterface.PGAdmin.Port = new(int32)
+		*cluster.Spec.UserInterface.PGAdmin.Port = 8080
+
+		call()
+
+		assert.Equal(t, len(pod.Containers), 1)
+		assert.Equal(t, pod.Containers[0].Image, "custom-image")
+		assert.Equal(t, pod.Containers[0].Ports[0].ContainerPort, int32(8080))
+	})
+}
@@ -0,0 +1,163 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// Executor provides methods for calling "python3" in the pgAdmin container.
+type Executor func(
+	ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error
+
+// serverDefinitions returns the PostgreSQL servers that every pgAdmin login
+// should have: the primary Service and, when enabled, the PgBouncer Service.
+func serverDefinitions(cluster *v1beta1.PostgresCluster) []map[string]interface{} {
+	primary := naming.ClusterPrimaryService(cluster)
+	servers := []map[string]interface{}{{
+		"name": primary.Name,
+		"host": primary.Name + "." + primary.Namespace + ".svc",
+		"port": *cluster.Spec.Port,
+	}}
+
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		pgBouncer := naming.ClusterPGBouncer(cluster)
+		servers = append(servers, map[string]interface{}{
+			"name": pgBouncer.Name,
+			"host": pgBouncer.Name + "." + pgBouncer.Namespace + ".svc",
+			"port": *cluster.Spec.Proxy.PGBouncer.Port,
+		})
+	}
+
+	return servers
+}
+
+// WriteUsersInPGAdmin calls exec to create a pgAdmin login for each PostgreSQL
+// user that does not have one. Once they exist, it updates their passwords and
+// the server definitions that connect to cluster as that user. Logins are not
+// removed when a user is removed from users.
+func WriteUsersInPGAdmin(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, exec Executor,
+	users []v1beta1.PostgresUserSpec, passwords map[string]string,
+) error {
+	log := logging.FromContext(ctx)
+
+	// The script reads one JSON object per line from stdin and is the same for
+	// every cluster. Server definitions are passed as an argument.
+	// - https://www.pgadmin.org/docs/pgadmin4/latest/import_export_servers.html
+	script := strings.Join([]string{
+		`import json, os, sys`,
+		`os.chdir(sys.argv[1])`,
+		`sys.path.insert(0, sys.argv[1])`,
+
+		`from pgadmin import create_app`,
+		`from pgadmin.model import db, Role, Server, ServerGroup, User`,
+		`from pgadmin.utils.crypto import encrypt`,
+		`try:`,
+		`    from flask_security.utils import hash_password`,
+		`except ImportError:`,
+		`    from flask_security.utils import encrypt_password as hash_password`,
+
+		`group_name, servers = sys.argv[2], json.loads(sys.argv[3])`,
+		`server_names = [s['name'] for s in servers]`,
+
+		`with create_app().app_context():`,
+		`    role = Role.query.filter_by(name='User').first()`,
+		`    for line in sys.stdin:`,
+		`        if not line.strip(): continue`,
+		`        data = json.loads(line)`,
+
+		// Create or update the login.
+		`        user = User.query.filter_by(email=data['email']).first()`,
+		`        if user is None:`,
+		`            user = User(email=data['email'], active=True)`,
+		`            user.roles.append(role)`,
+		`            db.session.add(user)`,
+		`        user.password = hash_password(data['password'])`,
+		`        db.session.commit()`,
+
+		// Keep the server definitions of the operator in their own group.
+		`        group = ServerGroup.query.filter_by(user_id=user.id, name=group_name).first()`,
+		`        if group is None:`,
+		`            group = ServerGroup(user_id=user.id, name=group_name)`,
+		`            db.session.add(group)`,
+		`            db.session.commit()`,
+		`        for server in Server.query.filter_by(user_id=user.id, servergroup_id=group.id):`,
+		`            if server.name not in server_names: db.session.delete(server)`,
+
+		// Server passwords are encrypted using the login password of the user.
+		`        for definition in servers:`,
+		`            server = Server.query.filter_by(user_id=user.id, servergroup_id=group.id, name=definition['name']).first()`,
+		`            if server is None:`,
+		`                server = Server(user_id=user.id, servergroup_id=group.id, name=definition['name'])`,
+		`                db.session.add(server)`,
+		`            server.host, server.port = definition['host'], definition['port']`,
+		`            server.maintenance_db, server.username = data['database'], data['username']`,
+		`            server.ssl_mode, server.save_password = 'require', 1`,
+		`            server.password = encrypt(data['password'], user.password)`,
+		`        db.session.commit()`,
+		`        print('wrote', user.email)`,
+	}, "\n")
+
+	var err error
+	var stdin bytes.Buffer
+	encoder := json.NewEncoder(&stdin)
+	encoder.SetEscapeHTML(false)
+
+	for i := range users {
+		spec := users[i]
+
+		// Connect to the first database of the user or to the "postgres"
+		// database, the same as the user Secret.
+		database := "postgres"
+		if spec.Name != "postgres" && len(spec.Databases) > 0 {
+			database = string(spec.Databases[0])
+		}
+
+		if err == nil {
+			err = encoder.Encode(map[string]interface{}{
+				"database": database,
+				"email":    loginEmail(string(spec.Name)),
+				"password": passwords[string(spec.Name)],
+				"username": spec.Name,
+			})
+		}
+	}
+
+	var servers []byte
+	if err == nil {
+		servers, err = json.Marshal(serverDefinitions(cluster))
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err == nil {
+		err = exec(ctx, &stdin, &stdout, &stderr,
+			"python3", "-c", script, installDirectory, serverGroupName, string(servers))
+	}
+
+	log.V(1).Info("wrote pgAdmin users", "stdout", stdout.String(), "stderr", stderr.String())
+
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgadmin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestWriteUsersInPGAdmin(t *testing.T) {
+	ctx := context.Background()
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "hippo"
+	cluster.Spec.Port = new(int32)
+	*cluster.Spec.Port = 5432
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+
+			assert.Equal(t, len(command), 6)
+			assert.DeepEqual(t, command[:2], []string{"python3", "-c"})
+			assert.DeepEqual(t, command[3:], []string{
+				"/usr/lib/python3.6/site-packages/pgadmin4-web",
+				"Crunchy PostgreSQL Operator",
+				`[{"host":"hippo-primary.ns1.svc","name":"hippo-primary","port":5432}]`,
+			})
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteUsersInPGAdmin(ctx, cluster, exec, nil, nil))
+	})
+
+	t.Run("PGBouncer", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+			PGBouncer: &v1beta1.PGBouncerPodSpec{Port: new(int32)},
+		}
+		*cluster.Spec.Proxy.PGBouncer.Port = 6432
+
+		calls := 0
+		exec := func(
+			_ context.Context, _ io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			assert.Equal(t, command[5], ``+
+				`[{"host":"hippo-primary.ns1.svc","name":"hippo-primary","port":5432},`+
+				`{"host":"hippo-pgbouncer.ns1.svc","name":"hippo-pgbouncer","port":6432}]`)
+			return nil
+		}
+
+		assert.NilError(t, WriteUsersInPGAdmin(ctx, cluster, exec, nil, nil))
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := ioutil.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimLeft(`
+{"database":"db1","email":"user-one@pgo","password":"pass1","username":"user-one"}
+{"database":"postgres","email":"user-two@pgo","password":"","username":"user-two"}
+{"database":"postgres","email":"postgres@pgo","password":"pass3","username":"postgres"}
+`, "\n"))
+			return nil
+		}
+
+		assert.NilError(t, WriteUsersInPGAdmin(ctx, cluster, exec,
+			[]v1beta1.PostgresUserSpec{
+				{Name: "user-one", Databases: []v1beta1.PostgresIdentifier{"db1", "db2"}},
+				{Name: "user-two"},
+				{Name: "postgres", Databases: []v1beta1.PostgresIdentifier{"db3"}},
+			},
+			map[string]string{"user-one": "pass1", "postgres": "pass3"},
+		))
+		assert.Equal(t, calls, 1)
+	})
+}
@@ -45,6 +45,12 @@ const (
 	cmPrimaryKey = "pgbackrest_primary.conf"
 	// CMRepoKey is the name of the configuration file for a pgBackRest dedicated repository host
 	CMRepoKey = "pgbackrest_repo.conf"
+	// CipherConfigKey is the name of the configuration file for encrypted repositories. It is
+	// stored in a Secret rather than the ConfigMap.
+	CipherConfigKey = "pgbackrest_cipher.conf"
+	// CipherHashKey is the name of the file storing the config hash that the cipher options
+	// were written for. It is stored in the same Secret as the cipher options.
+	CipherHashKey = "cipher-hash"
 
 	// ConfigDir is the pgBackRest configuration directory
 	ConfigDir = "/etc/pgbackrest/conf.d"
@@ -214,6 +220,70 @@ mv "${pgdata}" "${pgdata}_bootstrap"`
 	return append([]string{"bash", "-ceu", "--", restoreScript, "-", pgdata}, args...)
 }
 
+// SnapshotRestoreCommand returns the command for recovering a data directory that was cloned
+// from a VolumeSnapshot. Rather than restoring files, WAL is fetched from a pgBackRest repository
+// using any pgBackRest options provided. The script otherwise matches RestoreCommand:
+// - Writes the backup label that PostgreSQL produced when the snapshot was taken, if any, so
+//   that recovery begins where the backup started.
+// - Starts the database and allows recovery to complete.  A temporary postgresql.conf file
+//   with the minimum settings needed to safely start the database is created and utilized.
+// - Renames the data directory as needed to bootstrap the cluster using the restored database.
+func SnapshotRestoreCommand(pgdata string, version int, backupLabel string, args ...string) []string {
+
+	const restoreScript = `declare -r pgdata="$1" version="$2" label="$3" opts="$4"
+rm -f "${pgdata}/patroni.dynamic.json" "${pgdata}/postmaster.pid" \
+  "${pgdata}/standby.signal" "${pgdata}/recovery.signal" "${pgdata}/recovery.conf"
+if [[ -L "${pgdata}/pg_wal" && ! -d "${pgdata}/pg_wal/" ]]; then
+  rm "${pgdata}/pg_wal" && install --directory --mode=0700 "${pgdata}/pg_wal"
+fi
+if [[ -n "${label}" ]]; then printf '%s' "${label}" > "${pgdata}/backup_label"; fi
+echo "unix_socket_directories = '/tmp'" > /tmp/postgres.restore.conf
+echo "archive_command = 'false'" >> /tmp/postgres.restore.conf
+echo "archive_mode = 'on'" >> /tmp/postgres.restore.conf
+restore_command="restore_command = 'pgbackrest ${opts} archive-get %f \"%p\"'"
+if [[ "${version}" -ge 12 ]]; then
+  echo "${restore_command}" >> /tmp/postgres.restore.conf
+  touch "${pgdata}/recovery.signal"
+else
+  echo "${restore_command}" > "${pgdata}/recovery.conf"
+fi
+pg_ctl start -D "${pgdata}" -o "--config-file=/tmp/postgres.restore.conf"
+until [[ $(psql -At -c "SELECT pg_catalog.pg_is_in_recovery()") == "f" ]]; do sleep 1; done
+pg_ctl stop -D "${pgdata}"
+mv "${pgdata}" "${pgdata}_bootstrap"`
+
+	return append([]string{"bash", "-ceu", "--", restoreScript, "-",
+		pgdata, fmt.Sprint(version), backupLabel}, args...)
+}
+
+// RestoreTestCommand returns the command for testing a pgBackRest backup. The backup is
+// restored into pgdata using any pgBackRest options provided, and then:
+// - The database is started using a temporary postgresql.conf file like RestoreCommand.
+// - Once recovery completes, sql is run. The command fails when sql returns an error or false.
+// - The database is stopped. The data directory is left in place so the next test can be a
+//   delta restore.
+func RestoreTestCommand(pgdata, sql string, args ...string) []string {
+
+	const restoreScript = `declare -r pgdata="$1" sql="$2" opts="$3"
+install --directory --mode=0700 "${pgdata}"
+rm -f "${pgdata}/postmaster.pid"
+eval "pgbackrest restore ${opts}"
+rm -f "${pgdata}/patroni.dynamic.json"
+echo "unix_socket_directories = '/tmp'" > /tmp/postgres.restore.conf
+echo "archive_mode = 'off'" >> /tmp/postgres.restore.conf
+pg_ctl start -D "${pgdata}" -o "--config-file=/tmp/postgres.restore.conf"
+until [[ $(psql -At -c "SELECT pg_catalog.pg_is_in_recovery()") == "f" ]]; do sleep 1; done
+result=''
+if [[ -n "${sql}" ]]; then
+  result=$(psql -At --set=ON_ERROR_STOP=1 --command="${sql}") || result='error'
+fi
+pg_ctl stop -D "${pgdata}" --mode=fast
+printf 'SQL check result: %s\n' "${result}"
+[[ "${result}" != 'f' && "${result}" != 'error' ]]`
+
+	return append([]string{"bash", "-ceu", "--", restoreScript, "-", pgdata, sql}, args...)
+}
+
 // populatePGInstanceConfigurationMap returns a map representing the pgBackRest configuration for
 // a PostgreSQL instance
 func populatePGInstanceConfigurationMap(serviceName, serviceNamespace, repoHostName, pgdataDir string,
@@ -261,6 +331,14 @@ func populatePGInstanceConfigurationMap(serviceName, serviceNamespace, repoHostN
 		pgBackRestConfig["global"][option] = val
 	}
 
+	// The retention of a repository takes precedence over the same options in
+	// global. The validating webhook refuses both, but it may not be installed.
+	for _, repo := range repos {
+		for option, val := range getRepoRetentionConfigs(repo) {
+			pgBackRestConfig["global"][option] = val
+		}
+	}
+
 	i := 1
 	// Now add all PG instances to the stanza section. Make sure the local PG host is always
 	// index 1: https://github.com/pgbackrest/pgbackrest/issues/1197#issuecomment-708381800
@@ -323,6 +401,14 @@ func populateRepoHostConfigurationMap(serviceName, serviceNamespace, pgdataDir s
 		pgBackRestConfig["global"][option] = val
 	}
 
+	// The retention of a repository takes precedence over the same options in
+	// global. The validating webhook refuses both, but it may not be installed.
+	for _, repo := range repos {
+		for option, val := range getRepoRetentionConfigs(repo) {
+			pgBackRestConfig["global"][option] = val
+		}
+	}
+
 	// set the configs for all PG hosts
 	for i, pgHost := range pgHosts {
 		pgBackRestConfig["stanza"][fmt.Sprintf("pg%d-host", i+1)] = pgHost + "-0." + serviceName +
@@ -358,6 +444,12 @@ func getConfigString(c map[string]map[string]string) string {
 	return configString
 }
 
+// CipherConfigString returns the pgBackRest configuration file that encrypts repositories
+// using options, the "repoN-cipher-type" and "repoN-cipher-pass" of each repository.
+func CipherConfigString(options map[string]string) string {
+	return getConfigString(map[string]map[string]string{"global": options})
+}
+
 // getExternalRepoConfigs returns a map containing the configuration settings for an external
 // pgBackRest repository as defined in the PostgresCluster spec
 func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
@@ -380,6 +472,35 @@ func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
 	return repoConfigs
 }
 
+// getRepoRetentionConfigs returns a map containing the retention settings for a pgBackRest
+// repository as defined in the PostgresCluster spec
+func getRepoRetentionConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
+
+	repoConfigs := make(map[string]string)
+	retention := repo.Retention
+	if retention == nil {
+		return repoConfigs
+	}
+
+	if retention.Full != nil {
+		repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(*retention.Full)
+	}
+	if retention.FullType != "" {
+		repoConfigs[repo.Name+"-retention-full-type"] = retention.FullType
+	}
+	if retention.Differential != nil {
+		repoConfigs[repo.Name+"-retention-diff"] = fmt.Sprint(*retention.Differential)
+	}
+	if retention.Archive != nil {
+		repoConfigs[repo.Name+"-retention-archive"] = fmt.Sprint(*retention.Archive)
+	}
+	if retention.ArchiveType != "" {
+		repoConfigs[repo.Name+"-retention-archive-type"] = retention.ArchiveType
+	}
+
+	return repoConfigs
+}
+
 // sortedKeys sorts and returns the keys from a given map
 func sortedKeys(m map[string]string) []string {
 	keys := make([]string, 0, len(m))
@@ -329,3 +329,86 @@ func TestRestoreCommand(t *testing.T) {
 	output, err := cmd.CombinedOutput()
 	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
 }
+
+func TestRestoreTestCommand(t *testing.T) {
+	shellcheck, err := exec.LookPath("shellcheck")
+	if err != nil {
+		t.Skip(`requires "shellcheck" executable`)
+	}
+
+	command := RestoreTestCommand("/pgdata/pg13", "SELECT true",
+		"--stanza="+DefaultStanzaName+" --repo=1")
+
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[5:], []string{
+		"/pgdata/pg13", "SELECT true", "--stanza=db --repo=1",
+	})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.bash")
+	assert.NilError(t, ioutil.WriteFile(file, []byte(command[3]), 0o600))
+
+	cmd := exec.Command(shellcheck, "--enable=all", file)
+	output, err := cmd.CombinedOutput()
+	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+}
+
+func TestSnapshotRestoreCommand(t *testing.T) {
+	shellcheck, err := exec.LookPath("shellcheck")
+	if err != nil {
+		t.Skip(`requires "shellcheck" executable`)
+	}
+
+	command := SnapshotRestoreCommand("/pgdata/pg13", 13, "START WAL LOCATION: 0/2000028\n",
+		"--stanza="+DefaultStanzaName+" --repo=1")
+
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[5:], []string{
+		"/pgdata/pg13", "13", "START WAL LOCATION: 0/2000028\n", "--stanza=db --repo=1",
+	})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.bash")
+	assert.NilError(t, ioutil.WriteFile(file, []byte(command[3]), 0o600))
+
+	cmd := exec.Command(shellcheck, "--enable=all", file)
+	output, err := cmd.CombinedOutput()
+	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+}
+
+func TestCipherConfigString(t *testing.T) {
+	assert.Equal(t, CipherConfigString(map[string]string{
+		"repo2-cipher-type": "aes-256-cbc",
+		"repo2-cipher-pass": "secret",
+		"repo1-cipher-type": "aes-256-cbc",
+		"repo1-cipher-pass": "other",
+	}), `[global]
+repo1-cipher-pass=other
+repo1-cipher-type=aes-256-cbc
+repo2-cipher-pass=secret
+repo2-cipher-type=aes-256-cbc
+`)
+}
+
+func TestRetentionConfigPrecedence(t *testing.T) {
+	repos := []v1beta1.PGBackRestRepo{{
+		Name:      "repo1",
+		Volume:    &v1beta1.RepoPVC{},
+		Retention: &v1beta1.PGBackRestRetention{Full: initialize.Int32(5)},
+	}}
+	global := map[string]string{
+		"repo1-retention-full": "1",
+		"repo1-retention-diff": "2",
+	}
+
+	for name, config := range map[string]map[string]map[string]string{
+		"instance": populatePGInstanceConfigurationMap("svc", "ns", "", "/pgdata",
+			5432, nil, repos, global),
+		"repo host": populateRepoHostConfigurationMap("svc", "ns", "/pgdata",
+			5432, nil, repos, global),
+	} {
+		// The retention of the repository wins; other options in global remain.
+		assert.Equal(t, config["global"]["repo1-retention-full"], "5", name)
+		assert.Equal(t, config["global"]["repo1-retention-diff"], "2", name)
+	}
+}
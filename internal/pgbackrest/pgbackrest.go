@@ -18,6 +18,7 @@ package pgbackrest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 
@@ -39,25 +40,31 @@ type Executor func(
 // function is false, this indicates that a pgBackRest config hash mismatch was identified that
 // prevented the "pgbackrest stanza-create" command from running (with a config has mitmatch
 // indicating that pgBackRest configuration as stored in the cluster's pgBackRest ConfigMap has
-// not yet propagated to the Pod).
-func (exec Executor) StanzaCreate(ctx context.Context, configHash string) (bool, error) {
+// not yet propagated to the Pod). The cipherHash is compared the same way with the hash stored
+// alongside the cipher options of encrypted repositories, and is empty when no repository is
+// encrypted.
+func (exec Executor) StanzaCreate(ctx context.Context, configHash, cipherHash string) (bool, error) {
 
 	var stdout, stderr bytes.Buffer
 
 	// this is the script that is run to create a stanza.  First it checks the
-	// "config-hash" file to ensure all configuration changes (e.g. from ConfigMaps) have
-	// propagated to the container, and if so then runs the "stanza-create" command (and if
-	// not, it prints an error and returns with exit code 1).
+	// "config-hash" and "cipher-hash" files to ensure all configuration changes (e.g. from
+	// ConfigMaps and Secrets) have propagated to the container, and if so then runs the
+	// "stanza-create" command (and if not, it prints an error and returns with exit code 1).
 	const script = `
-declare -r hash="$1" stanza="$2" message="$3"
-if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" ]]; then
+declare -r hash="$1" cipher="$2" stanza="$3" message="$4"
+declare cipher_hash=''
+if [[ -f /etc/pgbackrest/conf.d/cipher-hash ]]; then
+    cipher_hash="$(< /etc/pgbackrest/conf.d/cipher-hash)"
+fi
+if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" || "${cipher_hash}" != "${cipher}" ]]; then
     printf >&2 "%s" "${message}"; exit 1;
 else
     pgbackrest stanza-create --stanza="${stanza}"
 fi
 `
-	if err := exec(ctx, nil, &stdout, &stderr, "bash", "-ceu", "--",
-		script, "-", configHash, DefaultStanzaName, errMsgConfigHashMismatch); err != nil {
+	if err := exec(ctx, nil, &stdout, &stderr, "bash", "-ceu", "--", script, "-",
+		configHash, cipherHash, DefaultStanzaName, errMsgConfigHashMismatch); err != nil {
 
 		// if the config hashes didn't match, return true and don't return an error since this is
 		// expected while waiting for config changes in ConfigMaps and Secrets to make it to the
@@ -71,3 +78,94 @@ fi
 
 	return false, nil
 }
+
+// StanzaUpgrade runs the pgBackRest "stanza-upgrade" command after a major PostgreSQL upgrade.
+// Like StanzaCreate, the bool returned from this function is true when a pgBackRest config
+// hash mismatch prevented the command from running, meaning configuration changes have not
+// yet propagated to the Pod.
+func (exec Executor) StanzaUpgrade(ctx context.Context, configHash string) (bool, error) {
+
+	var stdout, stderr bytes.Buffer
+
+	// this is the script that is run to upgrade a stanza.  It checks the "config-hash" file
+	// the same way as StanzaCreate before running the "stanza-upgrade" command.
+	const script = `
+declare -r hash="$1" stanza="$2" message="$3"
+if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" ]]; then
+    printf >&2 "%s" "${message}"; exit 1;
+else
+    pgbackrest stanza-upgrade --stanza="${stanza}"
+fi
+`
+	if err := exec(ctx, nil, &stdout, &stderr, "bash", "-ceu", "--",
+		script, "-", configHash, DefaultStanzaName, errMsgConfigHashMismatch); err != nil {
+
+		if stderr.String() == errMsgConfigHashMismatch {
+			return true, nil
+		}
+
+		return false, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	return false, nil
+}
+
+// BackupInfo is a single backup as reported by the pgBackRest "info" command.
+// - https://pgbackrest.org/command.html#command-info
+type BackupInfo struct {
+	Archive struct {
+		Start string `json:"start"`
+		Stop  string `json:"stop"`
+	} `json:"archive"`
+	Info struct {
+		Repository struct {
+			Size int64 `json:"size"`
+		} `json:"repository"`
+		Size int64 `json:"size"`
+	} `json:"info"`
+	Label     string `json:"label"`
+	Timestamp struct {
+		Start int64 `json:"start"`
+		Stop  int64 `json:"stop"`
+	} `json:"timestamp"`
+	Type string `json:"type"`
+}
+
+// ArchiveInfo is the range of WAL stored for one PostgreSQL system as reported by the
+// pgBackRest "info" command. A stanza has more than one after a major PostgreSQL upgrade.
+type ArchiveInfo struct {
+	ID  string `json:"id"`
+	Max string `json:"max"`
+	Min string `json:"min"`
+}
+
+// StanzaInfo is a stanza as reported by the pgBackRest "info" command.
+type StanzaInfo struct {
+	Archive []ArchiveInfo `json:"archive"`
+	Backup  []BackupInfo  `json:"backup"`
+	Name    string        `json:"name"`
+}
+
+// Info runs the pgBackRest "info" command for the repo at repoIndex and returns the default
+// stanza in that repo. Its backups and archives are ordered oldest first.
+func (exec Executor) Info(ctx context.Context, repoIndex string) (StanzaInfo, error) {
+
+	var stdout, stderr bytes.Buffer
+
+	if err := exec(ctx, nil, &stdout, &stderr, "pgbackrest", "info", "--output=json",
+		"--stanza="+DefaultStanzaName, "--repo="+repoIndex); err != nil {
+		return StanzaInfo{}, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	var stanzas []StanzaInfo
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return StanzaInfo{}, errors.WithStack(err)
+	}
+
+	for _, stanza := range stanzas {
+		if stanza.Name == DefaultStanzaName {
+			return stanza, nil
+		}
+	}
+	return StanzaInfo{}, nil
+}
@@ -17,6 +17,7 @@ package pgbackrest
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os/exec"
@@ -40,14 +41,18 @@ func TestStanzaCreate(t *testing.T) {
 	ctx := context.Background()
 	configHash := "7f5d4d5bdc"
 	expectedCommand := []string{"bash", "-ceu", "--", `
-declare -r hash="$1" stanza="$2" message="$3"
-if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" ]]; then
+declare -r hash="$1" cipher="$2" stanza="$3" message="$4"
+declare cipher_hash=''
+if [[ -f /etc/pgbackrest/conf.d/cipher-hash ]]; then
+    cipher_hash="$(< /etc/pgbackrest/conf.d/cipher-hash)"
+fi
+if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" || "${cipher_hash}" != "${cipher}" ]]; then
     printf >&2 "%s" "${message}"; exit 1;
 else
     pgbackrest stanza-create --stanza="${stanza}"
 fi
 `,
-		"-", "7f5d4d5bdc", "db", "postgres operator error: pgBackRest config hash mismatch"}
+		"-", "7f5d4d5bdc", "7f5d4d5bdc", "db", "postgres operator error: pgBackRest config hash mismatch"}
 
 	var shellCheckScript string
 	stanzaExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
@@ -62,7 +67,7 @@ fi
 		return nil
 	}
 
-	configHashMismatch, err := Executor(stanzaExec).StanzaCreate(ctx, configHash)
+	configHashMismatch, err := Executor(stanzaExec).StanzaCreate(ctx, configHash, configHash)
 	assert.NilError(t, err)
 	assert.Assert(t, !configHashMismatch)
 
@@ -77,3 +82,116 @@ fi
 	output, err := cmd.CombinedOutput()
 	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
 }
+
+func TestStanzaUpgrade(t *testing.T) {
+	ctx := context.Background()
+	configHash := "7f5d4d5bdc"
+	expectedCommand := []string{"bash", "-ceu", "--", `
+declare -r hash="$1" stanza="$2" message="$3"
+if [[ "$(< /etc/pgbackrest/conf.d/config-hash)" != "${hash}" ]]; then
+    printf >&2 "%s" "${message}"; exit 1;
+else
+    pgbackrest stanza-upgrade --stanza="${stanza}"
+fi
+`,
+		"-", "7f5d4d5bdc", "db", "postgres operator error: pgBackRest config hash mismatch"}
+
+	t.Run("Success", func(t *testing.T) {
+		stanzaExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			assert.DeepEqual(t, command, expectedCommand)
+			return nil
+		}
+
+		configHashMismatch, err := Executor(stanzaExec).StanzaUpgrade(ctx, configHash)
+		assert.NilError(t, err)
+		assert.Assert(t, !configHashMismatch)
+	})
+
+	t.Run("ConfigHashMismatch", func(t *testing.T) {
+		stanzaExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			_, _ = stderr.Write([]byte(errMsgConfigHashMismatch))
+			return errors.New("exit status 1")
+		}
+
+		configHashMismatch, err := Executor(stanzaExec).StanzaUpgrade(ctx, configHash)
+		assert.NilError(t, err)
+		assert.Assert(t, configHashMismatch)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		stanzaExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			_, _ = stderr.Write([]byte("boom"))
+			return errors.New("exit status 1")
+		}
+
+		configHashMismatch, err := Executor(stanzaExec).StanzaUpgrade(ctx, configHash)
+		assert.ErrorContains(t, err, "boom")
+		assert.Assert(t, !configHashMismatch)
+	})
+}
+
+func TestInfo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		infoExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "info", "--output=json", "--stanza=db", "--repo=2",
+			})
+			_, _ = stdout.Write([]byte(`[{
+	"archive": [{"id": "13-1", "max": "000000010000000000000004", "min": "000000010000000000000001"}],
+	"backup": [{
+		"archive": {"start": "000000010000000000000002", "stop": "000000010000000000000002"},
+		"info": {"delta": 24584000, "repository": {"delta": 3053000, "size": 3053000}, "size": 24584000},
+		"label": "20210607-175000F",
+		"timestamp": {"start": 1623088200, "stop": 1623088210},
+		"type": "full"
+	}, {
+		"archive": {"start": "000000010000000000000004", "stop": "000000010000000000000004"},
+		"info": {"delta": 8192, "repository": {"delta": 512, "size": 3053512}, "size": 24592192},
+		"label": "20210607-175000F_20210607-180000I",
+		"timestamp": {"start": 1623088800, "stop": 1623088805},
+		"type": "incr"
+	}],
+	"name": "db"
+}]`))
+			return nil
+		}
+
+		info, err := Executor(infoExec).Info(ctx, "2")
+		assert.NilError(t, err)
+		assert.Equal(t, info.Name, "db")
+		assert.DeepEqual(t, info.Archive, []ArchiveInfo{{
+			ID:  "13-1",
+			Max: "000000010000000000000004",
+			Min: "000000010000000000000001",
+		}})
+
+		backups := info.Backup
+		assert.Equal(t, len(backups), 2)
+
+		assert.Equal(t, backups[1].Label, "20210607-175000F_20210607-180000I")
+		assert.Equal(t, backups[1].Type, "incr")
+		assert.Equal(t, backups[1].Info.Size, int64(24592192))
+		assert.Equal(t, backups[1].Info.Repository.Size, int64(3053512))
+		assert.Equal(t, backups[1].Archive.Start, "000000010000000000000004")
+		assert.Equal(t, backups[1].Archive.Stop, "000000010000000000000004")
+		assert.Equal(t, backups[1].Timestamp.Start, int64(1623088800))
+		assert.Equal(t, backups[1].Timestamp.Stop, int64(1623088805))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		infoExec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+			command ...string) error {
+			_, _ = stderr.Write([]byte("boom"))
+			return errors.New("exit status 1")
+		}
+
+		_, err := Executor(infoExec).Info(ctx, "1")
+		assert.ErrorContains(t, err, "boom")
+	})
+}
@@ -46,4 +46,14 @@ func TestPostgreSQLParameters(t *testing.T) {
 		"archive_command": `pgbackrest --stanza=db archive-push "%p"`,
 		"restore_command": `pgbackrest --stanza=db archive-get %f "%p" --repo=99`,
 	})
+
+	// The restore command is not changed when streaming from a remote host only.
+	cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{
+		Enabled: true,
+		Host:    "rhino-primary.west.svc",
+	}
+
+	PostgreSQL(cluster, parameters)
+	assert.Equal(t, parameters.Mandatory.Value("restore_command"),
+		`pgbackrest --stanza=db archive-get %f "%p"`)
 }
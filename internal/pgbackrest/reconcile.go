@@ -45,8 +45,8 @@ func AddRepoVolumesToPod(postgresCluster *v1beta1.PostgresCluster, template *v1.
 			Name: repoVolName,
 			VolumeSource: v1.VolumeSource{
 				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-					ClaimName: naming.PGBackRestRepoVolume(postgresCluster,
-						repoVolName).Name},
+					ClaimName: naming.AdoptedVolume(postgresCluster,
+						naming.PGBackRestRepoVolume(postgresCluster, repoVolName)).Name},
 			},
 		})
 
@@ -66,7 +66,7 @@ func AddRepoVolumesToPod(postgresCluster *v1beta1.PostgresCluster, template *v1.
 			template.Spec.Containers[index].VolumeMounts =
 				append(template.Spec.Containers[index].VolumeMounts, v1.VolumeMount{
 					Name:      repoVolName,
-					MountPath: "/pgbackrest/" + repoVolName,
+					MountPath: RepoVolumeMountPath(repoVolName),
 				})
 		}
 	}
@@ -95,6 +95,22 @@ func AddConfigsToPod(postgresCluster *v1beta1.PostgresCluster, template *v1.PodT
 	}
 	pgBackRestConfigs = append(pgBackRestConfigs, defaultConfig)
 
+	// add the cipher options of encrypted repos, which are kept in a Secret
+	if CipherEnabled(postgresCluster) {
+		pgBackRestConfigs = append(pgBackRestConfigs, v1.VolumeProjection{
+			Secret: &v1.SecretProjection{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: naming.PGBackRestCipherSecret(postgresCluster).Name,
+				},
+				Items: []v1.KeyToPath{
+					{Key: CipherConfigKey, Path: CipherConfigKey},
+					{Key: CipherHashKey, Path: CipherHashKey},
+				},
+				Optional: initialize.Bool(true),
+			},
+		})
+	}
+
 	template.Spec.Volumes = append(template.Spec.Volumes, v1.Volume{
 		Name: ConfigVol,
 		VolumeSource: v1.VolumeSource{
@@ -254,6 +270,12 @@ func ReplicaCreateCommand(
 		//
 		// NOTE(cbandy): A standby cluster cannot use "online" stanza-create
 		// nor create backups because every instance is always in recovery.
+		//
+		// A standby cluster that only streams from a remote host has no
+		// repository from which to restore.
+		if cluster.Spec.Standby.RepoName == "" {
+			return nil
+		}
 		return command(cluster.Spec.Standby.RepoName)
 	}
 
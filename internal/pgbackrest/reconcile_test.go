@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 	"gotest.tools/v3/assert"
@@ -193,6 +194,31 @@ func TestAddConfigsToPod(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Encryption", func(t *testing.T) {
+		cluster := postgresCluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Configuration = nil
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+			Name: "repo1", Encryption: &v1beta1.PGBackRestRepoEncryption{},
+		}}
+		template := &v1.PodTemplateSpec{}
+		template.Spec.Containers = []v1.Container{{Name: "pgbackrest"}}
+
+		assert.NilError(t, AddConfigsToPod(cluster, template, confFile, "pgbackrest"))
+
+		sources := template.Spec.Volumes[0].Projected.Sources
+		assert.Equal(t, len(sources), 2)
+		assert.DeepEqual(t, sources[1], v1.VolumeProjection{
+			Secret: &v1.SecretProjection{
+				LocalObjectReference: v1.LocalObjectReference{Name: "hippo-pgbackrest-cipher"},
+				Items: []v1.KeyToPath{
+					{Key: CipherConfigKey, Path: CipherConfigKey},
+					{Key: CipherHashKey, Path: CipherHashKey},
+				},
+				Optional: initialize.Bool(true),
+			},
+		})
+	})
 }
 
 func TestAddSSHToPod(t *testing.T) {
@@ -367,5 +393,12 @@ func TestReplicaCreateCommand(t *testing.T) {
 			"pgbackrest", "restore", "--delta", "--stanza=db", "--repo=7",
 			"--link-map=pg_wal=/pgdata/pg0_wal",
 		})
+
+		// There is nothing to restore when streaming from a remote host only.
+		cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{
+			Enabled: true,
+			Host:    "rhino-primary.west.svc",
+		}
+		assert.Assert(t, ReplicaCreateCommand(cluster, instance) == nil)
 	})
 }
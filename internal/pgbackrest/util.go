@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 	"github.com/pkg/errors"
@@ -42,6 +44,30 @@ func DedicatedRepoHostEnabled(postgresCluster *v1beta1.PostgresCluster) bool {
 		postgresCluster.Spec.Backups.PGBackRest.RepoHost.Dedicated != nil)
 }
 
+// CipherEnabled determines whether or not any pgBackRest repository of the provided
+// PostgresCluster is encrypted, or is meant to be, according to its spec and status
+func CipherEnabled(postgresCluster *v1beta1.PostgresCluster) bool {
+	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		if repo.Encryption != nil {
+			return true
+		}
+	}
+	if postgresCluster.Status.PGBackRest != nil {
+		for _, repo := range postgresCluster.Status.PGBackRest.Repos {
+			if repo.CipherType != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RepoVolumeMountPath returns the path at which the volume of the repository named repoName is
+// mounted in the containers that use it
+func RepoVolumeMountPath(repoName string) string {
+	return "/pgbackrest/" + repoName
+}
+
 // CalculateConfigHashes calculates hashes for any external pgBackRest repository configuration
 // present in the PostgresCluster spec (e.g. configuration for Azure, GCR and/or S3 repositories).
 // Additionally it returns a hash of the hashes for each external repository.
@@ -93,6 +119,13 @@ func CalculateConfigHashes(
 			configHashes = append(configHashes, repoConfigHashes[configName])
 		}
 	}
+	// Encrypted repos change the overall hash only, so stanzas are created once the cipher
+	// configuration is in place without otherwise changing the hash of each repo.
+	for _, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		if repo.Encryption != nil {
+			configHashes = append(configHashes, repo.Name+"-cipher")
+		}
+	}
 	configHash, err := hashFunc(configHashes)
 	if err != nil {
 		return map[string]string{}, "", errors.WithStack(err)
@@ -101,6 +134,155 @@ func CalculateConfigHashes(
 	return repoConfigHashes, configHash, nil
 }
 
+// RepoRetention returns how long repo keeps backups and WAL archives according to its
+// retention and the pgBackRest options in global. It returns nil when neither says.
+// - https://pgbackrest.org/configuration.html#section-repository
+func RepoRetention(
+	repo v1beta1.PGBackRestRepo, global map[string]string,
+) *v1beta1.PGBackRestRetention {
+	retention := repo.Retention.DeepCopy()
+	if retention == nil {
+		retention = new(v1beta1.PGBackRestRetention)
+	}
+
+	number := func(option string, value **int32) {
+		if i, err := strconv.ParseInt(global[repo.Name+option], 10, 32); *value == nil && err == nil {
+			n := int32(i)
+			*value = &n
+		}
+	}
+	text := func(option string, value *string) {
+		if *value == "" {
+			*value = global[repo.Name+option]
+		}
+	}
+
+	number("-retention-full", &retention.Full)
+	text("-retention-full-type", &retention.FullType)
+	number("-retention-diff", &retention.Differential)
+	number("-retention-archive", &retention.Archive)
+	text("-retention-archive-type", &retention.ArchiveType)
+
+	if *retention == (v1beta1.PGBackRestRetention{}) {
+		return nil
+	}
+	return retention
+}
+
+// quoteShellWord ensures that s is interpreted by a shell as single word.
+func quoteShellWord(s string) string {
+	// https://www.gnu.org/software/bash/manual/html_node/Quoting.html
+	return `'` + strings.ReplaceAll(s, `'`, `'"'"'`) + `'`
+}
+
+// RestoreTargetOptions returns the options of the pgBackRest restore command that stop
+// recovery at target. Values are quoted for the shell that runs RestoreCommand.
+// - https://pgbackrest.org/command.html#command-restore
+func RestoreTargetOptions(target *v1beta1.PostgresRestoreTarget) ([]string, error) {
+	if target == nil {
+		return nil, nil
+	}
+
+	var opts []string
+	var targets int
+	if target.Time != nil {
+		targets++
+		opts = append(opts, "--type=time", "--target="+quoteShellWord(
+			target.Time.UTC().Format("2006-01-02 15:04:05")+"+00"))
+	}
+	if target.LSN != "" {
+		targets++
+		opts = append(opts, "--type=lsn", "--target="+quoteShellWord(target.LSN))
+	}
+	if target.XID != "" {
+		targets++
+		opts = append(opts, "--type=xid", "--target="+quoteShellWord(target.XID))
+	}
+	if target.Name != "" {
+		targets++
+		opts = append(opts, "--type=name", "--target="+quoteShellWord(target.Name))
+	}
+	if targets > 1 {
+		return nil, fmt.Errorf("only one of time, lsn, xid and name may be set")
+	}
+
+	if target.Exclusive != nil && *target.Exclusive {
+		if target.Time == nil && target.LSN == "" && target.XID == "" {
+			return nil, fmt.Errorf("exclusive applies only to a time, lsn or xid target")
+		}
+		opts = append(opts, "--target-exclusive")
+	}
+
+	if target.BackupLabel != "" {
+		opts = append(opts, "--set="+quoteShellWord(target.BackupLabel))
+
+		// Without another target, stop as soon as the backup is consistent.
+		if targets == 0 {
+			opts = append(opts, "--type=immediate")
+		}
+	}
+
+	return opts, nil
+}
+
+// RestoreTarget returns the values of the "--type", "--target" and "--set" options in
+// options, the words of a pgBackRest restore command such as those from RestoreTargetOptions.
+// Like pgBackRest, the last value of each option is the one that applies.
+func RestoreTarget(options []string) (kind, target, set string) {
+	words := shellWords(strings.Join(options, " "))
+	for i := 0; i < len(words); i++ {
+		name, value := words[i], ""
+		if j := strings.IndexByte(name, '='); j > 0 {
+			name, value = name[:j], name[j+1:]
+		} else if i+1 < len(words) {
+			value = words[i+1]
+		}
+
+		switch name {
+		case "--type":
+			kind = value
+		case "--target":
+			target = value
+		case "--set":
+			set = value
+		}
+	}
+	return
+}
+
+// shellWords splits s into words the way a shell would, removing any single or double
+// quotes. It does not expand anything.
+func shellWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	var inWord bool
+
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
 // safeHash32 runs content and returns a short alphanumeric string that
 // represents everything written to w. The string is unlikely to have bad words
 // and is safe to store in the Kubernetes API. This is the same algorithm used
@@ -20,7 +20,9 @@ import (
 	"math/rand"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 	"gotest.tools/v3/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -130,4 +132,162 @@ func TestCalculateConfigHashes(t *testing.T) {
 		repo := "repo" + strconv.Itoa(i+1)
 		assert.Assert(t, hashMap[repo] != configHashMap[repo])
 	}
+
+	// encrypting a repo changes the overall hash but not the hash of the repo
+	encrypted := postgresCluster.DeepCopy()
+	encrypted.Spec.Backups.PGBackRest.Repos[2].Encryption = &v1beta1.PGBackRestRepoEncryption{}
+	hashMap, hash, err := CalculateConfigHashes(encrypted)
+	assert.NilError(t, err)
+	assert.Assert(t, configHash != hash)
+	assert.Equal(t, hashMap["repo3"], configHashMap["repo3"])
+}
+
+func TestCipherEnabled(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+	assert.Assert(t, !CipherEnabled(cluster))
+
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}},
+	}
+	assert.Assert(t, !CipherEnabled(cluster))
+
+	cluster.Status.PGBackRest.Repos[0].CipherType = "aes-256-cbc"
+	assert.Assert(t, CipherEnabled(cluster), "expected the status to be considered")
+
+	cluster.Status.PGBackRest = nil
+	cluster.Spec.Backups.PGBackRest.Repos[0].Encryption = &v1beta1.PGBackRestRepoEncryption{}
+	assert.Assert(t, CipherEnabled(cluster))
+}
+
+func TestRestoreTargetOptions(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		opts, err := RestoreTargetOptions(nil)
+		assert.NilError(t, err)
+		assert.Assert(t, opts == nil)
+
+		opts, err = RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{})
+		assert.NilError(t, err)
+		assert.Assert(t, opts == nil)
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		at := metav1.NewTime(time.Date(2021, time.June, 7, 13, 50, 0, 0,
+			time.FixedZone("EDT", -4*60*60)))
+
+		opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+			Time: &at, Exclusive: initialize.Bool(true),
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{
+			"--type=time", `--target='2021-06-07 17:50:00+00'`, "--target-exclusive",
+		})
+	})
+
+	t.Run("LSN", func(t *testing.T) {
+		opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{LSN: "0/3000060"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{"--type=lsn", `--target='0/3000060'`})
+	})
+
+	t.Run("XID", func(t *testing.T) {
+		opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{XID: "1234"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{"--type=xid", `--target='1234'`})
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{Name: "before 'migration'"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{
+			"--type=name", `--target='before '"'"'migration'"'"''`,
+		})
+	})
+
+	t.Run("BackupLabel", func(t *testing.T) {
+		opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+			BackupLabel: "20210607-175000F",
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{`--set='20210607-175000F'`, "--type=immediate"})
+
+		opts, err = RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+			BackupLabel: "20210607-175000F", XID: "1234",
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, opts, []string{
+			"--type=xid", `--target='1234'`, `--set='20210607-175000F'`,
+		})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+			LSN: "0/3000060", XID: "1234",
+		})
+		assert.ErrorContains(t, err, "only one")
+
+		_, err = RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+			Name: "before", Exclusive: initialize.Bool(true),
+		})
+		assert.ErrorContains(t, err, "exclusive")
+	})
+}
+
+func TestRepoRetention(t *testing.T) {
+	repo := v1beta1.PGBackRestRepo{Name: "repo2"}
+	assert.Assert(t, RepoRetention(repo, nil) == nil)
+	assert.Assert(t, RepoRetention(repo, map[string]string{"repo1-retention-full": "3"}) == nil)
+
+	// Options in global are reported when the repo has no retention.
+	retention := RepoRetention(repo, map[string]string{
+		"repo2-retention-full":      "3",
+		"repo2-retention-full-type": "time",
+		"repo2-retention-diff":      "nope",
+	})
+	assert.DeepEqual(t, retention, &v1beta1.PGBackRestRetention{
+		Full: initialize.Int32(3), FullType: "time",
+	})
+
+	// Typed retention takes precedence over options in global.
+	repo.Retention = &v1beta1.PGBackRestRetention{
+		Full: initialize.Int32(5), Archive: initialize.Int32(2), ArchiveType: "full",
+	}
+	retention = RepoRetention(repo, map[string]string{
+		"repo2-retention-full": "3",
+		"repo2-retention-diff": "4",
+	})
+	assert.DeepEqual(t, retention, &v1beta1.PGBackRestRetention{
+		Full: initialize.Int32(5), Differential: initialize.Int32(4),
+		Archive: initialize.Int32(2), ArchiveType: "full",
+	})
+	assert.Equal(t, *repo.Retention.Full, int32(5), "expected the spec to be unchanged")
+	assert.Assert(t, repo.Retention.Differential == nil, "expected the spec to be unchanged")
+}
+
+func TestRestoreTarget(t *testing.T) {
+	kind, target, set := RestoreTarget(nil)
+	assert.Equal(t, kind+target+set, "")
+
+	opts, err := RestoreTargetOptions(&v1beta1.PostgresRestoreTarget{
+		Time:        &metav1.Time{Time: time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC)},
+		BackupLabel: "20210607-170000F",
+	})
+	assert.NilError(t, err)
+
+	kind, target, set = RestoreTarget(append(opts, "--delta"))
+	assert.Equal(t, kind, "time")
+	assert.Equal(t, target, "2021-06-07 18:00:00+00")
+	assert.Equal(t, set, "20210607-170000F")
+
+	// Options may be separated from their values and combined in one string.
+	kind, target, set = RestoreTarget([]string{
+		`--type lsn --target="0/3000060" --target-action=pause`, "--set", "x",
+	})
+	assert.Equal(t, kind, "lsn")
+	assert.Equal(t, target, "0/3000060")
+	assert.Equal(t, set, "x")
+
+	// The last value applies.
+	kind, _, _ = RestoreTarget([]string{"--type=time", "--type=immediate"})
+	assert.Equal(t, kind, "immediate")
 }
@@ -0,0 +1,76 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// AdoptCommand returns an entrypoint that checks an existing data directory
+// before cluster starts on it. The data directory must be the PostgreSQL version
+// of cluster, and its WAL directory must be mounted. When walVolume is true, WAL
+// must be in WALDirectory. When backupInfo is not empty, it is the path to a
+// pgBackRest "backup.info" file whose system identifier must match that of the
+// data directory.
+// - https://www.postgresql.org/docs/current/app-pgcontroldata.html
+// - https://pgbackrest.org/user-guide.html#concept/backup
+func AdoptCommand(
+	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
+	walVolume bool, backupInfo string,
+) []string {
+	var wal string
+	if walVolume {
+		wal = WALDirectory(cluster, instance)
+	}
+	args := []string{
+		fmt.Sprint(cluster.Spec.PostgresVersion),
+		DataDirectory(cluster),
+		wal,
+		backupInfo,
+	}
+	script := strings.Join([]string{
+		`declare -r version="$1" data="$2" wal="$3" backup_info="$4"`,
+
+		// Function to log values in a basic structured format.
+		`results() { printf '::postgres-operator: %s::%s\n' "$@"; }`,
+
+		// Log the effective user ID and all the group IDs.
+		`echo Checking ...`,
+		`results 'uid' "$(id -u)" 'gid' "$(id -G)"`,
+
+		// Abort when the data directory is not the version of the cluster.
+		`results 'data version' "${data_version:=$(< "${data}/PG_VERSION")}"`,
+		`[ "${data_version}" = "${version}" ]`,
+
+		// Abort when WAL is on a volume that is not mounted or not adopted.
+		`results 'wal directory' "${data_wal:=$(realpath "${data}/pg_wal")}"`,
+		`[ -d "${data_wal}" ]`,
+		`[ -z "${wal}" ] || [ "${data_wal}" = "${wal}" ]`,
+
+		// Abort when backups in the repository are of another data directory.
+		`results 'system identifier' "${system_id:=$("/usr/pgsql-${version}/bin/pg_controldata" "${data}" | sed -n 's/^Database system identifier:[[:space:]]*//p')}"`,
+		`[ -n "${system_id}" ]`,
+		`if [ -n "${backup_info}" ]; then`,
+		`results 'repository system identifier' "${repo_id:=$(sed -n 's/^db-system-id=//p' "${backup_info}")}"`,
+		`[ "${repo_id}" = "${system_id}" ]`,
+		`fi`,
+	}, "\n")
+
+	return append([]string{"bash", "-ceu", "--", script, "adopt"}, args...)
+}
@@ -0,0 +1,68 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestAdoptCommand(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 13
+	instance := new(v1beta1.PostgresInstanceSetSpec)
+
+	command := AdoptCommand(cluster, instance, false, "")
+
+	// Expect a bash command with an inline script and its arguments.
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.Assert(t, len(command) > 3)
+	assert.DeepEqual(t, command[4:], []string{"adopt", "13", "/pgdata/pg13", "", ""})
+
+	t.Run("WALVolume", func(t *testing.T) {
+		instance := new(v1beta1.PostgresInstanceSetSpec)
+		instance.WALVolumeClaimSpec = new(corev1.PersistentVolumeClaimSpec)
+
+		command := AdoptCommand(cluster, instance, true, "/pgbackrest/repo1/backup/db/backup.info")
+		assert.DeepEqual(t, command[4:], []string{
+			"adopt", "13", "/pgdata/pg13", "/pgwal/pg13_wal", "/pgbackrest/repo1/backup/db/backup.info",
+		})
+	})
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck, err := exec.LookPath("shellcheck")
+		if err != nil {
+			t.Skip(`requires "shellcheck" executable`)
+		}
+
+		// Write out that inline script.
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, ioutil.WriteFile(file, []byte(command[3]), 0o600))
+
+		// Expect shellcheck to be happy.
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+}
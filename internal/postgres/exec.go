@@ -18,6 +18,7 @@ package postgres
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"sort"
 	"strings"
@@ -90,3 +91,127 @@ done <<< "${databases}"
 		append([]string{"bash", "-ceu", "--", script, "-"}, args...)...)
 	return stdout.String(), stderr.String(), err
 }
+
+// backupStatements returns the SQL that starts and stops a non-exclusive backup
+// in PostgreSQL version. The functions were renamed in PostgreSQL 15.
+func backupStatements(version int) (start, stop string) {
+	start = `SELECT pg_catalog.pg_backup_start(:'label', true);`
+	stop = `SELECT labelfile FROM pg_catalog.pg_backup_stop(false);`
+	if version < 15 {
+		start = `SELECT pg_catalog.pg_start_backup(:'label', true, false);`
+		stop = `SELECT labelfile FROM pg_catalog.pg_stop_backup(false, false);`
+	}
+	return
+}
+
+// backupDirectory is where StartBackup keeps the files of the "psql" session
+// that holds PostgreSQL in backup mode for label.
+func backupDirectory(label string) string { return "/tmp/backup-" + label }
+
+// StartBackup uses "bash" and "psql" to start a non-exclusive backup labeled
+// label. A non-exclusive backup is canceled when its session ends, so "psql"
+// runs in the background and the backup continues after StartBackup returns.
+// Call StopBackup with the same label to end it. Nothing is left running when
+// StartBackup returns an error.
+// - https://www.postgresql.org/docs/current/continuous-archiving.html#BACKUP-LOWLEVEL-BASE-BACKUP
+func (exec Executor) StartBackup(ctx context.Context, version int, label string) error {
+	start, _ := backupStatements(version)
+
+	const script = `
+directory="$1" start="$2"; shift 2
+mkdir -p "${directory}" && cd "${directory}"
+
+# End any session that a prior attempt left behind.
+if [[ -f holder ]]; then kill "$(< holder)" 2> /dev/null || true; fi
+rm -f exited holder input output error session
+mkfifo input
+
+# Hold the pipe open so that psql waits for more statements rather than exit.
+# Opening it for both reading and writing does not block.
+exec 3<> input
+sleep infinity 0<&3 3>&- > /dev/null 2>&1 &
+echo "$!" > holder
+
+{
+	status=0; psql "$@" --file=input > output 2> error || status=$?
+	echo "${status}" > exited
+} < /dev/null > /dev/null 2>&1 3>&- &
+echo "$!" > session
+
+printf '%s\n' '\o /dev/null' "${start}" '\o' '\echo backup-started' >&3
+
+until grep --quiet --line-regexp --fixed-strings backup-started output; do
+	if [[ -f exited ]]; then
+		kill "$(< holder)" 2> /dev/null || true
+		cat error >&2
+		cd / && rm -rf "${directory}"
+		exit 1
+	fi
+	sleep 1
+done
+`
+
+	var stdout, stderr bytes.Buffer
+	err := exec(ctx, nil, &stdout, &stderr,
+		"bash", "-ceu", "--", script, "-", backupDirectory(label), start,
+		"-Xw", "--quiet", "--no-align", "--tuples-only",
+		"--set=ON_ERROR_STOP=1", "--set=label="+label)
+
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// StopBackup uses "bash" and "psql" to stop the non-exclusive backup that
+// StartBackup started with label. It returns the contents of the backup label
+// file that PostgreSQL produces when the backup stops. It returns an error when
+// the backup ended some other way, such as when PostgreSQL restarted.
+func (exec Executor) StopBackup(ctx context.Context, version int, label string) (string, error) {
+	_, stop := backupStatements(version)
+
+	const script = `
+directory="$1" stop="$2"
+cd "${directory}"
+
+# The session may have exited without a parent to reap it.
+running() {
+	local stat
+	read -r stat 2> /dev/null < "/proc/$(< session)/stat" || return 1
+	[[ "${stat##*) }" != Z* ]]
+}
+
+if running; then
+	printf '%s\n' "${stop}" 1<> input
+fi
+
+# Release the pipe so that psql exits after the last statement.
+kill "$(< holder)" 2> /dev/null || true
+while running; do sleep 1; done
+
+status=1
+if [[ -f exited ]]; then status="$(< exited)"; fi
+if [[ "${status}" == 0 ]]; then
+	awk 'started { print } /^backup-started$/ { started = 1 }' output
+elif [[ -s error ]]; then
+	cat error >&2
+else
+	echo 'the backup session ended before the backup stopped' >&2
+fi
+
+cd / && rm -rf "${directory}"
+exit "${status}"
+`
+
+	var stdout, stderr bytes.Buffer
+	err := exec(ctx, nil, &stdout, &stderr,
+		"bash", "-ceu", "--", script, "-", backupDirectory(label), stop)
+
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()) + "\n", nil
+}
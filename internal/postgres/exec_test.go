@@ -18,8 +18,10 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -162,3 +164,77 @@ done <<< "${databases}"
 		}).ExecInDatabasesFromQuery(context.Background(), "", "", nil)
 	})
 }
+
+func TestExecutorBackup(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip(`requires "bash" executable`)
+	}
+
+	// Write a "psql" that answers the statements of a backup as they arrive.
+	bin := t.TempDir()
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(bin, "psql"), []byte(`#!/bin/bash
+for arg; do
+	[[ "${arg}" == --file=* ]] && file="${arg#--file=}"
+	[[ "${arg}" == --set=label=*-fail ]] && fail=1
+done
+while IFS= read -r line; do
+	case "${line}" in
+	'\echo '*) echo "${line#'\echo '}" ;;
+	*start*) [[ -z "${fail-}" ]] || { echo "ERROR: recovery is in progress" >&2; exit 3; } ;;
+	*pg_stop_backup*) printf 'START WAL LOCATION: 0/2000028\n\n' ;;
+	*pg_backup_stop*) printf 'START WAL LOCATION: 0/3000028\n\n' ;;
+	esac
+done < "${file}"
+`), 0o700))
+
+	var commands [][]string
+	executor := Executor(func(
+		ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		commands = append(commands, command)
+
+		// #nosec G204 Only the scripts of Executor are run.
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+		return cmd.Run()
+	})
+
+	for _, tt := range []struct {
+		version int
+		label   string
+	}{
+		{version: 13, label: "START WAL LOCATION: 0/2000028\n"},
+		{version: 15, label: "START WAL LOCATION: 0/3000028\n"},
+	} {
+		commands = nil
+		label := fmt.Sprintf("backup-%d-%d", os.Getpid(), tt.version)
+		assert.NilError(t, executor.StartBackup(context.Background(), tt.version, label))
+		assert.Assert(t, strings.Contains(strings.Join(commands[0], " "), "--set=label="+label))
+
+		// The backup continues between calls.
+		_, err := os.Stat(filepath.Join(backupDirectory(label), "session"))
+		assert.NilError(t, err)
+
+		output, err := executor.StopBackup(context.Background(), tt.version, label)
+		assert.NilError(t, err)
+		assert.Equal(t, output, tt.label)
+
+		_, err = os.Stat(backupDirectory(label))
+		assert.Assert(t, os.IsNotExist(err), "expected the session files to be removed")
+	}
+
+	t.Run("StartError", func(t *testing.T) {
+		label := fmt.Sprintf("start-error-%d-fail", os.Getpid())
+		err := executor.StartBackup(context.Background(), 15, label)
+		assert.ErrorContains(t, err, "ERROR: recovery is in progress")
+
+		_, err = os.Stat(backupDirectory(label))
+		assert.Assert(t, os.IsNotExist(err), "expected the session files to be removed")
+	})
+
+	t.Run("StopWithoutStart", func(t *testing.T) {
+		_, err := executor.StopBackup(context.Background(), 15, "never-started")
+		assert.Assert(t, err != nil)
+	})
+}
@@ -16,7 +16,14 @@
 package postgres
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // NewParameters returns ParameterSets required by this package.
@@ -119,3 +126,85 @@ func (ps ParameterSet) Value(name string) string {
 	value, _ := ps.Get(name)
 	return value
 }
+
+// AutoTuneParameters populates outParameters with parameters derived from the
+// resources of the instance sets in cluster that enable auto-tuning. These
+// parameters must be the same on every instance, so they are calculated for
+// the largest instance set.
+func AutoTuneParameters(cluster *v1beta1.PostgresCluster, outParameters *Parameters) {
+	var workers int64
+	for i := range cluster.Spec.InstanceSets {
+		if n := autoTuneWorkerProcesses(&cluster.Spec.InstanceSets[i]); n > workers {
+			workers = n
+		}
+	}
+
+	// PostgreSQL must be restarted when changing this value. Replicas must
+	// have at least as many as the primary.
+	// - https://www.postgresql.org/docs/current/runtime-config-resource.html#GUC-MAX-WORKER-PROCESSES
+	// - https://www.postgresql.org/docs/current/hot-standby.html#HOT-STANDBY-ADMIN
+	if workers > 0 {
+		outParameters.Default.Add("max_worker_processes", strconv.FormatInt(workers, 10))
+	}
+}
+
+// InstanceParameters returns the PostgreSQL parameters of the instances in
+// instance set: those derived from its resources when it enables auto-tuning
+// followed by those it sets explicitly.
+func InstanceParameters(instance *v1beta1.PostgresInstanceSetSpec) *ParameterSet {
+	parameters := NewParameterSet()
+	if instance.Config == nil {
+		return parameters
+	}
+
+	memory := resourceRequest(instance.Resources, corev1.ResourceMemory)
+	if instance.Config.AutoTune && !memory.IsZero() {
+		// Recommend a quarter of memory for shared buffers and expect the
+		// operating system to cache most of the rest. PostgreSQL must be
+		// restarted when changing "shared_buffers".
+		// - https://www.postgresql.org/docs/current/runtime-config-resource.html#GUC-SHARED-BUFFERS
+		// - https://www.postgresql.org/docs/current/runtime-config-query.html#GUC-EFFECTIVE-CACHE-SIZE
+		kilobytes := memory.Value() / 1024
+		parameters.Add("shared_buffers", fmt.Sprintf("%dkB", maxInt64(128, kilobytes/4)))
+		parameters.Add("effective_cache_size", fmt.Sprintf("%dkB", maxInt64(8, kilobytes*3/4)))
+	}
+
+	for name, value := range instance.Config.Parameters {
+		parameters.Add(name, value)
+	}
+
+	return parameters
+}
+
+// autoTuneWorkerProcesses returns the number of background worker processes
+// for instance set, or zero when it does not enable auto-tuning. There is one
+// per CPU and never fewer than the PostgreSQL default of eight.
+func autoTuneWorkerProcesses(instance *v1beta1.PostgresInstanceSetSpec) int64 {
+	if instance.Config == nil || !instance.Config.AutoTune {
+		return 0
+	}
+
+	cpu := resourceRequest(instance.Resources, corev1.ResourceCPU)
+	if cpu.IsZero() {
+		return 0
+	}
+
+	// Round partial CPUs up.
+	return maxInt64(8, (cpu.MilliValue()+999)/1000)
+}
+
+// resourceRequest returns the amount of name requested in resources, or its
+// limit when there is no request.
+func resourceRequest(resources corev1.ResourceRequirements, name corev1.ResourceName) resource.Quantity {
+	if quantity, ok := resources.Requests[name]; ok && !quantity.IsZero() {
+		return quantity
+	}
+	return resources.Limits[name]
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
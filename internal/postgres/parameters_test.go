@@ -19,6 +19,10 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestNewParameters(t *testing.T) {
@@ -68,3 +72,65 @@ func TestParameterSet(t *testing.T) {
 	ps2.Add("x", "n")
 	assert.Assert(t, ps2.Value("x") != ps.Value("x"))
 }
+
+func TestAutoTuneParameters(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}, {Name: "01"}}
+
+	parameters := NewParameters()
+	AutoTuneParameters(cluster, &parameters)
+	assert.Assert(t, !parameters.Default.Has("max_worker_processes"))
+
+	// The largest instance set that enables auto-tuning determines the value.
+	cluster.Spec.InstanceSets[0].Config = &v1beta1.PostgresInstanceConfiguration{AutoTune: true}
+	cluster.Spec.InstanceSets[0].Resources.Requests = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("11500m"),
+	}
+	cluster.Spec.InstanceSets[1].Resources.Requests = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("32"),
+	}
+
+	parameters = NewParameters()
+	AutoTuneParameters(cluster, &parameters)
+	assert.Equal(t, parameters.Default.Value("max_worker_processes"), "12")
+
+	// Never fewer than the PostgreSQL default.
+	cluster.Spec.InstanceSets[0].Resources.Requests = nil
+	cluster.Spec.InstanceSets[0].Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("2"),
+	}
+
+	parameters = NewParameters()
+	AutoTuneParameters(cluster, &parameters)
+	assert.Equal(t, parameters.Default.Value("max_worker_processes"), "8")
+}
+
+func TestInstanceParameters(t *testing.T) {
+	instance := &v1beta1.PostgresInstanceSetSpec{}
+	assert.DeepEqual(t, InstanceParameters(instance).AsMap(), map[string]string{})
+
+	instance.Config = &v1beta1.PostgresInstanceConfiguration{
+		Parameters: map[string]string{"work_mem": "64MB"},
+	}
+	instance.Resources.Requests = corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	assert.DeepEqual(t, InstanceParameters(instance).AsMap(), map[string]string{
+		"work_mem": "64MB",
+	})
+
+	instance.Config.AutoTune = true
+	assert.DeepEqual(t, InstanceParameters(instance).AsMap(), map[string]string{
+		"effective_cache_size": "3145728kB",
+		"shared_buffers":       "1048576kB",
+		"work_mem":             "64MB",
+	})
+
+	// Explicit parameters take precedence.
+	instance.Config.Parameters["Shared_Buffers"] = "2GB"
+	assert.DeepEqual(t, InstanceParameters(instance).AsMap(), map[string]string{
+		"effective_cache_size": "3145728kB",
+		"shared_buffers":       "2GB",
+		"work_mem":             "64MB",
+	})
+}
@@ -0,0 +1,88 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// UpgradeCommand returns an entrypoint that upgrades the data directory of
+// fromVersion to the PostgreSQL version of cluster using `pg_upgrade --link`.
+// The image that runs it must have the binaries of both versions installed
+// in "/usr/pgsql-{version}/bin".
+//
+// The upgraded data directory is left next to DataDirectory with a "_bootstrap"
+// suffix, the same as a pgBackRest restore. The startup container and Patroni
+// move it into place when the cluster is bootstrapped again. Once pg_upgrade
+// succeeds, the old data directory is unusable and is removed.
+// - https://www.postgresql.org/docs/current/pgupgrade.html
+func UpgradeCommand(
+	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
+	fromVersion int,
+) []string {
+	args := []string{
+		fmt.Sprint(fromVersion),
+		fmt.Sprint(cluster.Spec.PostgresVersion),
+		fmt.Sprintf("%s/pg%d", dataMountPath, fromVersion),
+		DataDirectory(cluster) + "_bootstrap",
+		WALDirectory(cluster, instance),
+	}
+	script := strings.Join([]string{
+		`declare -r old_version="$1" new_version="$2" old_data="$3" new_data="$4" new_wal="$5"`,
+		`declare -r old_bin="/usr/pgsql-${old_version}/bin" new_bin="/usr/pgsql-${new_version}/bin"`,
+
+		// Function to log values in a basic structured format.
+		`results() { printf '::postgres-operator: %s::%s\n' "$@"; }`,
+
+		// Log the effective user ID and all the group IDs.
+		`echo Initializing ...`,
+		`results 'uid' "$(id -u)" 'gid' "$(id -G)"`,
+
+		// Abort when the data directory is not the version being upgraded.
+		`results 'data version' "${old_data_version:=$(< "${old_data}/PG_VERSION")}"`,
+		`[ "${old_data_version}" = "${old_version}" ]`,
+		`results 'wal directory' "${old_wal:=$(realpath "${old_data}/pg_wal")}"`,
+
+		// Remove anything left behind by a previous attempt. pg_upgrade does
+		// not modify the old data directory until it links files at the end.
+		`rm -rf "${new_data}" "${new_wal}"`,
+
+		// The new data directory must use the same checksum setting as the old.
+		`results 'data checksums' "${data_checksums:=$("${old_bin}/pg_controldata" "${old_data}" | sed -n 's/^Data page checksum version:[[:space:]]*//p')}"`,
+		`initdb_options=(--pgdata="${new_data}" --waldir="${new_wal}" --encoding=UTF8 --username=postgres)`,
+		`[ "${data_checksums}" = '0' ] || initdb_options+=(--data-checksums)`,
+		`"${new_bin}/initdb" "${initdb_options[@]}"`,
+
+		// pg_upgrade writes its logs and sockets to the working directory.
+		// The configuration that Patroni wrote to the old data directory refers
+		// to certificates and commands that are not in this container.
+		`cd "$(mktemp --directory)"`,
+		`"${new_bin}/pg_upgrade" --link --username=postgres` +
+			` --old-bindir="${old_bin}" --old-datadir="${old_data}"` +
+			` --new-bindir="${new_bin}" --new-datadir="${new_data}"` +
+			` --old-options='-c archive_mode=off -c ssl=off'` +
+			` --new-options='-c archive_mode=off -c ssl=off'`,
+
+		// The old data directory cannot be started after its files are linked.
+		`rm -rf "${old_data}" "${old_wal}"`,
+		`results 'upgraded data directory' "${new_data}"`,
+	}, "\n")
+
+	return append([]string{"bash", "-ceu", "--", script, "upgrade"}, args...)
+}
@@ -16,6 +16,7 @@
 package v1beta1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -54,6 +55,16 @@ type PatroniSpec struct {
 	// +kubebuilder:validation:Minimum=1
 	SyncPeriodSeconds *int32 `json:"syncPeriodSeconds,omitempty"`
 
+	// Synchronous replication settings. When omitted, synchronous replication is
+	// configured only through dynamicConfiguration.
+	// More info: https://patroni.readthedocs.io/en/latest/replication_modes.html
+	// +optional
+	Synchronous *PatroniSynchronous `json:"synchronous,omitempty"`
+
+	// Switchover gives options to perform ad hoc switchovers in a PostgresCluster.
+	// +optional
+	Switchover *PatroniSwitchover `json:"switchover,omitempty"`
+
 	// TODO(cbandy): Add UseConfigMaps bool, default false.
 	// TODO(cbandy): Allow other DCS: etcd, raft, etc?
 	// N.B. changing this will cause downtime.
@@ -73,6 +84,142 @@ func (s *PatroniSpec) Default() {
 		s.SyncPeriodSeconds = new(int32)
 		*s.SyncPeriodSeconds = 10
 	}
+	if s.Synchronous != nil {
+		if s.Synchronous.Mode == "" {
+			s.Synchronous.Mode = PatroniSynchronousModeOn
+		}
+		if s.Synchronous.NumberOfStandbys == nil {
+			s.Synchronous.NumberOfStandbys = new(int32)
+			*s.Synchronous.NumberOfStandbys = 1
+		}
+	}
+	if s.Switchover != nil && s.Switchover.Type == "" {
+		s.Switchover.Type = PatroniSwitchoverTypeSwitchover
+	}
+}
+
+const (
+	PatroniSynchronousModeOff    = "off"
+	PatroniSynchronousModeOn     = "on"
+	PatroniSynchronousModeStrict = "strict"
+	PatroniSynchronousModeQuorum = "quorum"
+)
+
+// PatroniSynchronous defines how Patroni manages synchronous replication.
+type PatroniSynchronous struct {
+
+	// Whether or not commits wait for synchronous standbys. With "on", Patroni
+	// disables synchronous replication when no standby is available. With
+	// "strict", commits wait until a standby is available, trading availability
+	// for durability. With "quorum", every replica is a synchronous standby and
+	// each commit waits for numberOfStandbys of them. Quorum commit requires
+	// Patroni 4.0 or later; until every instance runs such a version, "quorum"
+	// behaves like "on".
+	// +optional
+	// +kubebuilder:default=on
+	// +kubebuilder:validation:Enum={off,on,strict,quorum}
+	Mode string `json:"mode,omitempty"`
+
+	// The number of replicas that are synchronous standbys at the same time.
+	// Each commit waits for all of them or, with "quorum", for this many of any
+	// replicas. This must be less than the number of instances in the cluster.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	NumberOfStandbys *int32 `json:"numberOfStandbys,omitempty"`
+
+	// How far a commit proceeds on synchronous standbys before it returns:
+	// "remote_write" waits for standbys to write the commit to their operating
+	// system, "on" waits for them to flush it to disk, and "remote_apply" waits
+	// for it to be visible to queries on them. Defaults to the value of the
+	// "synchronous_commit" parameter.
+	// More info: https://www.postgresql.org/docs/current/runtime-config-wal.html#GUC-SYNCHRONOUS-COMMIT
+	// +optional
+	// +kubebuilder:validation:Enum={remote_write,on,remote_apply}
+	Commit string `json:"commit,omitempty"`
+}
+
+const (
+	PatroniSwitchoverTypeFailover   = "Failover"
+	PatroniSwitchoverTypeSwitchover = "Switchover"
+)
+
+// PatroniSwitchover defines a change of the PostgreSQL primary. It happens once for every
+// value of the "postgres-operator.crunchydata.com/trigger-switchover" annotation.
+type PatroniSwitchover struct {
+
+	// Whether or not the operator should allow switchovers in a PostgresCluster.
+	// +required
+	Enabled bool `json:"enabled"`
+
+	// The name of the instance that should become primary. When omitted, the operator chooses
+	// the most up-to-date replica of the instance set with the highest failoverPriority, or of
+	// targetInstanceSet when that is set. Cannot be set with targetInstanceSet.
+	// +optional
+	TargetInstance *string `json:"targetInstance,omitempty"`
+
+	// The name of the instance set whose most up-to-date replica should become primary.
+	// Cannot be set with targetInstance.
+	// +optional
+	TargetInstanceSet *string `json:"targetInstanceSet,omitempty"`
+
+	// Type of switchover to perform. A "Switchover" requires a healthy primary and demotes it
+	// gracefully. A "Failover" promotes the target whether or not the primary is healthy and
+	// requires targetInstance or targetInstanceSet.
+	// More info: https://patroni.readthedocs.io/en/latest/rest_api.html#switchover-and-failover-endpoints
+	// +optional
+	// +kubebuilder:default=Switchover
+	// +kubebuilder:validation:Enum={Switchover,Failover}
+	Type string `json:"type,omitempty"`
+}
+
+const (
+	PatroniFailoverRoleCandidate = "Candidate"
+	PatroniFailoverRoleReplica   = "Replica"
+)
+
+// PatroniInstanceSetSpec defines how Patroni treats the instances of one instance set.
+type PatroniInstanceSetSpec struct {
+
+	// Whether or not instances of this set may become primary. "Candidate"
+	// instances may become primary and serve as synchronous standbys. "Replica"
+	// instances never do, which suits a pool of replicas for reporting or
+	// disaster recovery. At least one instance set must be a "Candidate".
+	// +optional
+	// +kubebuilder:default=Candidate
+	// +kubebuilder:validation:Enum={Candidate,Replica}
+	FailoverRole string `json:"failoverRole,omitempty"`
+
+	// Preference for the instances of this set when the operator chooses a new
+	// primary. Replicas in sets with a higher priority are chosen first.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FailoverPriority int32 `json:"failoverPriority,omitempty"`
+
+	// Whether or not to exclude instances of this set from replica connections
+	// that are load balanced, such as the replica Service.
+	// +optional
+	NoLoadBalance bool `json:"noLoadBalance,omitempty"`
+
+	// Whether or not new replicas should prefer to copy their data from an
+	// instance of this set rather than from the primary.
+	// +optional
+	CloneFrom bool `json:"cloneFrom,omitempty"`
+}
+
+// FailoverCandidate returns true when instances with these settings may become
+// primary. Instances are candidates by default.
+func (s *PatroniInstanceSetSpec) FailoverCandidate() bool {
+	return s == nil || s.FailoverRole != PatroniFailoverRoleReplica
+}
+
+// GetFailoverPriority gets the failover priority from a PatroniInstanceSetSpec
+// pointer, if PatroniInstanceSetSpec hasn't been set return zero
+func (s *PatroniInstanceSetSpec) GetFailoverPriority() int32 {
+	if s == nil {
+		return 0
+	}
+	return s.FailoverPriority
 }
 
 type PatroniStatus struct {
@@ -84,4 +231,58 @@ type PatroniStatus struct {
 	// The PostgreSQL system identifier reported by Patroni.
 	// +optional
 	SystemIdentifier string `json:"systemIdentifier,omitempty"`
+
+	// The PostgreSQL timeline of the current leader. It increases each time a
+	// replica or a standby cluster is promoted.
+	// +optional
+	Timeline int64 `json:"timeline,omitempty"`
+
+	// The names of the instances that are currently synchronous standbys.
+	// +optional
+	SynchronousStandbys []string `json:"synchronousStandbys,omitempty"`
+
+	// The oldest version of Patroni reported by the instances of the cluster.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// The outcome of the most recent switchover.
+	// +optional
+	Switchover *PatroniSwitchoverStatus `json:"switchover,omitempty"`
+}
+
+// PatroniSwitchoverStatus describes the outcome of a switchover.
+type PatroniSwitchoverStatus struct {
+
+	// The value of the "postgres-operator.crunchydata.com/trigger-switchover" annotation
+	// that requested the switchover.
+	// +kubebuilder:validation:Required
+	ID string `json:"id"`
+
+	// The type of switchover that was performed.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Whether or not the switchover changed the primary to the target.
+	// +optional
+	Succeeded bool `json:"succeeded,omitempty"`
+
+	// A human-readable description of the outcome.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// The name of the instance that was primary before the switchover.
+	// +optional
+	OldPrimary string `json:"oldPrimary,omitempty"`
+
+	// The name of the instance that was primary after the switchover.
+	// +optional
+	NewPrimary string `json:"newPrimary,omitempty"`
+
+	// The PostgreSQL timeline of the primary after the switchover.
+	// +optional
+	Timeline *int64 `json:"timeline,omitempty"`
+
+	// The time the switchover finished. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
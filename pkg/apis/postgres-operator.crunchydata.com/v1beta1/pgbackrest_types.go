@@ -136,6 +136,13 @@ type PGBackRestArchive struct {
 	// Defines details for performing an in-place restore using pgBackRest
 	// +optional
 	Restore *PGBackRestRestore `json:"restore,omitempty"`
+
+	// The age in seconds after which the most recent backup is considered too old. When
+	// no repository has a backup that finished more recently, the PGBackRestRecentBackup
+	// condition is false. The condition is not reported when this is not set.
+	// +optional
+	// +kubebuilder:validation:Minimum=60
+	MaxBackupAgeSeconds *int32 `json:"maxBackupAgeSeconds,omitempty"`
 }
 
 type PGBackRestManualBackup struct {
@@ -204,6 +211,65 @@ type PGBackRestBackupSchedules struct {
 	// +optional
 	// +kubebuilder:validation:MinLength=6
 	Incremental *string `json:"incremental,omitempty"`
+
+	// Defines the Cron schedule for checking the backups and WAL archive in the repository
+	// using the pgBackRest "verify" command.
+	// Follows the standard Cron schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +optional
+	// +kubebuilder:validation:MinLength=6
+	Verify *string `json:"verify,omitempty"`
+}
+
+// PGBackRestRestoreTest defines a scheduled test of the backups in a pgBackRest repository.
+// The most recent backup is restored into a scratch volume and PostgreSQL is started on it.
+type PGBackRestRestoreTest struct {
+
+	// Defines the Cron schedule for the restore test.
+	// Follows the standard Cron schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=6
+	Schedule string `json:"schedule"`
+
+	// Defines a PersistentVolumeClaim for the restored data. It is kept between tests so that
+	// each restore copies only the files that changed.
+	// +kubebuilder:validation:Required
+	VolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec"`
+
+	// SQL to run once PostgreSQL has recovered. The test fails when the SQL returns an error
+	// or false, e.g. "SELECT count(*) > 0 FROM orders".
+	// +optional
+	SQL string `json:"sql,omitempty"`
+
+	// Resource requirements for the restore test container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PGBackRestRestoreTestStatus describes the most recent restore test of a pgBackRest repository
+// that finished.
+type PGBackRestRestoreTestStatus struct {
+
+	// The name of the Job that ran the test.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Whether or not the backup was restored and the SQL check passed.
+	// +optional
+	Succeeded bool `json:"succeeded"`
+
+	// The time the test started. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time the test finished. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+
+	// How long the test took, from restoring files to running the SQL check.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
 }
 
 // PGBackRestStatus defines the status of pgBackRest within a PostgresCluster
@@ -213,6 +279,11 @@ type PGBackRestStatus struct {
 	// +optional
 	ManualBackup *PGBackRestJobStatus `json:"manualBackup,omitempty"`
 
+	// The time the most recent backup of the cluster completed successfully. It is
+	// represented in RFC3339 form and is in UTC.
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+
 	// Status information for scheduled backups
 	// +optional
 	ScheduledBackups []PGBackRestScheduledBackupStatus `json:"scheduledBackups,omitempty"`
@@ -249,6 +320,21 @@ type PGBackRestRepo struct {
 	// +optional
 	BackupSchedules *PGBackRestBackupSchedules `json:"schedules,omitempty"`
 
+	// Defines a scheduled test that restores the most recent backup in the repository
+	// +optional
+	RestoreTest *PGBackRestRestoreTest `json:"restoreTest,omitempty"`
+
+	// Defines how long backups and WAL archives are kept in the repository. This takes
+	// the place of "retention" options for the repository in global.
+	// +optional
+	Retention *PGBackRestRetention `json:"retention,omitempty"`
+
+	// Defines how the backups and WAL archives in the repository are encrypted. This takes
+	// the place of "cipher" options for the repository in global. Encryption cannot change
+	// once the repository holds backups.
+	// +optional
+	Encryption *PGBackRestRepoEncryption `json:"encryption,omitempty"`
+
 	// Represents a pgBackRest repository that is created using Azure storage
 	// +optional
 	Azure *RepoAzure `json:"azure,omitempty"`
@@ -267,6 +353,76 @@ type PGBackRestRepo struct {
 	Volume *RepoPVC `json:"volume,omitempty"`
 }
 
+// PGBackRestRepoEncryption defines how pgBackRest encrypts the files in a repository.
+// More info: https://pgbackrest.org/user-guide.html#quickstart/configure-encryption
+type PGBackRestRepoEncryption struct {
+
+	// The cipher used to encrypt the repository.
+	// +kubebuilder:validation:Enum={aes-256-cbc}
+	// +kubebuilder:default=aes-256-cbc
+	// +optional
+	CipherType string `json:"cipherType,omitempty"`
+
+	// A key in a Secret that holds the passphrase of the repository. When omitted, a
+	// passphrase is generated and stored in a Secret that belongs to the cluster.
+	// +optional
+	CipherPassSecret *corev1.SecretKeySelector `json:"cipherPassSecret,omitempty"`
+}
+
+// PGBackRestRetention defines how long pgBackRest keeps the backups and WAL archives of a
+// repository. Expired backups are removed after each backup.
+// More info: https://pgbackrest.org/user-guide.html#retention
+type PGBackRestRetention struct {
+
+	// The number of full backups to keep, or the number of days to keep them when
+	// fullType is "time".
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Full *int32 `json:"full,omitempty"`
+
+	// Whether full counts backups or days. Defaults to "count".
+	// +optional
+	// +kubebuilder:validation:Enum={count,time}
+	FullType string `json:"fullType,omitempty"`
+
+	// The number of differential backups to keep.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Differential *int32 `json:"differential,omitempty"`
+
+	// The number of backups of archiveType for which WAL archives are kept. Older WAL
+	// is removed, so only the most recent backups can be recovered to a point in time.
+	// Defaults to keeping the WAL archives of every backup that is kept.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Archive *int32 `json:"archive,omitempty"`
+
+	// The type of backup that archive counts. Defaults to "full".
+	// +optional
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	ArchiveType string `json:"archiveType,omitempty"`
+}
+
+// PGBackRestLastBackups identifies the most recent backup of each type in a repository by
+// its pgBackRest label.
+type PGBackRestLastBackups struct {
+
+	// The label of the most recent full backup.
+	// +optional
+	Full string `json:"full,omitempty"`
+
+	// The label of the most recent differential backup.
+	// +optional
+	Differential string `json:"differential,omitempty"`
+
+	// The label of the most recent incremental backup.
+	// +optional
+	Incremental string `json:"incremental,omitempty"`
+}
+
 // RepoHostStatus defines the status of a pgBackRest repository host
 type RepoHostStatus struct {
 	metav1.TypeMeta `json:",inline"`
@@ -279,6 +435,11 @@ type RepoHostStatus struct {
 // RepoPVC represents a pgBackRest repository that is created using a PersistentVolumeClaim
 type RepoPVC struct {
 
+	// Grow the repository volume as it fills. The storage request in
+	// volumeClaimSpec is the smallest size of the volume.
+	// +optional
+	AutoGrow *VolumeAutoGrowSpec `json:"autoGrow,omitempty"`
+
 	// Defines a PersistentVolumeClaim spec used to create and/or bind a volume
 	// +kubebuilder:validation:Required
 	VolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec"`
@@ -340,6 +501,42 @@ type RepoStatus struct {
 	// to bootstrap replicas.
 	ReplicaCreateBackupComplete bool `json:"replicaCreateBackupComplete,omitempty"`
 
+	// How long backups and WAL archives are kept in the repository, whether it is defined by
+	// its retention or by options in global.
+	// +optional
+	Retention *PGBackRestRetention `json:"retention,omitempty"`
+
+	// The cipher that encrypts the repository, if any. This can differ from the spec when
+	// the encryption of a repository that holds backups was changed.
+	// +optional
+	CipherType string `json:"cipherType,omitempty"`
+
+	// The 20 most recent backups in the repository as reported by "pgbackrest info",
+	// oldest first.
+	// +optional
+	Backups []PGBackRestBackupInfo `json:"backups,omitempty"`
+
+	// The most recent backup of each type in the repository.
+	// +optional
+	LastBackups *PGBackRestLastBackups `json:"lastBackups,omitempty"`
+
+	// The oldest WAL segment archived in the repository.
+	// +optional
+	WALArchiveMin string `json:"walArchiveMin,omitempty"`
+
+	// The newest WAL segment archived in the repository.
+	// +optional
+	WALArchiveMax string `json:"walArchiveMax,omitempty"`
+
+	// The last time the backups in the repository were read using "pgbackrest info". It
+	// is represented in RFC3339 form and is in UTC.
+	// +optional
+	InfoTime *metav1.Time `json:"infoTime,omitempty"`
+
+	// The result of the most recent restore test of the repository that finished.
+	// +optional
+	RestoreTest *PGBackRestRestoreTestStatus `json:"restoreTest,omitempty"`
+
 	// A hash of the required fields in the spec for defining an Azure, GCS or S3 repository,
 	// Utilizd to detect changes to these fields and then execute pgBackRest stanza-create
 	// commands accordingly.
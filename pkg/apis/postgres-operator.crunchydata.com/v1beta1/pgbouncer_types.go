@@ -100,6 +100,10 @@ type PGBouncerPodSpec struct {
 	// +kubebuilder:validation:Minimum=0
 	Replicas *int32 `json:"replicas,omitempty"`
 
+	// Specification of the Service that exposes PgBouncer.
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
 	// Compute resources of a PgBouncer container. Changing this value causes
 	// PgBouncer to restart.
 	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers
@@ -123,6 +127,10 @@ func (s *PGBouncerPodSpec) Default() {
 		s.Replicas = new(int32)
 		*s.Replicas = 1
 	}
+
+	if s.Service != nil {
+		s.Service.Default()
+	}
 }
 
 type PGBouncerPodStatus struct {
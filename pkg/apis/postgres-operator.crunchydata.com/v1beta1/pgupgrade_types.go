@@ -0,0 +1,97 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PGUpgradeSpec defines a major PostgreSQL upgrade performed in-place using pg_upgrade.
+type PGUpgradeSpec struct {
+
+	// Whether or not major PostgreSQL upgrades are enabled for this PostgresCluster. When
+	// enabled, changing postgresVersion upgrades the data directory of the cluster from
+	// fromPostgresVersion to postgresVersion.
+	// +kubebuilder:default=false
+	Enabled *bool `json:"enabled"`
+
+	// The major version of PostgreSQL currently stored in the data directory of the cluster.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=13
+	FromPostgresVersion int `json:"fromPostgresVersion"`
+
+	// The image name to use for the pg_upgrade Job. The image must contain the binaries of
+	// both the current and the target PostgreSQL versions. The image may also be set using
+	// the RELATED_IMAGE_PGUPGRADE environment variable.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// The name of the pgBackRest repo that stores the full backup taken before the upgrade
+	// begins. Defaults to the first repo defined in the spec.
+	// +kubebuilder:validation:Pattern=^repo[1-4]
+	// +optional
+	RepoName string `json:"repoName,omitempty"`
+
+	// Resource requirements for the pg_upgrade Job.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Scheduling constraints of the pg_upgrade Job.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Tolerations of the pg_upgrade Job.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// PGUpgradeStatus defines the status of a major PostgreSQL upgrade.
+type PGUpgradeStatus struct {
+
+	// The major version of PostgreSQL the cluster is being upgraded from.
+	// +kubebuilder:validation:Required
+	FromPostgresVersion int `json:"fromPostgresVersion"`
+
+	// The major version of PostgreSQL the cluster is being upgraded to.
+	// +kubebuilder:validation:Required
+	ToPostgresVersion int `json:"toPostgresVersion"`
+
+	// The instance that was primary when the upgrade began, and whose data directory is
+	// upgraded. All other instances are recreated from it once the upgrade completes.
+	// +optional
+	PrimaryInstance string `json:"primaryInstance,omitempty"`
+
+	// The instance set associated with the primaryInstance.
+	// +optional
+	PrimaryInstanceSet string `json:"primaryInstanceSet,omitempty"`
+
+	// Specifies whether or not the upgrade is finished.
+	// +kubebuilder:validation:Required
+	Finished bool `json:"finished"`
+
+	// Represents the time the upgrade began. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents the time the upgrade finished successfully. It is represented in RFC3339
+	// form and is in UTC.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
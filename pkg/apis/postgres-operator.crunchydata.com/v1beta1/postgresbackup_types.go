@@ -0,0 +1,158 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresBackupSpec defines a single pgBackRest backup of a PostgresCluster. Changes to the
+// spec are ignored once the backup Job has been created.
+type PostgresBackupSpec struct {
+
+	// The name of the PostgresCluster to back up. The PostgresCluster must be in the same
+	// namespace as the PostgresBackup.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	PostgresCluster string `json:"postgresCluster"`
+
+	// The name of the pgBackRest repo to store the backup in. The repo must be defined in the
+	// spec of the PostgresCluster.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^repo[1-4]
+	RepoName string `json:"repoName"`
+
+	// The type of backup to take. When omitted, pgBackRest takes an incremental backup, or a
+	// full backup when no prior backup exists in the repo.
+	// More info: https://pgbackrest.org/command.html#command-backup/category-command/option-type
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Command line options to include when running the pgBackRest backup command. The repo and
+	// type of the backup are set using the repoName and type fields, not these options.
+	// https://pgbackrest.org/command.html#command-backup
+	// +optional
+	Options []string `json:"options,omitempty"`
+}
+
+// PostgresBackupStatus defines the observed state of a PostgresBackup.
+type PostgresBackupStatus struct {
+
+	// The name of the Job that runs the backup.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Specifies whether or not the backup Job is finished executing (does not indicate success
+	// or failure).
+	// +optional
+	Finished bool `json:"finished,omitempty"`
+
+	// Represents the time the backup Job was acknowledged by the Job controller.
+	// It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents the time the backup Job was determined by the Job controller to be completed.
+	// This field is only set if the backup completed successfully. Additionally, it is
+	// represented in RFC3339 form and is in UTC.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// The number of actively running backup Pods.
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// The number of Pods for the backup Job that reached the "Succeeded" phase.
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// The number of Pods for the backup Job that reached the "Failed" phase.
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+
+	// The backup as reported by pgBackRest once the backup Job completes successfully.
+	// +optional
+	Backup *PGBackRestBackupInfo `json:"backup,omitempty"`
+}
+
+// PGBackRestBackupInfo describes a backup stored in a pgBackRest repo.
+type PGBackRestBackupInfo struct {
+
+	// The label pgBackRest uses to identify the backup, e.g. when restoring with the "--set"
+	// option.
+	// +kubebuilder:validation:Required
+	Label string `json:"label"`
+
+	// The type of the backup: full, diff or incr.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// The size of the database in bytes at the time of the backup.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// The size of the backup in the repo in bytes, after compression.
+	// +optional
+	RepoSize int64 `json:"repoSize,omitempty"`
+
+	// The first WAL segment needed to make the backup consistent.
+	// +optional
+	WALStart string `json:"walStart,omitempty"`
+
+	// The last WAL segment needed to make the backup consistent.
+	// +optional
+	WALStop string `json:"walStop,omitempty"`
+
+	// The time pgBackRest started the backup. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time pgBackRest finished the backup. It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StopTime *metav1.Time `json:"stopTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.postgresCluster`
+// +kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repoName`
+// +kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.status.backup.label`
+// +kubebuilder:printcolumn:name="Finished",type=boolean,JSONPath=`.status.finished`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +operator-sdk:csv:customresourcedefinitions:resources={{Job,v1}}
+
+// PostgresBackup is the Schema for the postgresbackups API
+type PostgresBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresBackupSpec   `json:"spec,omitempty"`
+	Status PostgresBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresBackupList contains a list of PostgresBackup
+type PostgresBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresBackup{}, &PostgresBackupList{})
+}
@@ -21,12 +21,489 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/yaml"
 )
 
 func TestPostgresClusterWebhooks(t *testing.T) {
 	var _ webhook.Defaulter = new(PostgresCluster)
+	var _ webhook.Validator = new(PostgresCluster)
+}
+
+func TestPostgresClusterValidate(t *testing.T) {
+	valid := func() *PostgresCluster {
+		cluster := new(PostgresCluster)
+		cluster.Name = "hippo"
+		cluster.Spec.PostgresVersion = 13
+		cluster.Spec.InstanceSets = []PostgresInstanceSetSpec{{Name: "one"}, {}}
+		cluster.Spec.Backups.PGBackRest.Repos = []PGBackRestRepo{{
+			Name: "repo1", Volume: &RepoPVC{},
+		}, {
+			Name: "repo2", S3: &RepoS3{},
+		}}
+		return cluster
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Standby = &PostgresStandbySpec{Enabled: true, RepoName: "repo2"}
+		cluster.Spec.DataSource = &DataSource{
+			PostgresCluster: &PostgresClusterDataSource{RepoName: "repo1"},
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+		assert.NilError(t, cluster.ValidateUpdate(valid()))
+		assert.NilError(t, cluster.ValidateDelete())
+	})
+
+	t.Run("DuplicateInstanceSets", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.InstanceSets = []PostgresInstanceSetSpec{{Name: "01"}, {}}
+
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, `spec.instances[1].name: Duplicate value: "01"`)
+	})
+
+	t.Run("RepoWithoutStorage", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Backups.PGBackRest.Repos = append(
+			cluster.Spec.Backups.PGBackRest.Repos, PGBackRestRepo{Name: "repo3"})
+
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.repos[2]: Required value")
+	})
+
+	t.Run("StandbyRepoName", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Standby = &PostgresStandbySpec{Enabled: true, RepoName: "repo4"}
+
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, `spec.standby.repoName: Not found: "repo4"`)
+
+		cluster.Spec.Standby.Enabled = false
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("StandbyHost", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Standby = &PostgresStandbySpec{Enabled: true}
+
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.standby: Required")
+
+		cluster.Spec.Standby.Port = new(int32)
+		cluster.Spec.Standby.PrimarySlotName = "hippo"
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.standby.port: Forbidden")
+		assert.ErrorContains(t, err, "spec.standby.primarySlotName: Forbidden")
+
+		// Streaming needs certificates that the remote host trusts.
+		cluster.Spec.Standby.Host = "rhino-primary.west.svc"
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.customTLSSecret: Required")
+		assert.ErrorContains(t, err, "spec.customReplicationTLSSecret: Required")
+
+		cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{}
+		cluster.Spec.CustomReplicationClientTLSSecret = &corev1.SecretProjection{}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// A repository can be followed at the same time.
+		cluster.Spec.Standby.RepoName = "repo2"
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("StandbyDemotion", func(t *testing.T) {
+		previous := valid()
+		previous.Status.Patroni = &PatroniStatus{SystemIdentifier: "6952526174828511264"}
+
+		cluster := previous.DeepCopy()
+		cluster.Spec.Standby = &PostgresStandbySpec{Enabled: true, RepoName: "repo2"}
+
+		// pg_rewind cannot read from a repository.
+		err := cluster.ValidateUpdate(previous)
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.standby.host: Required")
+
+		cluster.Spec.Standby.Host = "rhino-primary.west.svc"
+		cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{}
+		cluster.Spec.CustomReplicationClientTLSSecret = &corev1.SecretProjection{}
+		assert.NilError(t, cluster.ValidateUpdate(previous))
+
+		// A cluster that has not started can follow a repository.
+		previous.Status.Patroni = nil
+		cluster.Spec.Standby.Host = ""
+		assert.NilError(t, cluster.ValidateUpdate(previous))
+	})
+
+	t.Run("DataSourceRepoName", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.DataSource = &DataSource{
+			PostgresCluster: &PostgresClusterDataSource{RepoName: "repo3"},
+		}
+
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err,
+			`spec.dataSource.postgresCluster.repoName: Not found: "repo3"`)
+
+		// The repositories of another cluster are not checked.
+		cluster.Spec.DataSource.PostgresCluster.ClusterName = "rhino"
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("PostgresVersion", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.PostgresVersion = 12
+
+		err := cluster.ValidateUpdate(valid())
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.postgresVersion: Forbidden")
+	})
+
+	t.Run("MetadataOnly", func(t *testing.T) {
+		previous := valid()
+		previous.Spec.InstanceSets = []PostgresInstanceSetSpec{{Name: "01"}, {}}
+		assert.Assert(t, previous.ValidateCreate() != nil)
+
+		cluster := previous.DeepCopy()
+		cluster.Finalizers = nil
+		assert.NilError(t, cluster.ValidateUpdate(previous),
+			"expected an invalid spec that does not change to be allowed")
+
+		cluster.Spec.PostgresVersion = 12
+		assert.Assert(t, apierrors.IsInvalid(cluster.ValidateUpdate(previous)))
+
+		now := metav1.Now()
+		cluster.DeletionTimestamp = &now
+		assert.NilError(t, cluster.ValidateUpdate(previous),
+			"expected a deleted cluster to be allowed")
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		previous := valid()
+		previous.Spec.PostgresVersion = 12
+
+		enabled, disabled := true, false
+		cluster := valid()
+		cluster.Spec.Upgrade = &PGUpgradeSpec{
+			Enabled: &enabled, FromPostgresVersion: 12, RepoName: "repo2",
+		}
+		assert.NilError(t, cluster.ValidateUpdate(previous))
+
+		// The upgrade must start from the version stored in the data directory.
+		cluster.Spec.Upgrade.FromPostgresVersion = 11
+		err := cluster.ValidateUpdate(previous)
+		assert.ErrorContains(t, err, "spec.postgresVersion: Forbidden")
+
+		// Downgrades are not possible.
+		cluster.Spec.Upgrade.FromPostgresVersion = 14
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.upgrade.fromPostgresVersion: Invalid")
+
+		cluster.Spec.Upgrade.FromPostgresVersion = 12
+		cluster.Spec.Upgrade.RepoName = "repo3"
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.upgrade.repoName: Not found")
+
+		// Nothing is checked when upgrades are disabled.
+		cluster.Spec.Upgrade.Enabled = &disabled
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("Service", func(t *testing.T) {
+		nodePort := int32(30432)
+		cluster := valid()
+		cluster.Spec.Service = &ServiceSpec{
+			Type: "LoadBalancer", NodePort: &nodePort, ExternalTrafficPolicy: "Local",
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		}
+		cluster.Spec.Proxy = &PostgresProxySpec{PGBouncer: &PGBouncerPodSpec{
+			Service: &ServiceSpec{Type: "NodePort", NodePort: &nodePort},
+		}}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// Some fields apply only to some types.
+		cluster.Spec.Service.Type = "NodePort"
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.service.loadBalancerSourceRanges: Forbidden")
+
+		cluster.Spec.Service = &ServiceSpec{ExternalTrafficPolicy: "Local"}
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.service.externalTrafficPolicy: Forbidden")
+
+		cluster.Spec.Service = nil
+		cluster.Spec.Proxy.PGBouncer.Service.Type = "ClusterIP"
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.proxy.pgBouncer.service.nodePort: Forbidden")
+	})
+
+	t.Run("Synchronous", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Patroni = &PatroniSpec{Synchronous: &PatroniSynchronous{}}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// There are two instances by default.
+		standbys := int32(2)
+		cluster.Spec.Patroni.Synchronous.NumberOfStandbys = &standbys
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.patroni.synchronous.numberOfStandbys: Invalid")
+
+		cluster.Spec.Patroni.Synchronous.Mode = "off"
+		assert.NilError(t, cluster.ValidateCreate())
+
+		replicas := int32(3)
+		cluster.Spec.Patroni.Synchronous.Mode = "strict"
+		cluster.Spec.InstanceSets[0].Replicas = &replicas
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("Switchover", func(t *testing.T) {
+		one, two, instance := "one", "two", "hippo-one-abcd"
+		cluster := valid()
+		cluster.Spec.Patroni = &PatroniSpec{Switchover: &PatroniSwitchover{Enabled: true}}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// A failover needs a target.
+		cluster.Spec.Patroni.Switchover.Type = "Failover"
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.patroni.switchover.targetInstance: Required")
+
+		cluster.Spec.Patroni.Switchover.TargetInstanceSet = &one
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// The instance set must exist.
+		cluster.Spec.Patroni.Switchover.TargetInstanceSet = &two
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.patroni.switchover.targetInstanceSet: Not found")
+
+		// Only one kind of target is allowed.
+		cluster.Spec.Patroni.Switchover.TargetInstanceSet = &one
+		cluster.Spec.Patroni.Switchover.TargetInstance = &instance
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.patroni.switchover.targetInstanceSet: Forbidden")
+	})
+
+	t.Run("InstanceParameters", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.InstanceSets[0].Config = &PostgresInstanceConfiguration{
+			AutoTune:   true,
+			Parameters: map[string]string{"work_mem": "64MB"},
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// Some parameters must be the same on every instance.
+		cluster.Spec.InstanceSets[0].Config.Parameters["Max_Connections"] = "200"
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err,
+			"spec.instances[0].config.parameters[Max_Connections]: Forbidden")
+	})
+
+	t.Run("FailoverRole", func(t *testing.T) {
+		one := "one"
+		cluster := valid()
+		cluster.Spec.InstanceSets[0].Patroni = &PatroniInstanceSetSpec{FailoverRole: "Replica"}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// Some instance set must be able to become primary.
+		cluster.Spec.InstanceSets[1].Patroni = &PatroniInstanceSetSpec{FailoverRole: "Replica"}
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.instances[0].patroni.failoverRole: Invalid")
+
+		// Instances that cannot become primary are not switchover targets.
+		cluster.Spec.InstanceSets[1].Patroni = nil
+		cluster.Spec.Patroni = &PatroniSpec{Switchover: &PatroniSwitchover{
+			Enabled: true, TargetInstanceSet: &one,
+		}}
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.patroni.switchover.targetInstanceSet: Invalid")
+
+		// Nor are they synchronous standbys.
+		standbys := int32(1)
+		cluster.Spec.Patroni = &PatroniSpec{Synchronous: &PatroniSynchronous{
+			NumberOfStandbys: &standbys,
+		}}
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.patroni.synchronous.numberOfStandbys: Invalid")
+	})
+
+	t.Run("VolumeAutoGrow", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.InstanceSets[0].DataVolumeClaimSpec.Resources.Requests =
+			corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+		cluster.Spec.InstanceSets[0].DataVolumeAutoGrow = &VolumeAutoGrowSpec{
+			Increment: "5Gi", Maximum: resource.MustParse("10Gi"),
+		}
+		cluster.Spec.Backups.PGBackRest.Repos[0].Volume.AutoGrow = &VolumeAutoGrowSpec{
+			Maximum: resource.MustParse("1Ti"),
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		cluster.Spec.InstanceSets[0].DataVolumeAutoGrow.Increment = "lots"
+		cluster.Spec.InstanceSets[0].DataVolumeAutoGrow.Maximum = resource.MustParse("512Mi")
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.instances[0].dataVolumeAutoGrow.increment: Invalid")
+		assert.ErrorContains(t, err, "spec.instances[0].dataVolumeAutoGrow.maximum: Invalid")
+
+		// There is no WAL volume to grow.
+		cluster = valid()
+		cluster.Spec.InstanceSets[1].WALVolumeAutoGrow = &VolumeAutoGrowSpec{
+			Maximum: resource.MustParse("10Gi"),
+		}
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.instances[1].walVolumeAutoGrow: Forbidden")
+	})
+
+	t.Run("VolumeSnapshotName", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.DataSource = &DataSource{
+			PostgresCluster: &PostgresClusterDataSource{
+				RepoName: "repo1", VolumeSnapshotName: "rhino-pgdata-abc",
+			},
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		cluster.Spec.DataSource.PostgresCluster.Options = []string{"--type=immediate"}
+		cluster.Spec.Backups.PGBackRest.Restore = &PGBackRestRestore{
+			PostgresClusterDataSource: &PostgresClusterDataSource{
+				RepoName: "repo1", VolumeSnapshotName: "hippo-pgdata-abc",
+			},
+		}
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.dataSource.postgresCluster.options: Forbidden")
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.restore.volumeSnapshotName: Forbidden")
+	})
+
+	t.Run("BootstrapReplicas", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Backups.Snapshots = &VolumeSnapshots{
+			VolumeSnapshotClassName: "csi", BootstrapReplicas: true,
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		cluster.Spec.InstanceSets[1].WALVolumeClaimSpec = &corev1.PersistentVolumeClaimSpec{}
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.backups.snapshots.bootstrapReplicas: Forbidden")
+
+		cluster.Spec.Backups.Snapshots.BootstrapReplicas = false
+		assert.NilError(t, cluster.ValidateCreate())
+	})
+
+	t.Run("DataSourceVolumes", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.DataSource = &DataSource{Volumes: &DataSourceVolumes{
+			PGDataVolume:     &DataSourceVolume{PVCName: "old-pgdata"},
+			PGBackRestVolume: &DataSourceVolume{PVCName: "old-repo1"},
+		}}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// The instance set that starts on the data volume has no WAL volume.
+		cluster.Spec.DataSource.Volumes.PGWALVolume = &DataSourceVolume{PVCName: "old-pgwal"}
+		cluster.Spec.DataSource.PostgresCluster = &PostgresClusterDataSource{RepoName: "repo1"}
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.dataSource.volumes: Forbidden")
+		assert.ErrorContains(t, err, "spec.dataSource.volumes.pgWALVolume: Forbidden")
+
+		cluster.Spec.DataSource.PostgresCluster = nil
+		cluster.Spec.InstanceSets[0].WALVolumeClaimSpec = &corev1.PersistentVolumeClaimSpec{}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		// There is no repository volume to adopt.
+		cluster.Spec.Backups.PGBackRest.Repos = cluster.Spec.Backups.PGBackRest.Repos[1:]
+		err = cluster.ValidateCreate()
+		assert.ErrorContains(t, err, "spec.dataSource.volumes.pgBackRestVolume: Forbidden")
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		full := int32(2)
+		cluster := valid()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"repo2-retention-full": "5",
+			"compress-type":        "lz4",
+		}
+		cluster.Spec.Backups.PGBackRest.Repos[0].Retention = &PGBackRestRetention{
+			Full: &full, FullType: "time",
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		cluster.Spec.Backups.PGBackRest.Repos[0].Retention = &PGBackRestRetention{
+			FullType: "time", ArchiveType: "diff",
+		}
+		cluster.Spec.Backups.PGBackRest.Global["repo1-retention-diff"] = "1"
+		cluster.Spec.Backups.PGBackRest.Global["repo3-retention-full"] = "1"
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.repos[0].retention.full: Required")
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.repos[0].retention.archive: Required")
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.global[repo1-retention-diff]: Forbidden")
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.global[repo3-retention-full]: Invalid")
+	})
+
+	t.Run("Encryption", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"repo2-cipher-type": "aes-256-cbc",
+		}
+		cluster.Spec.Backups.PGBackRest.Repos[0].Encryption = &PGBackRestRepoEncryption{
+			CipherPassSecret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "cipher"}, Key: "pass",
+			},
+		}
+		assert.NilError(t, cluster.ValidateCreate())
+
+		cluster.Spec.Backups.PGBackRest.Repos[1].Encryption = &PGBackRestRepoEncryption{
+			CipherPassSecret: &corev1.SecretKeySelector{Key: "pass"},
+		}
+		err := cluster.ValidateCreate()
+		assert.Assert(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.repos[1].encryption.cipherPassSecret.name: Required")
+		assert.ErrorContains(t, err, "spec.backups.pgbackrest.global[repo2-cipher-type]: Forbidden")
+
+		t.Run("Update", func(t *testing.T) {
+			previous := valid()
+			previous.Status.PGBackRest = &PGBackRestStatus{Repos: []RepoStatus{
+				{Name: "repo1", StanzaCreated: true}, {Name: "repo2", StanzaCreated: false},
+			}}
+
+			cluster := valid()
+			cluster.Spec.Backups.PGBackRest.Repos[1].Encryption = &PGBackRestRepoEncryption{}
+			assert.NilError(t, cluster.ValidateUpdate(previous),
+				"expected a repository without a stanza to change")
+
+			cluster.Spec.Backups.PGBackRest.Repos[0].Encryption = &PGBackRestRepoEncryption{}
+			err := cluster.ValidateUpdate(previous)
+			assert.Assert(t, apierrors.IsInvalid(err))
+			assert.ErrorContains(t, err, "spec.backups.pgbackrest.repos[0].encryption: Forbidden")
+		})
+	})
+
+	t.Run("DeletionProtection", func(t *testing.T) {
+		cluster := valid()
+		cluster.Spec.DeletionProtection = true
+		assert.NilError(t, cluster.ValidateCreate())
+
+		err := cluster.ValidateDelete()
+		assert.Assert(t, apierrors.IsForbidden(err))
+		assert.ErrorContains(t, err, "spec.deletionProtection: Forbidden")
+
+		cluster.Spec.DeletionProtection = false
+		assert.NilError(t, cluster.ValidateDelete())
+	})
 }
 
 func TestPostgresClusterDefault(t *testing.T) {
@@ -52,6 +529,7 @@ metadata:
 spec:
   backups:
     pgbackrest: {}
+  deletionPolicy: Delete
   instances: null
   patroni:
     dynamicConfiguration: null
@@ -82,6 +560,7 @@ metadata:
 spec:
   backups:
     pgbackrest: {}
+  deletionPolicy: Delete
   instances:
   - dataVolumeClaimSpec:
       resources: {}
@@ -124,6 +603,52 @@ pgBouncer:
   config: {}
   port: 5432
   replicas: 1
+  resources: {}
+		`)+"\n")
+	})
+
+	t.Run("Service", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.Service = new(ServiceSpec)
+		cluster.Spec.Proxy = &PostgresProxySpec{PGBouncer: &PGBouncerPodSpec{
+			Service: &ServiceSpec{Type: "LoadBalancer"},
+		}}
+		cluster.Default()
+
+		assert.Equal(t, cluster.Spec.Service.Type, "ClusterIP")
+		assert.Equal(t, cluster.Spec.Proxy.PGBouncer.Service.Type, "LoadBalancer")
+	})
+
+	t.Run("Synchronous", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.Patroni = &PatroniSpec{Synchronous: &PatroniSynchronous{}}
+		cluster.Default()
+
+		assert.Equal(t, cluster.Spec.Patroni.Synchronous.Mode, "on")
+		assert.Equal(t, *cluster.Spec.Patroni.Synchronous.NumberOfStandbys, int32(1))
+	})
+
+	t.Run("Switchover", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.Patroni = &PatroniSpec{Switchover: &PatroniSwitchover{Enabled: true}}
+		cluster.Default()
+
+		assert.Equal(t, cluster.Spec.Patroni.Switchover.Type, "Switchover")
+	})
+
+	t.Run("pgAdmin user interface", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.UserInterface = &PostgresUserInterfaceSpec{PGAdmin: &PGAdminPodSpec{}}
+		cluster.Default()
+
+		b, err := yaml.Marshal(cluster.Spec.UserInterface)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, string(b), strings.TrimSpace(`
+pgAdmin:
+  dataVolumeClaimSpec:
+    resources: {}
+  port: 5050
+  replicas: 1
   resources: {}
 		`)+"\n")
 	})
@@ -142,6 +667,55 @@ name: "05"
 replicas: 1
 resources: {}
 	`)+"\n")
+
+	spec.Patroni = &PatroniInstanceSetSpec{}
+	spec.Default(5)
+	assert.Equal(t, spec.Patroni.FailoverRole, "Candidate")
+}
+
+func TestPatroniInstanceSetSpecFailover(t *testing.T) {
+	var spec *PatroniInstanceSetSpec
+	assert.Assert(t, spec.FailoverCandidate())
+	assert.Equal(t, spec.GetFailoverPriority(), int32(0))
+
+	spec = &PatroniInstanceSetSpec{FailoverPriority: 5}
+	assert.Assert(t, spec.FailoverCandidate())
+	assert.Equal(t, spec.GetFailoverPriority(), int32(5))
+
+	spec.FailoverRole = "Replica"
+	assert.Assert(t, !spec.FailoverCandidate())
+}
+
+func TestVolumeAutoGrowSpec(t *testing.T) {
+	var spec *VolumeAutoGrowSpec
+	assert.Equal(t, spec.GetThreshold(), int32(80))
+
+	spec = &VolumeAutoGrowSpec{Maximum: resource.MustParse("2Gi")}
+	next, err := spec.NextRequest(resource.MustParse("1Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, next.String(), "1280Mi")
+
+	// Percentages round up to a whole mebibyte.
+	spec.Increment = "10%"
+	next, err = spec.NextRequest(resource.MustParse("1G"))
+	assert.NilError(t, err)
+	assert.Equal(t, next.Value(), int64(1000000000+96*1024*1024))
+
+	// Requests do not grow beyond the maximum.
+	spec.Increment = "5Gi"
+	next, err = spec.NextRequest(resource.MustParse("1Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, next.String(), "2Gi")
+
+	next, err = spec.NextRequest(resource.MustParse("3Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, next.String(), "3Gi")
+
+	for _, increment := range []string{"0%", "-1Gi", "x%", "lots"} {
+		spec.Increment = increment
+		_, err = spec.NextRequest(resource.MustParse("1Gi"))
+		assert.ErrorContains(t, err, increment)
+	}
 }
 
 func TestMetadataGetLabels(t *testing.T) {
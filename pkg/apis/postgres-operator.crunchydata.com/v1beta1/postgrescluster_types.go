@@ -50,6 +50,23 @@ type PostgresClusterSpec struct {
 	// +optional
 	DataSource *DataSource `json:"dataSource,omitempty"`
 
+	// What happens to the volumes and backups of the cluster when it is deleted.
+	// "Delete" removes its PostgreSQL data volumes and pgBackRest repository
+	// volumes. "Retain" keeps them so that a new cluster with the same name can
+	// use them. "Snapshot" takes a full pgBackRest backup in the first repository
+	// before the cluster stops, and keeps the repository volumes.
+	// Defaults to "Delete".
+	// +optional
+	// +kubebuilder:default=Delete
+	// +kubebuilder:validation:Enum={Delete,Retain,Snapshot}
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Whether or not the cluster is protected from deletion. While this is true,
+	// requests to delete the cluster are refused, and a cluster that is already
+	// being deleted keeps running.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
 	// PostgreSQL backup configuration
 	// +kubebuilder:validation:Required
 	Backups Backups `json:"backups"`
@@ -128,6 +145,14 @@ type PostgresClusterSpec struct {
 	// +optional
 	Proxy *PostgresProxySpec `json:"proxy,omitempty"`
 
+	// The specification of the Service that resolves to PostgreSQL replicas.
+	// +optional
+	ReplicaService *PostgresReplicaServiceSpec `json:"replicaService,omitempty"`
+
+	// Specification of the Service that exposes the PostgreSQL primary instance.
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
 	// The specification of monitoring tools that connect to PostgreSQL
 	// +optional
 	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
@@ -143,6 +168,14 @@ type PostgresClusterSpec struct {
 	// +optional
 	Standby *PostgresStandbySpec `json:"standby,omitempty"`
 
+	// Upgrade the major version of PostgreSQL in-place using pg_upgrade.
+	// +optional
+	Upgrade *PGUpgradeSpec `json:"upgrade,omitempty"`
+
+	// The specification of a user interface that connects to PostgreSQL.
+	// +optional
+	UserInterface *PostgresUserInterfaceSpec `json:"userInterface,omitempty"`
+
 	// Users to create inside PostgreSQL and the databases they should access.
 	// The default creates one user that can access one database matching the
 	// PostgresCluster name. An empty list creates no users. Removing a user
@@ -159,6 +192,42 @@ type DataSource struct {
 	// directory for a new PostgreSQL cluster using a pgBackRest restore.
 	// +optional
 	PostgresCluster *PostgresClusterDataSource `json:"postgresCluster,omitempty"`
+
+	// Defines existing PersistentVolumeClaims that the new PostgreSQL cluster adopts and
+	// starts on without a restore. The claims may be retained from a deleted
+	// PostgresCluster or copied from another namespace.
+	// +optional
+	Volumes *DataSourceVolumes `json:"volumes,omitempty"`
+}
+
+// DataSourceVolumes defines existing PersistentVolumeClaims to adopt when initializing a
+// new PostgresCluster. The claims must be in the namespace of the PostgresCluster and
+// must not belong to another object.
+type DataSourceVolumes struct {
+
+	// The PostgreSQL data directory. Its PostgreSQL version must match postgresVersion.
+	// The first instance of the first instance set that is a failover candidate starts on it.
+	// +kubebuilder:validation:Required
+	PGDataVolume *DataSourceVolume `json:"pgDataVolume"`
+
+	// The PostgreSQL WAL directory of the data directory in pgDataVolume. Requires
+	// walVolumeClaimSpec in the instance set that starts on pgDataVolume.
+	// +optional
+	PGWALVolume *DataSourceVolume `json:"pgWALVolume,omitempty"`
+
+	// The contents of a pgBackRest repository volume. It is adopted by the first repo
+	// that defines a volume, and its backups must be of the data directory in pgDataVolume.
+	// +optional
+	PGBackRestVolume *DataSourceVolume `json:"pgBackRestVolume,omitempty"`
+}
+
+// DataSourceVolume refers to an existing PersistentVolumeClaim.
+type DataSourceVolume struct {
+
+	// The name of the PersistentVolumeClaim.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	PVCName string `json:"pvcName"`
 }
 
 // PostgresClusterDataSource defines a data source for bootstrapping PostgreSQL clusters using a
@@ -187,6 +256,14 @@ type PostgresClusterDataSource struct {
 	// +optional
 	Options []string `json:"options,omitempty"`
 
+	// The name of a VolumeSnapshot of a data volume of the source PostgresCluster in
+	// the namespace of the new PostgresCluster. When set, the data directory is cloned
+	// from this snapshot and WAL from repoName is replayed on top of it instead of
+	// restoring a backup. The storage request of the data volume must be at least the
+	// restore size of the snapshot.
+	// +optional
+	VolumeSnapshotName string `json:"volumeSnapshotName,omitempty"`
+
 	// Resource requirements for the pgBackRest restore Job.
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -203,6 +280,10 @@ type PostgresClusterDataSource struct {
 }
 
 func (s *PostgresClusterSpec) Default() {
+	if s.DeletionPolicy == "" {
+		s.DeletionPolicy = DeletionPolicyDelete
+	}
+
 	for i := range s.InstanceSets {
 		s.InstanceSets[i].Default(i)
 	}
@@ -220,6 +301,14 @@ func (s *PostgresClusterSpec) Default() {
 	if s.Proxy != nil {
 		s.Proxy.Default()
 	}
+
+	if s.Service != nil {
+		s.Service.Default()
+	}
+
+	if s.UserInterface != nil {
+		s.UserInterface.Default()
+	}
 }
 
 // Backups defines a PostgreSQL archive configuration
@@ -228,11 +317,24 @@ type Backups struct {
 	// pgBackRest archive configuration
 	// +kubebuilder:validation:Required
 	PGBackRest PGBackRestArchive `json:"pgbackrest"`
+
+	// CSI VolumeSnapshots of PostgreSQL data volumes. Snapshots are taken of a
+	// replica when the "postgres-operator.crunchydata.com/volume-snapshot"
+	// annotation changes. They do not replace pgBackRest, which provides the
+	// WAL needed to recover from a snapshot.
+	// More info: https://kubernetes.io/docs/concepts/storage/volume-snapshots
+	// +optional
+	Snapshots *VolumeSnapshots `json:"snapshots,omitempty"`
 }
 
 // PostgresClusterStatus defines the observed state of PostgresCluster
 type PostgresClusterStatus struct {
 
+	// The PersistentVolumeClaims adopted from spec.dataSource.volumes, keyed by the
+	// name the operator would otherwise give each claim.
+	// +optional
+	AdoptedVolumes map[string]string `json:"adoptedVolumes,omitempty"`
+
 	// Identifies the databases that have been installed into PostgreSQL.
 	DatabaseRevision string `json:"databaseRevision,omitempty"`
 
@@ -249,10 +351,27 @@ type PostgresClusterStatus struct {
 	// +optional
 	PGBackRest *PGBackRestStatus `json:"pgbackrest,omitempty"`
 
+	// A short summary of the state of the cluster: Initializing, Ready, NotReady, Restoring,
+	// Upgrading or Shutdown. The Ready condition explains why a cluster is not ready.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// The name of the Pod of the current PostgreSQL primary, the Patroni leader.
+	// +optional
+	Primary string `json:"primary,omitempty"`
+
 	// Current state of the PostgreSQL proxy.
 	// +optional
 	Proxy PostgresProxyStatus `json:"proxy,omitempty"`
 
+	// Total number of ready PostgreSQL instances in all instance sets.
+	// +optional
+	ReadyInstances int32 `json:"readyInstances,omitempty"`
+
+	// Replication lag of a standby cluster.
+	// +optional
+	Standby *PostgresStandbyStatus `json:"standby,omitempty"`
+
 	// The instance that should be started first when bootstrapping and/or starting a
 	// PostgresCluster.
 	// +optional
@@ -262,9 +381,21 @@ type PostgresClusterStatus struct {
 	// +optional
 	StartupInstanceSet string `json:"startupInstanceSet,omitempty"`
 
+	// Status information for major PostgreSQL upgrades
+	// +optional
+	Upgrade *PGUpgradeStatus `json:"upgrade,omitempty"`
+
+	// Current state of the PostgreSQL user interface.
+	// +optional
+	UserInterface *PostgresUserInterfaceStatus `json:"userInterface,omitempty"`
+
 	// Identifies the users that have been installed into PostgreSQL.
 	UsersRevision string `json:"usersRevision,omitempty"`
 
+	// Status information for CSI VolumeSnapshots of PostgreSQL data volumes.
+	// +optional
+	VolumeSnapshots *VolumeSnapshotsStatus `json:"volumeSnapshots,omitempty"`
+
 	// Current state of PostgreSQL cluster monitoring tool configuration
 	// +optional
 	Monitoring MonitoringStatus `json:"monitoring,omitempty"`
@@ -275,8 +406,8 @@ type PostgresClusterStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// conditions represent the observations of postgrescluster's current state.
-	// Known .status.conditions.type are: "PersistentVolumeResizing",
-	// "ProxyAvailable"
+	// Known .status.conditions.type are: "PendingRestart",
+	// "PersistentVolumeResizing", "ProxyAvailable", "Ready"
 	// +optional
 	// +listType=map
 	// +listMapKey=type
@@ -284,10 +415,31 @@ type PostgresClusterStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// PostgresClusterSpec deletion policies.
+const (
+	DeletionPolicyDelete   = "Delete"
+	DeletionPolicyRetain   = "Retain"
+	DeletionPolicySnapshot = "Snapshot"
+)
+
 // PostgresClusterStatus condition types.
 const (
+	PendingRestart           = "PendingRestart"
 	PersistentVolumeResizing = "PersistentVolumeResizing"
 	ProxyAvailable           = "ProxyAvailable"
+	QuorumCommit             = "QuorumCommit"
+	Ready                    = "Ready"
+	StandbyTransition        = "StandbyTransition"
+)
+
+// PostgresClusterStatus phases.
+const (
+	PhaseInitializing = "Initializing"
+	PhaseNotReady     = "NotReady"
+	PhaseReady        = "Ready"
+	PhaseRestoring    = "Restoring"
+	PhaseShutdown     = "Shutdown"
+	PhaseUpgrading    = "Upgrading"
 )
 
 type PostgresInstanceSetSpec struct {
@@ -304,11 +456,24 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 
+	// PostgreSQL settings for the instances of this set.
+	// +optional
+	Config *PostgresInstanceConfiguration `json:"config,omitempty"`
+
+	// Grow the PostgreSQL data volume of each instance as it fills. The storage
+	// request in dataVolumeClaimSpec is the smallest size of the volume.
+	// +optional
+	DataVolumeAutoGrow *VolumeAutoGrowSpec `json:"dataVolumeAutoGrow,omitempty"`
+
 	// Defines a PersistentVolumeClaim for PostgreSQL data.
 	// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes
 	// +kubebuilder:validation:Required
 	DataVolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"dataVolumeClaimSpec"`
 
+	// Patroni settings for the instances of this set.
+	// +optional
+	Patroni *PatroniInstanceSetSpec `json:"patroni,omitempty"`
+
 	// +optional
 	// +kubebuilder:default=1
 	// +kubebuilder:validation:Minimum=1
@@ -323,6 +488,11 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
+	// Grow the PostgreSQL WAL volume of each instance as it fills. The storage
+	// request in walVolumeClaimSpec is the smallest size of the volume.
+	// +optional
+	WALVolumeAutoGrow *VolumeAutoGrowSpec `json:"walVolumeAutoGrow,omitempty"`
+
 	// Defines a separate PersistentVolumeClaim for PostgreSQL's write-ahead log.
 	// More info: https://www.postgresql.org/docs/current/wal.html
 	// +optional
@@ -337,6 +507,33 @@ func (s *PostgresInstanceSetSpec) Default(i int) {
 		s.Replicas = new(int32)
 		*s.Replicas = 1
 	}
+	if s.Patroni != nil && s.Patroni.FailoverRole == "" {
+		s.Patroni.FailoverRole = PatroniFailoverRoleCandidate
+	}
+}
+
+// PostgresInstanceConfiguration defines PostgreSQL settings for the instances
+// of one instance set.
+type PostgresInstanceConfiguration struct {
+
+	// Whether or not to derive memory and worker parameters from the resources
+	// of this set. When enabled, "shared_buffers" and "effective_cache_size" are
+	// calculated from the memory request, and "max_worker_processes" from the
+	// CPU request. Limits are used when there are no requests. Parameters set
+	// explicitly take precedence.
+	// +optional
+	AutoTune bool `json:"autoTune,omitempty"`
+
+	// NOTE: map[string]string fields are not presented in the OpenShift
+	// web console: https://github.com/openshift/console/issues/9538
+
+	// PostgreSQL parameters of the instances in this set. These take precedence
+	// over the parameters in spec.patroni.dynamicConfiguration. Parameters that
+	// must be the same on every instance, such as "max_connections", cannot be
+	// set here. Changing some parameters causes PostgreSQL to restart.
+	// More info: https://www.postgresql.org/docs/current/runtime-config.html
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 type PostgresInstanceSetStatus struct {
@@ -368,26 +565,98 @@ func (s *PostgresProxySpec) Default() {
 	}
 }
 
+// PostgresReplicaServiceSpec defines the Service that resolves to healthy PostgreSQL replicas.
+type PostgresReplicaServiceSpec struct {
+
+	// The most write-ahead log, in bytes, a replica may be behind the primary and still
+	// receive connections through the replica Service. When omitted, every ready replica
+	// receives connections.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaximumLag *int64 `json:"maximumLag,omitempty"`
+}
+
 type PostgresProxyStatus struct {
 	PGBouncer PGBouncerPodStatus `json:"pgBouncer,omitempty"`
 }
 
+// PostgresUserInterfaceSpec is a union of the supported PostgreSQL user interfaces.
+type PostgresUserInterfaceSpec struct {
+
+	// Defines a pgAdmin user interface.
+	PGAdmin *PGAdminPodSpec `json:"pgAdmin"`
+}
+
+func (s *PostgresUserInterfaceSpec) Default() {
+	if s.PGAdmin != nil {
+		s.PGAdmin.Default()
+	}
+}
+
+type PostgresUserInterfaceStatus struct {
+	PGAdmin PGAdminPodStatus `json:"pgAdmin,omitempty"`
+}
+
 // PostgresStandbySpec defines if/how the cluster should be a hot standby.
 type PostgresStandbySpec struct {
 	// Whether or not the PostgreSQL cluster should be read-only. When this is
-	// true, WAL files are applied from the pgBackRest repository.
+	// true, WAL files are applied from the pgBackRest repository, streamed from
+	// the remote host, or both.
 	// +optional
 	// +kubebuilder:default=true
 	Enabled bool `json:"enabled"`
 
 	// The name of the pgBackRest repository to follow for WAL files.
-	// +kubebuilder:validation:Required
+	// +optional
 	// +kubebuilder:validation:Pattern=^repo[1-4]
-	RepoName string `json:"repoName"`
+	RepoName string `json:"repoName,omitempty"`
+
+	// Network address of the PostgreSQL server to follow via streaming replication.
+	// The replication connection uses the certificates of customTLSSecret and
+	// customReplicationTLSSecret, which must be signed by the same certificate
+	// authority as those of the remote server. Required to demote a primary cluster.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Network port of the PostgreSQL server to follow via streaming replication.
+	// Defaults to 5432.
+	// +optional
+	// +kubebuilder:validation:Minimum=1024
+	Port *int32 `json:"port,omitempty"`
+
+	// The name of a replication slot on the remote server to use while streaming.
+	// The slot must already exist there.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9_]+$`
+	PrimarySlotName string `json:"primarySlotName,omitempty"`
+}
+
+// PostgresStandbyStatus describes how far a standby cluster is behind the cluster
+// it follows, as measured on its standby leader.
+type PostgresStandbyStatus struct {
+
+	// The state of the process that streams WAL from the remote host, such as
+	// "streaming". It is empty when WAL is not being streamed.
+	// +optional
+	WALReceiverStatus string `json:"walReceiverStatus,omitempty"`
+
+	// The amount of WAL, in bytes, that has been received but not yet replayed.
+	// +optional
+	ReplayLagBytes *int64 `json:"replayLagBytes,omitempty"`
+
+	// The number of seconds since the most recent transaction that was replayed
+	// committed on the remote server. This increases while the remote server is idle.
+	// +optional
+	ReplayLagSeconds *int64 `json:"replayLagSeconds,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Primary",type=string,JSONPath=`.status.primary`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyInstances`
+// +kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.pgbackrest.lastBackupTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +operator-sdk:csv:customresourcedefinitions:resources={{ConfigMap,v1},{Secret,v1},{Service,v1},{CronJob,v1beta1},{Deployment,v1},{Job,v1},{StatefulSet,v1},{PersistentVolumeClaim,v1}}
 
 // PostgresCluster is the Schema for the postgresclusters API
@@ -459,6 +728,47 @@ func (meta *Metadata) GetAnnotationsOrNil() map[string]string {
 	return meta.Annotations
 }
 
+// ServiceSpec defines how a Service is exposed inside and outside of Kubernetes.
+type ServiceSpec struct {
+	// Labels and annotations that apply only to the Service, e.g. those read by
+	// a cloud load-balancer controller.
+	// +optional
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#publishing-services-service-types
+	// +optional
+	// +kubebuilder:default=ClusterIP
+	// +kubebuilder:validation:Enum={ClusterIP,NodePort,LoadBalancer}
+	Type string `json:"type"`
+
+	// The port on which the Service is exposed on each node when type is NodePort or
+	// LoadBalancer. When omitted, Kubernetes allocates a port.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#type-nodeport
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	NodePort *int32 `json:"nodePort,omitempty"`
+
+	// The client IP ranges, in CIDR form, that may connect through the load balancer when
+	// type is LoadBalancer. This is ignored by cloud providers that do not support it.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Whether external traffic is routed to endpoints on every node (Cluster) or only on the
+	// node that receives it (Local) when type is NodePort or LoadBalancer.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/#preserving-the-client-source-ip
+	// +optional
+	// +kubebuilder:validation:Enum={Cluster,Local}
+	ExternalTrafficPolicy string `json:"externalTrafficPolicy,omitempty"`
+}
+
+func (s *ServiceSpec) Default() {
+	if s.Type == "" {
+		s.Type = "ClusterIP"
+	}
+}
+
 // MonitoringSpec is a union of the supported PostgreSQL Monitoring tools
 type MonitoringSpec struct {
 	// +optional
@@ -0,0 +1,511 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// +kubebuilder:webhook:path=/mutate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update,versions=v1beta1,name=mpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions={v1,v1beta1}
+// +kubebuilder:webhook:path=/validate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update;delete,versions=v1beta1,name=vpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions={v1,v1beta1}
+
+// ValidateCreate implements "sigs.k8s.io/controller-runtime/pkg/webhook.Validator"
+// so a webhook can be registered for the type.
+func (c *PostgresCluster) ValidateCreate() error {
+	return c.invalid(c.validate())
+}
+
+// ValidateUpdate implements "sigs.k8s.io/controller-runtime/pkg/webhook.Validator"
+// so a webhook can be registered for the type.
+func (c *PostgresCluster) ValidateUpdate(old runtime.Object) error {
+	previous, ok := old.(*PostgresCluster)
+
+	// The spec is not checked while the cluster is deleted or when it does not
+	// change. A cluster created before this webhook existed may have a spec that
+	// is now invalid; its labels, annotations, and finalizers must still change
+	// so that it can be deleted.
+	if c.GetDeletionTimestamp() != nil ||
+		(ok && equality.Semantic.DeepEqual(previous.Spec, c.Spec)) {
+		return nil
+	}
+
+	allErrors := c.validate()
+
+	if ok {
+		// PostgreSQL refuses to start when the major version of its data
+		// directory does not match the installed binaries. The data directory
+		// can be upgraded in-place when the upgrade section describes it.
+		// - https://www.postgresql.org/docs/current/upgrading.html
+		// - https://www.postgresql.org/docs/current/pgupgrade.html
+		upgrade := c.Spec.Upgrade
+		upgrading := upgrade != nil && upgrade.Enabled != nil && *upgrade.Enabled &&
+			upgrade.FromPostgresVersion == previous.Spec.PostgresVersion &&
+			upgrade.FromPostgresVersion < c.Spec.PostgresVersion
+
+		if previous.Spec.PostgresVersion != c.Spec.PostgresVersion && !upgrading {
+			allErrors = append(allErrors, field.Forbidden(
+				field.NewPath("spec", "postgresVersion"),
+				"cannot be changed once the cluster exists"))
+		}
+
+		// pgBackRest cannot read the backups in a repository once its encryption changes.
+		allErrors = append(allErrors, c.validateRepoEncryption(previous)...)
+
+		// A primary cluster is demoted by rewinding its data with pg_rewind, which
+		// reads from a running PostgreSQL server. A repository cannot be the source.
+		// - https://www.postgresql.org/docs/current/app-pgrewind.html
+		bootstrapped := previous.Status.Patroni != nil &&
+			previous.Status.Patroni.SystemIdentifier != ""
+		wasStandby := previous.Spec.Standby != nil && previous.Spec.Standby.Enabled
+
+		if standby := c.Spec.Standby; standby != nil && standby.Enabled &&
+			standby.Host == "" && bootstrapped && !wasStandby {
+			allErrors = append(allErrors, field.Required(
+				field.NewPath("spec", "standby", "host"),
+				"must be set to demote a primary cluster"))
+		}
+	}
+
+	return c.invalid(allErrors)
+}
+
+// ValidateDelete implements "sigs.k8s.io/controller-runtime/pkg/webhook.Validator"
+// so a webhook can be registered for the type.
+func (c *PostgresCluster) ValidateDelete() error {
+	if c.Spec.DeletionProtection {
+		return apierrors.NewForbidden(
+			GroupVersion.WithResource("postgresclusters").GroupResource(), c.Name,
+			field.Forbidden(field.NewPath("spec", "deletionProtection"),
+				"must be false to delete the cluster"))
+	}
+	return nil
+}
+
+// validateRepoEncryption checks that the encryption of pgBackRest repositories that
+// have a stanza in previous is the same in c.
+func (c *PostgresCluster) validateRepoEncryption(previous *PostgresCluster) field.ErrorList {
+	var allErrors field.ErrorList
+	if previous.Status.PGBackRest == nil {
+		return allErrors
+	}
+
+	stanzas := map[string]bool{}
+	for _, repo := range previous.Status.PGBackRest.Repos {
+		stanzas[repo.Name] = repo.StanzaCreated
+	}
+	encryptions := map[string]*PGBackRestRepoEncryption{}
+	for _, repo := range previous.Spec.Backups.PGBackRest.Repos {
+		encryptions[repo.Name] = repo.Encryption
+	}
+
+	repos := field.NewPath("spec", "backups", "pgbackrest", "repos")
+	for i, repo := range c.Spec.Backups.PGBackRest.Repos {
+		before, ok := encryptions[repo.Name]
+		if ok && stanzas[repo.Name] && !equality.Semantic.DeepEqual(before, repo.Encryption) {
+			allErrors = append(allErrors, field.Forbidden(repos.Index(i).Child("encryption"),
+				"cannot be changed once the repository has a stanza"))
+		}
+	}
+	return allErrors
+}
+
+// invalid returns an API error that describes allErrors, if any.
+func (c *PostgresCluster) invalid(allErrors field.ErrorList) error {
+	if len(allErrors) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		GroupVersion.WithKind("PostgresCluster").GroupKind(), c.Name, allErrors)
+}
+
+// clusterParameters are the PostgreSQL parameters that Patroni requires to be
+// the same on every instance.
+var clusterParameters = map[string]bool{
+	"hot_standby":               true,
+	"max_connections":           true,
+	"max_locks_per_transaction": true,
+	"max_prepared_transactions": true,
+	"max_replication_slots":     true,
+	"max_wal_senders":           true,
+	"max_worker_processes":      true,
+	"track_commit_timestamp":    true,
+	"wal_level":                 true,
+	"wal_log_hints":             true,
+}
+
+// validate checks the parts of the spec that cannot be expressed in the
+// OpenAPI schema of the CRD. It works on a defaulted copy so the same
+// names the controller will see are checked.
+func (c *PostgresCluster) validate() field.ErrorList {
+	cluster := c.DeepCopy()
+	cluster.Default()
+
+	spec := field.NewPath("spec")
+	allErrors := field.ErrorList{}
+
+	// Instance sets are a map keyed by name, and each name becomes part of
+	// the name of its Pods, StatefulSets, and volumes.
+	names := map[string]bool{}
+	candidates := map[string]bool{}
+	anyCandidate := false
+	for i, set := range cluster.Spec.InstanceSets {
+		if names[set.Name] {
+			allErrors = append(allErrors, field.Duplicate(
+				spec.Child("instances").Index(i).Child("name"), set.Name))
+		}
+		names[set.Name] = true
+		candidates[set.Name] = set.Patroni.FailoverCandidate()
+		anyCandidate = anyCandidate || candidates[set.Name]
+
+		// Patroni ignores the local value of parameters that must be the same
+		// on every instance.
+		// - https://patroni.readthedocs.io/en/latest/SETTINGS.html#postgresql
+		if set.Config != nil {
+			parameters := make([]string, 0, len(set.Config.Parameters))
+			for name := range set.Config.Parameters {
+				parameters = append(parameters, name)
+			}
+			sort.Strings(parameters)
+
+			for _, name := range parameters {
+				if clusterParameters[strings.ToLower(name)] {
+					allErrors = append(allErrors, field.Forbidden(
+						spec.Child("instances").Index(i).Child("config", "parameters").Key(name),
+						"must be the same on every instance; use spec.patroni.dynamicConfiguration"))
+				}
+			}
+		}
+
+		allErrors = append(allErrors, set.DataVolumeAutoGrow.validate(
+			spec.Child("instances").Index(i).Child("dataVolumeAutoGrow"),
+			&set.DataVolumeClaimSpec)...)
+
+		if set.WALVolumeAutoGrow != nil && set.WALVolumeClaimSpec == nil {
+			allErrors = append(allErrors, field.Forbidden(
+				spec.Child("instances").Index(i).Child("walVolumeAutoGrow"),
+				"may only be used with walVolumeClaimSpec"))
+		} else {
+			allErrors = append(allErrors, set.WALVolumeAutoGrow.validate(
+				spec.Child("instances").Index(i).Child("walVolumeAutoGrow"),
+				set.WALVolumeClaimSpec)...)
+		}
+	}
+
+	// A snapshot contains only the data volume. The data directory of a replica
+	// cloned from it is not consistent without the WAL on a separate volume.
+	if snapshots := cluster.Spec.Backups.Snapshots; snapshots != nil && snapshots.BootstrapReplicas {
+		for i, set := range cluster.Spec.InstanceSets {
+			if set.WALVolumeClaimSpec != nil {
+				allErrors = append(allErrors, field.Forbidden(
+					spec.Child("backups", "snapshots", "bootstrapReplicas"),
+					fmt.Sprintf("cannot be used with walVolumeClaimSpec in %s",
+						spec.Child("instances").Index(i))))
+				break
+			}
+		}
+	}
+
+	// Patroni does not start a cluster on an instance that cannot become
+	// primary, so at least one instance set must be able to.
+	if len(cluster.Spec.InstanceSets) > 0 && !anyCandidate {
+		allErrors = append(allErrors, field.Invalid(
+			spec.Child("instances").Index(0).Child("patroni", "failoverRole"),
+			cluster.Spec.InstanceSets[0].Patroni.FailoverRole,
+			"at least one instance set must be a failover candidate"))
+	}
+
+	// Every pgBackRest repository needs somewhere to store its backups.
+	repos := spec.Child("backups", "pgbackrest", "repos")
+	repoNames := map[string]bool{}
+	retentions := map[string]bool{}
+	encryptions := map[string]bool{}
+	for i, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		repoNames[repo.Name] = true
+
+		if repo.Azure == nil && repo.GCS == nil && repo.S3 == nil && repo.Volume == nil {
+			allErrors = append(allErrors, field.Required(repos.Index(i),
+				"must define one of azure, gcs, s3, or volume"))
+		}
+		if repo.Volume != nil {
+			allErrors = append(allErrors, repo.Volume.AutoGrow.validate(
+				repos.Index(i).Child("volume", "autoGrow"), &repo.Volume.VolumeClaimSpec)...)
+		}
+		if retention := repo.Retention; retention != nil {
+			retentions[repo.Name] = true
+			if retention.FullType != "" && retention.Full == nil {
+				allErrors = append(allErrors, field.Required(
+					repos.Index(i).Child("retention", "full"), "fullType requires full"))
+			}
+			if retention.ArchiveType != "" && retention.Archive == nil {
+				allErrors = append(allErrors, field.Required(
+					repos.Index(i).Child("retention", "archive"), "archiveType requires archive"))
+			}
+		}
+		if encryption := repo.Encryption; encryption != nil {
+			encryptions[repo.Name] = true
+			if encryption.CipherPassSecret != nil && encryption.CipherPassSecret.Name == "" {
+				allErrors = append(allErrors, field.Required(
+					repos.Index(i).Child("encryption", "cipherPassSecret", "name"), ""))
+			}
+		}
+	}
+
+	// Retention and cipher options in global refer to a repository by its name, "repo1"
+	// through "repo4". That repository must exist and must not define its retention or
+	// encryption as well.
+	global := spec.Child("backups", "pgbackrest", "global")
+	options := make([]string, 0, len(cluster.Spec.Backups.PGBackRest.Global))
+	for option := range cluster.Spec.Backups.PGBackRest.Global {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	for _, option := range options {
+		defined, kind := retentions, "retention"
+		i := strings.Index(option, "-retention-")
+		if i < 0 {
+			defined, kind = encryptions, "encryption"
+			i = strings.Index(option, "-cipher-")
+		}
+		if i < 0 || !strings.HasPrefix(option, "repo") {
+			continue
+		}
+		if name := option[:i]; !repoNames[name] {
+			allErrors = append(allErrors, field.Invalid(global.Key(option),
+				cluster.Spec.Backups.PGBackRest.Global[option],
+				"there is no repository named "+name))
+		} else if defined[name] {
+			allErrors = append(allErrors, field.Forbidden(global.Key(option),
+				"repository "+name+" defines "+kind))
+		}
+	}
+
+	// A standby cluster follows a pgBackRest repository, a remote host, or both.
+	// Streaming from a remote host authenticates with certificates that are
+	// trusted by that host.
+	if standby := cluster.Spec.Standby; standby != nil && standby.Enabled {
+		path := spec.Child("standby")
+		if standby.RepoName == "" && standby.Host == "" {
+			allErrors = append(allErrors, field.Required(path,
+				"must define at least one of host or repoName"))
+		}
+		if standby.RepoName != "" && !repoNames[standby.RepoName] {
+			allErrors = append(allErrors, field.NotFound(
+				path.Child("repoName"), standby.RepoName))
+		}
+		if standby.Host == "" {
+			if standby.Port != nil {
+				allErrors = append(allErrors, field.Forbidden(path.Child("port"),
+					"may only be used with host"))
+			}
+			if standby.PrimarySlotName != "" {
+				allErrors = append(allErrors, field.Forbidden(path.Child("primarySlotName"),
+					"may only be used with host"))
+			}
+		} else {
+			if cluster.Spec.CustomTLSSecret == nil {
+				allErrors = append(allErrors, field.Required(spec.Child("customTLSSecret"),
+					"streaming from a remote host requires a certificate authority it trusts"))
+			}
+			if cluster.Spec.CustomReplicationClientTLSSecret == nil {
+				allErrors = append(allErrors, field.Required(spec.Child("customReplicationTLSSecret"),
+					"streaming from a remote host requires a certificate it trusts"))
+			}
+		}
+	}
+
+	// A data source that omits the cluster name refers to the repositories of
+	// this PostgresCluster.
+	if source := cluster.Spec.DataSource; source != nil && source.PostgresCluster != nil {
+		local := (source.PostgresCluster.ClusterName == "" ||
+			source.PostgresCluster.ClusterName == cluster.Name) &&
+			(source.PostgresCluster.ClusterNamespace == "" ||
+				source.PostgresCluster.ClusterNamespace == cluster.Namespace)
+
+		if local && !repoNames[source.PostgresCluster.RepoName] {
+			allErrors = append(allErrors, field.NotFound(
+				spec.Child("dataSource", "postgresCluster", "repoName"),
+				source.PostgresCluster.RepoName))
+		}
+
+		// Recovery from a snapshot replays WAL until the end of the archive.
+		if source.PostgresCluster.VolumeSnapshotName != "" && len(source.PostgresCluster.Options) > 0 {
+			allErrors = append(allErrors, field.Forbidden(
+				spec.Child("dataSource", "postgresCluster", "options"),
+				"may not be used with volumeSnapshotName"))
+		}
+	}
+
+	// Existing volumes are adopted in place of a restore. The first instance set
+	// that can become primary starts on them, and the first repository with a
+	// volume takes the pgBackRest volume.
+	if source := cluster.Spec.DataSource; source != nil && source.Volumes != nil {
+		path := spec.Child("dataSource", "volumes")
+		if source.PostgresCluster != nil {
+			allErrors = append(allErrors, field.Forbidden(path,
+				"may not be used with postgresCluster"))
+		}
+		if source.Volumes.PGWALVolume != nil {
+			var startup *PostgresInstanceSetSpec
+			for i := range cluster.Spec.InstanceSets {
+				if startup == nil && candidates[cluster.Spec.InstanceSets[i].Name] {
+					startup = &cluster.Spec.InstanceSets[i]
+				}
+			}
+			if startup != nil && startup.WALVolumeClaimSpec == nil {
+				allErrors = append(allErrors, field.Forbidden(path.Child("pgWALVolume"),
+					"requires walVolumeClaimSpec in instance set "+startup.Name))
+			}
+		}
+		if source.Volumes.PGBackRestVolume != nil {
+			var volumeRepo bool
+			for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+				volumeRepo = volumeRepo || repo.Volume != nil
+			}
+			if !volumeRepo {
+				allErrors = append(allErrors, field.Forbidden(path.Child("pgBackRestVolume"),
+					"requires a repo that defines a volume"))
+			}
+		}
+	}
+
+	// An in-place restore replaces the files of existing volumes, so it cannot
+	// clone a volume from a snapshot.
+	if restore := cluster.Spec.Backups.PGBackRest.Restore; restore != nil &&
+		restore.PostgresClusterDataSource != nil && restore.VolumeSnapshotName != "" {
+		allErrors = append(allErrors, field.Forbidden(
+			spec.Child("backups", "pgbackrest", "restore", "volumeSnapshotName"),
+			"may only be used in spec.dataSource.postgresCluster"))
+	}
+
+	// An upgrade goes from an older major version to a newer one, and it
+	// starts with a backup to one of the pgBackRest repositories.
+	if upgrade := cluster.Spec.Upgrade; upgrade != nil && upgrade.Enabled != nil && *upgrade.Enabled {
+		if upgrade.FromPostgresVersion > cluster.Spec.PostgresVersion {
+			allErrors = append(allErrors, field.Invalid(
+				spec.Child("upgrade", "fromPostgresVersion"), upgrade.FromPostgresVersion,
+				"must be less than or equal to postgresVersion"))
+		}
+		if upgrade.RepoName != "" && !repoNames[upgrade.RepoName] {
+			allErrors = append(allErrors, field.NotFound(
+				spec.Child("upgrade", "repoName"), upgrade.RepoName))
+		}
+	}
+
+	// Every synchronous standby is a replica that could become primary, so
+	// there must be more of those instances than synchronous standbys.
+	if sync := cluster.Spec.Patroni.Synchronous; sync != nil &&
+		sync.Mode != PatroniSynchronousModeOff && sync.NumberOfStandbys != nil {
+		var instances int32
+		for _, set := range cluster.Spec.InstanceSets {
+			if set.Replicas != nil && candidates[set.Name] {
+				instances += *set.Replicas
+			}
+		}
+		if *sync.NumberOfStandbys >= instances {
+			allErrors = append(allErrors, field.Invalid(
+				spec.Child("patroni", "synchronous", "numberOfStandbys"),
+				*sync.NumberOfStandbys,
+				"must be less than the number of instances that are failover candidates"))
+		}
+	}
+
+	// A switchover targets either one instance or the instances of one set. A
+	// failover does not wait for the primary to choose, so it needs a target.
+	if switchover := cluster.Spec.Patroni.Switchover; switchover != nil {
+		path := spec.Child("patroni", "switchover")
+		if switchover.TargetInstance != nil && switchover.TargetInstanceSet != nil {
+			allErrors = append(allErrors, field.Forbidden(path.Child("targetInstanceSet"),
+				"may not be used with targetInstance"))
+		}
+		if switchover.TargetInstanceSet != nil && !names[*switchover.TargetInstanceSet] {
+			allErrors = append(allErrors, field.NotFound(
+				path.Child("targetInstanceSet"), *switchover.TargetInstanceSet))
+		} else if switchover.TargetInstanceSet != nil && !candidates[*switchover.TargetInstanceSet] {
+			allErrors = append(allErrors, field.Invalid(
+				path.Child("targetInstanceSet"), *switchover.TargetInstanceSet,
+				"instance set is not a failover candidate"))
+		}
+		if switchover.Type == PatroniSwitchoverTypeFailover &&
+			switchover.TargetInstance == nil && switchover.TargetInstanceSet == nil {
+			allErrors = append(allErrors, field.Required(path.Child("targetInstance"),
+				"a failover requires targetInstance or targetInstanceSet"))
+		}
+	}
+
+	allErrors = append(allErrors,
+		cluster.Spec.Service.validate(spec.Child("service"))...)
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		allErrors = append(allErrors, cluster.Spec.Proxy.PGBouncer.Service.validate(
+			spec.Child("proxy", "pgBouncer", "service"))...)
+	}
+
+	return allErrors
+}
+
+// validate checks the fields of s that apply only to some types of Service.
+func (s *ServiceSpec) validate(path *field.Path) field.ErrorList {
+	allErrors := field.ErrorList{}
+	if s == nil {
+		return allErrors
+	}
+
+	external := s.Type == "NodePort" || s.Type == "LoadBalancer"
+
+	if s.NodePort != nil && !external {
+		allErrors = append(allErrors, field.Forbidden(path.Child("nodePort"),
+			"may only be used when type is NodePort or LoadBalancer"))
+	}
+	if s.ExternalTrafficPolicy != "" && !external {
+		allErrors = append(allErrors, field.Forbidden(path.Child("externalTrafficPolicy"),
+			"may only be used when type is NodePort or LoadBalancer"))
+	}
+	if len(s.LoadBalancerSourceRanges) > 0 && s.Type != "LoadBalancer" {
+		allErrors = append(allErrors, field.Forbidden(path.Child("loadBalancerSourceRanges"),
+			"may only be used when type is LoadBalancer"))
+	}
+
+	return allErrors
+}
+
+// validate checks that the increment of s can be parsed and that its maximum is
+// not less than the storage request of claim.
+func (s *VolumeAutoGrowSpec) validate(
+	path *field.Path, claim *corev1.PersistentVolumeClaimSpec,
+) field.ErrorList {
+	allErrors := field.ErrorList{}
+	if s == nil || claim == nil {
+		return allErrors
+	}
+
+	request := claim.Resources.Requests[corev1.ResourceStorage]
+	if _, err := s.NextRequest(request); err != nil {
+		allErrors = append(allErrors, field.Invalid(path.Child("increment"), s.Increment,
+			`must be a percentage, such as "25%", or a quantity, such as "10Gi"`))
+	}
+	if s.Maximum.Cmp(request) < 0 {
+		allErrors = append(allErrors, field.Invalid(path.Child("maximum"), s.Maximum.String(),
+			"must be greater than or equal to the storage request of the volume"))
+	}
+	return allErrors
+}
@@ -0,0 +1,159 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresRestoreSpec defines a pgBackRest restore of a PostgresCluster.
+type PostgresRestoreSpec struct {
+
+	// The name of the PostgresCluster to restore. When this cluster is also the source of the
+	// backups, its data is restored in-place once the PostgresCluster is annotated with
+	// "postgres-operator.crunchydata.com/postgres-restore" set to the name of this
+	// PostgresRestore. Otherwise this should be a new PostgresCluster,
+	// and its data is initialized using the backups of the source cluster. The PostgresCluster
+	// must be in the same namespace as the PostgresRestore.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	PostgresCluster string `json:"postgresCluster"`
+
+	// The source of the backups to restore and the resources of the restore Job. The source
+	// defaults to the PostgresCluster being restored.
+	PostgresClusterDataSource `json:",inline"`
+
+	// The point to stop recovery at. When omitted, all WAL in the repo is replayed.
+	// +optional
+	Target *PostgresRestoreTarget `json:"target,omitempty"`
+}
+
+// PostgresRestoreTarget defines the point recovery stops at. At most one of time, lsn, xid and
+// name may be set.
+// More info: https://pgbackrest.org/command.html#command-restore/category-command/option-type
+type PostgresRestoreTarget struct {
+
+	// Recover to this point in time. It is represented in RFC3339 form.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// Recover to this write-ahead log location, e.g. "0/3000060".
+	// +kubebuilder:validation:Pattern=`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`
+	// +optional
+	LSN string `json:"lsn,omitempty"`
+
+	// Recover to this transaction ID.
+	// +kubebuilder:validation:Pattern=`^[0-9]+$`
+	// +optional
+	XID string `json:"xid,omitempty"`
+
+	// Recover to this named restore point, created with pg_create_restore_point().
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Restore this backup, identified by its pgBackRest label. When no other target is set,
+	// recovery stops as soon as the backup is consistent.
+	// +kubebuilder:validation:Pattern=`^[0-9]{8}-[0-9]{6}F(_[0-9]{8}-[0-9]{6}[DI])?$`
+	// +optional
+	BackupLabel string `json:"backupLabel,omitempty"`
+
+	// Whether or not to stop just before the time, lsn or xid target rather than just after it.
+	// +optional
+	Exclusive *bool `json:"exclusive,omitempty"`
+}
+
+// PostgresRestoreStatus defines the observed state of a PostgresRestore.
+type PostgresRestoreStatus struct {
+
+	// The name of the Job that runs the restore.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// The name of the PostgresCluster whose backups were restored.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// The namespace of the PostgresCluster whose backups were restored.
+	// +optional
+	ClusterNamespace string `json:"clusterNamespace,omitempty"`
+
+	// The name of the pgBackRest repo the backups were restored from.
+	// +optional
+	RepoName string `json:"repoName,omitempty"`
+
+	// The options, including those of the target, passed to the pgBackRest restore command.
+	// +optional
+	Options []string `json:"options,omitempty"`
+
+	// Specifies whether or not the restore Job is finished executing (does not indicate
+	// success or failure).
+	// +optional
+	Finished bool `json:"finished,omitempty"`
+
+	// Represents the time the restore Job was acknowledged by the Job controller.
+	// It is represented in RFC3339 form and is in UTC.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents the time the restore Job was determined by the Job controller to be completed.
+	// This field is only set if the restore completed successfully. Additionally, it is
+	// represented in RFC3339 form and is in UTC.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// The number of actively running restore Pods.
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// The number of Pods for the restore Job that reached the "Succeeded" phase.
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// The number of Pods for the restore Job that reached the "Failed" phase.
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.postgresCluster`
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.status.clusterName`
+// +kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.status.repoName`
+// +kubebuilder:printcolumn:name="Finished",type=boolean,JSONPath=`.status.finished`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +operator-sdk:csv:customresourcedefinitions:resources={{Job,v1}}
+
+// PostgresRestore is the Schema for the postgresrestores API
+type PostgresRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresRestoreSpec   `json:"spec,omitempty"`
+	Status PostgresRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresRestoreList contains a list of PostgresRestore
+type PostgresRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresRestore{}, &PostgresRestoreList{})
+}
@@ -0,0 +1,195 @@
+/*
+ Copyright 2021 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultVolumeAutoGrowThreshold is the percentage of a filesystem that is
+	// used before its volume grows when no threshold is specified.
+	DefaultVolumeAutoGrowThreshold int32 = 80
+
+	// DefaultVolumeAutoGrowIncrement is how much a volume grows when no
+	// increment is specified.
+	DefaultVolumeAutoGrowIncrement = "25%"
+)
+
+// VolumeAutoGrowSpec defines when and by how much the operator increases the
+// storage request of a PersistentVolumeClaim as its filesystem fills. The
+// StorageClass of the volume must allow expansion.
+// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes/#expanding-persistent-volumes-claims
+type VolumeAutoGrowSpec struct {
+
+	// The percentage of the filesystem that is used before the volume grows.
+	// Defaults to 80.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	Threshold *int32 `json:"threshold,omitempty"`
+
+	// How much to add to the storage request each time the volume grows. This
+	// is either a percentage of the current request, such as "25%", or a
+	// quantity, such as "10Gi". Defaults to "25%".
+	// +optional
+	Increment string `json:"increment,omitempty"`
+
+	// The largest storage request the operator sets. The volume does not grow
+	// beyond this size.
+	// +kubebuilder:validation:Required
+	Maximum resource.Quantity `json:"maximum"`
+}
+
+// GetThreshold returns the percentage of the filesystem that is used before
+// the volume grows.
+func (s *VolumeAutoGrowSpec) GetThreshold() int32 {
+	if s == nil || s.Threshold == nil {
+		return DefaultVolumeAutoGrowThreshold
+	}
+	return *s.Threshold
+}
+
+// NextRequest returns the storage request that follows request according to
+// s. It is never more than the maximum of s. It returns an error when the
+// increment of s cannot be parsed.
+func (s *VolumeAutoGrowSpec) NextRequest(request resource.Quantity) (resource.Quantity, error) {
+	increment := s.Increment
+	if increment == "" {
+		increment = DefaultVolumeAutoGrowIncrement
+	}
+
+	var grow resource.Quantity
+	if percent := strings.TrimSuffix(increment, "%"); percent != increment {
+		p, err := strconv.ParseInt(percent, 10, 64)
+		if err != nil || p < 1 {
+			return request, fmt.Errorf("increment %q is not a positive percentage", increment)
+		}
+
+		// Round up to a whole mebibyte so that requests remain readable.
+		const mebibyte = 1 << 20
+		bytes := (request.Value()*p/100 + mebibyte - 1) / mebibyte * mebibyte
+		grow = *resource.NewQuantity(bytes, resource.BinarySI)
+	} else {
+		q, err := resource.ParseQuantity(increment)
+		if err != nil || q.Sign() < 1 {
+			return request, fmt.Errorf("increment %q is not a positive quantity", increment)
+		}
+		grow = q
+	}
+
+	next := request.DeepCopy()
+	next.Add(grow)
+	if next.Cmp(s.Maximum) > 0 {
+		next = s.Maximum.DeepCopy()
+	}
+	if next.Cmp(request) < 0 {
+		next = request.DeepCopy()
+	}
+	return next, nil
+}
+
+// VolumeSnapshots defines how CSI VolumeSnapshots of PostgreSQL data volumes are
+// taken and used. The data volumes of every instance set must support them.
+type VolumeSnapshots struct {
+
+	// The name of the VolumeSnapshotClass used to take snapshots.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName"`
+
+	// Whether or not the data volumes of new replicas are cloned from the most
+	// recent snapshot that is ready to use. Replicas then replay WAL from the
+	// snapshot onward rather than copying every file from a pgBackRest backup.
+	// This cannot be used when an instance set has a WAL volume; its WAL is not
+	// in the snapshot.
+	// +optional
+	BootstrapReplicas bool `json:"bootstrapReplicas,omitempty"`
+}
+
+// VolumeSnapshotsStatus describes the VolumeSnapshots of a PostgresCluster.
+type VolumeSnapshotsStatus struct {
+
+	// The value of the "postgres-operator.crunchydata.com/volume-snapshot"
+	// annotation when a snapshot was last taken.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// The snapshot taken for the current ID, while it is being taken and after.
+	// +optional
+	Attempt *VolumeSnapshotAttemptStatus `json:"attempt,omitempty"`
+
+	// The VolumeSnapshots of PostgreSQL data volumes, oldest first.
+	// +optional
+	Snapshots []VolumeSnapshotStatus `json:"snapshots,omitempty"`
+}
+
+// VolumeSnapshotAttemptStatus describes the taking of one VolumeSnapshot.
+type VolumeSnapshotAttemptStatus struct {
+
+	// The name of the VolumeSnapshot.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// The instance whose data volume is snapshotted.
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// PostgreSQL leaves backup mode at this time if the storage provider has not
+	// taken the snapshot.
+	// +optional
+	Deadline *metav1.Time `json:"deadline,omitempty"`
+
+	// Whether or not the snapshot was taken while PostgreSQL was in backup mode.
+	// +optional
+	Succeeded bool `json:"succeeded,omitempty"`
+
+	// Details of the outcome.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// When PostgreSQL left backup mode. The snapshot is in progress until this is set.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// VolumeSnapshotStatus describes one VolumeSnapshot of a PostgreSQL data volume.
+type VolumeSnapshotStatus struct {
+
+	// The name of the VolumeSnapshot.
+	Name string `json:"name"`
+
+	// The instance whose data volume was snapshotted.
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// The point in time at which the snapshot was taken.
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// Whether or not a volume can be created from the snapshot.
+	// +optional
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+
+	// The smallest size of a volume created from the snapshot.
+	// +optional
+	RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+}
@@ -29,6 +29,11 @@ import (
 func (in *Backups) DeepCopyInto(out *Backups) {
 	*out = *in
 	in.PGBackRest.DeepCopyInto(&out.PGBackRest)
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = new(VolumeSnapshots)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backups.
@@ -49,6 +54,11 @@ func (in *DataSource) DeepCopyInto(out *DataSource) {
 		*out = new(PostgresClusterDataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = new(DataSourceVolumes)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataSource.
@@ -61,6 +71,51 @@ func (in *DataSource) DeepCopy() *DataSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataSourceVolume) DeepCopyInto(out *DataSourceVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataSourceVolume.
+func (in *DataSourceVolume) DeepCopy() *DataSourceVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(DataSourceVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataSourceVolumes) DeepCopyInto(out *DataSourceVolumes) {
+	*out = *in
+	if in.PGDataVolume != nil {
+		in, out := &in.PGDataVolume, &out.PGDataVolume
+		*out = new(DataSourceVolume)
+		**out = **in
+	}
+	if in.PGWALVolume != nil {
+		in, out := &in.PGWALVolume, &out.PGWALVolume
+		*out = new(DataSourceVolume)
+		**out = **in
+	}
+	if in.PGBackRestVolume != nil {
+		in, out := &in.PGBackRestVolume, &out.PGBackRestVolume
+		*out = new(DataSourceVolume)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataSourceVolumes.
+func (in *DataSourceVolumes) DeepCopy() *DataSourceVolumes {
+	if in == nil {
+		return nil
+	}
+	out := new(DataSourceVolumes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedRepo) DeepCopyInto(out *DedicatedRepo) {
 	*out = *in
@@ -176,6 +231,65 @@ func (in *MonitoringStatus) DeepCopy() *MonitoringStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGAdminPodSpec) DeepCopyInto(out *PGAdminPodSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(Metadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DataVolumeClaimSpec.DeepCopyInto(&out.DataVolumeClaimSpec)
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGAdminPodSpec.
+func (in *PGAdminPodSpec) DeepCopy() *PGAdminPodSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGAdminPodSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGAdminPodStatus) DeepCopyInto(out *PGAdminPodStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGAdminPodStatus.
+func (in *PGAdminPodStatus) DeepCopy() *PGAdminPodStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGAdminPodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 	*out = *in
@@ -220,6 +334,11 @@ func (in *PGBackRestArchive) DeepCopyInto(out *PGBackRestArchive) {
 		*out = new(PGBackRestRestore)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MaxBackupAgeSeconds != nil {
+		in, out := &in.MaxBackupAgeSeconds, &out.MaxBackupAgeSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestArchive.
@@ -232,6 +351,29 @@ func (in *PGBackRestArchive) DeepCopy() *PGBackRestArchive {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupInfo) DeepCopyInto(out *PGBackRestBackupInfo) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StopTime != nil {
+		in, out := &in.StopTime, &out.StopTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupInfo.
+func (in *PGBackRestBackupInfo) DeepCopy() *PGBackRestBackupInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestBackupSchedules) DeepCopyInto(out *PGBackRestBackupSchedules) {
 	*out = *in
@@ -250,6 +392,11 @@ func (in *PGBackRestBackupSchedules) DeepCopyInto(out *PGBackRestBackupSchedules
 		*out = new(string)
 		**out = **in
 	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupSchedules.
@@ -285,6 +432,21 @@ func (in *PGBackRestJobStatus) DeepCopy() *PGBackRestJobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestLastBackups) DeepCopyInto(out *PGBackRestLastBackups) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestLastBackups.
+func (in *PGBackRestLastBackups) DeepCopy() *PGBackRestLastBackups {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestLastBackups)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestManualBackup) DeepCopyInto(out *PGBackRestManualBackup) {
 	*out = *in
@@ -313,6 +475,21 @@ func (in *PGBackRestRepo) DeepCopyInto(out *PGBackRestRepo) {
 		*out = new(PGBackRestBackupSchedules)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RestoreTest != nil {
+		in, out := &in.RestoreTest, &out.RestoreTest
+		*out = new(PGBackRestRestoreTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(PGBackRestRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(PGBackRestRepoEncryption)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Azure != nil {
 		in, out := &in.Azure, &out.Azure
 		*out = new(RepoAzure)
@@ -345,6 +522,26 @@ func (in *PGBackRestRepo) DeepCopy() *PGBackRestRepo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRepoEncryption) DeepCopyInto(out *PGBackRestRepoEncryption) {
+	*out = *in
+	if in.CipherPassSecret != nil {
+		in, out := &in.CipherPassSecret, &out.CipherPassSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRepoEncryption.
+func (in *PGBackRestRepoEncryption) DeepCopy() *PGBackRestRepoEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRepoEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestRepoHost) DeepCopyInto(out *PGBackRestRepoHost) {
 	*out = *in
@@ -401,6 +598,81 @@ func (in *PGBackRestRestore) DeepCopy() *PGBackRestRestore {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRestoreTest) DeepCopyInto(out *PGBackRestRestoreTest) {
+	*out = *in
+	in.VolumeClaimSpec.DeepCopyInto(&out.VolumeClaimSpec)
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRestoreTest.
+func (in *PGBackRestRestoreTest) DeepCopy() *PGBackRestRestoreTest {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRestoreTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRestoreTestStatus) DeepCopyInto(out *PGBackRestRestoreTestStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishTime != nil {
+		in, out := &in.FinishTime, &out.FinishTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRestoreTestStatus.
+func (in *PGBackRestRestoreTestStatus) DeepCopy() *PGBackRestRestoreTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRestoreTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRetention) DeepCopyInto(out *PGBackRestRetention) {
+	*out = *in
+	if in.Full != nil {
+		in, out := &in.Full, &out.Full
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Differential != nil {
+		in, out := &in.Differential, &out.Differential
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRetention.
+func (in *PGBackRestRetention) DeepCopy() *PGBackRestRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestScheduledBackupStatus) DeepCopyInto(out *PGBackRestScheduledBackupStatus) {
 	*out = *in
@@ -432,6 +704,10 @@ func (in *PGBackRestStatus) DeepCopyInto(out *PGBackRestStatus) {
 		*out = new(PGBackRestJobStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LastBackupTime != nil {
+		in, out := &in.LastBackupTime, &out.LastBackupTime
+		*out = (*in).DeepCopy()
+	}
 	if in.ScheduledBackups != nil {
 		in, out := &in.ScheduledBackups, &out.ScheduledBackups
 		*out = make([]PGBackRestScheduledBackupStatus, len(*in))
@@ -447,7 +723,9 @@ func (in *PGBackRestStatus) DeepCopyInto(out *PGBackRestStatus) {
 	if in.Repos != nil {
 		in, out := &in.Repos, &out.Repos
 		*out = make([]RepoStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Restore != nil {
 		in, out := &in.Restore, &out.Restore
@@ -538,6 +816,11 @@ func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
@@ -594,76 +877,343 @@ func (in *PGMonitorSpec) DeepCopy() *PGMonitorSpec {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
+func (in *PGUpgradeSpec) DeepCopyInto(out *PGUpgradeSpec) {
 	*out = *in
-	in.DynamicConfiguration.DeepCopyInto(&out.DynamicConfiguration)
-	if in.LeaderLeaseDurationSeconds != nil {
-		in, out := &in.LeaderLeaseDurationSeconds, &out.LeaderLeaseDurationSeconds
-		*out = new(int32)
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
 		**out = **in
 	}
-	if in.Port != nil {
-		in, out := &in.Port, &out.Port
-		*out = new(int32)
-		**out = **in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.SyncPeriodSeconds != nil {
-		in, out := &in.SyncPeriodSeconds, &out.SyncPeriodSeconds
-		*out = new(int32)
-		**out = **in
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSpec.
-func (in *PatroniSpec) DeepCopy() *PatroniSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeSpec.
+func (in *PGUpgradeSpec) DeepCopy() *PGUpgradeSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PatroniSpec)
+	out := new(PGUpgradeSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PatroniStatus) DeepCopyInto(out *PatroniStatus) {
+func (in *PGUpgradeStatus) DeepCopyInto(out *PGUpgradeStatus) {
 	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniStatus.
-func (in *PatroniStatus) DeepCopy() *PatroniStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGUpgradeStatus.
+func (in *PGUpgradeStatus) DeepCopy() *PGUpgradeStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PatroniStatus)
+	out := new(PGUpgradeStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PostgresCluster) DeepCopyInto(out *PostgresCluster) {
+func (in *PatroniInstanceSetSpec) DeepCopyInto(out *PatroniInstanceSetSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresCluster.
-func (in *PostgresCluster) DeepCopy() *PostgresCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniInstanceSetSpec.
+func (in *PatroniInstanceSetSpec) DeepCopy() *PatroniInstanceSetSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PostgresCluster)
+	out := new(PatroniInstanceSetSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PostgresCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
+	*out = *in
+	in.DynamicConfiguration.DeepCopyInto(&out.DynamicConfiguration)
+	if in.LeaderLeaseDurationSeconds != nil {
+		in, out := &in.LeaderLeaseDurationSeconds, &out.LeaderLeaseDurationSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncPeriodSeconds != nil {
+		in, out := &in.SyncPeriodSeconds, &out.SyncPeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Synchronous != nil {
+		in, out := &in.Synchronous, &out.Synchronous
+		*out = new(PatroniSynchronous)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Switchover != nil {
+		in, out := &in.Switchover, &out.Switchover
+		*out = new(PatroniSwitchover)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSpec.
+func (in *PatroniSpec) DeepCopy() *PatroniSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniStatus) DeepCopyInto(out *PatroniStatus) {
+	*out = *in
+	if in.SynchronousStandbys != nil {
+		in, out := &in.SynchronousStandbys, &out.SynchronousStandbys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Switchover != nil {
+		in, out := &in.Switchover, &out.Switchover
+		*out = new(PatroniSwitchoverStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniStatus.
+func (in *PatroniStatus) DeepCopy() *PatroniStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSwitchover) DeepCopyInto(out *PatroniSwitchover) {
+	*out = *in
+	if in.TargetInstance != nil {
+		in, out := &in.TargetInstance, &out.TargetInstance
+		*out = new(string)
+		**out = **in
+	}
+	if in.TargetInstanceSet != nil {
+		in, out := &in.TargetInstanceSet, &out.TargetInstanceSet
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSwitchover.
+func (in *PatroniSwitchover) DeepCopy() *PatroniSwitchover {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSwitchover)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSwitchoverStatus) DeepCopyInto(out *PatroniSwitchoverStatus) {
+	*out = *in
+	if in.Timeline != nil {
+		in, out := &in.Timeline, &out.Timeline
+		*out = new(int64)
+		**out = **in
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSwitchoverStatus.
+func (in *PatroniSwitchoverStatus) DeepCopy() *PatroniSwitchoverStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSwitchoverStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSynchronous) DeepCopyInto(out *PatroniSynchronous) {
+	*out = *in
+	if in.NumberOfStandbys != nil {
+		in, out := &in.NumberOfStandbys, &out.NumberOfStandbys
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSynchronous.
+func (in *PatroniSynchronous) DeepCopy() *PatroniSynchronous {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSynchronous)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBackup) DeepCopyInto(out *PostgresBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackup.
+func (in *PostgresBackup) DeepCopy() *PostgresBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBackupList) DeepCopyInto(out *PostgresBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupList.
+func (in *PostgresBackupList) DeepCopy() *PostgresBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBackupSpec) DeepCopyInto(out *PostgresBackupSpec) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupSpec.
+func (in *PostgresBackupSpec) DeepCopy() *PostgresBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBackupStatus) DeepCopyInto(out *PostgresBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(PGBackRestBackupInfo)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupStatus.
+func (in *PostgresBackupStatus) DeepCopy() *PostgresBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresCluster) DeepCopyInto(out *PostgresCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresCluster.
+func (in *PostgresCluster) DeepCopy() *PostgresCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -787,6 +1337,16 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(PostgresProxySpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ReplicaService != nil {
+		in, out := &in.ReplicaService, &out.ReplicaService
+		*out = new(PostgresReplicaServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Monitoring != nil {
 		in, out := &in.Monitoring, &out.Monitoring
 		*out = new(MonitoringSpec)
@@ -800,7 +1360,17 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 	if in.Standby != nil {
 		in, out := &in.Standby, &out.Standby
 		*out = new(PostgresStandbySpec)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(PGUpgradeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UserInterface != nil {
+		in, out := &in.UserInterface, &out.UserInterface
+		*out = new(PostgresUserInterfaceSpec)
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Users != nil {
 		in, out := &in.Users, &out.Users
@@ -824,6 +1394,13 @@ func (in *PostgresClusterSpec) DeepCopy() *PostgresClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 	*out = *in
+	if in.AdoptedVolumes != nil {
+		in, out := &in.AdoptedVolumes, &out.AdoptedVolumes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.InstanceSets != nil {
 		in, out := &in.InstanceSets, &out.InstanceSets
 		*out = make([]PostgresInstanceSetStatus, len(*in))
@@ -832,7 +1409,7 @@ func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 	if in.Patroni != nil {
 		in, out := &in.Patroni, &out.Patroni
 		*out = new(PatroniStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.PGBackRest != nil {
 		in, out := &in.PGBackRest, &out.PGBackRest
@@ -840,6 +1417,26 @@ func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 		(*in).DeepCopyInto(*out)
 	}
 	out.Proxy = in.Proxy
+	if in.Standby != nil {
+		in, out := &in.Standby, &out.Standby
+		*out = new(PostgresStandbyStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(PGUpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UserInterface != nil {
+		in, out := &in.UserInterface, &out.UserInterface
+		*out = new(PostgresUserInterfaceStatus)
+		**out = **in
+	}
+	if in.VolumeSnapshots != nil {
+		in, out := &in.VolumeSnapshots, &out.VolumeSnapshots
+		*out = new(VolumeSnapshotsStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	out.Monitoring = in.Monitoring
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -860,6 +1457,28 @@ func (in *PostgresClusterStatus) DeepCopy() *PostgresClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresInstanceConfiguration) DeepCopyInto(out *PostgresInstanceConfiguration) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresInstanceConfiguration.
+func (in *PostgresInstanceConfiguration) DeepCopy() *PostgresInstanceConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresInstanceConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 	*out = *in
@@ -873,7 +1492,22 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 		*out = new(v1.Affinity)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(PostgresInstanceConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DataVolumeAutoGrow != nil {
+		in, out := &in.DataVolumeAutoGrow, &out.DataVolumeAutoGrow
+		*out = new(VolumeAutoGrowSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.DataVolumeClaimSpec.DeepCopyInto(&out.DataVolumeClaimSpec)
+	if in.Patroni != nil {
+		in, out := &in.Patroni, &out.Patroni
+		*out = new(PatroniInstanceSetSpec)
+		**out = **in
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -887,6 +1521,11 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.WALVolumeAutoGrow != nil {
+		in, out := &in.WALVolumeAutoGrow, &out.WALVolumeAutoGrow
+		*out = new(VolumeAutoGrowSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.WALVolumeClaimSpec != nil {
 		in, out := &in.WALVolumeClaimSpec, &out.WALVolumeClaimSpec
 		*out = new(v1.PersistentVolumeClaimSpec)
@@ -955,9 +1594,166 @@ func (in *PostgresProxyStatus) DeepCopy() *PostgresProxyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresReplicaServiceSpec) DeepCopyInto(out *PostgresReplicaServiceSpec) {
+	*out = *in
+	if in.MaximumLag != nil {
+		in, out := &in.MaximumLag, &out.MaximumLag
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresReplicaServiceSpec.
+func (in *PostgresReplicaServiceSpec) DeepCopy() *PostgresReplicaServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresReplicaServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestore) DeepCopyInto(out *PostgresRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestore.
+func (in *PostgresRestore) DeepCopy() *PostgresRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreList) DeepCopyInto(out *PostgresRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreList.
+func (in *PostgresRestoreList) DeepCopy() *PostgresRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreSpec) DeepCopyInto(out *PostgresRestoreSpec) {
+	*out = *in
+	in.PostgresClusterDataSource.DeepCopyInto(&out.PostgresClusterDataSource)
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(PostgresRestoreTarget)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreSpec.
+func (in *PostgresRestoreSpec) DeepCopy() *PostgresRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreStatus) DeepCopyInto(out *PostgresRestoreStatus) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreStatus.
+func (in *PostgresRestoreStatus) DeepCopy() *PostgresRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreTarget) DeepCopyInto(out *PostgresRestoreTarget) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	if in.Exclusive != nil {
+		in, out := &in.Exclusive, &out.Exclusive
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreTarget.
+func (in *PostgresRestoreTarget) DeepCopy() *PostgresRestoreTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresStandbySpec) DeepCopyInto(out *PostgresStandbySpec) {
 	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresStandbySpec.
@@ -970,6 +1766,67 @@ func (in *PostgresStandbySpec) DeepCopy() *PostgresStandbySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresStandbyStatus) DeepCopyInto(out *PostgresStandbyStatus) {
+	*out = *in
+	if in.ReplayLagBytes != nil {
+		in, out := &in.ReplayLagBytes, &out.ReplayLagBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ReplayLagSeconds != nil {
+		in, out := &in.ReplayLagSeconds, &out.ReplayLagSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresStandbyStatus.
+func (in *PostgresStandbyStatus) DeepCopy() *PostgresStandbyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresStandbyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserInterfaceSpec) DeepCopyInto(out *PostgresUserInterfaceSpec) {
+	*out = *in
+	if in.PGAdmin != nil {
+		in, out := &in.PGAdmin, &out.PGAdmin
+		*out = new(PGAdminPodSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserInterfaceSpec.
+func (in *PostgresUserInterfaceSpec) DeepCopy() *PostgresUserInterfaceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserInterfaceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserInterfaceStatus) DeepCopyInto(out *PostgresUserInterfaceStatus) {
+	*out = *in
+	out.PGAdmin = in.PGAdmin
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserInterfaceStatus.
+func (in *PostgresUserInterfaceStatus) DeepCopy() *PostgresUserInterfaceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserInterfaceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	*out = *in
@@ -1039,6 +1896,11 @@ func (in *RepoHostStatus) DeepCopy() *RepoHostStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoPVC) DeepCopyInto(out *RepoPVC) {
 	*out = *in
+	if in.AutoGrow != nil {
+		in, out := &in.AutoGrow, &out.AutoGrow
+		*out = new(VolumeAutoGrowSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.VolumeClaimSpec.DeepCopyInto(&out.VolumeClaimSpec)
 }
 
@@ -1070,6 +1932,32 @@ func (in *RepoS3) DeepCopy() *RepoS3 {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoStatus) DeepCopyInto(out *RepoStatus) {
 	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(PGBackRestRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]PGBackRestBackupInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastBackups != nil {
+		in, out := &in.LastBackups, &out.LastBackups
+		*out = new(PGBackRestLastBackups)
+		**out = **in
+	}
+	if in.InfoTime != nil {
+		in, out := &in.InfoTime, &out.InfoTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RestoreTest != nil {
+		in, out := &in.RestoreTest, &out.RestoreTest
+		*out = new(PGBackRestRestoreTestStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoStatus.
@@ -1081,3 +1969,143 @@ func (in *RepoStatus) DeepCopy() *RepoStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(Metadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodePort != nil {
+		in, out := &in.NodePort, &out.NodePort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
+func (in *ServiceSpec) DeepCopy() *ServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeAutoGrowSpec) DeepCopyInto(out *VolumeAutoGrowSpec) {
+	*out = *in
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(int32)
+		**out = **in
+	}
+	out.Maximum = in.Maximum.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeAutoGrowSpec.
+func (in *VolumeAutoGrowSpec) DeepCopy() *VolumeAutoGrowSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeAutoGrowSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotAttemptStatus) DeepCopyInto(out *VolumeSnapshotAttemptStatus) {
+	*out = *in
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotAttemptStatus.
+func (in *VolumeSnapshotAttemptStatus) DeepCopy() *VolumeSnapshotAttemptStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotAttemptStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotStatus) DeepCopyInto(out *VolumeSnapshotStatus) {
+	*out = *in
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RestoreSize != nil {
+		in, out := &in.RestoreSize, &out.RestoreSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotStatus.
+func (in *VolumeSnapshotStatus) DeepCopy() *VolumeSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshots) DeepCopyInto(out *VolumeSnapshots) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshots.
+func (in *VolumeSnapshots) DeepCopy() *VolumeSnapshots {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshots)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotsStatus) DeepCopyInto(out *VolumeSnapshotsStatus) {
+	*out = *in
+	if in.Attempt != nil {
+		in, out := &in.Attempt, &out.Attempt
+		*out = new(VolumeSnapshotAttemptStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]VolumeSnapshotStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotsStatus.
+func (in *VolumeSnapshotsStatus) DeepCopy() *VolumeSnapshotsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotsStatus)
+	in.DeepCopyInto(out)
+	return out
+}